@@ -4,15 +4,16 @@ set -e
 
 BASE_URL="$1"
 WORKFLOW_NAME="$2"
-SECRET_KEY="$3"
+# secret key is passed through the environment from the secret of the workflow
+SECRET_KEY="${SECRET_KEY:-}"
 
 if [ -z "$BASE_URL" ] || [ -z "$WORKFLOW_NAME" ] || [ -z "$SECRET_KEY" ]; then
-  echo "Usage: $0 <base_url> <workflow_name> <secret_key>"
+  echo "Usage: SECRET_KEY=<key> $0 <base_url> <workflow_name>"
   exit 1
 fi
 
 echo "Deleting context for workflow: $WORKFLOW_NAME"
-echo "${BASE_URL}/workflows/${WORKFLOW_NAME}/context?secret_key=${SECRET_KEY}"
+echo "${BASE_URL}/workflows/${WORKFLOW_NAME}/context"
 
 curl -f -k -H "Host: localhost" -X DELETE "${BASE_URL}/workflows/${WORKFLOW_NAME}/context?secret_key=${SECRET_KEY}" || {
   echo "Failed to delete context for workflow $WORKFLOW_NAME"
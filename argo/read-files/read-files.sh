@@ -1,7 +1,7 @@
 #!/bin/bash
 
-if [ "$#" -lt 3 ]; then
-  echo "Usage: $0 <url> <record-id> <secret-key> <file_id1> [file_id2] [...]"
+if [ "$#" -lt 3 ] || [ -z "$SECRET_KEY" ]; then
+  echo "Usage: SECRET_KEY=<key> $0 <url> <record-id> <file_id1> [file_id2] [...]"
   exit 1
 fi
 
@@ -9,23 +9,22 @@ URL="$1" # https://localhost:5000/api/experiments
 shift
 RECORD_ID="$1" # ew6jd-p8175
 shift
-SECRET="$1"
-shift
 
 DOWNLOAD_DIR="/output"
 mkdir -p $DOWNLOAD_DIR
 
 for FILE_ID in "$@"; do
-  DOWNLOAD_URL="${URL}/experiments/${RECORD_ID}/draft/files/${FILE_ID}/workflow/content?secret_key=${SECRET}"
+  CONTENT_URL="${URL}/experiments/${RECORD_ID}/draft/files/${FILE_ID}/workflow/content"
+  DOWNLOAD_URL="${CONTENT_URL}?secret_key=${SECRET_KEY}"
   OUTPUT_FILE="${DOWNLOAD_DIR}/${FILE_ID}"
-  echo "Downloading from: $DOWNLOAD_URL"
+  echo "Downloading from: $CONTENT_URL"
   echo "Saving to: $OUTPUT_FILE"
 
   REDIRECT_OUTPUT=$(wget --no-check-certificate --header="Host: localhost:5000" --max-redirect=0 "$DOWNLOAD_URL" -O "$OUTPUT_FILE" 2>&1)
   REDIRECT_URL=$(echo "$REDIRECT_OUTPUT" | grep -o "Location:.*" | cut -d' ' -f2- | sed 's/ \[following\]$//' | sed 's/127.0.0.1/172.22.0.3/')
 
   if [ -z "$REDIRECT_URL" ]; then
-    echo "Failed to get redirect URL from ${DOWNLOAD_URL}"
+    echo "Failed to get redirect URL from ${CONTENT_URL}"
     echo "$REDIRECT_OUTPUT"
     exit 1
   fi
@@ -0,0 +1,38 @@
+#!/bin/sh
+
+set -e
+
+CALLBACK_URL="$1"
+WORKFLOW_NAME="$2"
+PHASE="$3"
+# secret key, failures and outputs are passed through the environment, messages may contain quotes
+SECRET_KEY="${SECRET_KEY:-}"
+FAILURES="${FAILURES:-}"
+OUTPUTS="${OUTPUTS:-}"
+
+if [ -z "$CALLBACK_URL" ] || [ -z "$WORKFLOW_NAME" ] || [ -z "$PHASE" ] || [ -z "$SECRET_KEY" ]; then
+  echo "Usage: SECRET_KEY=<key> FAILURES=<json> OUTPUTS=<keys> $0 <callback_url> <workflow_name> <phase>"
+  exit 1
+fi
+
+# argo leaves workflow.failures unresolved when no node failed
+case "$FAILURES" in
+  ""|"{{"*) FAILURES="[]" ;;
+esac
+
+# outputs of write tasks which did not run stay unresolved as well
+OUTPUTS_JSON=$(printf '%s\n' $OUTPUTS | grep -v '^{{' | jq -R . | jq -s 'map(select(. != ""))')
+
+echo "Reporting outcome $PHASE of workflow: $WORKFLOW_NAME"
+
+BODY=$(jq -n --arg phase "$PHASE" --argjson failures "$FAILURES" --argjson outputs "$OUTPUTS_JSON" \
+  '{phase: $phase, outputs: $outputs, failures: $failures}')
+
+curl -f -k -X POST -H "Content-Type: application/json" -H "Authorization: Bearer ${SECRET_KEY}" \
+  -d "$BODY" "${CALLBACK_URL}/v1/workflows/${WORKFLOW_NAME}/outcome" || {
+  echo "Failed to report outcome of workflow $WORKFLOW_NAME"
+  exit 1
+}
+
+echo "Outcome reported for workflow: $WORKFLOW_NAME"
+exit 0
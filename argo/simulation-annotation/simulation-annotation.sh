@@ -3,6 +3,8 @@
 
 INPUT_DIR="/input"
 OUTPUT_DIR="/output"
+KEEP="${KEEP:-true}"
+ENDPOINT="${ENDPOINT:-https://gmd.ceitec.cz/api/annotate}"
 
 mkdir -p "$OUTPUT_DIR"
 
@@ -14,19 +16,19 @@ for input_file in "$INPUT_DIR"/*; do
   filename=$(basename "$input_file")
   filename="${filename%.*}"
   echo "filename $filename"
-  resp=$(curl -s -X POST https://gmd.ceitec.cz/api/annotate -F "tpr=@$input_file" -F "keep=true")
+  resp=$(curl -s -X POST "$ENDPOINT" -F "tpr=@$input_file" -F "keep=$KEEP")
   uuid=$(echo "$resp" | jq -r .uuid)
 
   # 2) Wait until the job is completed
   while true; do
-    status=$(curl -s https://gmd.ceitec.cz/api/annotate/"$uuid" | jq -r .status)
+    status=$(curl -s "$ENDPOINT/$uuid" | jq -r .status)
     echo "Status: $status"
     [[ $status == "completed" ]] && break
     sleep 5
   done
 
   # 3) Download the results
-  curl -s https://gmd.ceitec.cz/api/annotate/"$uuid"/results -o "$OUTPUT_DIR/$filename-simulation-annotation.json"
+  curl -s "$ENDPOINT/$uuid/results" -o "$OUTPUT_DIR/$filename-simulation-annotation.json"
   echo "output saved in: $OUTPUT_DIR/$filename-simulation-annotation.json"
 
 done
@@ -6,11 +6,14 @@ BASE_URL="$1"
 RECORD_ID="$2"
 WORKFLOW_NAME="$3"
 TASK_DISCRIMINATOR="$4"
-SECRET_KEY="$5"
+# secret key is passed through the environment from the secret of the workflow
+SECRET_KEY="${SECRET_KEY:-}"
 FILES_DIR="/input"
+# keys of uploaded files are exposed as output parameter of the template
+UPLOADED_FILES="/tmp/uploaded-files"
 
-if [ -z "$BASE_URL" ] || [ -z "$RECORD_ID" ]; then
-  echo "Usage: $0 <base_url> <record_id> <workflow_name> <task_discriminator> <secret_key>"
+if [ -z "$BASE_URL" ] || [ -z "$RECORD_ID" ] || [ -z "$SECRET_KEY" ]; then
+  echo "Usage: SECRET_KEY=<key> $0 <base_url> <record_id> <workflow_name> <task_discriminator>"
   exit 1
 fi
 
@@ -19,6 +22,8 @@ if [ ! -d "$FILES_DIR" ]; then
   exit 1
 fi
 
+: > "$UPLOADED_FILES"
+
 for FILE_PATH in "$FILES_DIR"/*; do
   FILE_NAME=$WORKFLOW_NAME-$TASK_DISCRIMINATOR-$(basename "$FILE_PATH")
   echo "Uploading file: $FILE_NAME"
@@ -27,6 +32,8 @@ for FILE_PATH in "$FILES_DIR"/*; do
     curl -f -k -H "Host: localhost" -H "Content-Type: application/octet-stream" -X POST "${BASE_URL}/experiments/${RECORD_ID}/draft/files/${FILE_NAME}/workflow/commit?secret_key=${SECRET_KEY}" \
     --data-binary "@${FILE_PATH}" || { echo "Failed to upload content for $FILE_NAME"; exit 1; }
 
+  echo "$FILE_NAME" >> "$UPLOADED_FILES"
+
 done
 
 echo "All files uploaded successfully."
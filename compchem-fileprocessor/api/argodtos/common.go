@@ -1,10 +1,27 @@
 package argodtos
 
+// Task runs either a template installed in the cluster or a template of the workflow itself.
 type Task struct {
 	Name              string                 `json:"name"`
 	Dependencies      []string               `json:"dependencies"`
-	TemplateReference TemplateReference      `json:"templateRef"`
+	TemplateReference *TemplateReference     `json:"templateRef,omitempty"`
+	Template          string                 `json:"template,omitempty"`
 	Arguments         ParametersAndArtifacts `json:"arguments"`
+	WithItems         []FileItem             `json:"withItems,omitempty"`
+	When              string                 `json:"when,omitempty"`
+	ContinueOn        *ContinueOn            `json:"continueOn,omitempty"`
+}
+
+// Item of a task fanned out over input files, index keeps names derived from it unique.
+type FileItem struct {
+	File  string `json:"file"`
+	Index string `json:"index"`
+}
+
+// Lets dependent tasks run even when the task did not succeed.
+type ContinueOn struct {
+	Failed bool `json:"failed,omitempty"`
+	Error  bool `json:"error,omitempty"`
 }
 
 type Parameter struct {
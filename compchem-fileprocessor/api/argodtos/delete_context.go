@@ -13,7 +13,7 @@ func newDeleteWorkflow(
 	return &Task{
 		Name:         fmt.Sprintf(deleteContextTemplate, recordId, workflowId),
 		Dependencies: previousTasks,
-		TemplateReference: TemplateReference{
+		TemplateReference: &TemplateReference{
 			Name:     "delete-context-template",
 			Template: "delete-context",
 		},
@@ -29,8 +29,8 @@ func newDeleteWorkflow(
 					Value: workflowFullName,
 				},
 				{
-					Name:  "secret-key",
-					Value: "{{workflow.parameters.secret-key}}",
+					Name:  "secret-name",
+					Value: "{{workflow.parameters.secret-name}}",
 				},
 			},
 		},
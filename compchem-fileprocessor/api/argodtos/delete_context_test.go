@@ -41,9 +41,9 @@ func TestDeleteWorkflow_AllArgumentsSupplied_ProperlyFormedTask(t *testing.T) {
 	assert.Equal(t, "workflow-name", task.Arguments.Parameters[1].Name)
 	assert.Equal(t, workflowFullName, task.Arguments.Parameters[1].Value)
 
-	// Check secret-key parameter
-	assert.Equal(t, "secret-key", task.Arguments.Parameters[2].Name)
-	assert.Equal(t, "{{workflow.parameters.secret-key}}", task.Arguments.Parameters[2].Value)
+	// Check secret-name parameter
+	assert.Equal(t, "secret-name", task.Arguments.Parameters[2].Name)
+	assert.Equal(t, "{{workflow.parameters.secret-name}}", task.Arguments.Parameters[2].Value)
 }
 
 func TestDeleteWorkflow_AllArgumentsSupplied_ProperlyFormedJson(t *testing.T) {
@@ -80,8 +80,8 @@ func TestDeleteWorkflow_AllArgumentsSupplied_ProperlyFormedJson(t *testing.T) {
 					"value": "read-count-write-12345-2"
 				},
 				{
-					"name": "secret-key",
-					"value": "{{workflow.parameters.secret-key}}"
+					"name": "secret-name",
+					"value": "{{workflow.parameters.secret-name}}"
 				}
 			],
 			"artifacts": []
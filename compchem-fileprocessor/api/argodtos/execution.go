@@ -0,0 +1,162 @@
+package argodtos
+
+import (
+	"encoding/json"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+)
+
+const podSpecPatchParameter = "pod-spec-patch"
+
+type RetryStrategy struct {
+	Limit       int      `json:"limit"`
+	RetryPolicy string   `json:"retryPolicy,omitempty"`
+	Backoff     *Backoff `json:"backoff,omitempty"`
+}
+
+type Backoff struct {
+	Duration    string `json:"duration,omitempty"`
+	Factor      int    `json:"factor,omitempty"`
+	MaxDuration string `json:"maxDuration,omitempty"`
+}
+
+type podSpecPatch struct {
+	Containers   []containerPatch  `json:"containers,omitempty"`
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	Tolerations  []toleration      `json:"tolerations,omitempty"`
+}
+
+type containerPatch struct {
+	Name      string    `json:"name"`
+	Resources resources `json:"resources"`
+}
+
+type resources struct {
+	Requests map[string]string `json:"requests,omitempty"`
+	Limits   map[string]string `json:"limits,omitempty"`
+}
+
+type toleration struct {
+	Key               string `json:"key,omitempty"`
+	Operator          string `json:"operator,omitempty"`
+	Value             string `json:"value,omitempty"`
+	Effect            string `json:"effect,omitempty"`
+	TolerationSeconds *int64 `json:"tolerationSeconds,omitempty"`
+}
+
+// Retries and deadline are template fields in argo, a dag task cannot carry them, so the
+// referenced template is wrapped into a local one when any of them is configured.
+func needsWrapper(cfg config.ProcessingTemplate) bool {
+	return cfg.RetryStrategy != nil || cfg.ActiveDeadlineSeconds > 0
+}
+
+// Wrapper runs the processing task as its only task and passes its artifacts through.
+func newProcessingWrapper(name string, cfg config.ProcessingTemplate, run *Task) Template {
+	inputs := []InputArtifact{}
+	passed := []Artifact{}
+	for _, artifact := range run.Arguments.Artifacts {
+		inputs = append(inputs, InputArtifact{Name: artifact.Name})
+		passed = append(passed, Artifact{
+			Name: artifact.Name,
+			From: "{{inputs.artifacts." + artifact.Name + "}}",
+		})
+	}
+	run.Name = cfg.Template
+	run.Dependencies = []string{}
+	run.Arguments.Artifacts = passed
+
+	return Template{
+		Name: name,
+		Inputs: &Inputs{
+			Artifacts: inputs,
+		},
+		Outputs: &Outputs{
+			Artifacts: []Artifact{
+				{
+					Name: "output-files",
+					From: "{{tasks." + run.Name + ".outputs.artifacts.output-files}}",
+				},
+			},
+		},
+		RetryStrategy:         newRetryStrategy(cfg.RetryStrategy),
+		ActiveDeadlineSeconds: cfg.ActiveDeadlineSeconds,
+		Dag: Dag{
+			Tasks: []*Task{run},
+		},
+	}
+}
+
+func newRetryStrategy(conf *config.RetryStrategy) *RetryStrategy {
+	if conf == nil {
+		return nil
+	}
+
+	strategy := &RetryStrategy{
+		Limit:       conf.Limit,
+		RetryPolicy: conf.RetryPolicy,
+	}
+	if conf.Backoff != nil {
+		strategy.Backoff = &Backoff{
+			Duration:    conf.Backoff.Duration,
+			Factor:      conf.Backoff.Factor,
+			MaxDuration: conf.Backoff.MaxDuration,
+		}
+	}
+
+	return strategy
+}
+
+// Pod settings are handed to the processing template as a strategic merge patch of its pod,
+// templates apply it with podSpecPatch. Empty parameters when nothing is configured.
+func podSpecPatchParameters(cfg config.ProcessingTemplate) []Parameter {
+	patch := podSpecPatch{
+		NodeSelector: cfg.NodeSelector,
+	}
+	if cfg.Resources != nil {
+		patch.Containers = []containerPatch{
+			{
+				Name: "main",
+				Resources: resources{
+					Requests: resourceList(cfg.Resources.Requests),
+					Limits:   resourceList(cfg.Resources.Limits),
+				},
+			},
+		}
+	}
+	for _, t := range cfg.Tolerations {
+		patch.Tolerations = append(patch.Tolerations, toleration{
+			Key:               t.Key,
+			Operator:          t.Operator,
+			Value:             t.Value,
+			Effect:            t.Effect,
+			TolerationSeconds: t.TolerationSeconds,
+		})
+	}
+
+	if patch.Containers == nil && len(patch.NodeSelector) == 0 && patch.Tolerations == nil {
+		return []Parameter{}
+	}
+
+	value, _ := json.Marshal(patch)
+	return []Parameter{
+		{
+			Name:  podSpecPatchParameter,
+			Value: string(value),
+		},
+	}
+}
+
+func resourceList(conf config.ResourceList) map[string]string {
+	result := map[string]string{}
+	if conf.Cpu != "" {
+		result["cpu"] = conf.Cpu
+	}
+	if conf.Memory != "" {
+		result["memory"] = conf.Memory
+	}
+
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
@@ -0,0 +1,103 @@
+package argodtos
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	exitHandlerTemplate   = "exit-handler-%s-%d"
+	reportOutcomeTemplate = "report-outcome-%s-%d"
+	uploadedFilesOutput   = "{{workflow.outputs.parameters.uploaded-files-%s}}"
+)
+
+// Exit handler runs whatever the outcome of the workflow is, so the context is always revoked
+// and the fileprocessor learns how the run ended. Reporting is skipped without a callback url,
+// otherwise it goes first since the fileprocessor may forward the outcome to compchem with the
+// workflow secret key, the context is revoked even when reporting fails.
+func newExitHandler(
+	recordId string,
+	workflowId uint64,
+	workflowFullName string,
+	callbackUrl string,
+	writeDiscriminators []string,
+) Template {
+	tasks := []*Task{}
+	previousTasks := []string{}
+	if callbackUrl != "" {
+		reportTask := newReportOutcomeWorkflow(
+			recordId,
+			workflowId,
+			workflowFullName,
+			callbackUrl,
+			writeDiscriminators,
+		)
+		tasks = append(tasks, reportTask)
+		previousTasks = append(previousTasks, reportTask.Name)
+	}
+	tasks = append(tasks, newDeleteWorkflow(recordId, workflowId, workflowFullName, previousTasks))
+
+	return Template{
+		Name: fmt.Sprintf(exitHandlerTemplate, recordId, workflowId),
+		Dag: Dag{
+			Tasks: tasks,
+		},
+	}
+}
+
+// Each write task exposes keys of files it uploaded as a global output named after its
+// discriminator, outputs of write tasks which did not run stay unresolved.
+func newReportOutcomeWorkflow(
+	recordId string,
+	workflowId uint64,
+	workflowFullName string,
+	callbackUrl string,
+	writeDiscriminators []string,
+) *Task {
+	outputs := []string{}
+	for _, discriminator := range writeDiscriminators {
+		outputs = append(outputs, fmt.Sprintf(uploadedFilesOutput, discriminator))
+	}
+
+	return &Task{
+		Name:         fmt.Sprintf(reportOutcomeTemplate, recordId, workflowId),
+		Dependencies: []string{},
+		TemplateReference: &TemplateReference{
+			Name:     "report-outcome-template",
+			Template: "report-outcome",
+		},
+		Arguments: ParametersAndArtifacts{
+			Artifacts: []Artifact{},
+			Parameters: []Parameter{
+				{
+					Name:  "callback-url",
+					Value: callbackUrl,
+				},
+				{
+					Name:  "workflow-name",
+					Value: workflowFullName,
+				},
+				{
+					Name:  "secret-name",
+					Value: "{{workflow.parameters.secret-name}}",
+				},
+				{
+					Name:  "phase",
+					Value: "{{workflow.status}}",
+				},
+				{
+					Name:  "failures",
+					Value: "{{workflow.failures}}",
+				},
+				{
+					Name:  "outputs",
+					Value: strings.Join(outputs, " "),
+				},
+			},
+		},
+		ContinueOn: &ContinueOn{
+			Failed: true,
+			Error:  true,
+		},
+	}
+}
@@ -0,0 +1,94 @@
+package argodtos
+
+import (
+	"fmt"
+	"testing"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitHandler_CallbackUrlSupplied_OutcomeReportedBeforeContextDeleted(t *testing.T) {
+	// Arrange
+	recordId := "12345"
+	workflowId := uint64(2)
+	workflowFullName := "read-count-write-12345-2"
+
+	// Act
+	handler := newExitHandler(
+		recordId,
+		workflowId,
+		workflowFullName,
+		"http://fileprocessor/api",
+		[]string{"count-words", "simulation-annotation"},
+	)
+
+	// Assert
+	assert.Equal(t, fmt.Sprintf(exitHandlerTemplate, recordId, workflowId), handler.Name)
+	assert.Equal(t, 2, len(handler.Dag.Tasks))
+
+	reportTask := handler.Dag.Tasks[0]
+	assert.Equal(t, fmt.Sprintf(reportOutcomeTemplate, recordId, workflowId), reportTask.Name)
+	assert.Equal(t, "report-outcome-template", reportTask.TemplateReference.Name)
+	assert.Equal(t, "report-outcome", reportTask.TemplateReference.Template)
+	assert.Equal(t, []string{}, reportTask.Dependencies)
+	assert.Equal(t, &ContinueOn{Failed: true, Error: true}, reportTask.ContinueOn)
+	assert.Equal(t, []Parameter{
+		{Name: "callback-url", Value: "http://fileprocessor/api"},
+		{Name: "workflow-name", Value: workflowFullName},
+		{Name: "secret-name", Value: "{{workflow.parameters.secret-name}}"},
+		{Name: "phase", Value: "{{workflow.status}}"},
+		{Name: "failures", Value: "{{workflow.failures}}"},
+		{
+			Name: "outputs",
+			Value: "{{workflow.outputs.parameters.uploaded-files-count-words}} " +
+				"{{workflow.outputs.parameters.uploaded-files-simulation-annotation}}",
+		},
+	}, reportTask.Arguments.Parameters)
+
+	deleteTask := handler.Dag.Tasks[1]
+	assert.Equal(t, "delete-context-template", deleteTask.TemplateReference.Name)
+	assert.Equal(
+		t,
+		[]string{reportTask.Name},
+		deleteTask.Dependencies,
+		"context must stay valid while the outcome is forwarded",
+	)
+}
+
+func TestExitHandler_NoCallbackUrl_OnlyContextDeleted(t *testing.T) {
+	// Arrange
+	recordId := "54321"
+	workflowId := uint64(5)
+
+	// Act
+	handler := newExitHandler(recordId, workflowId, "test-workflow-54321-5", "", []string{})
+
+	// Assert
+	assert.Equal(t, 1, len(handler.Dag.Tasks))
+	assert.Equal(t, fmt.Sprintf(deleteContextTemplate, recordId, workflowId), handler.Dag.Tasks[0].Name)
+	assert.Equal(t, []string{}, handler.Dag.Tasks[0].Dependencies)
+}
+
+func TestBuildWorkflow_AnyConfig_ExitHandlerRegistered(t *testing.T) {
+	// Act
+	workflow := BuildWorkflow(
+		config.WorkflowConfig{},
+		"https://localhost:5000",
+		"count-words",
+		1,
+		"12345",
+		[]config.FileInfo{{Name: "test.txt"}},
+		nil,
+		nil,
+		"compchem-test",
+		"",
+	)
+
+	// Assert
+	assert.Equal(t, "exit-handler-12345-1", workflow.Spec.OnExit)
+	assert.Equal(t, workflow.Spec.OnExit, workflow.Spec.Templates[len(workflow.Spec.Templates)-1].Name)
+	for _, task := range workflow.Spec.Templates[0].Dag.Tasks {
+		assert.NotEqual(t, "delete-context-template", task.TemplateReference.Name)
+	}
+}
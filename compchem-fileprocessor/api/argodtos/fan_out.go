@@ -0,0 +1,70 @@
+package argodtos
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+const (
+	perFileTemplate = "per-file-%s-%d"
+	fanOutFileId    = "{{inputs.parameters.file-id}}"
+	fanOutFileIndex = "{{inputs.parameters.file-index}}"
+)
+
+// Matches names argo gives to fanned out tasks, e.g. per-file-12345-2(0:file:a.tpr,index:0).
+var fanOutNodeRegex = regexp.MustCompile(`^per-file-.+\(\d+:file:(.*),index:\d+\)$`)
+
+// In fan-out mode every input file is read, processed and written by its own instance of this
+// template, so a bad file only fails its own branch.
+func newPerFileTemplate(recordId string, workflowId uint64, tasks []*Task) Template {
+	return Template{
+		Name: fmt.Sprintf(perFileTemplate, recordId, workflowId),
+		Inputs: &Inputs{
+			Parameters: []InputParameter{
+				{Name: "file-id"},
+				{Name: "file-index"},
+			},
+		},
+		Dag: Dag{
+			Tasks: tasks,
+		},
+	}
+}
+
+func newFanOutTask(perFileTemplate string, fileIds []string) *Task {
+	items := []FileItem{}
+	for index, fileId := range fileIds {
+		items = append(items, FileItem{File: fileId, Index: strconv.Itoa(index)})
+	}
+
+	return &Task{
+		Name:         perFileTemplate,
+		Dependencies: []string{},
+		Template:     perFileTemplate,
+		Arguments: ParametersAndArtifacts{
+			Artifacts: []Artifact{},
+			Parameters: []Parameter{
+				{
+					Name:  "file-id",
+					Value: "{{item.file}}",
+				},
+				{
+					Name:  "file-index",
+					Value: "{{item.index}}",
+				},
+			},
+		},
+		WithItems: items,
+	}
+}
+
+// Returns the input file a failed node was processing, empty when the node was not fanned out.
+func FanOutFile(displayName string) string {
+	match := fanOutNodeRegex.FindStringSubmatch(displayName)
+	if match == nil {
+		return ""
+	}
+
+	return match[1]
+}
@@ -0,0 +1,75 @@
+package argodtos
+
+import (
+	"testing"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildWorkflow_FanOut_BranchPerFile(t *testing.T) {
+	// Arrange
+	workflowConfig := config.WorkflowConfig{
+		FanOut:      true,
+		Parallelism: 2,
+		ProcessingTemplates: []config.ProcessingTemplate{
+			{Name: "simulation-annotation-template", Template: "simulation-annotation"},
+		},
+	}
+
+	// Act
+	workflow := BuildWorkflow(
+		workflowConfig,
+		"https://localhost:5000",
+		"simulation-annotation",
+		2,
+		"12345",
+		[]config.FileInfo{{Name: "a.tpr"}, {Name: "b.tpr"}},
+		nil,
+		nil,
+		"compchem-test",
+		"http://fileprocessor:8062/api",
+	)
+
+	// Assert
+	assert.Equal(t, 3, len(workflow.Spec.Templates))
+
+	main := workflow.Spec.Templates[0]
+	assert.Equal(t, 2, main.Parallelism)
+	assert.False(t, *main.Dag.FailFast, "other files must be processed when one fails")
+	assert.Equal(t, 1, len(main.Dag.Tasks))
+
+	fanOut := main.Dag.Tasks[0]
+	assert.Nil(t, fanOut.TemplateReference)
+	assert.Equal(t, "per-file-12345-2", fanOut.Template)
+	assert.Equal(t, []FileItem{{File: "a.tpr", Index: "0"}, {File: "b.tpr", Index: "1"}}, fanOut.WithItems)
+
+	perFile := workflow.Spec.Templates[1]
+	assert.Equal(t, "per-file-12345-2", perFile.Name)
+	assert.Equal(t, []InputParameter{{Name: "file-id"}, {Name: "file-index"}}, perFile.Inputs.Parameters)
+	assert.Equal(t, 3, len(perFile.Dag.Tasks))
+	assert.Equal(t, Parameter{Name: "file-ids", Value: fanOutFileId}, perFile.Dag.Tasks[0].Arguments.Parameters[3])
+	assert.Equal(
+		t,
+		Parameter{Name: "task-discriminator", Value: "simulation-annotation-{{inputs.parameters.file-index}}"},
+		perFile.Dag.Tasks[2].Arguments.Parameters[4],
+	)
+
+	exitHandler := workflow.Spec.Templates[2]
+	assert.Equal(
+		t,
+		"{{workflow.outputs.parameters.uploaded-files-simulation-annotation-0}} "+
+			"{{workflow.outputs.parameters.uploaded-files-simulation-annotation-1}}",
+		exitHandler.Dag.Tasks[0].Arguments.Parameters[5].Value,
+	)
+}
+
+func TestFanOutFile_FannedOutNode_FileReturned(t *testing.T) {
+	assert.Equal(t, "a.tpr", FanOutFile("per-file-12345-2(0:file:a.tpr,index:0)"))
+	assert.Equal(t, "run,1.tpr", FanOutFile("per-file-12345-2(12:file:run,1.tpr,index:12)"))
+}
+
+func TestFanOutFile_OtherNode_Empty(t *testing.T) {
+	assert.Equal(t, "", FanOutFile("simulation-annotation-12345-2"))
+	assert.Equal(t, "", FanOutFile("read-count-write-12345-2"))
+}
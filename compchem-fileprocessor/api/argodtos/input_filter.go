@@ -0,0 +1,105 @@
+package argodtos
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+)
+
+// Indexes of the workflow files every processing template runs for keyed by its name, nil when it
+// runs for all of them. Templates with dependencies run only for files all their dependencies run
+// for.
+func templateScopes(
+	conf []config.ProcessingTemplate,
+	files []config.FileInfo,
+	inputs map[string][]string,
+) map[string][]int {
+	slots := make(map[string]string)
+	for slot, names := range inputs {
+		for _, name := range names {
+			slots[name] = slot
+		}
+	}
+	byTemplate := make(map[string]config.ProcessingTemplate)
+	for _, cfg := range conf {
+		byTemplate[cfg.Name] = cfg
+	}
+
+	result := make(map[string][]int)
+	var scope func(cfg config.ProcessingTemplate) []int
+	scope = func(cfg config.ProcessingTemplate) []int {
+		if indexes, present := result[cfg.Name]; present {
+			return indexes
+		}
+
+		var indexes []int
+		if len(cfg.DependsOn) == 0 && cfg.Input != nil {
+			indexes = []int{}
+			for index, file := range files {
+				if cfg.Input.Accepts(file, slots[file.Name]) {
+					indexes = append(indexes, index)
+				}
+			}
+			if len(indexes) == len(files) {
+				indexes = nil
+			}
+		}
+		for _, dependency := range cfg.DependsOn {
+			indexes = intersectScopes(indexes, scope(byTemplate[dependency]))
+		}
+
+		result[cfg.Name] = indexes
+		return indexes
+	}
+	for _, cfg := range conf {
+		scope(cfg)
+	}
+
+	return result
+}
+
+func intersectScopes(a []int, b []int) []int {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+
+	return slices.DeleteFunc(slices.Clone(a), func(index int) bool { return !slices.Contains(b, index) })
+}
+
+// Template without any file to process is left out of the workflow together with its write step.
+func emptyScope(scope []int) bool {
+	return scope != nil && len(scope) == 0
+}
+
+// Fanned out templates are skipped in branches of files outside of their scope.
+func fanOutWhen(scope []int) string {
+	if scope == nil {
+		return ""
+	}
+
+	indexes := []string{}
+	for _, index := range scope {
+		indexes = append(indexes, strconv.Itoa(index))
+	}
+
+	return fmt.Sprintf("'%s' =~ '^(%s)$'", fanOutFileIndex, strings.Join(indexes, "|"))
+}
+
+func filesInScope(files []string, scope []int) []string {
+	if scope == nil {
+		return files
+	}
+
+	result := []string{}
+	for _, index := range scope {
+		result = append(result, files[index])
+	}
+
+	return result
+}
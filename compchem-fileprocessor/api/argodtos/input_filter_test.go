@@ -0,0 +1,159 @@
+package argodtos
+
+import (
+	"testing"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func filteredTemplates() []config.ProcessingTemplate {
+	return []config.ProcessingTemplate{
+		{Name: "count-words-template", Template: "count-words"},
+		{
+			Name:     "simulation-annotation-template",
+			Template: "simulation-annotation",
+			Input:    &config.InputFilter{MatchRule: config.MatchRule{Extensions: []string{"tpr"}}},
+		},
+		{
+			Name:      "summarize-template",
+			Template:  "summarize",
+			DependsOn: []string{"simulation-annotation-template"},
+		},
+		{
+			Name:     "render-template",
+			Template: "render",
+			Input:    &config.InputFilter{MatchRule: config.MatchRule{Extensions: []string{"pdb"}}},
+		},
+	}
+}
+
+func TestBuildWorkflow_InputFilters_TemplatesScopedToMatchingFiles(t *testing.T) {
+	// Arrange
+	conf := config.WorkflowConfig{ProcessingTemplates: filteredTemplates()}
+	files := []config.FileInfo{{Name: "run.tpr"}, {Name: "notes.txt"}}
+
+	// Act
+	workflow := BuildWorkflow(
+		conf,
+		"https://localhost:5000",
+		"annotate",
+		2,
+		"12345",
+		files,
+		nil,
+		nil,
+		"compchem-test",
+		"",
+	)
+
+	// Assert
+	byName := map[string]*Task{}
+	for _, task := range workflow.Spec.Templates[0].Dag.Tasks {
+		byName[task.Name] = task
+	}
+	assert.Equal(t, []string{"read-files-12345-2"}, byName["count-words-12345-2"].Dependencies)
+
+	read := byName["read-files-simulation-annotation-12345-2"]
+	assert.Equal(t, Parameter{Name: "file-ids", Value: "run.tpr"}, read.Arguments.Parameters[3])
+	assert.Equal(t, []string{read.Name}, byName["simulation-annotation-12345-2"].Dependencies)
+	assert.Contains(t, byName, "write-files-summarize-12345-2")
+
+	assert.NotContains(t, byName, "render-12345-2")
+	assert.NotContains(t, byName, "write-files-render-12345-2")
+	assert.NotContains(t, byName, "read-files-render-12345-2")
+	assert.Equal(t, []string{"count-words", "summarize"}, writeDiscriminators(
+		conf,
+		templateScopes(conf.ProcessingTemplates, files, nil),
+		len(files),
+	))
+}
+
+func TestBuildWorkflow_InputFiltersFanOut_TemplatesSkippedInOtherBranches(t *testing.T) {
+	// Arrange
+	conf := config.WorkflowConfig{FanOut: true, ProcessingTemplates: filteredTemplates()}
+	files := []config.FileInfo{{Name: "notes.txt"}, {Name: "a.tpr"}, {Name: "b.tpr"}}
+
+	// Act
+	workflow := BuildWorkflow(
+		conf,
+		"https://localhost:5000",
+		"annotate",
+		2,
+		"12345",
+		files,
+		nil,
+		nil,
+		"compchem-test",
+		"http://fileprocessor:8062/api",
+	)
+
+	// Assert
+	byName := map[string]*Task{}
+	for _, task := range workflow.Spec.Templates[1].Dag.Tasks {
+		byName[task.Name] = task
+	}
+	when := "'{{inputs.parameters.file-index}}' =~ '^(1|2)$'"
+	assert.Equal(t, "", byName["count-words-12345-2"].When)
+	assert.Equal(t, when, byName["simulation-annotation-12345-2"].When)
+	assert.Equal(t, when, byName["summarize-12345-2"].When)
+	assert.Equal(t, when, byName["write-files-summarize-12345-2"].When)
+	assert.NotContains(t, byName, "render-12345-2")
+
+	exitHandler := workflow.Spec.Templates[2]
+	assert.Equal(
+		t,
+		"{{workflow.outputs.parameters.uploaded-files-count-words-0}} "+
+			"{{workflow.outputs.parameters.uploaded-files-count-words-1}} "+
+			"{{workflow.outputs.parameters.uploaded-files-count-words-2}} "+
+			"{{workflow.outputs.parameters.uploaded-files-summarize-1}} "+
+			"{{workflow.outputs.parameters.uploaded-files-summarize-2}}",
+		exitHandler.Dag.Tasks[0].Arguments.Parameters[5].Value,
+	)
+}
+
+func TestConstructDag_SlotFilter_OnlySelectedSlotsPassed(t *testing.T) {
+	// Arrange
+	processingTemplates := []config.ProcessingTemplate{
+		{
+			Name:     "rmsd-template",
+			Template: "rmsd",
+			Input: &config.InputFilter{
+				Slots:     []string{"trajectory"},
+				MatchRule: config.MatchRule{Globs: []string{"part1.*"}},
+			},
+		},
+	}
+	files := []config.FileInfo{{Name: "run.tpr"}, {Name: "part1.xtc"}, {Name: "part2.xtc"}}
+	inputs := map[string][]string{
+		"structure":  {"run.tpr"},
+		"trajectory": {"part1.xtc", "part2.xtc"},
+	}
+	slots := []config.InputSlot{{Name: "structure"}, {Name: "trajectory"}}
+
+	// Act
+	tasks, _ := constructDag(
+		processingTemplates,
+		"gromacs",
+		"12345",
+		2,
+		allFileIds,
+		"",
+		[]string{"run.tpr", "part1.xtc", "part2.xtc"},
+		templateScopes(processingTemplates, files, inputs),
+		inputGroups(slots, inputs),
+		nil,
+	)
+
+	// Assert
+	byName := map[string]*Task{}
+	for _, task := range tasks {
+		byName[task.Name] = task
+	}
+	rmsd := byName["rmsd-12345-2"]
+	assert.Equal(t, []string{"read-trajectory-rmsd-12345-2"}, rmsd.Dependencies)
+	assert.Equal(t, []Artifact{
+		{Name: "trajectory", From: "{{tasks.read-trajectory-rmsd-12345-2.outputs.artifacts.output-files}}"},
+	}, rmsd.Arguments.Artifacts)
+	assert.Equal(t, []Parameter{{Name: "trajectory-files", Value: "part1.xtc"}}, rmsd.Arguments.Parameters)
+}
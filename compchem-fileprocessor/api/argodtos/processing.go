@@ -1,27 +1,96 @@
 package argodtos
 
-import "fmt"
+import (
+	"fmt"
+	"slices"
+	"strings"
 
+	"fi.muni.cz/invenio-file-processor/v2/config"
+)
+
+const processingTemplate = "%s-%s-%d"
+
+// Artifacts are the inputs of the processing step, the previous tasks are waited for. Values are
+// the resolved template parameters. Returns the local template the step runs when execution
+// settings require one.
 func newProcessingStep(
 	recordId string,
 	workflowId uint64,
-	previousTask string,
-	templateRef *TemplateReference,
-) *Task {
-	template := templateRef.Template
+	step string,
+	previousTasks []string,
+	artifacts []Artifact,
+	cfg config.ProcessingTemplate,
+	values map[string]string,
+) (*Task, *Template) {
+	name := processingTaskName(recordId, workflowId, step)
 
+	run := &Task{
+		Name:         name,
+		Dependencies: previousTasks,
+		TemplateReference: &TemplateReference{
+			Name:     cfg.Name,
+			Template: cfg.Template,
+		},
+		Arguments: ParametersAndArtifacts{
+			Artifacts:  artifacts,
+			Parameters: append(podSpecPatchParameters(cfg), templateParameters(values)...),
+		},
+	}
+	if !needsWrapper(cfg) {
+		return run, nil
+	}
+
+	wrapper := newProcessingWrapper(name, cfg, run)
 	return &Task{
-		Name:              fmt.Sprintf(template+"-%s-%d", recordId, workflowId),
-		Dependencies:      []string{previousTask},
-		TemplateReference: *templateRef,
+		Name:         name,
+		Dependencies: previousTasks,
+		Template:     wrapper.Name,
 		Arguments: ParametersAndArtifacts{
-			Artifacts: []Artifact{
-				{
-					Name: "input-files",
-					From: fmt.Sprintf("{{tasks.%s.outputs.artifacts.output-files}}", previousTask),
-				},
-			},
+			Artifacts:  artifacts,
 			Parameters: []Parameter{},
 		},
+	}, &wrapper
+}
+
+// Files produced by the task, read steps and processing templates output them the same way.
+func outputFilesOf(task string, as string) Artifact {
+	return Artifact{
+		Name: as,
+		From: fmt.Sprintf("{{tasks.%s.outputs.artifacts.output-files}}", task),
+	}
+}
+
+func processingTaskName(recordId string, workflowId uint64, step string) string {
+	return fmt.Sprintf(processingTemplate, step, recordId, workflowId)
+}
+
+// Tasks of a processing template are named after the argo template it runs, keyed by the
+// processing template name. Processing templates sharing the argo template are told apart by
+// their own name.
+func stepNames(conf []config.ProcessingTemplate) map[string]string {
+	uses := make(map[string]int)
+	for _, cfg := range conf {
+		uses[cfg.Template]++
+	}
+
+	result := make(map[string]string)
+	for _, cfg := range conf {
+		result[cfg.Name] = cfg.Template
+		if uses[cfg.Template] > 1 {
+			result[cfg.Name] = cfg.Name
+		}
 	}
+
+	return result
+}
+
+// Sorted by name so the rendered workflow does not depend on map order.
+func templateParameters(values map[string]string) []Parameter {
+	result := []Parameter{}
+	for name, value := range values {
+		result = append(result, Parameter{Name: name, Value: value})
+	}
+	slices.SortFunc(result, func(a, b Parameter) int { return strings.Compare(a.Name, b.Name) })
+
+	return result
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"testing"
 
+	"fi.muni.cz/invenio-file-processor/v2/config"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -14,22 +15,32 @@ func TestProcessingStep_AllArgumentsSupplied_ProperlyFormedTask(t *testing.T) {
 	recordId := "12345"
 	workflowId := uint64(2)
 	previousTask := "read-files-12345-2"
-	templateRef := &TemplateReference{
+	cfg := config.ProcessingTemplate{
 		Name:     "count-words-template",
 		Template: "count-words",
 	}
 
 	expectedName := fmt.Sprintf("count-words-%s-%d", recordId, workflowId)
 	expectedDependencies := []string{previousTask}
+	inputFiles := []Artifact{outputFilesOf(previousTask, "input-files")}
 
 	// Act
-	task := newProcessingStep(recordId, workflowId, previousTask, templateRef)
+	task, wrapper := newProcessingStep(
+		recordId,
+		workflowId,
+		cfg.Template,
+		[]string{previousTask},
+		inputFiles,
+		cfg,
+		nil,
+	)
 
 	// Assert
+	assert.Nil(t, wrapper)
 	assert.Equal(t, expectedName, task.Name)
 	assert.Equal(t, expectedDependencies, task.Dependencies)
-	assert.Equal(t, templateRef.Name, task.TemplateReference.Name)
-	assert.Equal(t, templateRef.Template, task.TemplateReference.Template)
+	assert.Equal(t, cfg.Name, task.TemplateReference.Name)
+	assert.Equal(t, cfg.Template, task.TemplateReference.Template)
 
 	// Verify parameters is empty
 	assert.Equal(t, 0, len(task.Arguments.Parameters))
@@ -49,12 +60,21 @@ func TestProcessingStep_AllArgumentsSupplied_ProperlyFormedJson(t *testing.T) {
 	recordId := "12345"
 	workflowId := uint64(2)
 	previousTask := "read-files-12345-2"
-	templateRef := &TemplateReference{
+	cfg := config.ProcessingTemplate{
 		Name:     "count-words-template",
 		Template: "count-words",
 	}
 
-	task := newProcessingStep(recordId, workflowId, previousTask, templateRef)
+	inputFiles := []Artifact{outputFilesOf(previousTask, "input-files")}
+	task, _ := newProcessingStep(
+		recordId,
+		workflowId,
+		cfg.Template,
+		[]string{previousTask},
+		inputFiles,
+		cfg,
+		nil,
+	)
 
 	// Act
 	taskJson, err := json.Marshal(task)
@@ -99,3 +119,172 @@ func TestProcessingStep_AllArgumentsSupplied_ProperlyFormedJson(t *testing.T) {
 	// Compare the normalized JSON strings
 	assert.Equal(t, string(expectedNormalized), string(actualNormalized))
 }
+
+func TestProcessingStep_PodSettingsConfigured_PodSpecPatchPassed(t *testing.T) {
+	// Arrange
+	tolerationSeconds := int64(60)
+	cfg := config.ProcessingTemplate{
+		Name:     "count-words-template",
+		Template: "count-words",
+		Resources: &config.Resources{
+			Requests: config.ResourceList{Cpu: "500m", Memory: "1Gi"},
+			Limits:   config.ResourceList{Memory: "2Gi"},
+		},
+		NodeSelector: map[string]string{"gpu": "true"},
+		Tolerations: []config.Toleration{
+			{
+				Key:               "gpu",
+				Operator:          "Exists",
+				Effect:            "NoSchedule",
+				TolerationSeconds: &tolerationSeconds,
+			},
+		},
+	}
+	inputFiles := []Artifact{outputFilesOf("read-files-12345-2", "input-files")}
+
+	// Act
+	task, wrapper := newProcessingStep(
+		"12345",
+		2,
+		cfg.Template,
+		[]string{"read-files-12345-2"},
+		inputFiles,
+		cfg,
+		nil,
+	)
+
+	// Assert
+	assert.Nil(t, wrapper)
+	assert.Equal(t, 1, len(task.Arguments.Parameters))
+	assert.Equal(t, "pod-spec-patch", task.Arguments.Parameters[0].Name)
+	assert.JSONEq(t, `{
+		"containers": [
+			{
+				"name": "main",
+				"resources": {
+					"requests": {"cpu": "500m", "memory": "1Gi"},
+					"limits": {"memory": "2Gi"}
+				}
+			}
+		],
+		"nodeSelector": {"gpu": "true"},
+		"tolerations": [
+			{"key": "gpu", "operator": "Exists", "effect": "NoSchedule", "tolerationSeconds": 60}
+		]
+	}`, task.Arguments.Parameters[0].Value)
+}
+
+func TestProcessingStep_RetryAndDeadlineConfigured_WrapperTemplateReturned(t *testing.T) {
+	// Arrange
+	cfg := config.ProcessingTemplate{
+		Name:     "count-words-template",
+		Template: "count-words",
+		RetryStrategy: &config.RetryStrategy{
+			Limit:       3,
+			RetryPolicy: "OnFailure",
+			Backoff: &config.Backoff{
+				Duration:    "30s",
+				Factor:      2,
+				MaxDuration: "10m",
+			},
+		},
+		ActiveDeadlineSeconds: 3600,
+	}
+	inputFiles := []Artifact{outputFilesOf("read-files-12345-2", "input-files")}
+
+	// Act
+	task, wrapper := newProcessingStep(
+		"12345",
+		2,
+		cfg.Template,
+		[]string{"read-files-12345-2"},
+		inputFiles,
+		cfg,
+		nil,
+	)
+	wrapperJson, err := json.Marshal(wrapper)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "count-words-12345-2", task.Name)
+	assert.Equal(t, []string{"read-files-12345-2"}, task.Dependencies)
+	assert.Nil(t, task.TemplateReference)
+	assert.Equal(t, "count-words-12345-2", task.Template)
+	assert.Equal(
+		t,
+		"{{tasks.read-files-12345-2.outputs.artifacts.output-files}}",
+		task.Arguments.Artifacts[0].From,
+	)
+
+	assert.JSONEq(t, `{
+		"name": "count-words-12345-2",
+		"inputs": {
+			"artifacts": [{"name": "input-files"}]
+		},
+		"outputs": {
+			"artifacts": [
+				{
+					"name": "output-files",
+					"from": "{{tasks.count-words.outputs.artifacts.output-files}}"
+				}
+			]
+		},
+		"retryStrategy": {
+			"limit": 3,
+			"retryPolicy": "OnFailure",
+			"backoff": {"duration": "30s", "factor": 2, "maxDuration": "10m"}
+		},
+		"activeDeadlineSeconds": 3600,
+		"dag": {
+			"tasks": [
+				{
+					"name": "count-words",
+					"dependencies": [],
+					"templateRef": {
+						"name": "count-words-template",
+						"template": "count-words"
+					},
+					"arguments": {
+						"parameters": [],
+						"artifacts": [
+							{
+								"name": "input-files",
+								"from": "{{inputs.artifacts.input-files}}"
+							}
+						]
+					}
+				}
+			]
+		}
+	}`, string(wrapperJson))
+}
+
+func TestProcessingStep_ParametersResolved_PassedSortedToTask(t *testing.T) {
+	// Arrange
+	cfg := config.ProcessingTemplate{
+		Name:     "simulation-annotation-template",
+		Template: "simulation-annotation",
+	}
+	values := map[string]string{
+		"keep":     "false",
+		"endpoint": "https://localhost/api/annotate",
+	}
+	inputFiles := []Artifact{outputFilesOf("read-files-12345-2", "input-files")}
+
+	// Act
+	task, _ := newProcessingStep(
+		"12345",
+		2,
+		cfg.Template,
+		[]string{"read-files-12345-2"},
+		inputFiles,
+		cfg,
+		values,
+	)
+
+	// Assert
+	assert.Equal(t, []Parameter{
+		{Name: "endpoint", Value: "https://localhost/api/annotate"},
+		{Name: "keep", Value: "false"},
+	}, task.Arguments.Parameters)
+}
@@ -1,17 +1,22 @@
 package argodtos
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 const readFilesTemplate = "read-files-%s-%d"
 
+// File ids are space separated, either all files of the workflow or a single fanned out file.
 func newReadFilesWorkflow(
 	recordId string,
 	workflowId uint64,
+	fileIds string,
 ) *Task {
 	return &Task{
 		Name:         fmt.Sprintf(readFilesTemplate, recordId, workflowId),
 		Dependencies: []string{},
-		TemplateReference: TemplateReference{
+		TemplateReference: &TemplateReference{
 			Name:     "read-files-template",
 			Template: "read-files",
 		},
@@ -27,14 +32,29 @@ func newReadFilesWorkflow(
 					Value: "{{workflow.parameters.record-id}}",
 				},
 				{
-					Name:  "secret-key",
-					Value: "{{workflow.parameters.secret-key}}",
+					Name:  "secret-name",
+					Value: "{{workflow.parameters.secret-name}}",
 				},
 				{
 					Name:  "file-ids",
-					Value: "{{workflow.parameters.file-ids}}",
+					Value: fileIds,
 				},
 			},
 		},
 	}
 }
+
+const readInputTemplate = "read-%s-%s-%d"
+
+// Files of an input slot are read by their own step, its output is passed on under the slot name.
+func newReadInputWorkflow(
+	recordId string,
+	workflowId uint64,
+	slot string,
+	fileIds []string,
+) *Task {
+	task := newReadFilesWorkflow(recordId, workflowId, strings.Join(fileIds, " "))
+	task.Name = fmt.Sprintf(readInputTemplate, slot, recordId, workflowId)
+
+	return task
+}
@@ -19,7 +19,7 @@ func TestReadFiles_AllArgumentsSupplied_ProperlyFormedTask(t *testing.T) {
 	expectedTemplateRefTemplate := "read-files"
 
 	// Act
-	task := newReadFilesWorkflow(recordId, workflowId)
+	task := newReadFilesWorkflow(recordId, workflowId, allFileIds)
 
 	// Assert
 	assert.Equal(t, expectedName, task.Name)
@@ -37,8 +37,8 @@ func TestReadFiles_AllArgumentsSupplied_ProperlyFormedTask(t *testing.T) {
 	assert.Equal(t, "record-id", task.Arguments.Parameters[1].Name)
 	assert.Equal(t, "{{workflow.parameters.record-id}}", task.Arguments.Parameters[1].Value)
 
-	assert.Equal(t, "secret-key", task.Arguments.Parameters[2].Name)
-	assert.Equal(t, "{{workflow.parameters.secret-key}}", task.Arguments.Parameters[2].Value)
+	assert.Equal(t, "secret-name", task.Arguments.Parameters[2].Name)
+	assert.Equal(t, "{{workflow.parameters.secret-name}}", task.Arguments.Parameters[2].Value)
 
 	assert.Equal(t, "file-ids", task.Arguments.Parameters[3].Name)
 	assert.Equal(t, "{{workflow.parameters.file-ids}}", task.Arguments.Parameters[3].Value)
@@ -48,7 +48,7 @@ func TestReadFiles_AllArgumentsSupplied_ProperlyFormedJson(t *testing.T) {
 	// Arrange
 	recordId := "recordId"
 	workflowId := uint64(1)
-	task := newReadFilesWorkflow(recordId, workflowId)
+	task := newReadFilesWorkflow(recordId, workflowId, allFileIds)
 
 	// Act
 	taskJson, err := json.Marshal(task)
@@ -76,8 +76,8 @@ func TestReadFiles_AllArgumentsSupplied_ProperlyFormedJson(t *testing.T) {
 					"value": "{{workflow.parameters.record-id}}"
 				},
 				{
-					"name": "secret-key",
-					"value": "{{workflow.parameters.secret-key}}"
+					"name": "secret-name",
+					"value": "{{workflow.parameters.secret-name}}"
 				},
 				{
 					"name": "file-ids",
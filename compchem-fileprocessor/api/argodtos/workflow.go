@@ -1,10 +1,15 @@
 package argodtos
 
 import (
+	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
+	"strconv"
 	"strings"
 
 	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/util"
 )
 
 type WorkflowWrapper struct {
@@ -19,57 +24,261 @@ type Workflow struct {
 }
 
 type Metadata struct {
-	Name string `json:"name"`
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
+const (
+	LabelRecordId        = "compchem.cerit.io/record-id"
+	LabelWorkflowConfig  = "compchem.cerit.io/workflow-config"
+	LabelSequenceId      = "compchem.cerit.io/sequence-id"
+	LabelInstance        = "compchem.cerit.io/instance"
+	AnnotationInputFiles = "compchem.cerit.io/input-files"
+)
+
+const (
+	// workflow parameter with the name of the kubernetes secret holding the workflow secret key
+	SecretNameParameter = "secret-name"
+	// key of the workflow secret key in the data of the secret
+	SecretKeyField = "secret-key"
+)
+
 type Spec struct {
-	Entrypoint string     `json:"entrypoint"`
-	Arguments  Arguments  `json:"arguments"`
-	Templates  []Template `json:"templates"`
+	Entrypoint            string       `json:"entrypoint"`
+	OnExit                string       `json:"onExit,omitempty"`
+	ServiceAccountName    string       `json:"serviceAccountName,omitempty"`
+	Priority              *int32       `json:"priority,omitempty"`
+	Parallelism           int          `json:"parallelism,omitempty"`
+	ActiveDeadlineSeconds *int64       `json:"activeDeadlineSeconds,omitempty"`
+	TTLStrategy           *TTLStrategy `json:"ttlStrategy,omitempty"`
+	PodGC                 *PodGC       `json:"podGC,omitempty"`
+	Arguments             Arguments    `json:"arguments"`
+	Templates             []Template   `json:"templates"`
 }
 
+type TTLStrategy struct {
+	SecondsAfterCompletion *int32 `json:"secondsAfterCompletion,omitempty"`
+	SecondsAfterSuccess    *int32 `json:"secondsAfterSuccess,omitempty"`
+	SecondsAfterFailure    *int32 `json:"secondsAfterFailure,omitempty"`
+}
+
+type PodGC struct {
+	Strategy            string `json:"strategy,omitempty"`
+	DeleteDelayDuration string `json:"deleteDelayDuration,omitempty"`
+}
+
+const allFileIds = "{{workflow.parameters.file-ids}}"
+
 type Arguments struct {
 	Parameters []Parameter `json:"parameters"`
 }
 
 type Template struct {
+	Name                  string         `json:"name"`
+	Inputs                *Inputs        `json:"inputs,omitempty"`
+	Outputs               *Outputs       `json:"outputs,omitempty"`
+	Parallelism           int            `json:"parallelism,omitempty"`
+	RetryStrategy         *RetryStrategy `json:"retryStrategy,omitempty"`
+	ActiveDeadlineSeconds int            `json:"activeDeadlineSeconds,omitempty"`
+	Dag                   Dag            `json:"dag"`
+}
+
+// Inputs only declare parameters, a value would take precedence over the passed argument.
+type Inputs struct {
+	Parameters []InputParameter `json:"parameters,omitempty"`
+	Artifacts  []InputArtifact  `json:"artifacts,omitempty"`
+}
+
+type InputParameter struct {
+	Name string `json:"name"`
+}
+
+type InputArtifact struct {
 	Name string `json:"name"`
-	Dag  Dag    `json:"dag"`
+}
+
+type Outputs struct {
+	Artifacts []Artifact `json:"artifacts"`
 }
 
 type Dag struct {
-	Tasks []*Task `json:"tasks"`
+	FailFast *bool   `json:"failFast,omitempty"`
+	Tasks    []*Task `json:"tasks"`
 }
 
 func ConstructFullWorkflowName(workflowName string, recordId string, workflowId uint64) string {
 	return fmt.Sprintf("%s-%s-%d", workflowName, recordId, workflowId)
 }
 
+// The secret key is not part of the workflow, steps read it from the secret of this name which
+// lives in the namespace of the workflow.
+func SecretName(workflowFullName string) string {
+	return workflowFullName + "-key"
+}
+
+// Returns value of the workflow argument, empty when the workflow does not have it.
+func (w *Workflow) GetParameter(name string) string {
+	for _, parameter := range w.Spec.Arguments.Parameters {
+		if parameter.Name == name {
+			return parameter.Value
+		}
+	}
+
+	return ""
+}
+
+// Builds label selector matching workflows of the instance, optionally narrowed by label pairs.
+func BuildLabelSelector(instance string, labels ...string) string {
+	selector := []string{fmt.Sprintf("%s=%s", LabelInstance, instance)}
+	for i := 0; i+1 < len(labels); i += 2 {
+		selector = append(selector, fmt.Sprintf("%s=%s", labels[i], labels[i+1]))
+	}
+
+	return strings.Join(selector, ",")
+}
+
 func BuildWorkflow(
 	conf config.WorkflowConfig,
 	baseUrl string,
 	workflowName string,
 	workflowId uint64,
-	secretKey string,
 	recordId string,
-	fileIds []string,
+	files []config.FileInfo,
+	inputs map[string][]string,
+	parameters map[string]map[string]string,
+	instance string,
+	callbackUrl string,
 ) *Workflow {
-	tasks := constructLinearDag(
-		conf.ProcessingTemplates,
-		workflowName,
+	fileIds := util.Map(files, func(file config.FileInfo) string { return file.Name })
+	scopes := templateScopes(conf.ProcessingTemplates, files, inputs)
+
+	var workflow *Workflow
+	if conf.FanOut {
+		perFileTasks, wrappers := constructDag(
+			conf.ProcessingTemplates,
+			workflowName,
+			recordId,
+			workflowId,
+			fanOutFileId,
+			"-"+fanOutFileIndex,
+			nil,
+			scopes,
+			nil,
+			parameters,
+		)
+		perFile := newPerFileTemplate(recordId, workflowId, perFileTasks)
+		tasks := []*Task{newFanOutTask(perFile.Name, fileIds)}
+
+		workflow = newWorkflow(workflowName, recordId, baseUrl, workflowId, fileIds, tasks)
+		failFast := false
+		workflow.Spec.Templates[0].Parallelism = conf.Parallelism
+		workflow.Spec.Templates[0].Dag.FailFast = &failFast
+		workflow.Spec.Templates = append(workflow.Spec.Templates, perFile)
+		workflow.Spec.Templates = append(workflow.Spec.Templates, wrappers...)
+	} else {
+		tasks, wrappers := constructDag(
+			conf.ProcessingTemplates,
+			workflowName,
+			recordId,
+			workflowId,
+			allFileIds,
+			"",
+			fileIds,
+			scopes,
+			inputGroups(conf.Inputs, inputs),
+			parameters,
+		)
+
+		workflow = newWorkflow(workflowName, recordId, baseUrl, workflowId, fileIds, tasks)
+		workflow.Spec.Templates = append(workflow.Spec.Templates, wrappers...)
+	}
+
+	exitHandler := newExitHandler(
 		recordId,
 		workflowId,
-		secretKey,
+		workflow.Metadata.Name,
+		callbackUrl,
+		writeDiscriminators(conf, scopes, len(fileIds)),
 	)
+	workflow.Spec.OnExit = exitHandler.Name
+	applySpecOptions(workflow, conf.Spec)
+	workflow.Spec.Templates = append(workflow.Spec.Templates, exitHandler)
+	workflow.Metadata.Labels = map[string]string{
+		LabelRecordId:       recordId,
+		LabelWorkflowConfig: workflowName,
+		LabelSequenceId:     strconv.FormatUint(workflowId, 10),
+		LabelInstance:       instance,
+	}
+	workflow.Metadata.Annotations = map[string]string{
+		AnnotationInputFiles: buildInputFilesAnnotation(fileIds),
+	}
+
+	return workflow
+}
+
+func applySpecOptions(workflow *Workflow, conf config.WorkflowSpec) {
+	workflow.Metadata.Namespace = conf.Namespace
+	workflow.Spec.ServiceAccountName = conf.ServiceAccountName
+	workflow.Spec.Priority = conf.Priority
+	workflow.Spec.Parallelism = conf.Parallelism
+	workflow.Spec.ActiveDeadlineSeconds = conf.ActiveDeadlineSeconds
+	if conf.TTLStrategy != nil {
+		workflow.Spec.TTLStrategy = &TTLStrategy{
+			SecondsAfterCompletion: conf.TTLStrategy.SecondsAfterCompletion,
+			SecondsAfterSuccess:    conf.TTLStrategy.SecondsAfterSuccess,
+			SecondsAfterFailure:    conf.TTLStrategy.SecondsAfterFailure,
+		}
+	}
+	if conf.PodGC != nil {
+		workflow.Spec.PodGC = &PodGC{
+			Strategy:            conf.PodGC.Strategy,
+			DeleteDelayDuration: conf.PodGC.DeleteDelayDuration,
+		}
+	}
+}
+
+// Write task of each processing template is discriminated by the name of its step, in fan-out mode
+// also by the index of the file. Templates only write for files in their scope.
+func writeDiscriminators(conf config.WorkflowConfig, scopes map[string][]int, fileCount int) []string {
+	writes := writtenTemplates(conf.ProcessingTemplates)
+	steps := stepNames(conf.ProcessingTemplates)
 
-	return newWorkflow(workflowName, recordId, baseUrl, workflowId, secretKey, fileIds, tasks)
+	result := []string{}
+	for _, cfg := range conf.ProcessingTemplates {
+		scope := scopes[cfg.Name]
+		if !writes[cfg.Name] || emptyScope(scope) {
+			continue
+		}
+		if !conf.FanOut {
+			result = append(result, steps[cfg.Name])
+			continue
+		}
+		for index := range fileCount {
+			if scope == nil || slices.Contains(scope, index) {
+				result = append(result, fmt.Sprintf("%s-%d", steps[cfg.Name], index))
+			}
+		}
+	}
+
+	return result
+}
+
+// Label values are too restrictive for file names, files are stored as json list in an annotation.
+func buildInputFilesAnnotation(fileIds []string) string {
+	if fileIds == nil {
+		fileIds = []string{}
+	}
+
+	files, _ := json.Marshal(fileIds)
+	return string(files)
 }
 
 func newWorkflow(workflowName string,
 	recordId string,
 	baseUrl string,
 	workflowId uint64,
-	secretKey string,
 	fileIds []string,
 	processingTasks []*Task,
 ) *Workflow {
@@ -93,8 +302,8 @@ func newWorkflow(workflowName string,
 						Value: recordId,
 					},
 					{
-						Name:  "secret-key",
-						Value: secretKey,
+						Name:  SecretNameParameter,
+						Value: SecretName(fullName),
 					},
 					{
 						Name:  "file-ids",
@@ -114,44 +323,168 @@ func newWorkflow(workflowName string,
 	}
 }
 
-func constructLinearDag(
+// Files assigned to an input slot, in the order the slots are declared.
+type inputGroup struct {
+	slot  string
+	files []string
+}
+
+// Slots without files are left out, their templates have to declare the artifact as optional.
+func inputGroups(slots []config.InputSlot, inputs map[string][]string) []inputGroup {
+	result := []inputGroup{}
+	for _, slot := range slots {
+		if len(inputs[slot.Name]) > 0 {
+			result = append(result, inputGroup{slot: slot.Name, files: inputs[slot.Name]})
+		}
+	}
+
+	return result
+}
+
+// Processing templates without dependencies run right after the read step, the others after
+// their dependencies. Only templates producing the final outputs get their own write step. With
+// input slots every slot has its own read step and all processing templates get the slot
+// artifacts. Templates with dependencies get the output of the first one as input files and the
+// output of every dependency as the artifact named after the dependency.
+// Templates are scoped to files by their input filter, a filtered template gets its own read step
+// or, in fan-out mode where file names are not known, runs only in branches of its files.
+func constructDag(
 	conf []config.ProcessingTemplate,
 	worfklowName string,
 	recordId string,
 	workflowId uint64,
-	secretKey string,
-) []*Task {
+	fileIds string,
+	discriminatorSuffix string,
+	fileNames []string,
+	scopes map[string][]int,
+	inputs []inputGroup,
+	parameters map[string]map[string]string,
+) ([]*Task, []Template) {
 	result := []*Task{}
-	deleteDependencies := []string{}
+	wrappers := []Template{}
+
+	var readStep *Task
+	if len(inputs) == 0 {
+		readStep = newReadFilesWorkflow(recordId, workflowId, fileIds)
+		result = append(result, readStep)
+	}
+	slotReads := make(map[string]*Task)
+	for _, input := range inputs {
+		slotReads[input.slot] = newReadInputWorkflow(recordId, workflowId, input.slot, input.files)
+		result = append(result, slotReads[input.slot])
+	}
 
-	readStep := newReadFilesWorkflow(recordId, workflowId)
-	result = append(result, readStep)
 	fullWorkflowName := ConstructFullWorkflowName(worfklowName, recordId, workflowId)
+	writes := writtenTemplates(conf)
+	steps := stepNames(conf)
 
-	// each processing task executed after write task with its own write task
 	for _, cfg := range conf {
-		task := newProcessingStep(recordId, workflowId, readStep.Name, &TemplateReference{
-			Name:     cfg.Name,
-			Template: cfg.Template,
-		})
-		writeTask := newWriteWorkflow(
+		step := steps[cfg.Name]
+		scope := scopes[cfg.Name]
+		if emptyScope(scope) {
+			continue
+		}
+		when := ""
+		if fileNames == nil {
+			when = fanOutWhen(scope)
+			scope = nil
+		}
+
+		previousTasks := []string{}
+		artifacts := []Artifact{}
+		values := map[string]string{}
+		if readStep != nil && len(cfg.DependsOn) == 0 {
+			read := readStep
+			if scope != nil {
+				read = newReadInputWorkflow(
+					recordId,
+					workflowId,
+					"files-"+step,
+					filesInScope(fileNames, scope),
+				)
+				result = append(result, read)
+			}
+			previousTasks = append(previousTasks, read.Name)
+			artifacts = append(artifacts, outputFilesOf(read.Name, "input-files"))
+		}
+		if len(cfg.DependsOn) > 0 {
+			previousTasks = util.Map(cfg.DependsOn, func(dependency string) string {
+				return processingTaskName(recordId, workflowId, steps[dependency])
+			})
+			artifacts = append(artifacts, outputFilesOf(previousTasks[0], "input-files"))
+			for index, dependency := range cfg.DependsOn {
+				artifacts = append(artifacts, outputFilesOf(previousTasks[index], dependency))
+			}
+		}
+		for _, input := range inputs {
+			files := input.files
+			read := slotReads[input.slot]
+			if scope != nil {
+				files = slices.DeleteFunc(slices.Clone(input.files), func(file string) bool {
+					return !slices.Contains(filesInScope(fileNames, scope), file)
+				})
+				if len(files) == 0 {
+					continue
+				}
+				if len(files) < len(input.files) {
+					read = newReadInputWorkflow(recordId, workflowId, input.slot+"-"+step, files)
+					result = append(result, read)
+				}
+			}
+			if len(cfg.DependsOn) == 0 {
+				previousTasks = append(previousTasks, read.Name)
+			}
+			artifacts = append(artifacts, outputFilesOf(read.Name, input.slot))
+			values[input.slot+"-files"] = strings.Join(files, " ")
+		}
+		maps.Copy(values, parameters[cfg.Name])
+
+		task, wrapper := newProcessingStep(
 			recordId,
 			workflowId,
-			task.Name,
-			cfg.Template,
-			fullWorkflowName,
+			step,
+			previousTasks,
+			artifacts,
+			cfg,
+			values,
 		)
-		result = append(result, task, writeTask)
-		deleteDependencies = append(deleteDependencies, writeTask.Name)
+		task.When = when
+		result = append(result, task)
+		if wrapper != nil {
+			wrappers = append(wrappers, *wrapper)
+		}
+
+		if writes[cfg.Name] {
+			write := newWriteWorkflow(
+				recordId,
+				workflowId,
+				task.Name,
+				step,
+				fullWorkflowName,
+				step+discriminatorSuffix,
+			)
+			write.When = when
+			result = append(result, write)
+		}
 	}
 
-	revokeTokenStep := newDeleteWorkflow(
-		recordId,
-		workflowId,
-		fullWorkflowName,
-		deleteDependencies,
-	)
-	result = append(result, revokeTokenStep)
+	return result, wrappers
+}
+
+// Leaf templates and templates marked with write have their outputs uploaded, keyed by the
+// processing template name.
+func writtenTemplates(conf []config.ProcessingTemplate) map[string]bool {
+	dependedOn := make(map[string]bool)
+	for _, cfg := range conf {
+		for _, dependency := range cfg.DependsOn {
+			dependedOn[dependency] = true
+		}
+	}
+
+	result := make(map[string]bool)
+	for _, cfg := range conf {
+		result[cfg.Name] = cfg.Write || !dependedOn[cfg.Name]
+	}
 
 	return result
 }
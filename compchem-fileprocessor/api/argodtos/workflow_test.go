@@ -36,9 +36,12 @@ func TestBuildWorkflow_IntegrationTest(t *testing.T) {
 		baseUrl,
 		workflowName,
 		workflowId,
-		"mysecretkey",
 		recordId,
-		[]string{"test.txt", "test1.txt"},
+		[]config.FileInfo{{Name: "test.txt"}, {Name: "test1.txt"}},
+		nil,
+		nil,
+		"compchem-test",
+		"http://fileprocessor:8062/api",
 	)
 
 	// Assert - JSON serialization
@@ -49,10 +52,20 @@ func TestBuildWorkflow_IntegrationTest(t *testing.T) {
 		"apiVersion": "argoproj.io/v1alpha1",
 		"kind": "Workflow",
 		"metadata": {
-			"name": "read-count-write-12345-2"
+			"name": "read-count-write-12345-2",
+			"labels": {
+				"compchem.cerit.io/record-id": "12345",
+				"compchem.cerit.io/workflow-config": "read-count-write",
+				"compchem.cerit.io/sequence-id": "2",
+				"compchem.cerit.io/instance": "compchem-test"
+			},
+			"annotations": {
+				"compchem.cerit.io/input-files": "[\"test.txt\",\"test1.txt\"]"
+			}
 		},
 		"spec": {
 			"entrypoint": "read-count-write-12345-2",
+			"onExit": "exit-handler-12345-2",
 			"arguments": {
 				"parameters": [
 					{
@@ -64,8 +77,8 @@ func TestBuildWorkflow_IntegrationTest(t *testing.T) {
 						"value": "12345"
 					},
 					{
-						"name": "secret-key",
-						"value": "mysecretkey"
+						"name": "secret-name",
+						"value": "read-count-write-12345-2-key"
 					},
 					{
 						"name": "file-ids",
@@ -96,8 +109,8 @@ func TestBuildWorkflow_IntegrationTest(t *testing.T) {
 											"value": "{{workflow.parameters.record-id}}"
 										},
 										{
-											"name": "secret-key",
-											"value": "{{workflow.parameters.secret-key}}"
+											"name": "secret-name",
+											"value": "{{workflow.parameters.secret-name}}"
 										},
 										{
 											"name": "file-ids",
@@ -142,8 +155,8 @@ func TestBuildWorkflow_IntegrationTest(t *testing.T) {
 											"value": "{{workflow.parameters.record-id}}"
 										},
 										{
-											"name": "secret-key",
-											"value": "{{workflow.parameters.secret-key}}"
+											"name": "secret-name",
+											"value": "{{workflow.parameters.secret-name}}"
 										},
 										{
 											"name": "workflow-name",
@@ -197,8 +210,8 @@ func TestBuildWorkflow_IntegrationTest(t *testing.T) {
 											"value": "{{workflow.parameters.record-id}}"
 										},
 										{
-											"name": "secret-key",
-											"value": "{{workflow.parameters.secret-key}}"
+											"name": "secret-name",
+											"value": "{{workflow.parameters.secret-name}}"
 										},
 										{
 											"name": "workflow-name",
@@ -216,10 +229,58 @@ func TestBuildWorkflow_IntegrationTest(t *testing.T) {
 										}
 									]
 								}
+							}
+						]
+					}
+				},
+				{
+					"name": "exit-handler-12345-2",
+					"dag": {
+						"tasks": [
+							{
+								"name": "report-outcome-12345-2",
+								"dependencies": [],
+								"templateRef": {
+									"name": "report-outcome-template",
+									"template": "report-outcome"
+								},
+								"arguments": {
+									"parameters": [
+										{
+											"name": "callback-url",
+											"value": "http://fileprocessor:8062/api"
+										},
+										{
+											"name": "workflow-name",
+											"value": "read-count-write-12345-2"
+										},
+										{
+											"name": "secret-name",
+											"value": "{{workflow.parameters.secret-name}}"
+										},
+										{
+											"name": "phase",
+											"value": "{{workflow.status}}"
+										},
+										{
+											"name": "failures",
+											"value": "{{workflow.failures}}"
+										},
+										{
+											"name": "outputs",
+											"value": "{{workflow.outputs.parameters.uploaded-files-count-words}} {{workflow.outputs.parameters.uploaded-files-count-words-advanced}}"
+										}
+									],
+									"artifacts": []
+								},
+								"continueOn": {
+									"failed": true,
+									"error": true
+								}
 							},
 							{
 								"name": "delete-context-12345-2",
-								"dependencies": ["write-files-count-words-12345-2", "write-files-count-words-advanced-12345-2"],
+								"dependencies": ["report-outcome-12345-2"],
 								"templateRef": {
 									"name": "delete-context-template",
 									"template": "delete-context"
@@ -235,8 +296,8 @@ func TestBuildWorkflow_IntegrationTest(t *testing.T) {
 											"value": "read-count-write-12345-2"
 										},
 										{
-											"name": "secret-key",
-											"value": "{{workflow.parameters.secret-key}}"
+											"name": "secret-name",
+											"value": "{{workflow.parameters.secret-name}}"
 										}
 									],
 									"artifacts": []
@@ -267,3 +328,286 @@ func TestBuildWorkflow_IntegrationTest(t *testing.T) {
 	// Compare the normalized JSON strings
 	assert.Equal(t, string(expectedNormalized), string(actualNormalized))
 }
+
+func TestConstructDag_ChainedTemplates_DependenciesWiredAndLeafWritten(t *testing.T) {
+	// Arrange
+	processingTemplates := []config.ProcessingTemplate{
+		{Name: "convert-template", Template: "convert"},
+		{Name: "annotate-template", Template: "annotate", DependsOn: []string{"convert-template"}},
+		{
+			Name:      "validate-template",
+			Template:  "validate",
+			DependsOn: []string{"convert-template"},
+			Write:     true,
+		},
+		{
+			Name:      "summarize-template",
+			Template:  "summarize",
+			DependsOn: []string{"annotate-template", "validate-template"},
+		},
+	}
+
+	// Act
+	tasks, _ := constructDag(
+		processingTemplates,
+		"chain",
+		"12345",
+		2,
+		allFileIds,
+		"",
+		[]string{"test.txt"},
+		nil,
+		nil,
+		nil,
+	)
+
+	// Assert
+	byName := map[string]*Task{}
+	for _, task := range tasks {
+		byName[task.Name] = task
+	}
+	assert.Equal(t, 7, len(tasks))
+
+	assert.Equal(t, []string{"read-files-12345-2"}, byName["convert-12345-2"].Dependencies)
+	assert.Equal(t, []string{"convert-12345-2"}, byName["annotate-12345-2"].Dependencies)
+
+	summarize := byName["summarize-12345-2"]
+	assert.Equal(t, []string{"annotate-12345-2", "validate-12345-2"}, summarize.Dependencies)
+	assert.Equal(t, []Artifact{
+		{Name: "input-files", From: "{{tasks.annotate-12345-2.outputs.artifacts.output-files}}"},
+		{Name: "annotate-template", From: "{{tasks.annotate-12345-2.outputs.artifacts.output-files}}"},
+		{Name: "validate-template", From: "{{tasks.validate-12345-2.outputs.artifacts.output-files}}"},
+	}, summarize.Arguments.Artifacts)
+
+	assert.Contains(t, byName, "write-files-summarize-12345-2")
+	assert.Contains(t, byName, "write-files-validate-12345-2")
+	assert.NotContains(t, byName, "write-files-convert-12345-2")
+	assert.NotContains(t, byName, "write-files-annotate-12345-2")
+	assert.Equal(
+		t,
+		[]string{"validate", "summarize"},
+		writeDiscriminators(config.WorkflowConfig{ProcessingTemplates: processingTemplates}, nil, 3),
+	)
+}
+
+func TestConstructDag_InputSlots_ReadPerSlotAndSlotArtifactsPassed(t *testing.T) {
+	// Arrange
+	processingTemplates := []config.ProcessingTemplate{
+		{Name: "rmsd-template", Template: "rmsd"},
+		{Name: "plot-template", Template: "plot", DependsOn: []string{"rmsd-template"}},
+	}
+	inputs := inputGroups(
+		[]config.InputSlot{{Name: "structure"}, {Name: "index"}, {Name: "trajectory"}},
+		map[string][]string{
+			"structure":  {"run.tpr"},
+			"trajectory": {"part1.xtc", "part2.xtc"},
+		},
+	)
+
+	// Act
+	tasks, _ := constructDag(
+		processingTemplates,
+		"gromacs",
+		"12345",
+		2,
+		allFileIds,
+		"",
+		[]string{"run.tpr", "part1.xtc", "part2.xtc"},
+		nil,
+		inputs,
+		nil,
+	)
+
+	// Assert
+	byName := map[string]*Task{}
+	for _, task := range tasks {
+		byName[task.Name] = task
+	}
+	assert.NotContains(t, byName, "read-files-12345-2")
+	assert.NotContains(t, byName, "read-index-12345-2")
+	assert.Equal(t, "part1.xtc part2.xtc", byName["read-trajectory-12345-2"].Arguments.Parameters[3].Value)
+
+	rmsd := byName["rmsd-12345-2"]
+	assert.Equal(t, []string{"read-structure-12345-2", "read-trajectory-12345-2"}, rmsd.Dependencies)
+	assert.Equal(t, []Artifact{
+		{Name: "structure", From: "{{tasks.read-structure-12345-2.outputs.artifacts.output-files}}"},
+		{Name: "trajectory", From: "{{tasks.read-trajectory-12345-2.outputs.artifacts.output-files}}"},
+	}, rmsd.Arguments.Artifacts)
+	assert.Equal(t, []Parameter{
+		{Name: "structure-files", Value: "run.tpr"},
+		{Name: "trajectory-files", Value: "part1.xtc part2.xtc"},
+	}, rmsd.Arguments.Parameters)
+
+	plot := byName["plot-12345-2"]
+	assert.Equal(t, []string{"rmsd-12345-2"}, plot.Dependencies)
+	assert.Equal(t, "input-files", plot.Arguments.Artifacts[0].Name)
+	assert.Equal(t, "{{tasks.rmsd-12345-2.outputs.artifacts.output-files}}", plot.Arguments.Artifacts[0].From)
+	assert.Equal(t, "rmsd-template", plot.Arguments.Artifacts[1].Name)
+	assert.Len(t, plot.Arguments.Artifacts, 4)
+}
+
+func TestConstructDag_SameTemplateInTwoSteps_StepsNamedAfterProcessingTemplates(t *testing.T) {
+	// Arrange
+	processingTemplates := []config.ProcessingTemplate{
+		{Name: "convert-input", Template: "convert"},
+		{Name: "annotate-template", Template: "annotate", DependsOn: []string{"convert-input"}},
+		{Name: "convert-annotated", Template: "convert", DependsOn: []string{"annotate-template"}},
+	}
+
+	// Act
+	tasks, _ := constructDag(
+		processingTemplates,
+		"chain",
+		"12345",
+		2,
+		allFileIds,
+		"",
+		[]string{"test.txt"},
+		nil,
+		nil,
+		nil,
+	)
+
+	// Assert
+	byName := map[string]*Task{}
+	for _, task := range tasks {
+		byName[task.Name] = task
+	}
+	assert.Equal(t, 5, len(tasks))
+	assert.Equal(t, []string{"read-files-12345-2"}, byName["convert-input-12345-2"].Dependencies)
+	assert.Equal(t, []string{"convert-input-12345-2"}, byName["annotate-12345-2"].Dependencies)
+	assert.Equal(t, "convert", byName["convert-annotated-12345-2"].TemplateReference.Template)
+	assert.Equal(t, []string{"annotate-12345-2"}, byName["convert-annotated-12345-2"].Dependencies)
+	assert.Equal(
+		t,
+		[]string{"convert-annotated-12345-2"},
+		byName["write-files-convert-annotated-12345-2"].Dependencies,
+	)
+}
+
+func TestConstructDag_SameTemplateInTwoSteps_ParametersPerProcessingTemplate(t *testing.T) {
+	// Arrange
+	processingTemplates := []config.ProcessingTemplate{
+		{Name: "convert-input", Template: "convert"},
+		{Name: "convert-output", Template: "convert", DependsOn: []string{"convert-input"}},
+	}
+	parameters := map[string]map[string]string{
+		"convert-input":  {"format": "pdb"},
+		"convert-output": {"format": "gro"},
+	}
+
+	// Act
+	tasks, _ := constructDag(
+		processingTemplates,
+		"chain",
+		"12345",
+		2,
+		allFileIds,
+		"",
+		[]string{"test.txt"},
+		nil,
+		nil,
+		parameters,
+	)
+
+	// Assert
+	byName := map[string]*Task{}
+	for _, task := range tasks {
+		byName[task.Name] = task
+	}
+	input := byName["convert-input-12345-2"].Arguments.Parameters
+	output := byName["convert-output-12345-2"].Arguments.Parameters
+	assert.Equal(t, []Parameter{{Name: "format", Value: "pdb"}}, input)
+	assert.Equal(t, []Parameter{{Name: "format", Value: "gro"}}, output)
+}
+
+func TestBuildLabelSelector_LabelsGiven_SelectorNarrowed(t *testing.T) {
+	// Arrange
+	instance := "compchem-test"
+
+	// Act
+	instanceOnly := BuildLabelSelector(instance)
+	narrowed := BuildLabelSelector(instance, LabelRecordId, "ew6jd-p8175", LabelSequenceId, "9")
+
+	// Assert
+	assert.Equal(t, "compchem.cerit.io/instance=compchem-test", instanceOnly)
+	assert.Equal(
+		t,
+		"compchem.cerit.io/instance=compchem-test,compchem.cerit.io/record-id=ew6jd-p8175,compchem.cerit.io/sequence-id=9",
+		narrowed,
+	)
+}
+
+func TestBuildWorkflow_SpecOptionsConfigured_OptionsEmitted(t *testing.T) {
+	// Arrange
+	priority := int32(5)
+	ttl := int32(3600)
+	conf := config.WorkflowConfig{
+		ProcessingTemplates: []config.ProcessingTemplate{
+			{Name: "count-words-template", Template: "count-words"},
+		},
+		Spec: config.WorkflowSpec{
+			Namespace:          "compchem-gpu",
+			ServiceAccountName: "compchem-workflow",
+			Priority:           &priority,
+			Parallelism:        3,
+			TTLStrategy:        &config.TTLStrategy{SecondsAfterCompletion: &ttl},
+			PodGC:              &config.PodGC{Strategy: "OnPodSuccess", DeleteDelayDuration: "30s"},
+		},
+	}
+
+	// Act
+	workflow := BuildWorkflow(
+		conf,
+		"https://localhost:5000",
+		"count-words",
+		1,
+		"12345",
+		[]config.FileInfo{{Name: "test.txt"}},
+		nil,
+		nil,
+		"compchem-test",
+		"",
+	)
+	specJson, err := json.Marshal(workflow.Spec)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "compchem-gpu", workflow.Metadata.Namespace)
+
+	var spec map[string]any
+	assert.NoError(t, json.Unmarshal(specJson, &spec))
+	assert.Equal(t, "compchem-workflow", spec["serviceAccountName"])
+	assert.Equal(t, float64(5), spec["priority"])
+	assert.Equal(t, float64(3), spec["parallelism"])
+	assert.Equal(t, map[string]any{"secondsAfterCompletion": float64(3600)}, spec["ttlStrategy"])
+	assert.Equal(
+		t,
+		map[string]any{"strategy": "OnPodSuccess", "deleteDelayDuration": "30s"},
+		spec["podGC"],
+	)
+}
+
+func TestGetParameter_ParameterPresent_ValueReturned(t *testing.T) {
+	// Arrange
+	workflow := BuildWorkflow(
+		config.WorkflowConfig{},
+		"https://localhost:5000",
+		"count-words",
+		1,
+		"12345",
+		[]config.FileInfo{{Name: "test.txt"}},
+		nil,
+		nil,
+		"compchem-test",
+		"",
+	)
+
+	// Act
+	secretName := workflow.GetParameter("secret-name")
+	missing := workflow.GetParameter("does-not-exist")
+
+	// Assert
+	assert.Equal(t, "count-words-12345-1-key", secretName)
+	assert.Empty(t, missing)
+}
@@ -10,6 +10,7 @@ func newWriteWorkflow(
 	previousTaskFullName string,
 	previousTaskTemplateName string,
 	workflowFullName string,
+	taskDiscriminator string,
 ) *Task {
 	return &Task{
 		Name: fmt.Sprintf(
@@ -19,7 +20,7 @@ func newWriteWorkflow(
 			workflowId,
 		),
 		Dependencies: []string{previousTaskFullName},
-		TemplateReference: TemplateReference{
+		TemplateReference: &TemplateReference{
 			Name:     "write-files-template",
 			Template: "write-files",
 		},
@@ -34,8 +35,8 @@ func newWriteWorkflow(
 					Value: "{{workflow.parameters.record-id}}",
 				},
 				{
-					Name:  "secret-key",
-					Value: "{{workflow.parameters.secret-key}}",
+					Name:  "secret-name",
+					Value: "{{workflow.parameters.secret-name}}",
 				},
 				{
 					Name:  "workflow-name",
@@ -43,7 +44,7 @@ func newWriteWorkflow(
 				},
 				{
 					Name:  "task-discriminator",
-					Value: previousTaskTemplateName,
+					Value: taskDiscriminator,
 				},
 			},
 			Artifacts: []Artifact{
@@ -21,7 +21,14 @@ func TestWriteFiles_AllArgumentsSupplied_ProperlyFormedTask(t *testing.T) {
 	expectedTemplateRefTemplate := "write-files"
 
 	// Act
-	task := newWriteWorkflow(recordId, workflowId, previousTask, previousTaskTemplate, previousTask)
+	task := newWriteWorkflow(
+		recordId,
+		workflowId,
+		previousTask,
+		previousTaskTemplate,
+		previousTask,
+		previousTaskTemplate,
+	)
 
 	// Assert
 	assert.Equal(t, expectedName, task.Name)
@@ -39,8 +46,8 @@ func TestWriteFiles_AllArgumentsSupplied_ProperlyFormedTask(t *testing.T) {
 	assert.Equal(t, "record-id", task.Arguments.Parameters[1].Name)
 	assert.Equal(t, "{{workflow.parameters.record-id}}", task.Arguments.Parameters[1].Value)
 
-	assert.Equal(t, "secret-key", task.Arguments.Parameters[2].Name)
-	assert.Equal(t, "{{workflow.parameters.secret-key}}", task.Arguments.Parameters[2].Value)
+	assert.Equal(t, "secret-name", task.Arguments.Parameters[2].Name)
+	assert.Equal(t, "{{workflow.parameters.secret-name}}", task.Arguments.Parameters[2].Value)
 
 	assert.Equal(t, "workflow-name", task.Arguments.Parameters[3].Name)
 	assert.Equal(t, "count-words-12345-2", task.Arguments.Parameters[3].Value)
@@ -64,7 +71,14 @@ func TestWriteFiles_AllArgumentsSupplied_ProperlyFormedJson(t *testing.T) {
 	workflowId := uint64(2)
 	previousTaskTemplate := "count-words"
 	previousTask := "count-words-12345-2"
-	task := newWriteWorkflow(recordId, workflowId, previousTask, previousTaskTemplate, previousTask)
+	task := newWriteWorkflow(
+		recordId,
+		workflowId,
+		previousTask,
+		previousTaskTemplate,
+		previousTask,
+		previousTaskTemplate,
+	)
 
 	// Act
 	taskJson, err := json.Marshal(task)
@@ -91,8 +105,8 @@ func TestWriteFiles_AllArgumentsSupplied_ProperlyFormedJson(t *testing.T) {
 					"value": "{{workflow.parameters.record-id}}"
 				},
 				{
-					"name": "secret-key",
-					"value": "{{workflow.parameters.secret-key}}"
+					"name": "secret-name",
+					"value": "{{workflow.parameters.secret-name}}"
 				},
 				{
 					"name": "workflow-name",
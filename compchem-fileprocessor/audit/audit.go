@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// Principal without credentials, e.g. workflows reporting their outcome.
+const AnonymousSubject = "anonymous"
+
+// Facts of a mutating request which only middleware and handlers further down learn, the audit
+// middleware writes them to the audit log once the request was answered.
+type Trail struct {
+	mu        sync.Mutex
+	subject   string
+	workflows []string
+}
+
+func NewTrail() *Trail {
+	return &Trail{subject: AnonymousSubject}
+}
+
+func (t *Trail) Subject() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.subject
+}
+
+// Workflows the request acted on in the order they were noted.
+func (t *Trail) Workflows() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return slices.Clone(t.workflows)
+}
+
+type trailKey struct{}
+
+type requestIdKey struct{}
+
+type sourceIpKey struct{}
+
+func WithTrail(ctx context.Context, trail *Trail) context.Context {
+	return context.WithValue(ctx, trailKey{}, trail)
+}
+
+// Notes the authenticated principal, requests outside the audit middleware are ignored.
+func NoteSubject(ctx context.Context, subject string) {
+	if trail, ok := ctx.Value(trailKey{}).(*Trail); ok {
+		trail.mu.Lock()
+		defer trail.mu.Unlock()
+		trail.subject = subject
+	}
+}
+
+// Notes workflows the request created or changed, names noted before are skipped.
+func NoteWorkflows(ctx context.Context, names ...string) {
+	trail, ok := ctx.Value(trailKey{}).(*Trail)
+	if !ok {
+		return
+	}
+
+	trail.mu.Lock()
+	defer trail.mu.Unlock()
+	for _, name := range names {
+		if name != "" && !slices.Contains(trail.workflows, name) {
+			trail.workflows = append(trail.workflows, name)
+		}
+	}
+}
+
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, requestId)
+}
+
+// Empty outside of requests.
+func RequestId(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIdKey{}).(string)
+	return requestId
+}
+
+func WithSourceIp(ctx context.Context, sourceIp string) context.Context {
+	return context.WithValue(ctx, sourceIpKey{}, sourceIp)
+}
+
+// Empty outside of requests.
+func SourceIp(ctx context.Context) string {
+	sourceIp, _ := ctx.Value(sourceIpKey{}).(string)
+	return sourceIp
+}
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/util"
+)
+
+const ApiKeyHeader = "X-Api-Key"
+
+type ApiKeyAuthenticator struct {
+	keys []config.ApiKeyConfig
+}
+
+func NewApiKeyAuthenticator(keys []config.ApiKeyConfig) *ApiKeyAuthenticator {
+	normalized := make([]config.ApiKeyConfig, len(keys))
+	for i, key := range keys {
+		key.KeyHash = strings.ToLower(key.KeyHash)
+		normalized[i] = key
+	}
+
+	return &ApiKeyAuthenticator{keys: normalized}
+}
+
+func (a *ApiKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get(ApiKeyHeader)
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+
+	for _, configured := range a.keys {
+		if util.SecretKeyMatches(key, configured.KeyHash) {
+			return &Principal{Subject: "api-key:" + configured.Name, Scopes: configured.Scopes}, nil
+		}
+	}
+
+	return nil, ErrInvalidCredentials
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiKeyAuthenticate_ConfiguredKey_ScopesOfKeyGranted(t *testing.T) {
+	authenticator := NewApiKeyAuthenticator([]config.ApiKeyConfig{
+		{
+			Name:    "compchem",
+			KeyHash: strings.ToUpper(util.HashSecretKey("compchem-key")),
+			Scopes:  []config.Scope{config.ScopeStart, config.ScopeList},
+		},
+	})
+	request := func(key string) *Principal {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set(ApiKeyHeader, key)
+		principal, err := authenticator.Authenticate(r)
+		if key == "compchem-key" {
+			assert.NoError(t, err)
+		} else if key == "" {
+			assert.ErrorIs(t, err, ErrNoCredentials)
+		} else {
+			assert.ErrorIs(t, err, ErrInvalidCredentials)
+		}
+		return principal
+	}
+
+	principal := request("compchem-key")
+	assert.Equal(t, "api-key:compchem", principal.Subject)
+	assert.True(t, principal.HasScope(config.ScopeStart))
+	assert.False(t, principal.HasScope(config.ScopeAdmin))
+
+	assert.Nil(t, request("other-key"))
+	assert.Nil(t, request(""))
+}
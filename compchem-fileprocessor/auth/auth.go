@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrNoCredentials      = errors.New("no credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Caller of the api, subject identifies the api key or the token subject. Credentials are the
+// bearer token and cookies of the request, compchem evaluates permissions of the caller with them.
+// Api keys are only known to the fileprocessor and never part of them.
+type Principal struct {
+	Subject     string
+	Scopes      []config.Scope
+	Credentials Credentials
+}
+
+type Credentials struct {
+	BearerToken string
+	Cookie      string
+}
+
+func credentialsOf(r *http.Request) Credentials {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		token = ""
+	}
+
+	return Credentials{BearerToken: token, Cookie: r.Header.Get("Cookie")}
+}
+
+func (p *Principal) HasScope(scope config.Scope) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// Verifies credentials of one kind, ErrNoCredentials is returned when the request carries none of
+// them so that the next authenticator can be tried.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Authenticators of the auth config, empty when authentication is disabled.
+func NewAuthenticators(logger *zap.Logger, auth config.AuthConfig) ([]Authenticator, error) {
+	authenticators := []Authenticator{}
+	if len(auth.ApiKeys) > 0 {
+		authenticators = append(authenticators, NewApiKeyAuthenticator(auth.ApiKeys))
+	}
+	if auth.Oidc != nil {
+		jwt, err := NewJwtAuthenticator(logger, *auth.Oidc)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, jwt)
+	}
+
+	return authenticators, nil
+}
+
+// Returns the principal of the first authenticator the request has credentials for.
+func Authenticate(r *http.Request, authenticators []Authenticator) (*Principal, error) {
+	for _, authenticator := range authenticators {
+		principal, err := authenticator.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		principal.Credentials = credentialsOf(r)
+		return principal, nil
+	}
+
+	return nil, ErrNoCredentials
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// Nil when the request was not authenticated.
+func PrincipalFrom(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
@@ -0,0 +1,24 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticate_ApiKeyWithCookie_CookieKeptKeyNotForwarded(t *testing.T) {
+	authenticators := []Authenticator{NewApiKeyAuthenticator([]config.ApiKeyConfig{
+		{Name: "compchem", KeyHash: util.HashSecretKey("compchem-key")},
+	})}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set(ApiKeyHeader, "compchem-key")
+	r.Header.Set("Cookie", "session=abc")
+
+	principal, err := Authenticate(r, authenticators)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Credentials{Cookie: "session=abc"}, principal.Credentials)
+}
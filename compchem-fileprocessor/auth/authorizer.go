@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/util"
+	"go.uber.org/zap"
+)
+
+// Outcome of a record check, source names the authorizer which made the decision.
+type Decision struct {
+	Allowed bool
+	Reason  string
+	Source  string
+	Cached  bool
+}
+
+// Decides whether the principal may act on the record, errors are returned when no decision could
+// be made, e.g. compchem is unreachable.
+type Authorizer interface {
+	Authorize(
+		ctx context.Context,
+		principal *Principal,
+		recordId string,
+		action config.RecordAction,
+	) (Decision, error)
+}
+
+// Authorizer of the auth config wrapped in a decision cache, nil when records are not authorized.
+func NewAuthorizer(logger *zap.Logger, auth config.AuthConfig, compchemUrl string) Authorizer {
+	if auth.Authorization == nil {
+		return nil
+	}
+
+	var authorizer Authorizer
+	switch auth.Authorization.Mode {
+	case config.AuthorizationPolicy:
+		authorizer = NewPolicyAuthorizer(auth.Authorization.Policy)
+	default:
+		authorizer = NewCompchemAuthorizer(logger, compchemUrl)
+	}
+
+	ttl := auth.Authorization.CacheTtl()
+	if ttl <= 0 {
+		return authorizer
+	}
+
+	return NewCachingAuthorizer(authorizer, ttl)
+}
+
+// Compchem decides with the forwarded credentials of the caller, a subject relaying requests of
+// several users gets a decision per user.
+type decisionKey struct {
+	subject     string
+	credentials string
+	recordId    string
+	action      config.RecordAction
+}
+
+type cachedDecision struct {
+	decision  Decision
+	expiresAt time.Time
+}
+
+// Keeps decisions for the ttl so that listing and polling a record does not ask compchem on every
+// request, failed checks are not cached.
+type CachingAuthorizer struct {
+	next      Authorizer
+	ttl       time.Duration
+	now       func() time.Time
+	mu        sync.Mutex
+	decisions map[decisionKey]cachedDecision
+}
+
+func NewCachingAuthorizer(next Authorizer, ttl time.Duration) *CachingAuthorizer {
+	return &CachingAuthorizer{
+		next:      next,
+		ttl:       ttl,
+		now:       time.Now,
+		decisions: make(map[decisionKey]cachedDecision),
+	}
+}
+
+func (c *CachingAuthorizer) Authorize(
+	ctx context.Context,
+	principal *Principal,
+	recordId string,
+	action config.RecordAction,
+) (Decision, error) {
+	key := decisionKey{
+		subject:     principal.Subject,
+		credentials: credentialsHash(principal.Credentials),
+		recordId:    recordId,
+		action:      action,
+	}
+
+	c.mu.Lock()
+	cached, present := c.decisions[key]
+	c.mu.Unlock()
+	if present && c.now().Before(cached.expiresAt) {
+		decision := cached.decision
+		decision.Cached = true
+		return decision, nil
+	}
+
+	decision, err := c.next.Authorize(ctx, principal, recordId, action)
+	if err != nil {
+		return decision, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for cachedKey, entry := range c.decisions {
+		if !now.Before(entry.expiresAt) {
+			delete(c.decisions, cachedKey)
+		}
+	}
+	c.decisions[key] = cachedDecision{decision: decision, expiresAt: now.Add(c.ttl)}
+
+	return decision, nil
+}
+
+// Only the hash of the credentials is kept in the cache.
+func credentialsHash(credentials Credentials) string {
+	if credentials == (Credentials{}) {
+		return ""
+	}
+
+	return util.HashSecretKey(credentials.BearerToken + "\n" + credentials.Cookie)
+}
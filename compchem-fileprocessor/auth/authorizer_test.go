@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type countingAuthorizer struct {
+	calls    int
+	decision Decision
+	err      error
+}
+
+func (c *countingAuthorizer) Authorize(
+	ctx context.Context,
+	principal *Principal,
+	recordId string,
+	action config.RecordAction,
+) (Decision, error) {
+	c.calls++
+	return c.decision, c.err
+}
+
+func TestPolicyAuthorize_MatchingRule_Allowed(t *testing.T) {
+	authorizer := NewPolicyAuthorizer([]config.PolicyRule{
+		{
+			Subjects: []string{"api-key:compchem"},
+			Records:  []string{"*"},
+			Actions:  []config.RecordAction{config.ActionRead, config.ActionProcess},
+		},
+		{
+			Subjects: []string{"oidc:*"},
+			Records:  []string{"public-*"},
+			Actions:  []config.RecordAction{config.ActionRead},
+		},
+	})
+	authorize := func(subject string, recordId string, action config.RecordAction) Decision {
+		decision, err := authorizer.Authorize(
+			context.Background(),
+			&Principal{Subject: subject},
+			recordId,
+			action,
+		)
+		assert.NoError(t, err)
+		return decision
+	}
+
+	assert.Equal(t, Decision{
+		Allowed: true,
+		Reason:  "allowed by policy rule 1",
+		Source:  "policy",
+	}, authorize("oidc:user-1", "public-1234", config.ActionRead))
+	assert.True(t, authorize("api-key:compchem", "abcd-1234", config.ActionProcess).Allowed)
+	assert.False(t, authorize("oidc:user-1", "public-1234", config.ActionProcess).Allowed)
+	assert.False(t, authorize("oidc:user-1", "abcd-1234", config.ActionRead).Allowed)
+}
+
+func TestCachingAuthorize_RepeatedRequest_DecisionCachedUntilExpired(t *testing.T) {
+	// Arrange
+	next := &countingAuthorizer{decision: Decision{Allowed: true, Source: "compchem"}}
+	authorizer := NewCachingAuthorizer(next, time.Minute)
+	now := time.Now()
+	authorizer.now = func() time.Time { return now }
+	principal := &Principal{Subject: "oidc:user-1"}
+	ctx := context.Background()
+
+	// Act
+	first, _ := authorizer.Authorize(ctx, principal, "abcd-1234", config.ActionRead)
+	cached, _ := authorizer.Authorize(ctx, principal, "abcd-1234", config.ActionRead)
+	authorizer.Authorize(ctx, principal, "abcd-1234", config.ActionProcess)
+	now = now.Add(2 * time.Minute)
+	expired, _ := authorizer.Authorize(ctx, principal, "abcd-1234", config.ActionRead)
+
+	// Assert
+	assert.False(t, first.Cached)
+	assert.True(t, cached.Cached)
+	assert.True(t, cached.Allowed)
+	assert.False(t, expired.Cached)
+	assert.Equal(t, 3, next.calls)
+}
+
+func TestCachingAuthorize_RelayedUsers_DecisionPerCredentials(t *testing.T) {
+	// Arrange
+	next := &countingAuthorizer{decision: Decision{Allowed: true, Source: "compchem"}}
+	authorizer := NewCachingAuthorizer(next, time.Minute)
+	ctx := context.Background()
+	principalWith := func(cookie string) *Principal {
+		return &Principal{Subject: "api-key:compchem", Credentials: Credentials{Cookie: cookie}}
+	}
+
+	// Act
+	first, _ := authorizer.Authorize(ctx, principalWith("session=abc"), "abcd-1234", config.ActionRead)
+	other, _ := authorizer.Authorize(ctx, principalWith("session=def"), "abcd-1234", config.ActionRead)
+	cached, _ := authorizer.Authorize(ctx, principalWith("session=abc"), "abcd-1234", config.ActionRead)
+
+	// Assert
+	assert.False(t, first.Cached)
+	assert.False(t, other.Cached)
+	assert.True(t, cached.Cached)
+	assert.Equal(t, 2, next.calls)
+}
+
+func TestCachingAuthorize_CheckFailed_NotCached(t *testing.T) {
+	next := &countingAuthorizer{err: errors.New("compchem unreachable")}
+	authorizer := NewCachingAuthorizer(next, time.Minute)
+	principal := &Principal{Subject: "oidc:user-1"}
+
+	for range 2 {
+		_, err := authorizer.Authorize(context.Background(), principal, "abcd-1234", config.ActionRead)
+		assert.Error(t, err)
+	}
+
+	assert.Equal(t, 2, next.calls)
+}
+
+func TestCompchemAuthorize_PermissionsOfRecord_DecisionOfCompchem(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "oidc:user-1", r.URL.Query().Get("subject"))
+		assert.Equal(t, "process", r.URL.Query().Get("action"))
+
+		switch r.URL.Path {
+		case "/api/experiments/abcd-1234/permissions":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"allowed": true}`))
+		case "/api/experiments/efgh-5678/permissions":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	authorizer := NewCompchemAuthorizer(zap.NewNop(), server.URL+"/api")
+	principal := &Principal{Subject: "oidc:user-1"}
+
+	allowed, err := authorizer.Authorize(
+		context.Background(),
+		principal,
+		"abcd-1234",
+		config.ActionProcess,
+	)
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		Decision{Allowed: true, Reason: "allowed by compchem", Source: "compchem"},
+		allowed,
+	)
+
+	denied, err := authorizer.Authorize(
+		context.Background(),
+		principal,
+		"efgh-5678",
+		config.ActionProcess,
+	)
+	assert.NoError(t, err)
+	assert.False(t, denied.Allowed)
+	assert.Equal(t, "compchem answered 403", denied.Reason)
+}
+
+func TestCompchemAuthorize_CallerCredentials_ForwardedToCompchem(t *testing.T) {
+	// Arrange
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/api/experiments/abcd-1234/permissions", r.URL.Path)
+		assert.Equal(t, "action=read&subject=oidc%3Auser-1", r.URL.RawQuery)
+		assert.Equal(t, "Bearer user-token", r.Header.Get("Authorization"))
+		assert.Equal(t, "session=abc", r.Header.Get("Cookie"))
+		assert.Empty(t, r.Header.Get(ApiKeyHeader))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"allowed": false, "reason": "not an owner"}`))
+	}))
+	defer server.Close()
+	authorizer := NewCompchemAuthorizer(zap.NewNop(), server.URL+"/api")
+	principal := &Principal{
+		Subject:     "oidc:user-1",
+		Credentials: Credentials{BearerToken: "user-token", Cookie: "session=abc"},
+	}
+
+	// Act
+	decision, err := authorizer.Authorize(
+		context.Background(),
+		principal,
+		"abcd-1234",
+		config.ActionRead,
+	)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, requests)
+	assert.Equal(
+		t,
+		Decision{Allowed: false, Reason: "not an owner", Source: "compchem"},
+		decision,
+	)
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/httpclient"
+	"go.uber.org/zap"
+)
+
+const compchemSource = "compchem"
+
+type permissionResponse struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason"`
+}
+
+// Asks compchem whether the subject may act on the record, the credentials of the caller are
+// forwarded so compchem evaluates the permissions of that user. Compchem answers 404 for records it
+// does not know and 403 when it refuses to tell, both deny the request.
+type CompchemAuthorizer struct {
+	logger      *zap.Logger
+	compchemUrl string
+}
+
+func NewCompchemAuthorizer(logger *zap.Logger, compchemUrl string) *CompchemAuthorizer {
+	return &CompchemAuthorizer{logger: logger, compchemUrl: compchemUrl}
+}
+
+func (c *CompchemAuthorizer) Authorize(
+	ctx context.Context,
+	principal *Principal,
+	recordId string,
+	action config.RecordAction,
+) (Decision, error) {
+	params := url.Values{}
+	params.Set("subject", principal.Subject)
+	params.Set("action", string(action))
+	permissionsUrl := fmt.Sprintf(
+		"%s/experiments/%s/permissions?%s",
+		c.compchemUrl,
+		url.PathEscape(recordId),
+		params.Encode(),
+	)
+
+	headers := make(map[string]string)
+	if principal.Credentials.BearerToken != "" {
+		headers["Authorization"] = "Bearer " + principal.Credentials.BearerToken
+	}
+	if principal.Credentials.Cookie != "" {
+		headers["Cookie"] = principal.Credentials.Cookie
+	}
+
+	response, err := httpclient.GetRequestWithHeaders[permissionResponse](
+		ctx,
+		c.logger,
+		permissionsUrl,
+		headers,
+		true,
+	)
+	var clientError *httpclient.ClientError
+	if errors.As(err, &clientError) &&
+		(clientError.Status == http.StatusNotFound || clientError.Status == http.StatusForbidden) {
+		return Decision{
+			Allowed: false,
+			Reason:  fmt.Sprintf("compchem answered %d", clientError.Status),
+			Source:  compchemSource,
+		}, nil
+	} else if err != nil {
+		return Decision{}, fmt.Errorf("Error when checking permissions of record: %v", err)
+	}
+
+	reason := response.Reason
+	if reason == "" && response.Allowed {
+		reason = "allowed by compchem"
+	} else if reason == "" {
+		reason = "denied by compchem"
+	}
+
+	return Decision{Allowed: response.Allowed, Reason: reason, Source: compchemSource}, nil
+}
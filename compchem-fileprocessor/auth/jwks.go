@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"go.uber.org/zap"
+)
+
+// Unknown keys trigger reading the file again at most this often, so rotated keys are picked up
+// without letting forged key ids hammer the file.
+const jwksReloadInterval = time.Minute
+
+// Signing keys of a jwks file, safe for concurrent use.
+type JwksFile struct {
+	path     string
+	logger   *zap.Logger
+	mu       sync.Mutex
+	keys     map[string]jose.JSONWebKey
+	loadedAt time.Time
+}
+
+func LoadJwks(logger *zap.Logger, path string) (*JwksFile, error) {
+	file := &JwksFile{path: path, logger: logger}
+	if err := file.load(); err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// Returns the key of the id, a token without key id may use the only key of the file.
+func (f *JwksFile) Key(kid string) (*jose.JSONWebKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if key, found := f.find(kid); found {
+		return key, nil
+	}
+	if time.Since(f.loadedAt) >= jwksReloadInterval {
+		if err := f.load(); err != nil {
+			f.logger.Error("Failed to reload jwks file", zap.String("path", f.path), zap.Error(err))
+		} else if key, found := f.find(kid); found {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (f *JwksFile) find(kid string) (*jose.JSONWebKey, bool) {
+	if kid == "" && len(f.keys) == 1 {
+		for _, key := range f.keys {
+			return &key, true
+		}
+	}
+	key, found := f.keys[kid]
+	return &key, found
+}
+
+func (f *JwksFile) load() error {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return fmt.Errorf("Error when reading jwks file: %v", err)
+	}
+
+	keys, err := parseJwks(data)
+	if err != nil {
+		return err
+	}
+
+	f.keys = keys
+	f.loadedAt = time.Now()
+	return nil
+}
+
+// Keys not meant for signatures and key types other than RSA and EC are skipped.
+func parseJwks(data []byte) (map[string]jose.JSONWebKey, error) {
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("Error when decoding jwks: %v", err)
+	}
+
+	keys := make(map[string]jose.JSONWebKey)
+	for _, raw := range set.Keys {
+		// keys other than signing keys may use members go-jose does not decode
+		var member struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+		}
+		if err := json.Unmarshal(raw, &member); err != nil {
+			return nil, fmt.Errorf("Error when decoding jwks: %v", err)
+		}
+		if member.Use != "" && member.Use != "sig" || member.Kty != "RSA" && member.Kty != "EC" {
+			continue
+		}
+
+		var key jose.JSONWebKey
+		if err := key.UnmarshalJSON(raw); err != nil {
+			return nil, fmt.Errorf("Error when decoding key %q: %v", member.Kid, err)
+		}
+		keys[key.KeyID] = key.Public()
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("jwks has no signing keys")
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"go.uber.org/zap"
+)
+
+// Tolerated clock skew between the issuer and this service.
+const clockLeeway = time.Minute
+
+const defaultScopesClaim = "scope"
+
+// Only asymmetric algorithms are accepted, the keys come from the jwks of the issuer.
+var signatureAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+}
+
+type JwtAuthenticator struct {
+	oidc config.OidcConfig
+	keys *JwksFile
+	now  func() time.Time
+}
+
+func NewJwtAuthenticator(logger *zap.Logger, oidc config.OidcConfig) (*JwtAuthenticator, error) {
+	keys, err := LoadJwks(logger, oidc.JwksFile)
+	if err != nil {
+		return nil, err
+	}
+	if oidc.ScopesClaim == "" {
+		oidc.ScopesClaim = defaultScopesClaim
+	}
+
+	return &JwtAuthenticator{oidc: oidc, keys: keys, now: time.Now}, nil
+}
+
+func (a *JwtAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || token == "" {
+		return nil, ErrNoCredentials
+	}
+
+	principal, err := a.verify(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	return principal, nil
+}
+
+func (a *JwtAuthenticator) verify(token string) (*Principal, error) {
+	parsed, err := jwt.ParseSigned(token, signatureAlgorithms)
+	if err != nil {
+		return nil, fmt.Errorf("malformed token: %v", err)
+	}
+	header := parsed.Headers[0]
+	key, err := a.keys.Key(header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if key.Algorithm != "" && key.Algorithm != header.Algorithm {
+		return nil, fmt.Errorf("key does not match algorithm %s", header.Algorithm)
+	}
+
+	var claims jwt.Claims
+	var rawClaims map[string]any
+	if err := parsed.Claims(key.Key, &claims, &rawClaims); err != nil {
+		return nil, claimsError(err)
+	}
+	if claims.Expiry == nil {
+		return nil, errors.New("token does not expire")
+	}
+	expected := jwt.Expected{
+		Issuer:      a.oidc.Issuer,
+		AnyAudience: jwt.Audience{a.oidc.Audience},
+		Time:        a.now(),
+	}
+	if err := claims.ValidateWithLeeway(expected, clockLeeway); err != nil {
+		return nil, claimsError(err)
+	}
+
+	return &Principal{
+		Subject: "oidc:" + claims.Subject,
+		Scopes:  a.scopesOf(rawClaims[a.oidc.ScopesClaim]),
+	}, nil
+}
+
+// Errors of go-jose in the wording of the rest of the service.
+func claimsError(err error) error {
+	switch {
+	case errors.Is(err, jose.ErrCryptoFailure):
+		return errors.New("invalid signature")
+	case errors.Is(err, jwt.ErrExpired):
+		return errors.New("token expired")
+	case errors.Is(err, jwt.ErrNotValidYet), errors.Is(err, jwt.ErrIssuedInTheFuture):
+		return errors.New("token is not valid yet")
+	case errors.Is(err, jwt.ErrInvalidIssuer), errors.Is(err, jwt.ErrInvalidAudience):
+		return errors.New("token was not issued for this service")
+	default:
+		return fmt.Errorf("malformed claims: %v", err)
+	}
+}
+
+// Scopes of the api in the claim, other scopes of the token are ignored. The claim is either a
+// space separated string or a list.
+func (a *JwtAuthenticator) scopesOf(claim any) []config.Scope {
+	var names []string
+	switch claim := claim.(type) {
+	case string:
+		names = strings.Fields(claim)
+	case []any:
+		for _, name := range claim {
+			if name, ok := name.(string); ok {
+				names = append(names, name)
+			}
+		}
+	default:
+		return nil
+	}
+
+	scopes := []config.Scope{}
+	for _, name := range names {
+		name, found := strings.CutPrefix(name, a.oidc.ScopePrefix)
+		if !found {
+			continue
+		}
+		if scope, known := config.ParseScope(name); known {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return scopes
+}
@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func encodeSegment(t *testing.T, value any) string {
+	data, err := json.Marshal(value)
+	assert.NoError(t, err)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func signToken(
+	t *testing.T,
+	alg jose.SignatureAlgorithm,
+	key any,
+	kid string,
+	claims map[string]any,
+) string {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: alg, Key: jose.JSONWebKey{Key: key, KeyID: kid}},
+		nil,
+	)
+	assert.NoError(t, err)
+	token, err := jwt.Signed(signer).Claims(claims).Serialize()
+	assert.NoError(t, err)
+
+	return token
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	return signToken(t, jose.RS256, key, kid, claims)
+}
+
+func signES256(t *testing.T, key *ecdsa.PrivateKey, kid string, claims map[string]any) string {
+	return signToken(t, jose.ES256, key, kid, claims)
+}
+
+func writeJwks(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) string {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	encode := base64.RawURLEncoding.EncodeToString
+	jwks := map[string]any{"keys": []map[string]string{
+		{
+			"kty": "RSA",
+			"kid": "rsa-1",
+			"use": "sig",
+			"n":   encode(rsaKey.N.Bytes()),
+			"e":   encode(big.NewInt(int64(rsaKey.E)).Bytes()),
+		},
+		{
+			"kty": "EC",
+			"kid": "ec-1",
+			"crv": "P-256",
+			"x":   encode(ecKey.X.FillBytes(make([]byte, 32))),
+			"y":   encode(ecKey.Y.FillBytes(make([]byte, 32))),
+		},
+		{"kty": "RSA", "kid": "enc-1", "use": "enc"},
+	}}
+	data, err := json.Marshal(jwks)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path, data, 0644))
+
+	return path
+}
+
+type jwtFixture struct {
+	authenticator *JwtAuthenticator
+	rsaKey        *rsa.PrivateKey
+	ecKey         *ecdsa.PrivateKey
+}
+
+func newJwtFixture(t *testing.T) jwtFixture {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	authenticator, err := NewJwtAuthenticator(zap.NewNop(), config.OidcConfig{
+		Issuer:      "https://login.example.org",
+		Audience:    "compchem-fileprocessor",
+		JwksFile:    writeJwks(t, rsaKey, ecKey),
+		ScopePrefix: "fileprocessor:",
+	})
+	assert.NoError(t, err)
+
+	return jwtFixture{authenticator: authenticator, rsaKey: rsaKey, ecKey: ecKey}
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"iss":   "https://login.example.org",
+		"sub":   "user-1",
+		"aud":   []string{"compchem-fileprocessor", "other"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "openid fileprocessor:start fileprocessor:list list",
+	}
+}
+
+func authenticateBearer(authenticator Authenticator, token string) (*Principal, error) {
+	r := httptest.NewRequest("GET", "/", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return authenticator.Authenticate(r)
+}
+
+func TestJwtAuthenticate_ValidTokens_ScopesWithPrefixGranted(t *testing.T) {
+	// Arrange
+	fixture := newJwtFixture(t)
+	claims := validClaims()
+	esClaims := validClaims()
+	esClaims["aud"] = "compchem-fileprocessor"
+	esClaims["scope"] = []string{"fileprocessor:admin"}
+
+	// Act
+	principal, err := authenticateBearer(
+		fixture.authenticator,
+		signRS256(t, fixture.rsaKey, "rsa-1", claims),
+	)
+	esPrincipal, esErr := authenticateBearer(
+		fixture.authenticator,
+		signES256(t, fixture.ecKey, "ec-1", esClaims),
+	)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "oidc:user-1", principal.Subject)
+	assert.Equal(t, []config.Scope{config.ScopeStart, config.ScopeList}, principal.Scopes)
+	assert.NoError(t, esErr)
+	assert.Equal(t, []config.Scope{config.ScopeAdmin}, esPrincipal.Scopes)
+}
+
+func TestJwtAuthenticate_InvalidTokens_Rejected(t *testing.T) {
+	fixture := newJwtFixture(t)
+	sign := func(change func(claims map[string]any)) string {
+		claims := validClaims()
+		change(claims)
+		return signRS256(t, fixture.rsaKey, "rsa-1", claims)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	unsigned := encodeSegment(t, map[string]string{"alg": "none"}) + "." +
+		encodeSegment(t, validClaims()) + "."
+	hmacKey := []byte("0123456789abcdef0123456789abcdef")
+
+	tokens := map[string]string{
+		"expired":        sign(func(c map[string]any) { c["exp"] = time.Now().Add(-time.Hour).Unix() }),
+		"not yet valid":  sign(func(c map[string]any) { c["nbf"] = time.Now().Add(time.Hour).Unix() }),
+		"no expiration":  sign(func(c map[string]any) { delete(c, "exp") }),
+		"other issuer":   sign(func(c map[string]any) { c["iss"] = "https://evil.example.org" }),
+		"other audience": sign(func(c map[string]any) { c["aud"] = "other" }),
+		"unknown key":    signRS256(t, fixture.rsaKey, "rsa-2", validClaims()),
+		"forged":         signRS256(t, otherKey, "rsa-1", validClaims()),
+		"key mismatch":   signRS256(t, fixture.rsaKey, "ec-1", validClaims()),
+		"unsigned":       unsigned,
+		"hmac":           signToken(t, jose.HS256, hmacKey, "rsa-1", validClaims()),
+		"malformed":      "not-a-token",
+	}
+
+	for name, token := range tokens {
+		principal, err := authenticateBearer(fixture.authenticator, token)
+		assert.ErrorIs(t, err, ErrInvalidCredentials, name)
+		assert.Nil(t, principal, name)
+	}
+
+	_, err = authenticateBearer(fixture.authenticator, "")
+	assert.ErrorIs(t, err, ErrNoCredentials)
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+)
+
+const policySource = "policy"
+
+// Allows requests matching one of the rules of the config, the first matching rule wins.
+type PolicyAuthorizer struct {
+	rules []config.PolicyRule
+}
+
+func NewPolicyAuthorizer(rules []config.PolicyRule) *PolicyAuthorizer {
+	return &PolicyAuthorizer{rules: rules}
+}
+
+func (p *PolicyAuthorizer) Authorize(
+	ctx context.Context,
+	principal *Principal,
+	recordId string,
+	action config.RecordAction,
+) (Decision, error) {
+	for index, rule := range p.rules {
+		if slices.Contains(rule.Actions, action) &&
+			matchesAny(rule.Subjects, principal.Subject) &&
+			matchesAny(rule.Records, recordId) {
+			return Decision{
+				Allowed: true,
+				Reason:  fmt.Sprintf("allowed by policy rule %d", index),
+				Source:  policySource,
+			}, nil
+		}
+	}
+
+	return Decision{
+		Allowed: false,
+		Reason:  "no policy rule allows " + string(action),
+		Source:  policySource,
+	}, nil
+}
+
+// Patterns are validated with the config, a malformed one never matches.
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,271 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// Operation a workflow token allows on the workflow context in compchem.
+type WorkflowOperation string
+
+const (
+	OpReadInputs    WorkflowOperation = "read-inputs"
+	OpWriteOutputs  WorkflowOperation = "write-outputs"
+	OpDeleteContext WorkflowOperation = "delete-context"
+)
+
+// Operations of the token a workflow runs with.
+var WorkflowOperations = []WorkflowOperation{OpReadInputs, OpWriteOutputs, OpDeleteContext}
+
+const minRsaKeyBits = 2048
+
+// What a token is minted for, a zero deadline falls back to the configured lifetime.
+type WorkflowGrant struct {
+	RecordId   string
+	Workflow   string
+	Files      []string
+	Operations []WorkflowOperation
+	Deadline   time.Duration
+}
+
+type WorkflowClaims struct {
+	Issuer     string              `json:"iss"`
+	Subject    string              `json:"sub"`
+	Audience   string              `json:"aud"`
+	IssuedAt   int64               `json:"iat"`
+	NotBefore  int64               `json:"nbf"`
+	ExpiresAt  int64               `json:"exp"`
+	Id         string              `json:"jti"`
+	RecordId   string              `json:"record_id"`
+	Workflow   string              `json:"workflow"`
+	Files      []string            `json:"files"`
+	Operations []WorkflowOperation `json:"ops"`
+}
+
+// Mints the signed tokens workflows authenticate to compchem with, compchem verifies them against
+// the published jwks. Key ids are the RFC 7638 thumbprints of the keys.
+type WorkflowTokenSigner struct {
+	tokens config.WorkflowTokensConfig
+	signer jose.Signer
+	alg    jose.SignatureAlgorithm
+	kid    string
+	keys   map[string]jose.JSONWebKey
+	jwks   []byte
+	now    func() time.Time
+}
+
+// Returns nil when workflow tokens are not configured, workflows get random secret keys then.
+func NewWorkflowTokenSigner(tokens *config.WorkflowTokensConfig) (*WorkflowTokenSigner, error) {
+	if tokens == nil {
+		return nil, nil
+	}
+
+	key, err := loadPemKey(tokens.SigningKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	signingKey, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("signing key file does not contain a private key")
+	}
+
+	s := &WorkflowTokenSigner{
+		tokens: *tokens,
+		keys:   make(map[string]jose.JSONWebKey),
+		now:    time.Now,
+	}
+	published := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{}}
+	publicKeys := []crypto.PublicKey{signingKey.Public()}
+	for _, path := range tokens.VerificationKeyFiles {
+		key, err := loadPemKey(path)
+		if err != nil {
+			return nil, err
+		}
+		if signer, ok := key.(crypto.Signer); ok {
+			key = signer.Public()
+		}
+		publicKeys = append(publicKeys, key)
+	}
+	for index, publicKey := range publicKeys {
+		key, err := publicJwk(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		if index == 0 {
+			s.alg, s.kid = jose.SignatureAlgorithm(key.Algorithm), key.KeyID
+		}
+		if _, known := s.keys[key.KeyID]; known {
+			continue
+		}
+		s.keys[key.KeyID] = key
+		published.Keys = append(published.Keys, key)
+	}
+
+	s.signer, err = jose.NewSigner(
+		jose.SigningKey{Algorithm: s.alg, Key: jose.JSONWebKey{Key: signingKey, KeyID: s.kid}},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Error when creating workflow token signer: %v", err)
+	}
+
+	s.jwks, err = json.Marshal(published)
+	if err != nil {
+		return nil, fmt.Errorf("Error when encoding jwks: %v", err)
+	}
+
+	return s, nil
+}
+
+// Public keys tokens are verified with, encoded as a jwks document.
+func (s *WorkflowTokenSigner) Jwks() []byte {
+	return s.jwks
+}
+
+// Returns the token and the time it expires at, the deadline of the grant plus the expiry margin.
+func (s *WorkflowTokenSigner) Mint(grant WorkflowGrant) (string, time.Time, error) {
+	now := s.now()
+	lifetime := grant.Deadline
+	if lifetime == 0 {
+		lifetime = s.tokens.Lifetime()
+	}
+	expiresAt := now.Add(lifetime + s.tokens.ExpiryMargin())
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", time.Time{}, err
+	}
+
+	token, err := jwt.Signed(s.signer).Claims(WorkflowClaims{
+		Issuer:     s.tokens.Issuer,
+		Subject:    "workflow:" + grant.Workflow,
+		Audience:   s.tokens.Audience,
+		IssuedAt:   now.Unix(),
+		NotBefore:  now.Unix(),
+		ExpiresAt:  expiresAt.Unix(),
+		Id:         hex.EncodeToString(id),
+		RecordId:   grant.RecordId,
+		Workflow:   grant.Workflow,
+		Files:      grant.Files,
+		Operations: grant.Operations,
+	}).Serialize()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("Error when signing workflow token: %v", err)
+	}
+
+	return token, expiresAt, nil
+}
+
+// Returns the claims of a token minted by this service which is valid now.
+func (s *WorkflowTokenSigner) Verify(token string) (*WorkflowClaims, error) {
+	parsed, err := jwt.ParseSigned(token, signatureAlgorithms)
+	if err != nil {
+		return nil, fmt.Errorf("malformed token: %v", err)
+	}
+	header := parsed.Headers[0]
+	key, found := s.keys[header.KeyID]
+	if !found {
+		return nil, fmt.Errorf("unknown signing key %q", header.KeyID)
+	}
+	if header.Algorithm != key.Algorithm {
+		return nil, fmt.Errorf("key does not match algorithm %s", header.Algorithm)
+	}
+
+	var registered jwt.Claims
+	var claims WorkflowClaims
+	if err := parsed.Claims(key.Key, &registered, &claims); err != nil {
+		return nil, claimsError(err)
+	}
+	expected := jwt.Expected{
+		Issuer:      s.tokens.Issuer,
+		AnyAudience: jwt.Audience{s.tokens.Audience},
+		Time:        s.now(),
+	}
+	if err := registered.ValidateWithLeeway(expected, clockLeeway); err != nil {
+		return nil, claimsError(err)
+	}
+
+	return &claims, nil
+}
+
+// Reads PKCS#8, PKCS#1 and SEC 1 private keys and PKIX public keys.
+func loadPemKey(path string) (any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error when reading key file: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key file %s is not PEM encoded", path)
+	}
+
+	var key any
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported key type %s in %s", block.Type, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error when parsing key file %s: %v", path, err)
+	}
+
+	return key, nil
+}
+
+// Public jwk of the key with its algorithm, RSA keys sign with RS256 and EC keys with the ES
+// algorithm of their curve.
+func publicJwk(key crypto.PublicKey) (jose.JSONWebKey, error) {
+	var alg jose.SignatureAlgorithm
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < minRsaKeyBits {
+			return jose.JSONWebKey{}, fmt.Errorf("rsa keys need at least %d bits", minRsaKeyBits)
+		}
+		alg = jose.RS256
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			alg = jose.ES256
+		case elliptic.P384():
+			alg = jose.ES384
+		case elliptic.P521():
+			alg = jose.ES512
+		default:
+			return jose.JSONWebKey{}, errors.New("unsupported curve")
+		}
+	default:
+		return jose.JSONWebKey{}, errors.New("unsupported key type, use an RSA or EC key")
+	}
+
+	result := jose.JSONWebKey{Key: key, Algorithm: string(alg), Use: "sig"}
+	thumbprint, err := result.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return jose.JSONWebKey{}, err
+	}
+	result.KeyID = base64.RawURLEncoding.EncodeToString(thumbprint)
+
+	return result, nil
+}
@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func writePemKey(t *testing.T, name string, key any) string {
+	data, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), name)
+	err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: data}), 0600)
+	assert.NoError(t, err)
+
+	return path
+}
+
+func newSigner(t *testing.T, signingKey any, verificationKeys ...any) *WorkflowTokenSigner {
+	tokens := &config.WorkflowTokensConfig{
+		Issuer:         "compchem-fileprocessor",
+		Audience:       "compchem",
+		SigningKeyFile: writePemKey(t, "signing.pem", signingKey),
+	}
+	for index, key := range verificationKeys {
+		path := writePemKey(t, "verification-"+string(rune('a'+index))+".pem", key)
+		tokens.VerificationKeyFiles = append(tokens.VerificationKeyFiles, path)
+	}
+
+	signer, err := NewWorkflowTokenSigner(tokens)
+	assert.NoError(t, err)
+	return signer
+}
+
+func TestNewWorkflowTokenSigner_NotConfigured_Nil(t *testing.T) {
+	signer, err := NewWorkflowTokenSigner(nil)
+
+	assert.NoError(t, err)
+	assert.Nil(t, signer)
+}
+
+func TestWorkflowTokenMint_EcKey_ScopedClaimsVerified(t *testing.T) {
+	// Arrange
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	signer := newSigner(t, key)
+	now := time.Unix(1_700_000_000, 0)
+	signer.now = func() time.Time { return now }
+
+	// Act
+	token, expiresAt, err := signer.Mint(WorkflowGrant{
+		RecordId:   "fq6n4-1zq45",
+		Workflow:   "orca-fq6n4-1zq45-1",
+		Files:      []string{"benzene.xyz"},
+		Operations: WorkflowOperations,
+		Deadline:   2 * time.Hour,
+	})
+	assert.NoError(t, err)
+	claims, verifyErr := signer.Verify(token)
+
+	// Assert
+	assert.NoError(t, verifyErr)
+	assert.Equal(t, now.Add(3*time.Hour), expiresAt)
+	assert.Equal(t, "workflow:orca-fq6n4-1zq45-1", claims.Subject)
+	assert.Equal(t, "compchem", claims.Audience)
+	assert.Equal(t, "fq6n4-1zq45", claims.RecordId)
+	assert.Equal(t, []string{"benzene.xyz"}, claims.Files)
+	assert.Equal(t, WorkflowOperations, claims.Operations)
+	assert.Equal(t, expiresAt.Unix(), claims.ExpiresAt)
+}
+
+func TestWorkflowTokenMint_NoDeadline_LifetimeUsed(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	assert.NoError(t, err)
+	signer := newSigner(t, key)
+	now := time.Unix(1_700_000_000, 0)
+	signer.now = func() time.Time { return now }
+
+	_, expiresAt, err := signer.Mint(WorkflowGrant{Workflow: "orca-fq6n4-1zq45-1"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(25*time.Hour), expiresAt)
+}
+
+func TestWorkflowTokenVerify_ExpiredOrTampered_Rejected(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	signer := newSigner(t, key)
+	token, expiresAt, err := signer.Mint(WorkflowGrant{Workflow: "orca-fq6n4-1zq45-1"})
+	assert.NoError(t, err)
+
+	parts := strings.Split(token, ".")
+	other, _, err := signer.Mint(WorkflowGrant{Workflow: "xtb-fq6n4-1zq45-2"})
+	assert.NoError(t, err)
+	tampered := parts[0] + "." + strings.Split(other, ".")[1] + "." + parts[2]
+
+	_, tamperedErr := signer.Verify(tampered)
+	signer.now = func() time.Time { return expiresAt.Add(2 * clockLeeway) }
+	_, expiredErr := signer.Verify(token)
+
+	assert.EqualError(t, tamperedErr, "invalid signature")
+	assert.EqualError(t, expiredErr, "token expired")
+}
+
+func TestWorkflowTokenSigner_RotatedKey_PublishedAndVerified(t *testing.T) {
+	// Arrange
+	oldKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	oldToken, _, err := newSigner(t, oldKey).Mint(WorkflowGrant{Workflow: "orca-fq6n4-1zq45-1"})
+	assert.NoError(t, err)
+
+	// Act
+	signer := newSigner(t, newKey, oldKey)
+	newToken, _, mintErr := signer.Mint(WorkflowGrant{Workflow: "orca-fq6n4-1zq45-2"})
+	keys, parseErr := parseJwks(signer.Jwks())
+
+	// Assert
+	assert.NoError(t, mintErr)
+	assert.NoError(t, parseErr)
+	assert.Len(t, keys, 2)
+	assert.Equal(t, newKey.Public(), keys[signer.kid].Key)
+	_, err = signer.Verify(oldToken)
+	assert.NoError(t, err)
+	claims, err := signer.Verify(newToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "orca-fq6n4-1zq45-2", claims.Workflow)
+	assert.Equal(t, jose.RS256, signer.alg)
+}
+
+func TestNewWorkflowTokenSigner_WeakRsaKey_Rejected(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+
+	_, err = NewWorkflowTokenSigner(&config.WorkflowTokensConfig{
+		SigningKeyFile: writePemKey(t, "signing.pem", key),
+	})
+
+	assert.EqualError(t, err, "rsa keys need at least 2048 bits")
+}
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"path"
+	"slices"
+	"time"
+)
+
+// Permission a route requires, start also covers stopping and retrying workflows.
+type Scope string
+
+const (
+	ScopeStart Scope = "start"
+	ScopeList  Scope = "list"
+	ScopeAdmin Scope = "admin"
+)
+
+var scopes = []Scope{ScopeStart, ScopeList, ScopeAdmin}
+
+// Action on a record checked by the authorizer, process covers starting, stopping and retrying
+// workflows of the record.
+type RecordAction string
+
+const (
+	ActionRead    RecordAction = "read"
+	ActionProcess RecordAction = "process"
+)
+
+var recordActions = []RecordAction{ActionRead, ActionProcess}
+
+const (
+	AuthorizationCompchem = "compchem"
+	AuthorizationPolicy   = "policy"
+)
+
+const defaultAuthorizationCache = 30 * time.Second
+
+// Routes are open when neither api keys nor oidc are configured. Health endpoints and the outcome
+// callback, which authenticates with the workflow secret key, never require credentials.
+type AuthConfig struct {
+	ApiKeys       []ApiKeyConfig       `yaml:"api-keys"`
+	Oidc          *OidcConfig          `yaml:"oidc"`
+	Authorization *AuthorizationConfig `yaml:"authorization"`
+}
+
+// Static key sent in the X-Api-Key header, only the sha256 hash of the key is configured.
+type ApiKeyConfig struct {
+	Name    string  `yaml:"name"`
+	KeyHash string  `yaml:"key-hash"`
+	Scopes  []Scope `yaml:"scopes"`
+}
+
+// Bearer tokens are verified against the keys of the jwks file, which is read again when a token is
+// signed by an unknown key. Scopes are read from the scopes claim, either a space separated string
+// or a list, and have to start with the scope prefix.
+type OidcConfig struct {
+	Issuer      string `yaml:"issuer"`
+	Audience    string `yaml:"audience"`
+	JwksFile    string `yaml:"jwks-file"`
+	ScopesClaim string `yaml:"scopes-claim"`
+	ScopePrefix string `yaml:"scope-prefix"`
+}
+
+// Decides whether a principal may read or process a record, either by asking compchem or by the
+// rules of a local policy. Decisions are cached for cache-seconds, zero falls back to the default
+// and negative disables the cache.
+type AuthorizationConfig struct {
+	Mode         string       `yaml:"mode"`
+	CacheSeconds int          `yaml:"cache-seconds"`
+	Policy       []PolicyRule `yaml:"policy"`
+}
+
+// Allows the actions on records matching one of the patterns to subjects matching one of the
+// subject patterns, patterns use path.Match syntax. Requests no rule allows are denied.
+type PolicyRule struct {
+	Subjects []string       `yaml:"subjects"`
+	Records  []string       `yaml:"records"`
+	Actions  []RecordAction `yaml:"actions"`
+}
+
+func (a AuthorizationConfig) CacheTtl() time.Duration {
+	if a.CacheSeconds == 0 {
+		return defaultAuthorizationCache
+	}
+
+	return time.Duration(a.CacheSeconds) * time.Second
+}
+
+func (a AuthConfig) Enabled() bool {
+	return len(a.ApiKeys) > 0 || a.Oidc != nil
+}
+
+// Scope of the name, false for names that are not scopes of the api.
+func ParseScope(name string) (Scope, bool) {
+	scope := Scope(name)
+	return scope, slices.Contains(scopes, scope)
+}
+
+func ParseRecordAction(name string) (RecordAction, bool) {
+	action := RecordAction(name)
+	return action, slices.Contains(recordActions, action)
+}
+
+func validateAuth(auth AuthConfig, errors map[string]string) {
+	known := make(map[string]bool)
+	for index, key := range auth.ApiKeys {
+		field := fmt.Sprintf("auth-api-key-%d", index)
+		if key.Name == "" {
+			errors[field] = "missing name"
+		} else if known[key.Name] {
+			errors[field] = "duplicate api key " + key.Name
+		}
+		known[key.Name] = true
+
+		if decoded, err := hex.DecodeString(key.KeyHash); err != nil || len(decoded) != 32 {
+			errors[field+"-hash"] = "key hash is not a hex encoded sha256"
+		}
+		if len(key.Scopes) == 0 {
+			errors[field+"-scopes"] = "no scopes"
+		}
+		for _, scope := range key.Scopes {
+			if _, ok := ParseScope(string(scope)); !ok {
+				errors[field+"-scopes"] = "unknown scope " + string(scope)
+			}
+		}
+	}
+
+	if auth.Oidc != nil {
+		if auth.Oidc.Issuer == "" {
+			errors["auth-oidc-issuer"] = "missing issuer"
+		}
+		if auth.Oidc.Audience == "" {
+			errors["auth-oidc-audience"] = "missing audience"
+		}
+		if auth.Oidc.JwksFile == "" {
+			errors["auth-oidc-jwks-file"] = "missing jwks file"
+		}
+	}
+
+	if auth.Authorization != nil {
+		validateAuthorization(*auth.Authorization, auth.Enabled(), errors)
+	}
+}
+
+func validateAuthorization(
+	authorization AuthorizationConfig,
+	authenticated bool,
+	errors map[string]string,
+) {
+	if !authenticated {
+		errors["auth-authorization"] = "authorization requires api keys or oidc"
+	}
+
+	switch authorization.Mode {
+	case AuthorizationCompchem:
+		if len(authorization.Policy) > 0 {
+			errors["auth-authorization-policy"] = "policy is only used by the policy mode"
+		}
+	case AuthorizationPolicy:
+		if len(authorization.Policy) == 0 {
+			errors["auth-authorization-policy"] = "no policy rules"
+		}
+	default:
+		errors["auth-authorization-mode"] = fmt.Sprintf(
+			"mode has to be %s or %s",
+			AuthorizationCompchem,
+			AuthorizationPolicy,
+		)
+	}
+
+	for index, rule := range authorization.Policy {
+		field := fmt.Sprintf("auth-authorization-policy-%d", index)
+		if len(rule.Subjects) == 0 {
+			errors[field+"-subjects"] = "no subjects"
+		}
+		if len(rule.Records) == 0 {
+			errors[field+"-records"] = "no records"
+		}
+		for _, pattern := range append(slices.Clone(rule.Subjects), rule.Records...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				errors[field] = "invalid pattern " + pattern
+			}
+		}
+		if len(rule.Actions) == 0 {
+			errors[field+"-actions"] = "no actions"
+		}
+		for _, action := range rule.Actions {
+			if _, ok := ParseRecordAction(string(action)); !ok {
+				errors[field+"-actions"] = "unknown action " + string(action)
+			}
+		}
+	}
+}
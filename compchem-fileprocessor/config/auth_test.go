@@ -0,0 +1,61 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAuth_InvalidKeysAndOidc_Rejected(t *testing.T) {
+	errors := make(map[string]string)
+	validateAuth(AuthConfig{
+		ApiKeys: []ApiKeyConfig{
+			{
+				Name:    "compchem",
+				KeyHash: "6f7a0ad5b9fd1fa8b1e96d8b4f0a2b1f3f1c30b3cbd5f0d4f4a0c8b9e2d6a1c7",
+				Scopes:  []Scope{ScopeStart},
+			},
+			{Name: "compchem", KeyHash: "secret", Scopes: []Scope{"write"}},
+		},
+		Oidc: &OidcConfig{Issuer: "https://login.example.org"},
+	}, errors)
+
+	assert.Equal(t, map[string]string{
+		"auth-api-key-1":        "duplicate api key compchem",
+		"auth-api-key-1-hash":   "key hash is not a hex encoded sha256",
+		"auth-api-key-1-scopes": "unknown scope write",
+		"auth-oidc-audience":    "missing audience",
+		"auth-oidc-jwks-file":   "missing jwks file",
+	}, errors)
+}
+
+func TestAuthConfigEnabled_NothingConfigured_Disabled(t *testing.T) {
+	assert.False(t, AuthConfig{}.Enabled())
+	assert.True(t, AuthConfig{Oidc: &OidcConfig{}}.Enabled())
+}
+
+func TestValidateAuth_InvalidAuthorization_Rejected(t *testing.T) {
+	errors := make(map[string]string)
+	validateAuth(AuthConfig{
+		Authorization: &AuthorizationConfig{
+			Mode: AuthorizationPolicy,
+			Policy: []PolicyRule{
+				{Subjects: []string{"oidc:["}, Records: []string{"*"}, Actions: []RecordAction{"write"}},
+				{Subjects: []string{"*"}},
+			},
+		},
+	}, errors)
+
+	assert.Equal(t, map[string]string{
+		"auth-authorization":                  "authorization requires api keys or oidc",
+		"auth-authorization-policy-0":         "invalid pattern oidc:[",
+		"auth-authorization-policy-0-actions": "unknown action write",
+		"auth-authorization-policy-1-records": "no records",
+		"auth-authorization-policy-1-actions": "no actions",
+	}, errors)
+}
+
+func TestAuthorizationCacheTtl_Unset_DefaultUsed(t *testing.T) {
+	assert.Equal(t, defaultAuthorizationCache, AuthorizationConfig{}.CacheTtl())
+	assert.True(t, AuthorizationConfig{CacheSeconds: -1}.CacheTtl() < 0)
+}
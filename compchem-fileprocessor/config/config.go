@@ -4,20 +4,36 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
 
 	"go.uber.org/zap"
 	"gopkg.in/yaml.v3"
 )
 
+// Path and revision identify the file the config was loaded from, the revision is a hash of its
+// content.
 type Config struct {
-	Server      Server           `yaml:"server"`
-	ApiContext  string           `yaml:"context-path"`
-	CompchemApi CompchemApi      `yaml:"compchem"`
-	ArgoApi     ArgoApi          `yaml:"argo-workflows"`
-	Workflows   []WorkflowConfig `yaml:"workflows"`
-	Postgres    Postgres         `yaml:"postgres"`
-	Migrations  string           `yaml:"migrations"`
+	Server         Server                `yaml:"server"`
+	ApiContext     string                `yaml:"context-path"`
+	CompchemApi    CompchemApi           `yaml:"compchem"`
+	ArgoApi        ArgoApi               `yaml:"argo-workflows"`
+	Workflows      []WorkflowConfig      `yaml:"workflows"`
+	Postgres       Postgres              `yaml:"postgres"`
+	Migrations     string                `yaml:"migrations"`
+	Reload         ReloadConfig          `yaml:"reload"`
+	Auth           AuthConfig            `yaml:"auth"`
+	WorkflowTokens *WorkflowTokensConfig `yaml:"workflow-tokens"`
+	Kubernetes     KubernetesApi         `yaml:"kubernetes"`
+	Path           string                `yaml:"-"`
+	Revision       string                `yaml:"-"`
+}
+
+// Interval the config file is checked for changes in, zero falls back to the default and negative
+// disables checking, SIGHUP reloads the file regardless.
+type ReloadConfig struct {
+	IntervalSeconds int `yaml:"interval-seconds"`
 }
 
 type Postgres struct {
@@ -32,30 +48,141 @@ type Auth struct {
 	Password string `yaml:"password"`
 }
 
+// Trust forwarded for only behind a proxy which sets X-Forwarded-For, the audit log records the
+// client address from it then.
 type Server struct {
-	Host string `yaml:"host"`
-	Port int    `yaml:"port"`
+	Host              string `yaml:"host"`
+	Port              int    `yaml:"port"`
+	TrustForwardedFor bool   `yaml:"trust-forwarded-for"`
 }
 
 type CompchemApi struct {
-	Url string `yaml:"url"`
+	Url           string `yaml:"url"`
+	NotifyOutcome bool   `yaml:"notify-outcome"`
 }
 
 type ArgoApi struct {
+	Url         string           `yaml:"url"`
+	Namespace   string           `yaml:"namespace"`
+	Instance    string           `yaml:"instance"`
+	CallbackUrl string           `yaml:"callback-url"`
+	Submission  SubmissionConfig `yaml:"submission"`
+	Watcher     WatcherConfig    `yaml:"watcher"`
+	Defaults    WorkflowSpec     `yaml:"defaults"`
+}
+
+// Kubernetes api the secrets of workflows are created in. Unset values fall back to the api
+// server and service account of the pod the service runs in.
+type KubernetesApi struct {
 	Url       string `yaml:"url"`
-	Namespace string `yaml:"namespace"`
+	TokenFile string `yaml:"token-file"`
+	CaFile    string `yaml:"ca-file"`
+}
+
+// Tunes the dispatcher submitting workflows from the outbox, zero values fall back to defaults.
+type SubmissionConfig struct {
+	IntervalSeconds int `yaml:"interval-seconds"`
+	MaxAttempts     int `yaml:"max-attempts"`
+	BatchSize       int `yaml:"batch-size"`
+}
+
+// Tunes the watcher syncing argo workflow state into the database, zero values fall back to defaults.
+type WatcherConfig struct {
+	ReconnectSeconds int `yaml:"reconnect-seconds"`
+	PageSize         int `yaml:"page-size"`
 }
 
+// With fan-out every input file is processed by its own branch of the workflow, parallelism caps
+// how many files are processed at once, zero means no cap. Files are eligible when they have the
+// mimetype and extension and satisfy the match rule, a workflow with a match rule or input slots
+// may omit both. Input slots split the files of a workflow into named groups.
 type WorkflowConfig struct {
 	Name                string               `yaml:"name"`
 	Mimetype            string               `yaml:"mimetype"`
 	Extension           string               `yaml:"extension"`
+	Match               *MatchRule           `yaml:"match"`
+	Inputs              []InputSlot          `yaml:"inputs"`
 	ProcessingTemplates []ProcessingTemplate `yaml:"processing-templates"`
+	FanOut              bool                 `yaml:"fan-out"`
+	Parallelism         int                  `yaml:"parallelism"`
+	Spec                WorkflowSpec         `yaml:"spec"`
 }
 
+// Options of the generated argo workflow spec. Unset options of a workflow are taken from the
+// argo-workflows defaults, the namespace defaults to the argo-workflows namespace. Parallelism caps
+// pods of the whole workflow running at once, the active deadline is the time argo lets the whole
+// workflow run and also bounds the lifetime of its token.
+type WorkflowSpec struct {
+	Namespace             string       `yaml:"namespace"`
+	ServiceAccountName    string       `yaml:"service-account-name"`
+	Priority              *int32       `yaml:"priority"`
+	Parallelism           int          `yaml:"parallelism"`
+	ActiveDeadlineSeconds *int64       `yaml:"active-deadline-seconds"`
+	TTLStrategy           *TTLStrategy `yaml:"ttl-strategy"`
+	PodGC                 *PodGC       `yaml:"pod-gc"`
+}
+
+// Seconds a finished workflow is kept in argo before it is deleted.
+type TTLStrategy struct {
+	SecondsAfterCompletion *int32 `yaml:"seconds-after-completion"`
+	SecondsAfterSuccess    *int32 `yaml:"seconds-after-success"`
+	SecondsAfterFailure    *int32 `yaml:"seconds-after-failure"`
+}
+
+type PodGC struct {
+	Strategy            string `yaml:"strategy"`
+	DeleteDelayDuration string `yaml:"delete-delay-duration"`
+}
+
+// Templates without dependencies process the read files, or the subset of them selected by the
+// input filter. Templates with dependencies process the output of their first dependency instead
+// and get outputs of all dependencies, which are referred to by the processing template name.
+// Outputs of leaf templates and templates marked with write are uploaded to compchem. Unset
+// execution settings fall back to argo defaults.
 type ProcessingTemplate struct {
-	Name     string `yaml:"name"`
-	Template string `yaml:"template"`
+	Name                  string              `yaml:"name"`
+	Template              string              `yaml:"template"`
+	DependsOn             []string            `yaml:"depends-on"`
+	Write                 bool                `yaml:"write"`
+	RetryStrategy         *RetryStrategy      `yaml:"retry-strategy"`
+	ActiveDeadlineSeconds int                 `yaml:"active-deadline-seconds"`
+	Resources             *Resources          `yaml:"resources"`
+	NodeSelector          map[string]string   `yaml:"node-selector"`
+	Tolerations           []Toleration        `yaml:"tolerations"`
+	Parameters            []TemplateParameter `yaml:"parameters"`
+	Input                 *InputFilter        `yaml:"input"`
+}
+
+type RetryStrategy struct {
+	Limit       int      `yaml:"limit"`
+	RetryPolicy string   `yaml:"retry-policy"`
+	Backoff     *Backoff `yaml:"backoff"`
+}
+
+// Durations are argo duration strings, e.g. 30s or 2m.
+type Backoff struct {
+	Duration    string `yaml:"duration"`
+	Factor      int    `yaml:"factor"`
+	MaxDuration string `yaml:"max-duration"`
+}
+
+type Resources struct {
+	Requests ResourceList `yaml:"requests"`
+	Limits   ResourceList `yaml:"limits"`
+}
+
+// Quantities are kubernetes quantity strings, e.g. 500m or 1Gi.
+type ResourceList struct {
+	Cpu    string `yaml:"cpu"`
+	Memory string `yaml:"memory"`
+}
+
+type Toleration struct {
+	Key               string `yaml:"key"`
+	Operator          string `yaml:"operator"`
+	Value             string `yaml:"value"`
+	Effect            string `yaml:"effect"`
+	TolerationSeconds *int64 `yaml:"toleration-seconds"`
 }
 
 type EnvBinding struct {
@@ -74,6 +201,10 @@ func LoadConfig(logger *zap.Logger, workdir string) (*Config, error) {
 
 	logger.Info("Loading config", zap.String("config_path", configPath))
 
+	return loadConfigFile(logger, configPath)
+}
+
+func loadConfigFile(logger *zap.Logger, configPath string) (*Config, error) {
 	configBytes, err := readConfig(logger, configPath)
 	if err != nil {
 		return nil, err
@@ -92,6 +223,9 @@ func LoadConfig(logger *zap.Logger, workdir string) (*Config, error) {
 		return nil, fmt.Errorf("config validation failed with %d error(s)", len(validationErrors))
 	}
 
+	config.Path = configPath
+	config.Revision = revisionOf(configBytes)
+
 	return config, nil
 }
 
@@ -143,6 +277,7 @@ func validateConfig(logger *zap.Logger, cfg *Config) (*Config, map[string]string
 
 	DEFAULT_HOST := "localhost"
 	DEFAULT_PORT := 8079
+	DEFAULT_INSTANCE := "compchem-fileprocessor"
 
 	if cfg.Server.Host == "" {
 		logger.Warn("Missing host, defaulting to localhost")
@@ -162,15 +297,41 @@ func validateConfig(logger *zap.Logger, cfg *Config) (*Config, map[string]string
 		errors["argo-ns"] = "missing argo ns"
 	}
 
+	if cfg.ArgoApi.Instance == "" {
+		logger.Warn("Missing argo instance, defaulting to compchem-fileprocessor")
+		cfg.ArgoApi.Instance = DEFAULT_INSTANCE
+	}
+
+	if cfg.ArgoApi.CallbackUrl == "" {
+		logger.Warn("Missing argo callback url, workflows will not report their outcome")
+	}
+
 	if cfg.CompchemApi.Url == "" {
 		errors["compchem-url"] = "missing compchem api url"
 	}
 
+	if cfg.ArgoApi.Defaults.Namespace != "" {
+		errors["argo-defaults-ns"] = "default namespace is the argo-workflows namespace"
+	}
+	if msg := validateWorkflowSpec(cfg.ArgoApi.Defaults); msg != "" {
+		errors["argo-defaults"] = msg
+	}
+
 	if len(cfg.Workflows) > 0 {
 		validateWorkflows(cfg.Workflows, errors)
 	}
+	for i := range cfg.Workflows {
+		cfg.Workflows[i].Spec = resolveWorkflowSpec(cfg.Workflows[i].Spec, cfg.ArgoApi)
+	}
 
 	validatePostgresParams(cfg.Postgres, errors)
+	validateAuth(cfg.Auth, errors)
+	if cfg.WorkflowTokens != nil {
+		validateWorkflowTokens(*cfg.WorkflowTokens, errors)
+	}
+	if !cfg.Auth.Enabled() {
+		logger.Warn("No authentication configured, the api is open to anyone who can reach it")
+	}
 
 	return cfg, errors
 }
@@ -193,6 +354,17 @@ func validatePostgresParams(postgres Postgres, errors map[string]string) {
 	}
 }
 
+// Validates a single workflow the same way workflows of the config file are validated and resolves
+// its spec against the argo-workflows defaults, errors are keyed as for the first workflow of the
+// config file.
+func ValidateWorkflow(workflow WorkflowConfig, argo ArgoApi) (WorkflowConfig, map[string]string) {
+	errors := make(map[string]string)
+	validateWorkflows([]WorkflowConfig{workflow}, errors)
+	workflow.Spec = resolveWorkflowSpec(workflow.Spec, argo)
+
+	return workflow, errors
+}
+
 func validateWorkflows(workflows []WorkflowConfig, errors map[string]string) {
 	errorTemplate := "%s-%d"
 
@@ -200,11 +372,37 @@ func validateWorkflows(workflows []WorkflowConfig, errors map[string]string) {
 		if workflow.Name == "" {
 			errors[fmt.Sprintf(errorTemplate, "name", index)] = "missing name"
 		}
-		if workflow.Mimetype == "" {
-			errors[fmt.Sprintf(errorTemplate, "mimetype", index)] = "missing filetype"
+		if workflow.Match != nil {
+			if msg := validateMatchRule(*workflow.Match); msg != "" {
+				errors[fmt.Sprintf(errorTemplate, "match", index)] = msg
+			}
+		}
+		if len(workflow.Inputs) > 0 {
+			if msg := validateInputSlots(workflow); msg != "" {
+				errors[fmt.Sprintf(errorTemplate, "inputs", index)] = msg
+			}
+		}
+		if workflow.Match == nil && len(workflow.Inputs) == 0 {
+			if workflow.Mimetype == "" {
+				errors[fmt.Sprintf(errorTemplate, "mimetype", index)] = "missing filetype"
+			}
+			if workflow.Extension == "" {
+				errors[fmt.Sprintf(errorTemplate, "extension", index)] = "missing extension"
+			}
+		}
+		if workflow.Parallelism < 0 {
+			errors[fmt.Sprintf(errorTemplate, "parallelism", index)] = "negative parallelism"
+		}
+		if msg := validateWorkflowSpec(workflow.Spec); msg != "" {
+			errors[fmt.Sprintf(errorTemplate, "spec", index)] = msg
 		}
-		if workflow.Extension == "" {
-			errors[fmt.Sprintf(errorTemplate, "extension", index)] = "missing extension"
+		for inx, template := range workflow.ProcessingTemplates {
+			if template.Input == nil {
+				continue
+			}
+			if msg := validateInputFilter(template, workflow.Inputs); msg != "" {
+				errors[fmt.Sprintf("template-%d-%d-input", index, inx)] = msg
+			}
 		}
 		if len(workflow.ProcessingTemplates) > 0 {
 			validateProcessingTemplates(workflow.ProcessingTemplates, index, errors)
@@ -221,6 +419,7 @@ func validateProcessingTemplates(
 ) {
 	errorTemplate := "template-" + strconv.Itoa(index) + "-%d"
 
+	known := make(map[string]bool)
 	for inx, template := range templates {
 		if template.Name == "" {
 			errors[fmt.Sprintf(errorTemplate, inx)] = "missing template name"
@@ -228,5 +427,205 @@ func validateProcessingTemplates(
 		if template.Template == "" {
 			errors[fmt.Sprintf(errorTemplate, inx)] = "missing template"
 		}
+		if known[template.Name] {
+			errors[fmt.Sprintf(errorTemplate, inx)] = "duplicate template name " + template.Name
+		}
+		known[template.Name] = true
+		if msg := validateExecutionSettings(template); msg != "" {
+			errors[fmt.Sprintf(errorTemplate, inx)+"-execution"] = msg
+		}
+		validateTemplateParameters(template.Parameters, fmt.Sprintf(errorTemplate, inx), errors)
+	}
+
+	for inx, template := range templates {
+		for _, dependency := range template.DependsOn {
+			if !known[dependency] {
+				errors[fmt.Sprintf(errorTemplate, inx)] = "unknown dependency " + dependency
+			}
+		}
+	}
+
+	if cycle := findDependencyCycle(templates); cycle != nil {
+		errors["template-"+strconv.Itoa(index)+"-cycle"] = "dependency cycle " +
+			strings.Join(cycle, " -> ")
+	}
+}
+
+// Returns templates forming a cycle with the first template repeated at the end, nil when
+// dependencies form a DAG.
+func findDependencyCycle(templates []ProcessingTemplate) []string {
+	dependencies := make(map[string][]string)
+	for _, template := range templates {
+		dependencies[template.Name] = template.DependsOn
 	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	path := []string{}
+
+	var visit func(template string) []string
+	visit = func(template string) []string {
+		switch state[template] {
+		case visiting:
+			start := slices.Index(path, template)
+			return append(slices.Clone(path[start:]), template)
+		case visited:
+			return nil
+		}
+
+		state[template] = visiting
+		path = append(path, template)
+		for _, dependency := range dependencies[template] {
+			if cycle := visit(dependency); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[template] = visited
+
+		return nil
+	}
+
+	for _, template := range templates {
+		if cycle := visit(template.Name); cycle != nil {
+			return cycle
+		}
+	}
+
+	return nil
+}
+
+func validateTemplateParameters(
+	parameters []TemplateParameter,
+	templateKey string,
+	errors map[string]string,
+) {
+	known := make(map[string]bool)
+	for inx, parameter := range parameters {
+		key := fmt.Sprintf("%s-parameter-%d", templateKey, inx)
+		if known[parameter.Name] {
+			errors[key] = "duplicate parameter " + parameter.Name
+		}
+		known[parameter.Name] = true
+		if msg := validateTemplateParameter(parameter); msg != "" {
+			errors[key] = msg
+		}
+	}
+}
+
+var (
+	retryPolicies       = []string{"", "Always", "OnFailure", "OnError", "OnTransientError"}
+	tolerationOperators = []string{"", "Exists", "Equal"}
+	tolerationEffects   = []string{"", "NoSchedule", "PreferNoSchedule", "NoExecute"}
+)
+
+// Returns empty string when execution settings of the template are valid.
+func validateExecutionSettings(template ProcessingTemplate) string {
+	if template.ActiveDeadlineSeconds < 0 {
+		return "negative active deadline"
+	}
+
+	if retry := template.RetryStrategy; retry != nil {
+		if retry.Limit < 0 {
+			return "negative retry limit"
+		}
+		if !slices.Contains(retryPolicies, retry.RetryPolicy) {
+			return "unknown retry policy " + retry.RetryPolicy
+		}
+		if retry.Backoff != nil && retry.Backoff.Factor < 0 {
+			return "negative backoff factor"
+		}
+	}
+
+	for _, toleration := range template.Tolerations {
+		if !slices.Contains(tolerationOperators, toleration.Operator) {
+			return "unknown toleration operator " + toleration.Operator
+		}
+		if !slices.Contains(tolerationEffects, toleration.Effect) {
+			return "unknown toleration effect " + toleration.Effect
+		}
+	}
+
+	return ""
+}
+
+var podGCStrategies = []string{
+	"",
+	"OnPodCompletion",
+	"OnPodSuccess",
+	"OnWorkflowCompletion",
+	"OnWorkflowSuccess",
+}
+
+// Returns empty string when the workflow spec options are valid.
+func validateWorkflowSpec(spec WorkflowSpec) string {
+	if spec.Parallelism < 0 {
+		return "negative parallelism"
+	}
+
+	if spec.ActiveDeadlineSeconds != nil && *spec.ActiveDeadlineSeconds <= 0 {
+		return "active deadline has to be positive"
+	}
+
+	if ttl := spec.TTLStrategy; ttl != nil {
+		for _, seconds := range []*int32{
+			ttl.SecondsAfterCompletion,
+			ttl.SecondsAfterSuccess,
+			ttl.SecondsAfterFailure,
+		} {
+			if seconds != nil && *seconds < 0 {
+				return "negative ttl"
+			}
+		}
+	}
+
+	if spec.PodGC != nil && !slices.Contains(podGCStrategies, spec.PodGC.Strategy) {
+		return "unknown pod gc strategy " + spec.PodGC.Strategy
+	}
+
+	return ""
+}
+
+func resolveWorkflowSpec(spec WorkflowSpec, argo ArgoApi) WorkflowSpec {
+	defaults := argo.Defaults
+
+	if spec.Namespace == "" {
+		spec.Namespace = argo.Namespace
+	}
+	if spec.ServiceAccountName == "" {
+		spec.ServiceAccountName = defaults.ServiceAccountName
+	}
+	if spec.Priority == nil {
+		spec.Priority = defaults.Priority
+	}
+	if spec.Parallelism == 0 {
+		spec.Parallelism = defaults.Parallelism
+	}
+	if spec.ActiveDeadlineSeconds == nil {
+		spec.ActiveDeadlineSeconds = defaults.ActiveDeadlineSeconds
+	}
+	if spec.TTLStrategy == nil {
+		spec.TTLStrategy = defaults.TTLStrategy
+	}
+	if spec.PodGC == nil {
+		spec.PodGC = defaults.PodGC
+	}
+
+	return spec
+}
+
+// Namespaces workflows of this instance run in, the argo-workflows namespace comes first.
+func (cfg *Config) WorkflowNamespaces() []string {
+	namespaces := []string{cfg.ArgoApi.Namespace}
+	for _, workflow := range cfg.Workflows {
+		if !slices.Contains(namespaces, workflow.Spec.Namespace) {
+			namespaces = append(namespaces, workflow.Spec.Namespace)
+		}
+	}
+
+	return namespaces
 }
@@ -163,6 +163,12 @@ postgres:
 	// Check ArgoApi
 	assert.Equal(t, "https://localhost:2746", config.ArgoApi.Url, "Argo API URL should match")
 	assert.Equal(t, "argo", config.ArgoApi.Namespace, "Argo namespace should match")
+	assert.Equal(
+		t,
+		"compchem-fileprocessor",
+		config.ArgoApi.Instance,
+		"Argo instance should default",
+	)
 
 	// Check CompchemApi
 	assert.Equal(
@@ -197,3 +203,160 @@ postgres:
 		"Processing template value should match",
 	)
 }
+
+func TestValidateProcessingTemplates_ChainedTemplates_NoErrors(t *testing.T) {
+	errs := make(map[string]string)
+
+	validateProcessingTemplates([]ProcessingTemplate{
+		{Name: "convert-template", Template: "convert"},
+		{Name: "annotate-template", Template: "annotate", DependsOn: []string{"convert-template"}},
+		{
+			Name:      "summarize-template",
+			Template:  "summarize",
+			DependsOn: []string{"annotate-template", "convert-template"},
+		},
+	}, 0, errs)
+
+	assert.Empty(t, errs)
+}
+
+func TestValidateProcessingTemplates_DependencyCycle_CycleRejected(t *testing.T) {
+	errs := make(map[string]string)
+
+	validateProcessingTemplates([]ProcessingTemplate{
+		{Name: "convert", Template: "convert"},
+		{Name: "annotate", Template: "annotate", DependsOn: []string{"convert", "summarize"}},
+		{Name: "summarize", Template: "summarize", DependsOn: []string{"annotate"}},
+	}, 1, errs)
+
+	assert.Equal(
+		t,
+		map[string]string{"template-1-cycle": "dependency cycle annotate -> summarize -> annotate"},
+		errs,
+	)
+}
+
+func TestValidateProcessingTemplates_UnknownDependencyOrDuplicateName_Rejected(t *testing.T) {
+	errs := make(map[string]string)
+
+	validateProcessingTemplates([]ProcessingTemplate{
+		{Name: "convert-template", Template: "convert"},
+		{Name: "convert-template", Template: "convert-legacy"},
+		{Name: "annotate-template", Template: "annotate", DependsOn: []string{"convert"}},
+	}, 0, errs)
+
+	assert.Equal(t, map[string]string{
+		"template-0-1": "duplicate template name convert-template",
+		"template-0-2": "unknown dependency convert",
+	}, errs)
+}
+
+func TestValidateProcessingTemplates_SameTemplateInTwoSteps_NoErrors(t *testing.T) {
+	errs := make(map[string]string)
+
+	validateProcessingTemplates([]ProcessingTemplate{
+		{Name: "convert-input", Template: "convert"},
+		{Name: "annotate", Template: "annotate", DependsOn: []string{"convert-input"}},
+		{Name: "convert-annotated", Template: "convert", DependsOn: []string{"annotate"}},
+	}, 0, errs)
+
+	assert.Empty(t, errs)
+}
+
+func TestValidateProcessingTemplates_ExecutionSettingsOk_NoErrors(t *testing.T) {
+	errs := make(map[string]string)
+
+	validateProcessingTemplates([]ProcessingTemplate{
+		{
+			Name:                  "count-words-template",
+			Template:              "count-words",
+			RetryStrategy:         &RetryStrategy{Limit: 3, RetryPolicy: "OnError", Backoff: &Backoff{Factor: 2}},
+			ActiveDeadlineSeconds: 600,
+			Tolerations:           []Toleration{{Key: "gpu", Operator: "Exists", Effect: "NoSchedule"}},
+		},
+	}, 0, errs)
+
+	assert.Empty(t, errs)
+}
+
+func TestValidateProcessingTemplates_ExecutionSettingsInvalid_Rejected(t *testing.T) {
+	errs := make(map[string]string)
+
+	validateProcessingTemplates([]ProcessingTemplate{
+		{
+			Name:          "count-words-template",
+			Template:      "count-words",
+			RetryStrategy: &RetryStrategy{Limit: 3, RetryPolicy: "Sometimes"},
+		},
+		{
+			Name:        "annotate-template",
+			Template:    "annotate",
+			Tolerations: []Toleration{{Key: "gpu", Operator: "Contains"}},
+		},
+	}, 0, errs)
+
+	assert.Equal(t, "unknown retry policy Sometimes", errs["template-0-0-execution"])
+	assert.Equal(t, "unknown toleration operator Contains", errs["template-0-1-execution"])
+}
+
+func TestResolveWorkflowSpec_DefaultsConfigured_UnsetOptionsDefaulted(t *testing.T) {
+	defaultPriority := int32(1)
+	priority := int32(10)
+	deadline := int64(3600)
+	argo := ArgoApi{
+		Namespace: "argo",
+		Defaults: WorkflowSpec{
+			ServiceAccountName:    "compchem-workflow",
+			Priority:              &defaultPriority,
+			ActiveDeadlineSeconds: &deadline,
+			PodGC:                 &PodGC{Strategy: "OnPodSuccess"},
+		},
+	}
+
+	resolved := resolveWorkflowSpec(WorkflowSpec{Priority: &priority}, argo)
+	overridden := resolveWorkflowSpec(WorkflowSpec{Namespace: "compchem-gpu"}, argo)
+
+	assert.Equal(t, "argo", resolved.Namespace)
+	assert.Equal(t, "compchem-workflow", resolved.ServiceAccountName)
+	assert.Equal(t, int32(10), *resolved.Priority)
+	assert.Equal(t, "OnPodSuccess", resolved.PodGC.Strategy)
+	assert.Equal(t, int64(3600), *resolved.ActiveDeadlineSeconds)
+	assert.Nil(t, resolved.TTLStrategy)
+	assert.Equal(t, "compchem-gpu", overridden.Namespace)
+	assert.Equal(t, int32(1), *overridden.Priority)
+}
+
+func TestValidateWorkflowSpec_InvalidOptions_Rejected(t *testing.T) {
+	negative := int32(-1)
+
+	assert.Empty(t, validateWorkflowSpec(WorkflowSpec{PodGC: &PodGC{Strategy: "OnWorkflowSuccess"}}))
+	assert.Equal(t, "negative parallelism", validateWorkflowSpec(WorkflowSpec{Parallelism: -1}))
+	assert.Equal(
+		t,
+		"negative ttl",
+		validateWorkflowSpec(WorkflowSpec{TTLStrategy: &TTLStrategy{SecondsAfterFailure: &negative}}),
+	)
+	assert.Equal(
+		t,
+		"unknown pod gc strategy Never",
+		validateWorkflowSpec(WorkflowSpec{PodGC: &PodGC{Strategy: "Never"}}),
+	)
+	assert.Equal(
+		t,
+		"active deadline has to be positive",
+		validateWorkflowSpec(WorkflowSpec{ActiveDeadlineSeconds: new(int64)}),
+	)
+}
+
+func TestWorkflowNamespaces_NamespaceOverridden_EachNamespaceOnce(t *testing.T) {
+	cfg := &Config{
+		ArgoApi: ArgoApi{Namespace: "argo"},
+		Workflows: []WorkflowConfig{
+			{Spec: WorkflowSpec{Namespace: "argo"}},
+			{Spec: WorkflowSpec{Namespace: "compchem-gpu"}},
+			{Spec: WorkflowSpec{Namespace: "compchem-gpu"}},
+		},
+	}
+
+	assert.Equal(t, []string{"argo", "compchem-gpu"}, cfg.WorkflowNamespaces())
+}
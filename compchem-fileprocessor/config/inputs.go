@@ -0,0 +1,151 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+)
+
+// Slot names become argo artifact and parameter names.
+var inputNameRegex = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// Named input of a workflow taking several kinds of files. Processing templates get the files of
+// the slot as the artifact of the same name and their keys space separated in the <name>-files
+// parameter. Min files defaults to one, zero makes the slot optional, max files of zero means
+// no limit.
+type InputSlot struct {
+	Name     string    `yaml:"name"`
+	Match    MatchRule `yaml:"match"`
+	MinFiles *int      `yaml:"min-files"`
+	MaxFiles int       `yaml:"max-files"`
+}
+
+func (s InputSlot) Min() int {
+	if s.MinFiles == nil {
+		return 1
+	}
+	return *s.MinFiles
+}
+
+func (s InputSlot) Full(count int) bool {
+	return s.MaxFiles > 0 && count >= s.MaxFiles
+}
+
+// Assigns files to the input slots of the workflow. Files with a requested slot are put into it,
+// the others into the first slot accepting them that is not full yet. Errors are keyed by the
+// file name for files that can not be assigned and by the slot name for unmet cardinality.
+func (w WorkflowConfig) AssignInputs(
+	files []FileInfo,
+	requested map[string]string,
+) (map[string][]string, map[string]string) {
+	assigned := make(map[string][]string)
+	errs := make(map[string]string)
+
+	for _, file := range files {
+		slot, present := requested[file.Name]
+		if !present {
+			continue
+		}
+
+		index := slices.IndexFunc(w.Inputs, func(s InputSlot) bool { return s.Name == slot })
+		if index < 0 {
+			errs[file.Name] = "unknown input " + slot
+			continue
+		}
+		if err := w.Inputs[index].Match.Match(file); err != nil {
+			errs[file.Name] = fmt.Sprintf("not accepted by input %s, %v", slot, err)
+			continue
+		}
+		assigned[slot] = append(assigned[slot], file.Name)
+	}
+
+	for _, file := range files {
+		if _, present := requested[file.Name]; present {
+			continue
+		}
+
+		index := slices.IndexFunc(w.Inputs, func(s InputSlot) bool {
+			return !s.Full(len(assigned[s.Name])) && s.Match.Match(file) == nil
+		})
+		if index < 0 {
+			errs[file.Name] = "not accepted by any input"
+			continue
+		}
+		assigned[w.Inputs[index].Name] = append(assigned[w.Inputs[index].Name], file.Name)
+	}
+
+	for _, slot := range w.Inputs {
+		count := len(assigned[slot.Name])
+		if count < slot.Min() {
+			errs[slot.Name] = fmt.Sprintf("requires at least %d files, got %d", slot.Min(), count)
+		}
+		if slot.MaxFiles > 0 && count > slot.MaxFiles {
+			errs[slot.Name] = fmt.Sprintf("accepts at most %d files, got %d", slot.MaxFiles, count)
+		}
+	}
+
+	return assigned, errs
+}
+
+// Returns empty string when the slots are valid.
+func validateInputSlots(workflow WorkflowConfig) string {
+	if workflow.FanOut {
+		return "fan-out is not supported with inputs"
+	}
+
+	known := make(map[string]bool)
+	for _, slot := range workflow.Inputs {
+		if !inputNameRegex.MatchString(slot.Name) || slot.Name == "input-files" {
+			return "invalid input name " + slot.Name
+		}
+		if known[slot.Name] {
+			return "duplicate input " + slot.Name
+		}
+		known[slot.Name] = true
+
+		if slot.Min() < 0 || slot.MaxFiles < 0 {
+			return "negative file count of input " + slot.Name
+		}
+		if slot.MaxFiles > 0 && slot.Min() > slot.MaxFiles {
+			return "min-files is greater than max-files of input " + slot.Name
+		}
+		if msg := validateMatchRule(slot.Match); msg != "" {
+			return msg + " of input " + slot.Name
+		}
+	}
+
+	return ""
+}
+
+// Subset of the workflow files a processing template gets, files of the listed input slots which
+// satisfy the match rule. Only templates without dependencies can filter their inputs.
+type InputFilter struct {
+	Slots     []string `yaml:"slots"`
+	MatchRule `yaml:",inline"`
+}
+
+// Slot is the input slot the file is assigned to, empty for workflows without input slots.
+func (f *InputFilter) Accepts(file FileInfo, slot string) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.Slots) > 0 && !slices.Contains(f.Slots, slot) {
+		return false
+	}
+
+	return f.Match(file) == nil
+}
+
+// Returns empty string when the filter of the template is valid.
+func validateInputFilter(template ProcessingTemplate, slots []InputSlot) string {
+	if len(template.DependsOn) > 0 {
+		return "input filter of a template with depends-on"
+	}
+	for _, slot := range template.Input.Slots {
+		if !slices.ContainsFunc(slots, func(s InputSlot) bool { return s.Name == slot }) {
+			return "unknown input " + slot
+		}
+	}
+
+	return validateMatchRule(template.Input.MatchRule)
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func gromacsInputs() WorkflowConfig {
+	zero := 0
+	return WorkflowConfig{
+		Name: "trajectory-analysis",
+		Inputs: []InputSlot{
+			{Name: "structure", Match: MatchRule{Extensions: []string{"tpr"}}, MaxFiles: 1},
+			{Name: "trajectory", Match: MatchRule{Extensions: []string{"xtc", "trr"}}},
+			{Name: "index", Match: MatchRule{Extensions: []string{"ndx"}}, MinFiles: &zero},
+		},
+	}
+}
+
+func TestAssignInputs_FilesMatchSlots_AssignedInDeclarationOrder(t *testing.T) {
+	files := []FileInfo{
+		{Name: "run.tpr"},
+		{Name: "part1.xtc"},
+		{Name: "part2.xtc"},
+	}
+
+	assigned, errs := gromacsInputs().AssignInputs(files, nil)
+
+	assert.Empty(t, errs)
+	assert.Equal(t, map[string][]string{
+		"structure":  {"run.tpr"},
+		"trajectory": {"part1.xtc", "part2.xtc"},
+	}, assigned)
+}
+
+func TestAssignInputs_RequestedSlots_Validated(t *testing.T) {
+	files := []FileInfo{
+		{Name: "run.tpr"},
+		{Name: "other.tpr"},
+		{Name: "part1.xtc"},
+		{Name: "notes.txt"},
+	}
+	requested := map[string]string{
+		"part1.xtc": "structure",
+		"notes.txt": "logs",
+	}
+
+	_, errs := gromacsInputs().AssignInputs(files, requested)
+
+	assert.Equal(t, map[string]string{
+		"part1.xtc":  "not accepted by input structure, file part1.xtc does not have extension [tpr]",
+		"notes.txt":  "unknown input logs",
+		"other.tpr":  "not accepted by any input",
+		"trajectory": "requires at least 1 files, got 0",
+	}, errs)
+}
+
+func TestValidateWorkflows_InputSlots_Validated(t *testing.T) {
+	templates := []ProcessingTemplate{{Name: "analysis", Template: "analysis"}}
+	fanOut := gromacsInputs()
+	fanOut.FanOut = true
+	duplicate := gromacsInputs()
+	duplicate.Inputs = append(duplicate.Inputs, InputSlot{Name: "index"})
+	invalidName := gromacsInputs()
+	invalidName.Inputs[0].Name = "Structure"
+	workflows := []WorkflowConfig{gromacsInputs(), fanOut, duplicate, invalidName}
+	for i := range workflows {
+		workflows[i].ProcessingTemplates = templates
+	}
+	errors := make(map[string]string)
+
+	validateWorkflows(workflows, errors)
+
+	assert.Equal(t, map[string]string{
+		"inputs-1": "fan-out is not supported with inputs",
+		"inputs-2": "duplicate input index",
+		"inputs-3": "invalid input name Structure",
+	}, errors)
+}
+
+func TestValidateWorkflows_InputFilters_Validated(t *testing.T) {
+	workflow := gromacsInputs()
+	workflow.ProcessingTemplates = []ProcessingTemplate{
+		{Name: "rmsd", Template: "rmsd", Input: &InputFilter{Slots: []string{"trajectory"}}},
+		{Name: "energy", Template: "energy", Input: &InputFilter{Slots: []string{"energy"}}},
+		{Name: "plot", Template: "plot", DependsOn: []string{"rmsd"}, Input: &InputFilter{}},
+		{Name: "render", Template: "render", Input: &InputFilter{MatchRule: MatchRule{Globs: []string{"["}}}},
+	}
+	errors := make(map[string]string)
+
+	validateWorkflows([]WorkflowConfig{workflow}, errors)
+
+	assert.Equal(t, map[string]string{
+		"template-0-1-input": "unknown input energy",
+		"template-0-2-input": "input filter of a template with depends-on",
+		"template-0-3-input": "invalid glob [",
+	}, errors)
+}
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// File as seen by the matching rules, size is unknown when the caller did not send it.
+type FileInfo struct {
+	Name     string
+	Mimetype string
+	Size     *int64
+}
+
+// Rule deciding which files a workflow processes. Every condition that is set has to hold, lists
+// match when any of their entries matches. Mimetypes may end with /* to match a whole family,
+// extensions may be compound like tar.gz and are compared case insensitively. Globs and the regex
+// are matched against the file name, size bounds are inclusive and reject files of unknown size.
+// All, any and not combine nested rules.
+type MatchRule struct {
+	Mimetypes  []string    `yaml:"mimetypes"`
+	Extensions []string    `yaml:"extensions"`
+	Globs      []string    `yaml:"globs"`
+	Regex      string      `yaml:"regex"`
+	MinSize    *int64      `yaml:"min-size"`
+	MaxSize    *int64      `yaml:"max-size"`
+	All        []MatchRule `yaml:"all"`
+	Any        []MatchRule `yaml:"any"`
+	Not        *MatchRule  `yaml:"not"`
+}
+
+// Rule of the workflow, the plain mimetype and extension are combined with the match rule.
+func (w WorkflowConfig) Rule() MatchRule {
+	rule := MatchRule{}
+	if w.Mimetype != "" {
+		rule.Mimetypes = []string{w.Mimetype}
+	}
+	if w.Extension != "" {
+		rule.Extensions = []string{w.Extension}
+	}
+	if w.Match != nil {
+		rule.All = []MatchRule{*w.Match}
+	}
+
+	return rule
+}
+
+// Returns nil when the workflow accepts the file, otherwise the error explains why it does not.
+func (w WorkflowConfig) MatchFile(file FileInfo) error {
+	return w.Rule().Match(file)
+}
+
+func (r MatchRule) Match(file FileInfo) error {
+	if len(r.Mimetypes) > 0 && !slices.ContainsFunc(r.Mimetypes, func(m string) bool {
+		return matchMimetype(m, file.Mimetype)
+	}) {
+		return fmt.Errorf("mimetype %s is not one of %v", file.Mimetype, r.Mimetypes)
+	}
+
+	if len(r.Extensions) > 0 && !slices.ContainsFunc(r.Extensions, func(e string) bool {
+		return matchExtension(e, file.Name)
+	}) {
+		return fmt.Errorf("file %s does not have extension %v", file.Name, r.Extensions)
+	}
+
+	if len(r.Globs) > 0 && !slices.ContainsFunc(r.Globs, func(g string) bool {
+		matched, err := path.Match(g, file.Name)
+		return err == nil && matched
+	}) {
+		return fmt.Errorf("file %s does not match any of %v", file.Name, r.Globs)
+	}
+
+	if r.Regex != "" {
+		matched, err := regexp.MatchString(r.Regex, file.Name)
+		if err != nil || !matched {
+			return fmt.Errorf("file %s does not match %s", file.Name, r.Regex)
+		}
+	}
+
+	if r.MinSize != nil || r.MaxSize != nil {
+		if file.Size == nil {
+			return fmt.Errorf("size of file %s is unknown", file.Name)
+		}
+		if r.MinSize != nil && *file.Size < *r.MinSize {
+			return fmt.Errorf("file %s has %d bytes, at least %d required", file.Name, *file.Size, *r.MinSize)
+		}
+		if r.MaxSize != nil && *file.Size > *r.MaxSize {
+			return fmt.Errorf("file %s has %d bytes, at most %d allowed", file.Name, *file.Size, *r.MaxSize)
+		}
+	}
+
+	for _, rule := range r.All {
+		if err := rule.Match(file); err != nil {
+			return err
+		}
+	}
+
+	if len(r.Any) > 0 {
+		reasons := []string{}
+		for _, rule := range r.Any {
+			err := rule.Match(file)
+			if err == nil {
+				reasons = nil
+				break
+			}
+			reasons = append(reasons, err.Error())
+		}
+		if reasons != nil {
+			return errors.New("none of the alternatives matched: " + strings.Join(reasons, "; "))
+		}
+	}
+
+	if r.Not != nil && r.Not.Match(file) == nil {
+		return fmt.Errorf("file %s matches an excluded rule", file.Name)
+	}
+
+	return nil
+}
+
+func matchMimetype(pattern string, mimetype string) bool {
+	if family, found := strings.CutSuffix(pattern, "/*"); found {
+		return strings.HasPrefix(mimetype, family+"/")
+	}
+
+	return pattern == mimetype
+}
+
+func matchExtension(extension string, fileName string) bool {
+	suffix := "." + strings.ToLower(strings.TrimPrefix(extension, "."))
+	name := strings.ToLower(fileName)
+
+	return len(name) > len(suffix) && strings.HasSuffix(name, suffix)
+}
+
+// Returns empty string when the rule is valid.
+func validateMatchRule(rule MatchRule) string {
+	for _, glob := range rule.Globs {
+		if _, err := path.Match(glob, ""); err != nil {
+			return "invalid glob " + glob
+		}
+	}
+	if rule.Regex != "" {
+		if _, err := regexp.Compile(rule.Regex); err != nil {
+			return "invalid regex " + rule.Regex
+		}
+	}
+	if rule.MinSize != nil && *rule.MinSize < 0 || rule.MaxSize != nil && *rule.MaxSize < 0 {
+		return "negative size bound"
+	}
+	if rule.MinSize != nil && rule.MaxSize != nil && *rule.MinSize > *rule.MaxSize {
+		return "min-size is greater than max-size"
+	}
+
+	nested := append(slices.Clone(rule.All), rule.Any...)
+	if rule.Not != nil {
+		nested = append(nested, *rule.Not)
+	}
+	for _, r := range nested {
+		if msg := validateMatchRule(r); msg != "" {
+			return msg
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch_ListsAndCompoundExtensions_Matched(t *testing.T) {
+	rule := MatchRule{
+		Mimetypes:  []string{"application/gzip", "chemical/*"},
+		Extensions: []string{".tar.gz", "XYZ"},
+	}
+
+	assert.NoError(t, rule.Match(FileInfo{Name: "run.TAR.GZ", Mimetype: "application/gzip"}))
+	assert.NoError(t, rule.Match(FileInfo{Name: "water.xyz", Mimetype: "chemical/x-xyz"}))
+	assert.EqualError(
+		t,
+		rule.Match(FileInfo{Name: "run.gz", Mimetype: "application/gzip"}),
+		"file run.gz does not have extension [.tar.gz XYZ]",
+	)
+	assert.EqualError(
+		t,
+		rule.Match(FileInfo{Name: "water.xyz", Mimetype: "text/plain"}),
+		"mimetype text/plain is not one of [application/gzip chemical/*]",
+	)
+}
+
+func TestMatch_NamesAndSizes_ReasonReturned(t *testing.T) {
+	minSize := int64(10)
+	maxSize := int64(100)
+	rule := MatchRule{
+		Globs:   []string{"traj-*"},
+		Regex:   `-\d+\.`,
+		MinSize: &minSize,
+		MaxSize: &maxSize,
+	}
+	size := int64(50)
+	large := int64(500)
+
+	assert.NoError(t, rule.Match(FileInfo{Name: "traj-1.xtc", Size: &size}))
+	assert.EqualError(t, rule.Match(FileInfo{Name: "run-1.xtc", Size: &size}), "file run-1.xtc does not match any of [traj-*]")
+	assert.EqualError(t, rule.Match(FileInfo{Name: "traj-a.xtc", Size: &size}), `file traj-a.xtc does not match -\d+\.`)
+	assert.EqualError(t, rule.Match(FileInfo{Name: "traj-1.xtc"}), "size of file traj-1.xtc is unknown")
+	assert.EqualError(t, rule.Match(FileInfo{Name: "traj-1.xtc", Size: &large}), "file traj-1.xtc has 500 bytes, at most 100 allowed")
+}
+
+func TestMatch_BooleanCombinations_Evaluated(t *testing.T) {
+	rule := MatchRule{
+		Any: []MatchRule{
+			{Extensions: []string{"pdb"}},
+			{Mimetypes: []string{"chemical/x-pdb"}},
+		},
+		Not: &MatchRule{Globs: []string{"tmp-*"}},
+	}
+
+	assert.NoError(t, rule.Match(FileInfo{Name: "protein.pdb", Mimetype: "text/plain"}))
+	assert.NoError(t, rule.Match(FileInfo{Name: "protein", Mimetype: "chemical/x-pdb"}))
+	assert.EqualError(
+		t,
+		rule.Match(FileInfo{Name: "tmp-protein.pdb"}),
+		"file tmp-protein.pdb matches an excluded rule",
+	)
+	assert.EqualError(
+		t,
+		rule.Match(FileInfo{Name: "protein.cif", Mimetype: "text/plain"}),
+		"none of the alternatives matched: file protein.cif does not have extension [pdb]; "+
+			"mimetype text/plain is not one of [chemical/x-pdb]",
+	)
+}
+
+func TestWorkflowMatchFile_MimetypeExtensionAndRule_AllRequired(t *testing.T) {
+	maxSize := int64(1000)
+	workflow := WorkflowConfig{
+		Mimetype:  "text/plain",
+		Extension: "txt",
+		Match:     &MatchRule{MaxSize: &maxSize},
+	}
+	size := int64(10)
+
+	assert.NoError(t, workflow.MatchFile(FileInfo{Name: "words.txt", Mimetype: "text/plain", Size: &size}))
+	assert.Error(t, workflow.MatchFile(FileInfo{Name: "words.txt", Mimetype: "text/plain"}))
+	assert.Error(t, workflow.MatchFile(FileInfo{Name: "words", Mimetype: "text/plain", Size: &size}))
+}
+
+func TestValidateWorkflows_MatchRule_ReplacesMimetypeAndExtension(t *testing.T) {
+	minSize := int64(10)
+	maxSize := int64(1)
+	templates := []ProcessingTemplate{{Name: "count-words", Template: "count-words"}}
+	workflows := []WorkflowConfig{
+		{Name: "ok", Match: &MatchRule{Globs: []string{"*.txt"}}, ProcessingTemplates: templates},
+		{Name: "regex", Match: &MatchRule{Regex: "("}, ProcessingTemplates: templates},
+		{Name: "sizes", Match: &MatchRule{Any: []MatchRule{{MinSize: &minSize, MaxSize: &maxSize}}}, ProcessingTemplates: templates},
+	}
+	errors := make(map[string]string)
+
+	validateWorkflows(workflows, errors)
+
+	assert.Equal(t, map[string]string{
+		"match-1": "invalid regex (",
+		"match-2": "min-size is greater than max-size",
+	}, errors)
+}
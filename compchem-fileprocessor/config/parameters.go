@@ -0,0 +1,168 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+const (
+	ParameterString  = "string"
+	ParameterInteger = "integer"
+	ParameterNumber  = "number"
+	ParameterBoolean = "boolean"
+)
+
+var parameterTypes = []string{"", ParameterString, ParameterInteger, ParameterNumber, ParameterBoolean}
+
+// Parameter a processing template accepts from the start request, values are passed to the task
+// as argo parameters of the same name. Missing type means string, a parameter without default
+// has to be supplied when it is required.
+type TemplateParameter struct {
+	Name     string   `yaml:"name" json:"name"`
+	Type     string   `yaml:"type" json:"type,omitempty"`
+	Required bool     `yaml:"required" json:"required,omitempty"`
+	Default  *string  `yaml:"default" json:"default,omitempty"`
+	Enum     []string `yaml:"enum" json:"enum,omitempty"`
+	Minimum  *float64 `yaml:"minimum" json:"minimum,omitempty"`
+	Maximum  *float64 `yaml:"maximum" json:"maximum,omitempty"`
+	Pattern  string   `yaml:"pattern" json:"pattern,omitempty"`
+}
+
+// Returns value in the form passed to argo, booleans and numbers are accepted as json values or
+// strings.
+func (p TemplateParameter) Validate(value any) (string, error) {
+	result, err := p.normalize(value)
+	if err != nil {
+		return "", err
+	}
+
+	if len(p.Enum) > 0 && !slices.Contains(p.Enum, result) {
+		return "", fmt.Errorf("must be one of %v", p.Enum)
+	}
+
+	if p.Type == ParameterInteger || p.Type == ParameterNumber {
+		number, _ := strconv.ParseFloat(result, 64)
+		if p.Minimum != nil && number < *p.Minimum {
+			return "", fmt.Errorf("must be at least %v", *p.Minimum)
+		}
+		if p.Maximum != nil && number > *p.Maximum {
+			return "", fmt.Errorf("must be at most %v", *p.Maximum)
+		}
+	}
+
+	if p.Pattern != "" {
+		matched, err := regexp.MatchString(p.Pattern, result)
+		if err != nil || !matched {
+			return "", fmt.Errorf("must match %s", p.Pattern)
+		}
+	}
+
+	return result, nil
+}
+
+func (p TemplateParameter) normalize(value any) (string, error) {
+	switch p.Type {
+	case ParameterBoolean:
+		switch v := value.(type) {
+		case bool:
+			return strconv.FormatBool(v), nil
+		case string:
+			parsed, err := strconv.ParseBool(v)
+			if err == nil {
+				return strconv.FormatBool(parsed), nil
+			}
+		}
+		return "", errors.New("must be a boolean")
+	case ParameterInteger:
+		number, err := toNumber(value)
+		if err != nil || number != float64(int64(number)) {
+			return "", errors.New("must be an integer")
+		}
+		return strconv.FormatInt(int64(number), 10), nil
+	case ParameterNumber:
+		number, err := toNumber(value)
+		if err != nil {
+			return "", errors.New("must be a number")
+		}
+		return strconv.FormatFloat(number, 'f', -1, 64), nil
+	default:
+		v, ok := value.(string)
+		if !ok {
+			return "", errors.New("must be a string")
+		}
+		return v, nil
+	}
+}
+
+func toNumber(value any) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+
+	return 0, errors.New("not a number")
+}
+
+// Returns empty string when the declared parameter is valid.
+func validateTemplateParameter(parameter TemplateParameter) string {
+	if parameter.Name == "" {
+		return "missing parameter name"
+	}
+	if !slices.Contains(parameterTypes, parameter.Type) {
+		return "unknown parameter type " + parameter.Type
+	}
+	if parameter.Pattern != "" {
+		if _, err := regexp.Compile(parameter.Pattern); err != nil {
+			return "invalid pattern of parameter " + parameter.Name
+		}
+	}
+	if parameter.Default != nil {
+		if _, err := parameter.Validate(*parameter.Default); err != nil {
+			return "invalid default of parameter " + parameter.Name + ", " + err.Error()
+		}
+	}
+
+	return ""
+}
+
+// Validates values supplied for the template and fills in defaults. Errors are keyed by the
+// parameter name, parameters without value and default are left for argo to default.
+func (t ProcessingTemplate) ResolveParameters(values map[string]any) (map[string]string, map[string]string) {
+	resolved := make(map[string]string)
+	errs := make(map[string]string)
+
+	for name := range values {
+		if !slices.ContainsFunc(t.Parameters, func(p TemplateParameter) bool { return p.Name == name }) {
+			errs[name] = "unknown parameter"
+		}
+	}
+
+	for _, parameter := range t.Parameters {
+		value, present := values[parameter.Name]
+		if !present && parameter.Default != nil {
+			value, present = *parameter.Default, true
+		}
+		if !present {
+			if parameter.Required {
+				errs[parameter.Name] = "required"
+			}
+			continue
+		}
+
+		result, err := parameter.Validate(value)
+		if err != nil {
+			errs[parameter.Name] = err.Error()
+			continue
+		}
+		resolved[parameter.Name] = result
+	}
+
+	return resolved, errs
+}
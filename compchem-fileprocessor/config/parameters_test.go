@@ -0,0 +1,99 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateParameterValidate_TypedValues_NormalizedForArgo(t *testing.T) {
+	boolean := TemplateParameter{Name: "keep", Type: ParameterBoolean}
+	integer := TemplateParameter{Name: "frames", Type: ParameterInteger}
+	number := TemplateParameter{Name: "threshold", Type: ParameterNumber}
+
+	value, err := boolean.Validate(false)
+	assert.NoError(t, err)
+	assert.Equal(t, "false", value)
+
+	value, err = boolean.Validate("TRUE")
+	assert.NoError(t, err)
+	assert.Equal(t, "true", value)
+
+	value, err = integer.Validate(float64(12))
+	assert.NoError(t, err)
+	assert.Equal(t, "12", value)
+
+	value, err = number.Validate("0.25")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.25", value)
+}
+
+func TestTemplateParameterValidate_ConstraintsViolated_ErrReturned(t *testing.T) {
+	minimum := float64(1)
+	maximum := float64(10)
+
+	_, err := TemplateParameter{Type: ParameterInteger}.Validate(1.5)
+	assert.EqualError(t, err, "must be an integer")
+
+	_, err = TemplateParameter{Type: ParameterBoolean}.Validate("maybe")
+	assert.EqualError(t, err, "must be a boolean")
+
+	_, err = TemplateParameter{}.Validate(true)
+	assert.EqualError(t, err, "must be a string")
+
+	_, err = TemplateParameter{Type: ParameterInteger, Minimum: &minimum, Maximum: &maximum}.Validate(11)
+	assert.EqualError(t, err, "must be at most 10")
+
+	_, err = TemplateParameter{Enum: []string{"fast", "precise"}}.Validate("slow")
+	assert.EqualError(t, err, "must be one of [fast precise]")
+
+	_, err = TemplateParameter{Pattern: "^https://"}.Validate("http://localhost")
+	assert.EqualError(t, err, "must match ^https://")
+}
+
+func TestResolveParameters_ValuesAndDefaults_Resolved(t *testing.T) {
+	keep := "true"
+	template := ProcessingTemplate{
+		Name:     "simulation-annotation-template",
+		Template: "simulation-annotation",
+		Parameters: []TemplateParameter{
+			{Name: "keep", Type: ParameterBoolean, Default: &keep},
+			{Name: "endpoint", Required: true},
+			{Name: "comment"},
+		},
+	}
+
+	resolved, errs := template.ResolveParameters(map[string]any{"endpoint": "https://localhost"})
+
+	assert.Empty(t, errs)
+	assert.Equal(t, map[string]string{"keep": "true", "endpoint": "https://localhost"}, resolved)
+}
+
+func TestResolveParameters_MissingAndUnknown_ErrorsPerParameter(t *testing.T) {
+	template := ProcessingTemplate{
+		Name:       "simulation-annotation-template",
+		Template:   "simulation-annotation",
+		Parameters: []TemplateParameter{{Name: "endpoint", Required: true}},
+	}
+
+	_, errs := template.ResolveParameters(map[string]any{"kep": true})
+
+	assert.Equal(t, map[string]string{"endpoint": "required", "kep": "unknown parameter"}, errs)
+}
+
+func TestValidateTemplateParameters_InvalidDeclarations_Rejected(t *testing.T) {
+	errs := make(map[string]string)
+	invalidDefault := "yes please"
+
+	validateTemplateParameters([]TemplateParameter{
+		{Name: "keep", Type: ParameterBoolean, Default: &invalidDefault},
+		{Name: "frames", Type: "int"},
+		{Name: "frames"},
+	}, "template-0-0", errs)
+
+	assert.Equal(t, map[string]string{
+		"template-0-0-parameter-0": "invalid default of parameter keep, must be a boolean",
+		"template-0-0-parameter-1": "unknown parameter type int",
+		"template-0-0-parameter-2": "duplicate parameter frames",
+	}, errs)
+}
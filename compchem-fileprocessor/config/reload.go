@@ -0,0 +1,194 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const defaultReloadInterval = 30 * time.Second
+
+// Workflow definitions the handlers use, replaced as a whole when the config file is reloaded or
+// the workflows managed through the admin api change. Managed workflows never shadow workflows of
+// the config file.
+type WorkflowSet struct {
+	Revision  string
+	LoadedAt  time.Time
+	Workflows []WorkflowConfig
+	Managed   []WorkflowConfig
+	all       []WorkflowConfig
+}
+
+func newWorkflowSet(revision string, workflows []WorkflowConfig, managed []WorkflowConfig) *WorkflowSet {
+	all := slices.Clone(workflows)
+	for _, workflow := range managed {
+		if !slices.ContainsFunc(workflows, func(w WorkflowConfig) bool { return w.Name == workflow.Name }) {
+			all = append(all, workflow)
+		}
+	}
+
+	return &WorkflowSet{
+		Revision:  revision,
+		LoadedAt:  time.Now(),
+		Workflows: workflows,
+		Managed:   managed,
+		all:       all,
+	}
+}
+
+// Holds the active workflow set, safe for concurrent use. Handlers read the set on every request
+// so a reload takes effect without a restart.
+type Workflows struct {
+	mu      sync.Mutex
+	active  atomic.Pointer[WorkflowSet]
+	watched []string
+}
+
+func NewWorkflows(cfg *Config) *Workflows {
+	workflows := &Workflows{watched: cfg.WorkflowNamespaces()}
+	workflows.active.Store(newWorkflowSet(cfg.Revision, cfg.Workflows, nil))
+
+	return workflows
+}
+
+func (w *Workflows) Active() *WorkflowSet {
+	return w.active.Load()
+}
+
+// Workflows of the config file followed by the managed ones.
+func (w *Workflows) List() []WorkflowConfig {
+	return w.active.Load().all
+}
+
+// Whether a watcher was started for the namespace, workflows in other namespaces need a restart.
+func (w *Workflows) Watches(namespace string) bool {
+	return slices.Contains(w.watched, namespace)
+}
+
+// Replaces the managed workflows, workflows of the config file are kept.
+func (w *Workflows) SetManaged(managed []WorkflowConfig) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	active := w.Active()
+	set := newWorkflowSet(active.Revision, active.Workflows, managed)
+	set.LoadedAt = active.LoadedAt
+	w.active.Store(set)
+}
+
+// Zero or negative when polling is disabled.
+func (r ReloadConfig) Interval() time.Duration {
+	if r.IntervalSeconds == 0 {
+		return defaultReloadInterval
+	}
+
+	return time.Duration(r.IntervalSeconds) * time.Second
+}
+
+func revisionOf(configBytes []byte) string {
+	hash := sha256.Sum256(configBytes)
+	return hex.EncodeToString(hash[:])[:12]
+}
+
+// Loads the config file again and activates its workflows, an invalid config is rejected and the
+// active workflows stay in use. Only workflows are reloaded, a workflow in a namespace without a
+// running watcher needs a restart. Returns whether a new revision was activated.
+func (w *Workflows) Reload(logger *zap.Logger, path string, watchedNamespaces []string) (bool, error) {
+	cfg, err := loadConfigFile(logger, path)
+	if err != nil {
+		return false, err
+	}
+	if cfg.Revision == w.Active().Revision {
+		return false, nil
+	}
+
+	for _, namespace := range cfg.WorkflowNamespaces() {
+		if !slices.Contains(watchedNamespaces, namespace) {
+			return false, fmt.Errorf("namespace %s is not watched, restart is required", namespace)
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.active.Store(newWorkflowSet(cfg.Revision, cfg.Workflows, w.Active().Managed))
+
+	return true, nil
+}
+
+// Reloads the workflows on SIGHUP and whenever the content of the config file changes, until ctx
+// is done.
+func RunReloader(
+	ctx context.Context,
+	logger *zap.Logger,
+	cfg *Config,
+	workflows *Workflows,
+) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	var tick <-chan time.Time
+	if interval := cfg.Reload.Interval(); interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	watchedNamespaces := cfg.WorkflowNamespaces()
+	reload := func(reason string) {
+		reloaded, err := workflows.Reload(logger, cfg.Path, watchedNamespaces)
+		if err != nil {
+			logger.Error(
+				"Config reload rejected, keeping active workflows",
+				zap.String("reason", reason),
+				zap.String("revision", workflows.Active().Revision),
+				zap.Error(err),
+			)
+			return
+		}
+		if reloaded {
+			logger.Info(
+				"Config reloaded",
+				zap.String("reason", reason),
+				zap.String("revision", workflows.Active().Revision),
+				zap.Int("workflows", len(workflows.List())),
+			)
+		}
+	}
+
+	// a rejected revision is not retried until the file changes again
+	seen := workflows.Active().Revision
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			reload("sighup")
+		case <-tick:
+			if revision := fileRevision(cfg.Path); revision != seen {
+				seen = revision
+				reload("file changed")
+			}
+		}
+	}
+}
+
+// Empty for an unreadable file, the reload reports the error.
+func fileRevision(path string) string {
+	configBytes, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	return revisionOf(configBytes)
+}
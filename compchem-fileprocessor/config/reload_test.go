@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+const reloadConfigTemplate = `
+argo-workflows:
+  url: https://localhost:2746
+  namespace: argo
+
+compchem:
+  url: https://localhost:5000
+
+workflows:
+%s
+
+postgres:
+  auth:
+    user: test
+    password: test123
+  host: host.com
+  port: 12345
+  database: postgres
+`
+
+func writeReloadConfig(t *testing.T, path string, workflows string) {
+	content := []byte(fmt.Sprintf(reloadConfigTemplate, workflows))
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+}
+
+const countWords = `
+  - name: count-words
+    mimetype: text/plain
+    extension: txt
+    processing-templates:
+      - name: count-words-template
+        template: count-words`
+
+func TestReload_ConfigChanged_WorkflowsSwapped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server-config.yaml")
+	writeReloadConfig(t, path, countWords)
+	cfg, err := loadConfigFile(zap.NewNop(), path)
+	assert.NoError(t, err)
+	workflows := NewWorkflows(cfg)
+
+	reloaded, err := workflows.Reload(zap.NewNop(), path, cfg.WorkflowNamespaces())
+	assert.NoError(t, err)
+	assert.False(t, reloaded, "unchanged file keeps the revision")
+
+	writeReloadConfig(t, path, countWords+`
+  - name: count-words-json
+    mimetype: application/json
+    extension: json
+    processing-templates:
+      - name: count-words-template
+        template: count-words`)
+	reloaded, err = workflows.Reload(zap.NewNop(), path, cfg.WorkflowNamespaces())
+
+	assert.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.NotEqual(t, cfg.Revision, workflows.Active().Revision)
+	assert.Len(t, workflows.List(), 2)
+}
+
+func TestReload_InvalidConfig_ActiveWorkflowsKept(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server-config.yaml")
+	writeReloadConfig(t, path, countWords)
+	cfg, err := loadConfigFile(zap.NewNop(), path)
+	assert.NoError(t, err)
+	workflows := NewWorkflows(cfg)
+
+	writeReloadConfig(t, path, `
+  - name: count-words`)
+	_, err = workflows.Reload(zap.NewNop(), path, cfg.WorkflowNamespaces())
+	assert.Error(t, err)
+
+	writeReloadConfig(t, path, countWords+`
+    spec:
+      namespace: compchem-gpu`)
+	_, err = workflows.Reload(zap.NewNop(), path, cfg.WorkflowNamespaces())
+	assert.EqualError(t, err, "namespace compchem-gpu is not watched, restart is required")
+
+	assert.Equal(t, cfg.Revision, workflows.Active().Revision)
+	assert.Equal(t, cfg.Workflows, workflows.List())
+}
+
+func TestSetManaged_ManagedWorkflows_ListedAfterConfigWorkflows(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server-config.yaml")
+	writeReloadConfig(t, path, countWords)
+	cfg, err := loadConfigFile(zap.NewNop(), path)
+	assert.NoError(t, err)
+	workflows := NewWorkflows(cfg)
+
+	workflows.SetManaged([]WorkflowConfig{{Name: "count-words"}, {Name: "count-lines"}})
+
+	names := []string{}
+	for _, workflow := range workflows.List() {
+		names = append(names, workflow.Name)
+	}
+	assert.Equal(t, []string{"count-words", "count-lines"}, names)
+	assert.Equal(t, cfg.Revision, workflows.Active().Revision)
+	assert.True(t, workflows.Watches("argo"))
+	assert.False(t, workflows.Watches("compchem-gpu"))
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Json document of the value written with the keys of the config file.
+func ToDocument(value any) (any, error) {
+	encoded, err := yaml.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var document any
+	if err := yaml.Unmarshal(encoded, &document); err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
+
+// Effective config of the workflow as json with the keys of the config file together with its
+// sha256 hash. Keys are sorted and the json is compact, so the same config always hashes the same.
+func (w WorkflowConfig) Snapshot() ([]byte, string, error) {
+	document, err := ToDocument(w)
+	if err != nil {
+		return nil, "", fmt.Errorf("Error when encoding workflow config snapshot: %v", err)
+	}
+
+	snapshot, err := json.Marshal(document)
+	if err != nil {
+		return nil, "", fmt.Errorf("Error when encoding workflow config snapshot: %v", err)
+	}
+
+	hash := sha256.Sum256(snapshot)
+	return snapshot, hex.EncodeToString(hash[:]), nil
+}
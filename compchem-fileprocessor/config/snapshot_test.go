@@ -0,0 +1,36 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot_SameConfig_SameHash(t *testing.T) {
+	workflow := WorkflowConfig{
+		Name:      "count-words",
+		Mimetype:  "text/plain",
+		Extension: "txt",
+		ProcessingTemplates: []ProcessingTemplate{
+			{Name: "count-words-template", Template: "count-words"},
+		},
+	}
+
+	snapshot, hash, err := workflow.Snapshot()
+	assert.NoError(t, err)
+	_, again, err := workflow.Snapshot()
+	assert.NoError(t, err)
+
+	sum := sha256.Sum256(snapshot)
+	assert.Equal(t, hex.EncodeToString(sum[:]), hash)
+	assert.Equal(t, hash, again)
+	assert.Contains(t, string(snapshot), `"processing-templates":[{`)
+	assert.Contains(t, string(snapshot), `"template":"count-words"`)
+
+	workflow.ProcessingTemplates[0].ActiveDeadlineSeconds = 60
+	_, changed, err := workflow.Snapshot()
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, changed)
+}
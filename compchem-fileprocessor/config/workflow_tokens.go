@@ -0,0 +1,58 @@
+package config
+
+import "time"
+
+const (
+	defaultTokenLifetime     = 24 * time.Hour
+	defaultTokenExpiryMargin = time.Hour
+)
+
+// Workflows get signed tokens instead of random secret keys when workflow tokens are configured.
+// Tokens are signed by the PEM encoded RSA or EC private key of the signing key file. Public keys
+// of the verification key files are published next to the signing key, so tokens signed by a
+// rotated out key stay verifiable until they expire. A token expires after the active deadline of
+// its workflow, or after the lifetime for workflows without one, plus the expiry margin covering
+// the time the workflow is pending in argo and its exit handler. Zero values fall back to the
+// defaults.
+type WorkflowTokensConfig struct {
+	Issuer               string   `yaml:"issuer"`
+	Audience             string   `yaml:"audience"`
+	SigningKeyFile       string   `yaml:"signing-key-file"`
+	VerificationKeyFiles []string `yaml:"verification-key-files"`
+	LifetimeSeconds      int      `yaml:"lifetime-seconds"`
+	ExpiryMarginSeconds  int      `yaml:"expiry-margin-seconds"`
+}
+
+func (t WorkflowTokensConfig) Lifetime() time.Duration {
+	if t.LifetimeSeconds == 0 {
+		return defaultTokenLifetime
+	}
+
+	return time.Duration(t.LifetimeSeconds) * time.Second
+}
+
+func (t WorkflowTokensConfig) ExpiryMargin() time.Duration {
+	if t.ExpiryMarginSeconds == 0 {
+		return defaultTokenExpiryMargin
+	}
+
+	return time.Duration(t.ExpiryMarginSeconds) * time.Second
+}
+
+func validateWorkflowTokens(tokens WorkflowTokensConfig, errors map[string]string) {
+	if tokens.Issuer == "" {
+		errors["workflow-tokens-issuer"] = "missing issuer"
+	}
+	if tokens.Audience == "" {
+		errors["workflow-tokens-audience"] = "missing audience"
+	}
+	if tokens.SigningKeyFile == "" {
+		errors["workflow-tokens-signing-key-file"] = "missing signing key file"
+	}
+	if tokens.LifetimeSeconds < 0 {
+		errors["workflow-tokens-lifetime"] = "negative lifetime"
+	}
+	if tokens.ExpiryMarginSeconds < 0 {
+		errors["workflow-tokens-expiry-margin"] = "negative expiry margin"
+	}
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateWorkflowTokens_MissingOptions_Rejected(t *testing.T) {
+	errors := make(map[string]string)
+	validateWorkflowTokens(
+		WorkflowTokensConfig{Issuer: "compchem-fileprocessor", LifetimeSeconds: -1},
+		errors,
+	)
+
+	assert.Equal(t, map[string]string{
+		"workflow-tokens-audience":         "missing audience",
+		"workflow-tokens-signing-key-file": "missing signing key file",
+		"workflow-tokens-lifetime":         "negative lifetime",
+	}, errors)
+}
+
+func TestWorkflowTokensLifetime_Unset_DefaultUsed(t *testing.T) {
+	assert.Equal(t, defaultTokenLifetime, WorkflowTokensConfig{}.Lifetime())
+	assert.Equal(t, defaultTokenExpiryMargin, WorkflowTokensConfig{}.ExpiryMargin())
+	assert.Equal(t, 10*time.Minute, WorkflowTokensConfig{ExpiryMarginSeconds: 600}.ExpiryMargin())
+}
@@ -42,6 +42,31 @@ func doMigration(
 	migrations string,
 ) error {
 	logger.Info("Running migrations", zap.String("migration-source", migrations))
+	migration, err := newMigration(logger, pgConfig, migrations)
+	if err != nil {
+		return err
+	}
+
+	err = migration.Up()
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		logger.Error(
+			"Error migrating schema",
+			zap.String("host", pgConfig.Host),
+			zap.String("port", pgConfig.Port),
+			zap.String("database", pgConfig.Database),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func newMigration(
+	logger *zap.Logger,
+	pgConfig *config.Postgres,
+	migrations string,
+) (*migrate.Migrate, error) {
 	db, err := sql.Open("postgres", createPgUrl(pgConfig)+"?sslmode=disable")
 	if err != nil {
 		logger.Error(
@@ -50,7 +75,7 @@ func doMigration(
 			zap.String("port", pgConfig.Port),
 			zap.String("database", pgConfig.Database),
 		)
-		return err
+		return nil, err
 	}
 
 	driver, err := postgres.WithInstance(db, &postgres.Config{})
@@ -62,7 +87,7 @@ func doMigration(
 			zap.String("database", pgConfig.Database),
 			zap.Error(err),
 		)
-		return err
+		return nil, err
 	}
 
 	migration, err := migrate.NewWithDatabaseInstance(
@@ -78,22 +103,10 @@ func doMigration(
 			zap.String("database", pgConfig.Database),
 			zap.Error(err),
 		)
-		return err
-	}
-
-	err = migration.Up()
-	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
-		logger.Error(
-			"Error migrating schema",
-			zap.String("host", pgConfig.Host),
-			zap.String("port", pgConfig.Port),
-			zap.String("database", pgConfig.Database),
-			zap.Error(err),
-		)
-		return err
+		return nil, err
 	}
 
-	return nil
+	return migration, nil
 }
 
 func doConnect(
@@ -61,7 +61,26 @@ func TestConnect_DbMissing_ErrorInMigrations(t *testing.T) {
 
 func TestConnect_DbExists_ConnectedAndMigratedToCorrectSchema(t *testing.T) {
 	ctx := context.Background()
+	pgConfig := startPostgres(ctx, t)
 
+	pool, err := CreatePgPool(ctx, zap.NewNop(), pgConfig, "file://../migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer pool.Close()
+
+	assert.NoError(t, err)
+	assert.NotNil(t, pool)
+
+	assertTableExists(ctx, t, pool, "compchem_file")
+	assertTableExists(ctx, t, pool, "compchem_workflow")
+	assertTableExists(ctx, t, pool, "compchem_workflow_file")
+	assertTableExists(ctx, t, pool, "compchem_workflow_submission")
+}
+
+// Starts an empty postgres which is removed when the test ends.
+func startPostgres(ctx context.Context, t *testing.T) *config.Postgres {
 	req := testcontainers.ContainerRequest{
 		Image:        "postgres:17",
 		ExposedPorts: []string{"5432/tcp"},
@@ -79,7 +98,7 @@ func TestConnect_DbExists_ConnectedAndMigratedToCorrectSchema(t *testing.T) {
 		Started:          true,
 	})
 	require.NoError(t, err)
-	defer testcontainers.CleanupContainer(t, pg)
+	testcontainers.CleanupContainer(t, pg)
 
 	mappedPort, err := pg.MappedPort(ctx, "5432/tcp")
 	require.NoError(t, err)
@@ -87,7 +106,7 @@ func TestConnect_DbExists_ConnectedAndMigratedToCorrectSchema(t *testing.T) {
 	host, err := pg.Host(ctx)
 	require.NoError(t, err)
 
-	pool, err := CreatePgPool(ctx, zap.NewNop(), &config.Postgres{
+	return &config.Postgres{
 		Database: "test",
 		Host:     host,
 		Port:     mappedPort.Port(),
@@ -95,19 +114,7 @@ func TestConnect_DbExists_ConnectedAndMigratedToCorrectSchema(t *testing.T) {
 			Password: "test123",
 			Username: "test",
 		},
-	}, "file://../migrations")
-	if err != nil {
-		t.Fatal(err)
 	}
-
-	defer pool.Close()
-
-	assert.NoError(t, err)
-	assert.NotNil(t, pool)
-
-	assertTableExists(ctx, t, pool, "compchem_file")
-	assertTableExists(ctx, t, pool, "compchem_workflow")
-	assertTableExists(ctx, t, pool, "compchem_workflow_file")
 }
 
 func assertTableExists(ctx context.Context, t *testing.T, pool *pgxpool.Pool, table string) {
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"fi.muni.cz/invenio-file-processor/v2/repository/workflow_repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestMigrations_WorkflowFromBeforeOutbox_ListedAsSubmitted(t *testing.T) {
+	// Arrange
+	ctx, logger := context.Background(), zap.NewNop()
+	pgConfig := startPostgres(ctx, t)
+
+	migration, err := newMigration(logger, pgConfig, "file://../migrations")
+	require.NoError(t, err)
+	require.NoError(t, migration.Migrate(1))
+
+	pool, err := doConnect(ctx, logger, pgConfig)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	_, err = pool.Exec(ctx, `
+		INSERT INTO compchem_workflow (record_id, workflow_name, workflow_record_seq_id)
+		VALUES ('ej26y-ad28j', 'count-words', 1)
+	`)
+	require.NoError(t, err)
+	// databases which ran the lifecycle migration already get the submission time backfilled
+	require.NoError(t, migration.Migrate(3))
+
+	// Act
+	err = migration.Up()
+
+	// Assert
+	require.NoError(t, err)
+	tx, err := pool.Begin(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback(ctx)
+
+	workflows, err := workflow_repository.FindSubmittedWorkflowsForRecord(
+		ctx,
+		logger,
+		tx,
+		"ej26y-ad28j",
+		nil,
+		10,
+		0,
+	)
+	assert.NoError(t, err)
+	assert.Len(t, workflows, 1)
+	assert.Equal(t, "Unknown", workflows[0].Phase)
+	assert.NotNil(t, workflows[0].SubmittedAt)
+}
+
+func TestMigrations_SubmittedWorkflowWithSecretKey_KeyCleared(t *testing.T) {
+	// Arrange
+	ctx, logger := context.Background(), zap.NewNop()
+	pgConfig := startPostgres(ctx, t)
+
+	migration, err := newMigration(logger, pgConfig, "file://../migrations")
+	require.NoError(t, err)
+	require.NoError(t, migration.Migrate(15))
+
+	pool, err := doConnect(ctx, logger, pgConfig)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	workflow := `{"spec":{"arguments":{"parameters":[` +
+		`{"name":"secret-key","value":"mysecretkey"},{"name":"record-id","value":"ej26y-ad28j"}]}}}`
+	_, err = pool.Exec(ctx, `
+		INSERT INTO compchem_workflow (record_id, workflow_name, workflow_record_seq_id)
+		VALUES ('ej26y-ad28j', 'count-words', 1), ('ej26y-ad28j', 'count-words', 2)
+	`)
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, `
+		INSERT INTO compchem_workflow_submission (compchem_workflow_id, workflow, status, secret_key)
+		SELECT id, $1::jsonb,
+			CASE WHEN workflow_record_seq_id = 1 THEN 'submitted' ELSE 'pending' END, 'mysecretkey'
+		FROM compchem_workflow
+	`, workflow)
+	require.NoError(t, err)
+
+	// Act
+	err = migration.Up()
+
+	// Assert
+	require.NoError(t, err)
+	rows, err := pool.Query(ctx, `
+		SELECT s.secret_key, s.workflow->'spec'->'arguments'->'parameters'->0->>'value'
+		FROM compchem_workflow_submission s
+		JOIN compchem_workflow w ON w.id = s.compchem_workflow_id
+		ORDER BY w.workflow_record_seq_id
+	`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var secretKeys, parameters []*string
+	for rows.Next() {
+		var secretKey, parameter *string
+		require.NoError(t, rows.Scan(&secretKey, &parameter))
+		secretKeys = append(secretKeys, secretKey)
+		parameters = append(parameters, parameter)
+	}
+	require.NoError(t, rows.Err())
+	require.Len(t, secretKeys, 2)
+	assert.Nil(t, secretKeys[0])
+	assert.Nil(t, parameters[0])
+	assert.Equal(t, "mysecretkey", *secretKeys[1])
+	assert.Equal(t, "mysecretkey", *parameters[1])
+}
@@ -1,6 +1,7 @@
 package httpclient
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/tls"
@@ -17,6 +18,7 @@ const (
 	DefaultTimeout    = 20 * time.Second
 	DefaultRetries    = 3
 	DefaultRetryDelay = 500 * time.Millisecond
+	MaxStreamLineSize = 16 * 1024 * 1024
 )
 
 type Opts struct {
@@ -90,6 +92,9 @@ func (c *Client) requestRaw(
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
+	for name, value := range c.options.Headers {
+		req.Header.Set(name, value)
+	}
 
 	var lastError error
 	for attempt := range c.options.MaxRetries {
@@ -167,24 +172,7 @@ func request[T any](
 	options *Opts,
 	ignoreTls bool,
 ) (T, error) {
-	var client *Client
-	if ignoreTls {
-		client = &Client{
-			httpClient: &http.Client{
-				Transport: &http.Transport{
-					TLSClientConfig: &tls.Config{
-						InsecureSkipVerify: true,
-					},
-				},
-			},
-			options: options,
-		}
-	} else {
-		client = &Client{
-			httpClient: http.DefaultClient,
-			options:    options,
-		}
-	}
+	client := newClient(options, ignoreTls)
 
 	var result T
 
@@ -201,6 +189,26 @@ func request[T any](
 	return result, nil
 }
 
+func newClient(options *Opts, ignoreTls bool) *Client {
+	if ignoreTls {
+		return &Client{
+			httpClient: &http.Client{
+				Transport: &http.Transport{
+					TLSClientConfig: &tls.Config{
+						InsecureSkipVerify: true,
+					},
+				},
+			},
+			options: options,
+		}
+	}
+
+	return &Client{
+		httpClient: http.DefaultClient,
+		options:    options,
+	}
+}
+
 func GetRequest[T any](
 	ctx context.Context,
 	logger *zap.Logger,
@@ -210,6 +218,20 @@ func GetRequest[T any](
 	return request[T](ctx, http.MethodGet, url, nil, NewDefaultOpts(logger), ignoreTls)
 }
 
+// Headers are set on the request, e.g. credentials of the caller the request is made for.
+func GetRequestWithHeaders[T any](
+	ctx context.Context,
+	logger *zap.Logger,
+	url string,
+	headers map[string]string,
+	ignoreTls bool,
+) (T, error) {
+	options := NewDefaultOpts(logger)
+	options.Headers = headers
+
+	return request[T](ctx, http.MethodGet, url, nil, options, ignoreTls)
+}
+
 func PostRequest[T any](
 	ctx context.Context,
 	logger *zap.Logger,
@@ -219,3 +241,90 @@ func PostRequest[T any](
 ) (T, error) {
 	return request[T](ctx, http.MethodPost, url, body, NewDefaultOpts(logger), ignoreTls)
 }
+
+func PutRequest[T any](
+	ctx context.Context,
+	logger *zap.Logger,
+	url string,
+	body any,
+	ignoreTls bool,
+) (T, error) {
+	return request[T](ctx, http.MethodPut, url, body, NewDefaultOpts(logger), ignoreTls)
+}
+
+// Response body is ignored, for notifications only the status matters.
+func PostRequestNoContent(
+	ctx context.Context,
+	logger *zap.Logger,
+	url string,
+	body any,
+	ignoreTls bool,
+) error {
+	_, err := newClient(NewDefaultOpts(logger), ignoreTls).requestRaw(ctx, http.MethodPost, url, body)
+	return err
+}
+
+// Response body of delete requests is ignored, it is commonly empty.
+func DeleteRequest(
+	ctx context.Context,
+	logger *zap.Logger,
+	url string,
+	ignoreTls bool,
+) error {
+	_, err := newClient(NewDefaultOpts(logger), ignoreTls).requestRaw(ctx, http.MethodDelete, url, nil)
+	return err
+}
+
+// Reads a long lived newline delimited response line by line until the server closes it,
+// ctx is cancelled or handle fails. Stream requests are not retried, that is up to the caller.
+func StreamRequest(
+	ctx context.Context,
+	logger *zap.Logger,
+	url string,
+	ignoreTls bool,
+	handle func(line []byte) error,
+) error {
+	client := newClient(NewDefaultOpts(logger), ignoreTls)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		logger.Error("failed to create request", zap.Error(err))
+		return fmt.Errorf("failed to create stream request: %w", err)
+	}
+
+	response, err := client.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to open stream: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(response.Body)
+		if response.StatusCode < 500 {
+			return &ClientError{Status: response.StatusCode, Message: string(respBody)}
+		}
+		return &ServerError{Status: response.StatusCode, Message: string(respBody)}
+	}
+
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 64*1024), MaxStreamLineSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		if err := handle(line); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	return nil
+}
@@ -216,3 +216,93 @@ func TestGetRequest_ServerTemporarilyDown_ClientRetriesAndEventuallySucceeds(t *
 	assert.Equal(t, 200, result.Status)
 	assert.Equal(t, successAttempt+1, attemptCount, "Expected number of attempts didn't match")
 }
+
+func TestStreamRequest_ServerStreamsLines_EachLineHandled(t *testing.T) {
+	logger := zap.NewNop()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("{\"message\":\"first\"}\n\n"))
+		w.(http.Flusher).Flush()
+		w.Write([]byte("{\"message\":\"second\"}\n"))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	received := []string{}
+	err := StreamRequest(ctx, logger, server.URL, false, func(line []byte) error {
+		var response TestResponse
+		assert.NoError(t, json.Unmarshal(line, &response))
+		received = append(received, response.Message)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first", "second"}, received)
+}
+
+func TestStreamRequest_ServerUnavailable_ServerErrorReturned(t *testing.T) {
+	logger := zap.NewNop()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := StreamRequest(ctx, logger, server.URL, false, func(line []byte) error {
+		t.Fail()
+		return nil
+	})
+
+	var serverErr *ServerError
+	assert.ErrorAs(t, err, &serverErr)
+	assert.Equal(t, http.StatusServiceUnavailable, serverErr.Status)
+}
+
+func TestPutRequest_ServerHasHandler_ReturnsCorrectObject(t *testing.T) {
+	logger := zap.NewNop()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+
+		var reqBody TestRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&reqBody))
+		assert.Equal(t, "test-name", reqBody.Name)
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(TestResponse{Message: "put successful", Status: 200})
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := PutRequest[TestResponse](ctx, logger, server.URL, TestRequest{Name: "test-name"}, false)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "put successful", result.Message)
+}
+
+func TestDeleteRequest_EmptyResponse_NoError(t *testing.T) {
+	logger := zap.NewNop()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodDelete, r.Method)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := DeleteRequest(ctx, logger, server.URL, false)
+
+	assert.NoError(t, err)
+}
@@ -0,0 +1,62 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Reports whether the client asked for yaml in the Accept header.
+func AcceptsYaml(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/yaml") ||
+		strings.Contains(accept, "application/x-yaml") ||
+		strings.Contains(accept, "text/yaml")
+}
+
+// Encodes v as yaml, field names and their order follow the json encoding of v.
+func EncodeYaml[T any](w http.ResponseWriter, r *http.Request, status int, v T) error {
+	body, err := ToYaml(v)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.WriteHeader(status)
+	_, err = w.Write(body)
+	return err
+}
+
+func ToYaml[T any](v T) ([]byte, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("encode json: %w", err)
+	}
+
+	// json is valid yaml, decoding into a node keeps the order of fields
+	var node yaml.Node
+	if err := yaml.Unmarshal(body, &node); err != nil {
+		return nil, fmt.Errorf("decode json as yaml: %w", err)
+	}
+	resetStyle(&node)
+
+	var result bytes.Buffer
+	encoder := yaml.NewEncoder(&result)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return nil, fmt.Errorf("encode yaml: %w", err)
+	}
+	return result.Bytes(), nil
+}
+
+// Decoded json is in flow style with quoted strings, block style reads better.
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
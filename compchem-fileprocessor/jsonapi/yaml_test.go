@@ -0,0 +1,46 @@
+package jsonapi
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToYaml_StructWithJsonTags_BlockYamlInFieldOrder(t *testing.T) {
+	type metadata struct {
+		Name   string            `json:"name"`
+		Labels map[string]string `json:"labels,omitempty"`
+	}
+	value := struct {
+		Kind     string   `json:"kind"`
+		Metadata metadata `json:"metadata"`
+		Flags    []string `json:"flags"`
+	}{
+		Kind:     "Workflow",
+		Metadata: metadata{Name: "count-words-12345-0"},
+		Flags:    []string{"true", "{{item.file}}"},
+	}
+
+	result, err := ToYaml(value)
+
+	assert.NoError(t, err)
+	assert.Equal(t, `kind: Workflow
+metadata:
+  name: count-words-12345-0
+flags:
+  - "true"
+  - '{{item.file}}'
+`, string(result))
+}
+
+func TestAcceptsYaml_AcceptHeader_Negotiated(t *testing.T) {
+	yamlRequest := httptest.NewRequest("POST", "https://localhost:8080", nil)
+	yamlRequest.Header.Set("Accept", "application/yaml")
+	jsonRequest := httptest.NewRequest("POST", "https://localhost:8080", nil)
+	jsonRequest.Header.Set("Accept", "application/json")
+
+	assert.True(t, AcceptsYaml(yamlRequest))
+	assert.False(t, AcceptsYaml(jsonRequest))
+	assert.False(t, AcceptsYaml(httptest.NewRequest("POST", "https://localhost:8080", nil)))
+}
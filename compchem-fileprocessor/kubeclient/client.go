@@ -0,0 +1,253 @@
+package kubeclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/httpclient"
+	"go.uber.org/zap"
+)
+
+const (
+	inClusterTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	inClusterCaFile    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	mergePatch         = "application/merge-patch+json"
+)
+
+var errNotConfigured = errors.New(
+	"kubernetes api url is not configured and the service does not run in a cluster",
+)
+
+type Secret struct {
+	ApiVersion string            `json:"apiVersion,omitempty"`
+	Kind       string            `json:"kind,omitempty"`
+	Metadata   ObjectMeta        `json:"metadata"`
+	Type       string            `json:"type,omitempty"`
+	Data       map[string][]byte `json:"data,omitempty"`
+	StringData map[string]string `json:"stringData,omitempty"`
+}
+
+type ObjectMeta struct {
+	Name            string            `json:"name,omitempty"`
+	Namespace       string            `json:"namespace,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	OwnerReferences []OwnerReference  `json:"ownerReferences,omitempty"`
+}
+
+// Kubernetes deletes objects together with their owner.
+type OwnerReference struct {
+	ApiVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Uid        string `json:"uid"`
+}
+
+// Calls the few endpoints of the kubernetes api the service needs, errors are reported as
+// httpclient client and server errors. The token is read on every request since kubernetes
+// rotates projected service account tokens.
+type Client struct {
+	url        string
+	tokenFile  string
+	httpClient *http.Client
+}
+
+// Unset values of the config fall back to the in cluster api server and service account, outside
+// of a cluster the url has to be configured since workflows can not be submitted without their
+// secrets.
+func NewClient(conf config.KubernetesApi) (*Client, error) {
+	url := conf.Url
+	if url == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host != "" && port != "" {
+			url = "https://" + net.JoinHostPort(host, port)
+		}
+	}
+	if url == "" {
+		return nil, errNotConfigured
+	}
+
+	tokenFile := conf.TokenFile
+	if tokenFile == "" && fileExists(inClusterTokenFile) {
+		tokenFile = inClusterTokenFile
+	}
+	if tokenFile != "" && !fileExists(tokenFile) {
+		return nil, fmt.Errorf("kubernetes token file %s does not exist", tokenFile)
+	}
+
+	caFile := conf.CaFile
+	if caFile == "" && fileExists(inClusterCaFile) {
+		caFile = inClusterCaFile
+	}
+
+	httpClient := &http.Client{Timeout: httpclient.DefaultTimeout}
+	if caFile != "" {
+		ca, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error when reading kubernetes ca file: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("kubernetes ca file %s does not contain certificates", caFile)
+		}
+		httpClient.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}
+	}
+
+	return &Client{
+		url:        strings.TrimSuffix(url, "/"),
+		tokenFile:  tokenFile,
+		httpClient: httpClient,
+	}, nil
+}
+
+// Fails with a client error of status conflict when the secret exists.
+func (c *Client) CreateSecret(ctx context.Context, logger *zap.Logger, secret Secret) error {
+	secret.ApiVersion = "v1"
+	secret.Kind = "Secret"
+	logger.Debug(
+		"Creating secret",
+		zap.String("namespace", secret.Metadata.Namespace),
+		zap.String("name", secret.Metadata.Name),
+	)
+
+	return c.do(
+		ctx,
+		http.MethodPost,
+		secretsPath(secret.Metadata.Namespace),
+		"application/json",
+		secret,
+		nil,
+	)
+}
+
+// Returns nil when the secret does not exist, values are in data since the api never returns
+// string data.
+func (c *Client) GetSecret(
+	ctx context.Context,
+	logger *zap.Logger,
+	namespace string,
+	name string,
+) (*Secret, error) {
+	logger.Debug("Getting secret", zap.String("namespace", namespace), zap.String("name", name))
+
+	var secret Secret
+	err := c.do(ctx, http.MethodGet, secretsPath(namespace, name), "", nil, &secret)
+	var clientErr *httpclient.ClientError
+	if errors.As(err, &clientErr) && clientErr.Status == http.StatusNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &secret, nil
+}
+
+// Merges the patch into the secret, string data replaces values of the same keys.
+func (c *Client) PatchSecret(
+	ctx context.Context,
+	logger *zap.Logger,
+	namespace string,
+	name string,
+	patch Secret,
+) error {
+	logger.Debug("Patching secret", zap.String("namespace", namespace), zap.String("name", name))
+
+	return c.do(ctx, http.MethodPatch, secretsPath(namespace, name), mergePatch, patch, nil)
+}
+
+// Deleting a secret which does not exist succeeds.
+func (c *Client) DeleteSecret(
+	ctx context.Context,
+	logger *zap.Logger,
+	namespace string,
+	name string,
+) error {
+	logger.Debug("Deleting secret", zap.String("namespace", namespace), zap.String("name", name))
+
+	err := c.do(ctx, http.MethodDelete, secretsPath(namespace, name), "", nil, nil)
+	var clientErr *httpclient.ClientError
+	if errors.As(err, &clientErr) && clientErr.Status == http.StatusNotFound {
+		return nil
+	}
+
+	return err
+}
+
+func (c *Client) do(
+	ctx context.Context,
+	method string,
+	path string,
+	contentType string,
+	body any,
+	result any,
+) error {
+	var reqBody io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request body: %w", err)
+		}
+		reqBody = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.tokenFile != "" {
+		token, err := os.ReadFile(c.tokenFile)
+		if err != nil {
+			return fmt.Errorf("Error when reading kubernetes token file: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	response, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 400 {
+		if result == nil {
+			return nil
+		}
+		if err := json.NewDecoder(response.Body).Decode(result); err != nil {
+			return fmt.Errorf("failed to decode response body: %w", err)
+		}
+		return nil
+	}
+	respBody, _ := io.ReadAll(response.Body)
+	if response.StatusCode < 500 {
+		return &httpclient.ClientError{Status: response.StatusCode, Message: string(respBody)}
+	}
+	return &httpclient.ServerError{Status: response.StatusCode, Message: string(respBody)}
+}
+
+func secretsPath(namespace string, name ...string) string {
+	path := fmt.Sprintf("/api/v1/namespaces/%s/secrets", namespace)
+	if len(name) == 0 {
+		return path
+	}
+
+	return path + "/" + name[0]
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,169 @@
+package kubeclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/httpclient"
+	kubetest "fi.muni.cz/invenio-file-processor/v2/kubeclient/test"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func newTestClient(t *testing.T, fake *kubetest.FakeApiServer) *Client {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(tokenFile, []byte("service-account-token\n"), 0600))
+
+	client, err := NewClient(config.KubernetesApi{Url: fake.URL, TokenFile: tokenFile})
+	assert.NoError(t, err)
+	return client
+}
+
+func workflowSecret() Secret {
+	return Secret{
+		Metadata: ObjectMeta{
+			Name:      "count-words-ej281-k87lh-1-key",
+			Namespace: "argo",
+			Labels:    map[string]string{"compchem.cerit.io/record-id": "ej281-k87lh"},
+		},
+		Type:       "Opaque",
+		StringData: map[string]string{"secret-key": "mysecretkey"},
+	}
+}
+
+func TestCreateSecret_NewSecret_CreatedWithToken(t *testing.T) {
+	// Arrange
+	fake := kubetest.NewFakeApiServer()
+	defer fake.Close()
+	fake.Token = "service-account-token"
+	client := newTestClient(t, fake)
+
+	// Act
+	err := client.CreateSecret(context.Background(), zap.NewNop(), workflowSecret())
+
+	// Assert
+	assert.NoError(t, err)
+	secret := fake.Secret("argo", "count-words-ej281-k87lh-1-key")
+	assert.NotNil(t, secret)
+	assert.Equal(t, map[string]string{"secret-key": "mysecretkey"}, secret.StringData)
+	assert.Equal(t, "ej281-k87lh", secret.Labels["compchem.cerit.io/record-id"])
+}
+
+func TestCreateSecret_SecretExists_ConflictReturned(t *testing.T) {
+	fake := kubetest.NewFakeApiServer()
+	defer fake.Close()
+	client := newTestClient(t, fake)
+	assert.NoError(t, client.CreateSecret(context.Background(), zap.NewNop(), workflowSecret()))
+
+	err := client.CreateSecret(context.Background(), zap.NewNop(), workflowSecret())
+
+	var clientErr *httpclient.ClientError
+	assert.True(t, errors.As(err, &clientErr))
+	assert.Equal(t, http.StatusConflict, clientErr.Status)
+}
+
+func TestPatchSecret_OwnerAndData_Merged(t *testing.T) {
+	// Arrange
+	fake := kubetest.NewFakeApiServer()
+	defer fake.Close()
+	client := newTestClient(t, fake)
+	assert.NoError(t, client.CreateSecret(context.Background(), zap.NewNop(), workflowSecret()))
+
+	// Act
+	err := client.PatchSecret(
+		context.Background(),
+		zap.NewNop(),
+		"argo",
+		"count-words-ej281-k87lh-1-key",
+		Secret{
+			Metadata: ObjectMeta{OwnerReferences: []OwnerReference{{
+				ApiVersion: "argoproj.io/v1alpha1",
+				Kind:       "Workflow",
+				Name:       "count-words-ej281-k87lh-1",
+				Uid:        "50efc9c4-124e-41fa-80ea-61515b4bcc1b",
+			}}},
+			StringData: map[string]string{"secret-key": "rotated"},
+		},
+	)
+
+	// Assert
+	assert.NoError(t, err)
+	secret := fake.Secret("argo", "count-words-ej281-k87lh-1-key")
+	assert.Equal(t, "rotated", secret.StringData["secret-key"])
+	assert.Equal(t, "ej281-k87lh", secret.Labels["compchem.cerit.io/record-id"])
+	assert.Equal(t, []kubetest.OwnerReference{{
+		ApiVersion: "argoproj.io/v1alpha1",
+		Kind:       "Workflow",
+		Name:       "count-words-ej281-k87lh-1",
+		Uid:        "50efc9c4-124e-41fa-80ea-61515b4bcc1b",
+	}}, secret.OwnerReferences)
+}
+
+func TestGetSecret_ExistingAndMissing_DataDecoded(t *testing.T) {
+	// Arrange
+	fake := kubetest.NewFakeApiServer()
+	defer fake.Close()
+	client := newTestClient(t, fake)
+	assert.NoError(t, client.CreateSecret(context.Background(), zap.NewNop(), workflowSecret()))
+
+	// Act
+	secret, err := client.GetSecret(
+		context.Background(),
+		zap.NewNop(),
+		"argo",
+		"count-words-ej281-k87lh-1-key",
+	)
+	missing, missingErr := client.GetSecret(context.Background(), zap.NewNop(), "argo", "missing-key")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"secret-key": []byte("mysecretkey")}, secret.Data)
+	assert.NoError(t, missingErr)
+	assert.Nil(t, missing)
+}
+
+func TestDeleteSecret_MissingSecret_NoError(t *testing.T) {
+	fake := kubetest.NewFakeApiServer()
+	defer fake.Close()
+	client := newTestClient(t, fake)
+
+	err := client.DeleteSecret(context.Background(), zap.NewNop(), "argo", "missing-key")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"DELETE /api/v1/namespaces/argo/secrets/missing-key"}, fake.Requests())
+}
+
+func TestSecretRequest_ApiUnavailable_ServerErrorReturned(t *testing.T) {
+	fake := kubetest.NewFakeApiServer()
+	defer fake.Close()
+	fake.Failing = http.StatusServiceUnavailable
+	client := newTestClient(t, fake)
+
+	err := client.DeleteSecret(context.Background(), zap.NewNop(), "argo", "count-words-key")
+
+	var serverErr *httpclient.ServerError
+	assert.True(t, errors.As(err, &serverErr))
+	assert.Equal(t, http.StatusServiceUnavailable, serverErr.Status)
+}
+
+func TestNewClient_OutsideOfCluster_NotConfigured(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+
+	client, err := NewClient(config.KubernetesApi{})
+
+	assert.ErrorIs(t, err, errNotConfigured)
+	assert.Nil(t, client)
+}
+
+func TestNewClient_MissingTokenFile_Rejected(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+
+	_, err := NewClient(config.KubernetesApi{Url: "https://localhost:6443", TokenFile: tokenFile})
+
+	assert.EqualError(t, err, "kubernetes token file "+tokenFile+" does not exist")
+}
@@ -0,0 +1,174 @@
+package kubetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+const secretsPrefix = "/api/v1/namespaces/"
+
+type OwnerReference struct {
+	ApiVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Uid        string `json:"uid"`
+}
+
+// Secret as the fake api server keeps it, string data stands in for the encoded data.
+type StoredSecret struct {
+	Labels          map[string]string
+	OwnerReferences []OwnerReference
+	StringData      map[string]string
+}
+
+type secretBody struct {
+	Metadata struct {
+		Name            string            `json:"name"`
+		Labels          map[string]string `json:"labels"`
+		OwnerReferences *[]OwnerReference `json:"ownerReferences"`
+	} `json:"metadata"`
+	StringData map[string]string `json:"stringData"`
+}
+
+// Serves create, get, merge patch and delete of secrets like the kubernetes api does. Requests
+// without the bearer token are rejected when a token is set, failing makes every request fail with
+// the status.
+type FakeApiServer struct {
+	*httptest.Server
+	Token   string
+	Failing int
+
+	mu       sync.Mutex
+	secrets  map[string]*StoredSecret
+	requests []string
+}
+
+func NewFakeApiServer() *FakeApiServer {
+	fake := &FakeApiServer{secrets: make(map[string]*StoredSecret)}
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.serve))
+
+	return fake
+}
+
+// Returns nil when the secret does not exist.
+func (f *FakeApiServer) Secret(namespace string, name string) *StoredSecret {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.secrets[namespace+"/"+name]
+}
+
+// Method and path of every request served so far.
+func (f *FakeApiServer) Requests() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string{}, f.requests...)
+}
+
+func (f *FakeApiServer) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+
+	if f.Failing != 0 {
+		http.Error(w, `{"kind":"Status","status":"Failure"}`, f.Failing)
+		return
+	}
+	if f.Token != "" && r.Header.Get("Authorization") != "Bearer "+f.Token {
+		http.Error(w, `{"kind":"Status","reason":"Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	// /api/v1/namespaces/{namespace}/secrets[/{name}]
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, secretsPrefix), "/")
+	if !strings.HasPrefix(r.URL.Path, secretsPrefix) || len(parts) < 2 || parts[1] != "secrets" {
+		http.NotFound(w, r)
+		return
+	}
+	namespace := parts[0]
+
+	var body secretBody
+	if r.Body != nil && (r.Method == http.MethodPost || r.Method == http.MethodPatch) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	status := http.StatusOK
+	switch {
+	case r.Method == http.MethodPost && len(parts) == 2:
+		key := namespace + "/" + body.Metadata.Name
+		if _, exists := f.secrets[key]; exists {
+			http.Error(w, `{"kind":"Status","reason":"AlreadyExists"}`, http.StatusConflict)
+			return
+		}
+		secret := &StoredSecret{Labels: body.Metadata.Labels, StringData: body.StringData}
+		if body.Metadata.OwnerReferences != nil {
+			secret.OwnerReferences = *body.Metadata.OwnerReferences
+		}
+		f.secrets[key] = secret
+		status = http.StatusCreated
+	case r.Method == http.MethodGet && len(parts) == 3:
+		secret, exists := f.secrets[namespace+"/"+parts[2]]
+		if !exists {
+			http.Error(w, `{"kind":"Status","reason":"NotFound"}`, http.StatusNotFound)
+			return
+		}
+		// the api returns the encoded data, never string data
+		data := make(map[string][]byte)
+		for key, value := range secret.StringData {
+			data[key] = []byte(value)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+		return
+	case r.Method == http.MethodPatch && len(parts) == 3:
+		if r.Header.Get("Content-Type") != "application/merge-patch+json" {
+			http.Error(w, "unsupported patch", http.StatusUnsupportedMediaType)
+			return
+		}
+		secret, exists := f.secrets[namespace+"/"+parts[2]]
+		if !exists {
+			http.Error(w, `{"kind":"Status","reason":"NotFound"}`, http.StatusNotFound)
+			return
+		}
+		if body.Metadata.Labels != nil {
+			secret.Labels = merged(secret.Labels, body.Metadata.Labels)
+		}
+		if body.Metadata.OwnerReferences != nil {
+			secret.OwnerReferences = *body.Metadata.OwnerReferences
+		}
+		if body.StringData != nil {
+			secret.StringData = merged(secret.StringData, body.StringData)
+		}
+	case r.Method == http.MethodDelete && len(parts) == 3:
+		key := namespace + "/" + parts[2]
+		if _, exists := f.secrets[key]; !exists {
+			http.Error(w, `{"kind":"Status","reason":"NotFound"}`, http.StatusNotFound)
+			return
+		}
+		delete(f.secrets, key)
+	default:
+		http.Error(w, fmt.Sprintf("%s not supported", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprint(w, "{}")
+}
+
+func merged(current map[string]string, patch map[string]string) map[string]string {
+	result := make(map[string]string)
+	maps.Copy(result, current)
+	maps.Copy(result, patch)
+
+	return result
+}
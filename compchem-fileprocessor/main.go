@@ -11,9 +11,14 @@ import (
 	"sync"
 	"time"
 
+	"fi.muni.cz/invenio-file-processor/v2/auth"
 	"fi.muni.cz/invenio-file-processor/v2/config"
 	"fi.muni.cz/invenio-file-processor/v2/db"
+	"fi.muni.cz/invenio-file-processor/v2/kubeclient"
 	"fi.muni.cz/invenio-file-processor/v2/routes"
+	submitworkflow_service "fi.muni.cz/invenio-file-processor/v2/services/submit_workflow"
+	watchworkflows_service "fi.muni.cz/invenio-file-processor/v2/services/watch_workflows"
+	workflowdefinitions_service "fi.muni.cz/invenio-file-processor/v2/services/workflow_definitions"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
 )
@@ -24,22 +29,44 @@ func NewServer(
 	logger *zap.Logger,
 	pool *pgxpool.Pool,
 	config *config.Config,
+	workflows *config.Workflows,
+	authenticators []auth.Authenticator,
+	authorizer auth.Authorizer,
+	tokens *auth.WorkflowTokenSigner,
+	kube *kubeclient.Client,
 ) http.Handler {
 	mux := http.NewServeMux()
 
-	routes.AddRoutes(ctx, logger, mux, config, pool)
+	routes.AddRoutes(
+		ctx,
+		logger,
+		mux,
+		config,
+		workflows,
+		authenticators,
+		authorizer,
+		tokens,
+		kube,
+		pool,
+	)
 
 	return mux
 }
 
+// The config is read from the workdir unless CONFIG_DIR points elsewhere, kubernetes only
+// propagates configmap updates into directory mounts.
 func getConfig(logger *zap.Logger) (*config.Config, error) {
-	wd, err := os.Getwd()
-	if err != nil {
-		logger.Error("Could not get workdir", zap.Error(err))
-		return nil, err
+	dir := os.Getenv("CONFIG_DIR")
+	if dir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			logger.Error("Could not get workdir", zap.Error(err))
+			return nil, err
+		}
+		dir = wd
 	}
 
-	config, err := config.LoadConfig(logger, wd)
+	config, err := config.LoadConfig(logger, dir)
 	if err != nil {
 		return nil, err
 	}
@@ -47,6 +74,14 @@ func getConfig(logger *zap.Logger) (*config.Config, error) {
 	return config, nil
 }
 
+// Workflow definitions are reloaded from the config file without a restart.
+func startReloader(ctx context.Context, logger *zap.Logger, cfg *config.Config) *config.Workflows {
+	workflows := config.NewWorkflows(cfg)
+	go config.RunReloader(ctx, logger, cfg, workflows)
+
+	return workflows
+}
+
 func run(
 	ctx context.Context,
 	logger *zap.Logger,
@@ -63,7 +98,72 @@ func run(
 	}
 	defer pool.Close()
 
-	srv := NewServer(ctx, logger, pool, config)
+	kube, err := kubeclient.NewClient(config.Kubernetes)
+	if err != nil {
+		logger.Error("Error initializing kubernetes client", zap.Error(err))
+		return err
+	}
+	tokens, err := auth.NewWorkflowTokenSigner(config.WorkflowTokens)
+	if err != nil {
+		logger.Error("Error initializing workflow tokens", zap.Error(err))
+		return err
+	}
+
+	go submitworkflow_service.RunDispatcher(
+		ctx,
+		logger,
+		pool,
+		kube,
+		tokens,
+		config.ArgoApi.Url,
+		config.ArgoApi.Namespace,
+		submitworkflow_service.NewDispatcherOpts(config.ArgoApi.Submission),
+	)
+
+	// argo events are streamed per namespace
+	for _, namespace := range config.WorkflowNamespaces() {
+		go watchworkflows_service.RunWatcher(
+			ctx,
+			logger,
+			pool,
+			config.ArgoApi.Url,
+			namespace,
+			config.ArgoApi.Instance,
+			watchworkflows_service.NewWatcherOpts(config.ArgoApi.Watcher),
+		)
+	}
+
+	authenticators, err := auth.NewAuthenticators(logger, config.Auth)
+	if err != nil {
+		logger.Error("Error initializing authentication", zap.Error(err))
+		return err
+	}
+	authorizer := auth.NewAuthorizer(logger, config.Auth, config.CompchemApi.Url)
+
+	workflows := startReloader(ctx, logger, config)
+	// workflows of the config file are seeded and managed ones activated before serving requests
+	seeded := workflowdefinitions_service.Sync(ctx, logger, pool, config.ArgoApi, workflows, "")
+	go workflowdefinitions_service.RunRefresher(
+		ctx,
+		logger,
+		pool,
+		config.ArgoApi,
+		workflows,
+		config.Reload.Interval(),
+		seeded,
+	)
+
+	srv := NewServer(
+		ctx,
+		logger,
+		pool,
+		config,
+		workflows,
+		authenticators,
+		authorizer,
+		tokens,
+		kube,
+	)
 	httpServer := &http.Server{
 		Addr:    net.JoinHostPort(config.Server.Host, strconv.Itoa(config.Server.Port)),
 		Handler: srv,
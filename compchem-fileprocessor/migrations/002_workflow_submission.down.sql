@@ -0,0 +1,3 @@
+DROP INDEX compchem_workflow_submission_due_idx;
+
+DROP TABLE compchem_workflow_submission;
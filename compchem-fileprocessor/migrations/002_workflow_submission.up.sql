@@ -0,0 +1,17 @@
+CREATE TABLE compchem_workflow_submission(
+  id SERIAL PRIMARY KEY,
+  compchem_workflow_id BIGINT NOT NULL,
+  workflow JSONB NOT NULL,
+  status VARCHAR(20) NOT NULL DEFAULT 'pending',
+  attempts INT NOT NULL DEFAULT 0,
+  last_error TEXT,
+  next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  submitted_at TIMESTAMPTZ,
+
+  CONSTRAINT unique_workflow_submission UNIQUE (compchem_workflow_id),
+  CONSTRAINT submission_status_check CHECK (status IN ('pending', 'submitted', 'failed')),
+  CONSTRAINT submission_workflow_id_fk FOREIGN KEY(compchem_workflow_id) REFERENCES compchem_workflow(id) ON DELETE CASCADE
+);
+
+CREATE INDEX compchem_workflow_submission_due_idx ON compchem_workflow_submission(status, next_attempt_at);
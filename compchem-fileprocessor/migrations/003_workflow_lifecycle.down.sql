@@ -0,0 +1,12 @@
+DROP INDEX compchem_workflow_record_phase_idx;
+
+ALTER TABLE compchem_workflow
+  DROP CONSTRAINT unique_workflow_full_name,
+  DROP COLUMN workflow_full_name,
+  DROP COLUMN phase,
+  DROP COLUMN argo_uid,
+  DROP COLUMN message,
+  DROP COLUMN progress,
+  DROP COLUMN submitted_at,
+  DROP COLUMN started_at,
+  DROP COLUMN finished_at;
@@ -0,0 +1,36 @@
+ALTER TABLE compchem_workflow
+  ADD COLUMN workflow_full_name VARCHAR(255) GENERATED ALWAYS AS (
+    workflow_name || '-' || record_id || '-' || workflow_record_seq_id::text
+  ) STORED,
+  ADD COLUMN phase VARCHAR(30) NOT NULL DEFAULT 'Unsubmitted',
+  ADD COLUMN argo_uid VARCHAR(64),
+  ADD COLUMN message TEXT,
+  ADD COLUMN progress VARCHAR(20),
+  ADD COLUMN submitted_at TIMESTAMPTZ,
+  ADD COLUMN started_at TIMESTAMPTZ,
+  ADD COLUMN finished_at TIMESTAMPTZ;
+
+ALTER TABLE compchem_workflow
+  ADD CONSTRAINT unique_workflow_full_name UNIQUE (workflow_full_name);
+
+-- workflows created before this migration were already handed to argo, their state is unknown
+-- until argo is asked again
+UPDATE compchem_workflow wf
+SET phase = COALESCE(
+      (
+        SELECT CASE s.status
+          WHEN 'pending' THEN 'Unsubmitted'
+          WHEN 'failed' THEN 'SubmissionFailed'
+        END
+        FROM compchem_workflow_submission s
+        WHERE s.compchem_workflow_id = wf.id
+      ),
+      'Unknown'
+    ),
+    submitted_at = (
+      SELECT s.submitted_at
+      FROM compchem_workflow_submission s
+      WHERE s.compchem_workflow_id = wf.id
+    );
+
+CREATE INDEX compchem_workflow_record_phase_idx ON compchem_workflow(record_id, phase);
@@ -0,0 +1,9 @@
+ALTER TABLE compchem_workflow
+  DROP COLUMN cancel_action,
+  DROP COLUMN cancelled_at;
+
+UPDATE compchem_workflow_submission SET status = 'failed' WHERE status = 'cancelled';
+
+ALTER TABLE compchem_workflow_submission
+  DROP CONSTRAINT submission_status_check,
+  ADD CONSTRAINT submission_status_check CHECK (status IN ('pending', 'submitted', 'failed'));
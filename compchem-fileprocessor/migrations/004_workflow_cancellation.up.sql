@@ -0,0 +1,7 @@
+ALTER TABLE compchem_workflow_submission
+  DROP CONSTRAINT submission_status_check,
+  ADD CONSTRAINT submission_status_check CHECK (status IN ('pending', 'submitted', 'failed', 'cancelled'));
+
+ALTER TABLE compchem_workflow
+  ADD COLUMN cancel_action VARCHAR(20),
+  ADD COLUMN cancelled_at TIMESTAMPTZ;
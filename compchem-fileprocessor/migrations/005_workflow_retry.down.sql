@@ -0,0 +1,4 @@
+ALTER TABLE compchem_workflow
+  DROP COLUMN retried_from,
+  DROP COLUMN retry_count,
+  DROP COLUMN retried_at;
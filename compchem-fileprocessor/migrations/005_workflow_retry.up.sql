@@ -0,0 +1,8 @@
+ALTER TABLE compchem_workflow
+  ADD COLUMN retried_from BIGINT,
+  ADD COLUMN retry_count INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN retried_at TIMESTAMPTZ;
+
+ALTER TABLE compchem_workflow
+  ADD CONSTRAINT compchem_workflow_retried_from_fk FOREIGN KEY(retried_from) REFERENCES compchem_workflow(id),
+  ADD CONSTRAINT unique_workflow_retried_from UNIQUE (retried_from);
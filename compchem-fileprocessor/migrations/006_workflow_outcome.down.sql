@@ -0,0 +1,3 @@
+ALTER TABLE compchem_workflow
+  DROP COLUMN failed_nodes,
+  DROP COLUMN outcome_reported_at;
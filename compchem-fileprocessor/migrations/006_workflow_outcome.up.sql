@@ -0,0 +1,3 @@
+ALTER TABLE compchem_workflow
+  ADD COLUMN failed_nodes JSONB,
+  ADD COLUMN outcome_reported_at TIMESTAMPTZ;
@@ -0,0 +1,3 @@
+ALTER TABLE compchem_workflow
+  DROP COLUMN secret_key_hash,
+  DROP COLUMN output_files;
@@ -0,0 +1,12 @@
+ALTER TABLE compchem_workflow
+  ADD COLUMN secret_key_hash VARCHAR(64),
+  ADD COLUMN output_files JSONB;
+
+-- secret keys of existing workflows are only kept in the submitted workflow
+UPDATE compchem_workflow wf
+SET secret_key_hash = encode(sha256(convert_to(
+  jsonb_path_query_first(s.workflow, '$.spec.arguments.parameters[*] ? (@.name == "secret-key").value') #>> '{}',
+  'UTF8'
+)), 'hex')
+FROM compchem_workflow_submission s
+WHERE s.compchem_workflow_id = wf.id;
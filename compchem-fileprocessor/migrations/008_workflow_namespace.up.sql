@@ -0,0 +1,3 @@
+-- namespace the workflow is submitted to, NULL for the argo-workflows namespace
+ALTER TABLE compchem_workflow
+  ADD COLUMN namespace VARCHAR(253);
@@ -0,0 +1,3 @@
+-- resolved template parameters, reused when the workflow is resubmitted
+ALTER TABLE compchem_workflow
+  ADD COLUMN parameters JSONB;
@@ -0,0 +1,2 @@
+ALTER TABLE compchem_workflow
+  DROP COLUMN inputs;
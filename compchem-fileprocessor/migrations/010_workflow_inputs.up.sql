@@ -0,0 +1,3 @@
+-- file keys assigned to the input slots, reused when the workflow is resubmitted
+ALTER TABLE compchem_workflow
+  ADD COLUMN inputs JSONB;
@@ -0,0 +1,3 @@
+DROP TABLE compchem_processing_template;
+DROP TABLE compchem_workflow_definition_version;
+DROP TABLE compchem_workflow_definition;
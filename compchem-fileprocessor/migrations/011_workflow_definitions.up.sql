@@ -0,0 +1,39 @@
+-- workflows registered through the admin api, workflows of the config file are seeded as read-only
+-- entries with the config source
+CREATE TABLE compchem_workflow_definition(
+  id SERIAL PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  source VARCHAR(20) NOT NULL DEFAULT 'api',
+  enabled BOOLEAN NOT NULL DEFAULT TRUE,
+  version INT NOT NULL DEFAULT 1,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  CONSTRAINT unique_workflow_definition_name UNIQUE (name),
+  CONSTRAINT workflow_definition_source_check CHECK (source IN ('config', 'api'))
+);
+
+-- every change of a definition is kept as a new version, the definition holds the workflow config
+-- without its processing templates
+CREATE TABLE compchem_workflow_definition_version(
+  id SERIAL PRIMARY KEY,
+  compchem_workflow_definition_id BIGINT NOT NULL,
+  version INT NOT NULL,
+  definition JSONB NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  CONSTRAINT unique_workflow_definition_version UNIQUE (compchem_workflow_definition_id, version),
+  CONSTRAINT definition_version_definition_id_fk FOREIGN KEY(compchem_workflow_definition_id) REFERENCES compchem_workflow_definition(id) ON DELETE CASCADE
+);
+
+CREATE TABLE compchem_processing_template(
+  id SERIAL PRIMARY KEY,
+  compchem_workflow_definition_version_id BIGINT NOT NULL,
+  position INT NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  template VARCHAR(255) NOT NULL,
+  definition JSONB NOT NULL,
+
+  CONSTRAINT unique_processing_template_position UNIQUE (compchem_workflow_definition_version_id, position),
+  CONSTRAINT processing_template_version_id_fk FOREIGN KEY(compchem_workflow_definition_version_id) REFERENCES compchem_workflow_definition_version(id) ON DELETE CASCADE
+);
@@ -0,0 +1,7 @@
+ALTER TABLE compchem_workflow
+  DROP CONSTRAINT compchem_workflow_config_hash_fk;
+
+ALTER TABLE compchem_workflow
+  DROP COLUMN config_hash;
+
+DROP TABLE compchem_workflow_config_snapshot;
@@ -0,0 +1,14 @@
+-- effective workflow configs runs were started with, runs with the same config share the snapshot,
+-- json keeps the text the hash was computed from
+CREATE TABLE compchem_workflow_config_snapshot(
+  config_hash VARCHAR(64) PRIMARY KEY,
+  workflow_name VARCHAR(255) NOT NULL,
+  config JSON NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE compchem_workflow
+  ADD COLUMN config_hash VARCHAR(64);
+
+ALTER TABLE compchem_workflow
+  ADD CONSTRAINT compchem_workflow_config_hash_fk FOREIGN KEY(config_hash) REFERENCES compchem_workflow_config_snapshot(config_hash);
@@ -0,0 +1,3 @@
+DROP INDEX compchem_audit_log_record_idx;
+
+DROP TABLE compchem_audit_log;
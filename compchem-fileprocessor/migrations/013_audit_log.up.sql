@@ -0,0 +1,16 @@
+-- authorization decisions on records, one row per checked request including cached decisions
+CREATE TABLE compchem_audit_log(
+  id BIGSERIAL PRIMARY KEY,
+  subject VARCHAR(255) NOT NULL,
+  record_id VARCHAR(255) NOT NULL,
+  action VARCHAR(20) NOT NULL,
+  allowed BOOLEAN NOT NULL,
+  source VARCHAR(20) NOT NULL,
+  cached BOOLEAN NOT NULL,
+  reason TEXT NOT NULL,
+  request_method VARCHAR(10) NOT NULL,
+  request_path TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX compchem_audit_log_record_idx ON compchem_audit_log(record_id, created_at);
@@ -0,0 +1,24 @@
+DROP TRIGGER compchem_audit_log_no_truncate ON compchem_audit_log;
+
+DROP TRIGGER compchem_audit_log_append_only ON compchem_audit_log;
+
+DROP FUNCTION compchem_audit_log_append_only();
+
+DROP INDEX compchem_audit_log_created_idx;
+
+DROP INDEX compchem_audit_log_workflow_idx;
+
+DELETE FROM compchem_audit_log WHERE event = 'action';
+
+ALTER TABLE compchem_audit_log
+  DROP CONSTRAINT audit_log_event_check,
+  DROP COLUMN event,
+  DROP COLUMN workflow_name,
+  DROP COLUMN outcome,
+  DROP COLUMN status,
+  DROP COLUMN request_id,
+  DROP COLUMN source_ip,
+  ALTER COLUMN record_id SET NOT NULL,
+  ALTER COLUMN allowed SET NOT NULL,
+  ALTER COLUMN source SET NOT NULL,
+  ALTER COLUMN cached SET NOT NULL;
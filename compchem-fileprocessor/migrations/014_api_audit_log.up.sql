@@ -0,0 +1,40 @@
+-- the audit log also records mutating api requests, event tells them apart from authorization
+-- decisions which keep using allowed, source and cached
+ALTER TABLE compchem_audit_log
+  ADD COLUMN event VARCHAR(20) NOT NULL DEFAULT 'authorization',
+  ADD COLUMN workflow_name VARCHAR(255),
+  ADD COLUMN outcome VARCHAR(20),
+  ADD COLUMN status INT,
+  ADD COLUMN request_id VARCHAR(64),
+  ADD COLUMN source_ip VARCHAR(64),
+  ALTER COLUMN record_id DROP NOT NULL,
+  ALTER COLUMN allowed DROP NOT NULL,
+  ALTER COLUMN source DROP NOT NULL,
+  ALTER COLUMN cached DROP NOT NULL;
+
+UPDATE compchem_audit_log
+SET outcome = CASE WHEN allowed THEN 'allowed' ELSE 'denied' END;
+
+ALTER TABLE compchem_audit_log
+  ALTER COLUMN event DROP DEFAULT,
+  ALTER COLUMN outcome SET NOT NULL,
+  ADD CONSTRAINT audit_log_event_check CHECK (event IN ('authorization', 'action'));
+
+CREATE INDEX compchem_audit_log_workflow_idx ON compchem_audit_log(workflow_name, created_at);
+
+CREATE INDEX compchem_audit_log_created_idx ON compchem_audit_log(created_at);
+
+-- entries are never changed or removed by the service
+CREATE FUNCTION compchem_audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'compchem_audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER compchem_audit_log_append_only
+  BEFORE UPDATE OR DELETE ON compchem_audit_log
+  FOR EACH ROW EXECUTE FUNCTION compchem_audit_log_append_only();
+
+CREATE TRIGGER compchem_audit_log_no_truncate
+  BEFORE TRUNCATE ON compchem_audit_log
+  FOR EACH STATEMENT EXECUTE FUNCTION compchem_audit_log_append_only();
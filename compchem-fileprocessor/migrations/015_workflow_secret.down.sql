@@ -0,0 +1,29 @@
+UPDATE compchem_workflow_submission s
+SET workflow = jsonb_set(
+  renamed.workflow,
+  '{spec,arguments,parameters}',
+  COALESCE((
+    SELECT jsonb_agg(
+      CASE WHEN p->>'name' = 'secret-key'
+        THEN jsonb_build_object('name', 'secret-key', 'value', s.secret_key)
+        ELSE p
+      END
+      ORDER BY position
+    )
+    FROM jsonb_array_elements(renamed.workflow->'spec'->'arguments'->'parameters')
+      WITH ORDINALITY AS arguments(p, position)
+  ), '[]'::jsonb)
+)
+FROM (
+  SELECT id, replace(
+    replace(workflow::text, '"name": "secret-name"', '"name": "secret-key"'),
+    '{{workflow.parameters.secret-name}}',
+    '{{workflow.parameters.secret-key}}'
+  )::jsonb AS workflow
+  FROM compchem_workflow_submission
+  WHERE status = 'pending'
+) renamed
+WHERE s.id = renamed.id;
+
+ALTER TABLE compchem_workflow_submission
+  DROP COLUMN secret_key;
@@ -0,0 +1,42 @@
+-- the secret key is handed to argo in a kubernetes secret instead of a workflow parameter, the
+-- dispatcher creates the secret from this column before the workflow is submitted
+ALTER TABLE compchem_workflow_submission
+  ADD COLUMN secret_key TEXT;
+
+UPDATE compchem_workflow_submission
+SET secret_key = (
+  SELECT p->>'value'
+  FROM jsonb_array_elements(workflow->'spec'->'arguments'->'parameters') p
+  WHERE p->>'name' = 'secret-key'
+);
+
+-- workflows waiting for submission pass the name of their secret to the steps instead of the key
+UPDATE compchem_workflow_submission s
+SET workflow = jsonb_set(
+  renamed.workflow,
+  '{spec,arguments,parameters}',
+  COALESCE((
+    SELECT jsonb_agg(
+      CASE WHEN p->>'name' = 'secret-name'
+        THEN jsonb_build_object(
+          'name', 'secret-name',
+          'value', (renamed.workflow->'metadata'->>'name') || '-key'
+        )
+        ELSE p
+      END
+      ORDER BY position
+    )
+    FROM jsonb_array_elements(renamed.workflow->'spec'->'arguments'->'parameters')
+      WITH ORDINALITY AS arguments(p, position)
+  ), '[]'::jsonb)
+)
+FROM (
+  SELECT id, replace(
+    replace(workflow::text, '"name": "secret-key"', '"name": "secret-name"'),
+    '{{workflow.parameters.secret-key}}',
+    '{{workflow.parameters.secret-name}}'
+  )::jsonb AS workflow
+  FROM compchem_workflow_submission
+  WHERE status = 'pending'
+) renamed
+WHERE s.id = renamed.id;
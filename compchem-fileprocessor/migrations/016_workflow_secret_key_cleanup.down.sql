@@ -0,0 +1,2 @@
+-- cleared secret keys can not be restored
+SELECT 1;
@@ -0,0 +1,21 @@
+-- secret keys are only kept until the kubernetes secret of the workflow holds them, workflows
+-- handed over before carry their key in the submitted workflow parameters as well
+UPDATE compchem_workflow_submission
+SET secret_key = NULL
+WHERE status <> 'pending';
+
+UPDATE compchem_workflow_submission
+SET workflow = jsonb_set(
+  workflow,
+  '{spec,arguments,parameters}',
+  COALESCE((
+    SELECT jsonb_agg(
+      CASE WHEN p->>'name' = 'secret-key' THEN p - 'value' ELSE p END
+      ORDER BY position
+    )
+    FROM jsonb_array_elements(workflow->'spec'->'arguments'->'parameters')
+      WITH ORDINALITY AS arguments(p, position)
+  ), '[]'::jsonb)
+)
+WHERE status <> 'pending'
+  AND workflow->'spec'->'arguments'->'parameters' @> '[{"name": "secret-key"}]';
@@ -0,0 +1,5 @@
+UPDATE compchem_workflow_submission SET status = 'pending' WHERE status = 'submitting';
+
+ALTER TABLE compchem_workflow_submission
+  DROP CONSTRAINT submission_status_check,
+  ADD CONSTRAINT submission_status_check CHECK (status IN ('pending', 'submitted', 'failed', 'cancelled'));
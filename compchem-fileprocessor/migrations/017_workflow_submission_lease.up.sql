@@ -0,0 +1,6 @@
+-- the dispatcher claims a submission as submitting until next_attempt_at and calls argo outside of
+-- the transaction, a claim which was not resolved in time is due again
+ALTER TABLE compchem_workflow_submission
+  DROP CONSTRAINT submission_status_check,
+  ADD CONSTRAINT submission_status_check
+    CHECK (status IN ('pending', 'submitting', 'submitted', 'failed', 'cancelled'));
@@ -0,0 +1,2 @@
+-- the submission time of workflows without a submission is not told apart from a real one
+SELECT 1;
@@ -0,0 +1,10 @@
+-- workflows created before the outbox have no submission, they were submitted right away and
+-- the start is the best known time of submission, the time of this migration otherwise
+UPDATE compchem_workflow wf
+SET submitted_at = COALESCE(wf.started_at, now())
+WHERE wf.submitted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM compchem_workflow_submission s
+    WHERE s.compchem_workflow_id = wf.id
+  );
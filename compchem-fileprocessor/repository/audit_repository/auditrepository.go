@@ -0,0 +1,196 @@
+package audit_repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	repository_common "fi.muni.cz/invenio-file-processor/v2/repository/common"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	EventAuthorization = "authorization"
+	EventAction        = "action"
+)
+
+// Allowed, source and cached are only set for authorization decisions, status only for actions.
+type AuditEntryEntity struct {
+	Event         string  `db:"event"`
+	Subject       string  `db:"subject"`
+	RecordId      *string `db:"record_id"`
+	WorkflowName  *string `db:"workflow_name"`
+	Action        string  `db:"action"`
+	Outcome       string  `db:"outcome"`
+	Status        *int    `db:"status"`
+	Allowed       *bool   `db:"allowed"`
+	Source        *string `db:"source"`
+	Cached        *bool   `db:"cached"`
+	Reason        string  `db:"reason"`
+	RequestId     *string `db:"request_id"`
+	SourceIp      *string `db:"source_ip"`
+	RequestMethod string  `db:"request_method"`
+	RequestPath   string  `db:"request_path"`
+}
+
+type ExistingAuditEntryEntity struct {
+	AuditEntryEntity
+	Id        int64     `db:"id"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// Empty fields match every entry, From is inclusive and To exclusive.
+type AuditFilter struct {
+	Event        string
+	Subject      string
+	RecordId     string
+	WorkflowName string
+	Action       string
+	Outcome      string
+	From         *time.Time
+	To           *time.Time
+}
+
+func CreateAuditEntry(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	entry AuditEntryEntity,
+) error {
+	logger.Debug(
+		"Creating audit entry",
+		zap.String("event", entry.Event),
+		zap.String("subject", entry.Subject),
+		zap.String("action", entry.Action),
+	)
+	SQL := `
+  INSERT INTO compchem_audit_log(
+    event,
+    subject,
+    record_id,
+    workflow_name,
+    action,
+    outcome,
+    status,
+    allowed,
+    source,
+    cached,
+    reason,
+    request_id,
+    source_ip,
+    request_method,
+    request_path
+  )
+  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15);
+  `
+
+	_, err := tx.Exec(
+		ctx,
+		SQL,
+		entry.Event,
+		entry.Subject,
+		entry.RecordId,
+		entry.WorkflowName,
+		entry.Action,
+		entry.Outcome,
+		entry.Status,
+		entry.Allowed,
+		entry.Source,
+		entry.Cached,
+		entry.Reason,
+		entry.RequestId,
+		entry.SourceIp,
+		entry.RequestMethod,
+		entry.RequestPath,
+	)
+	if err != nil {
+		return fmt.Errorf("Error during creation of audit entry: %v", err)
+	}
+
+	return nil
+}
+
+const filterAuditEntriesSQL = `
+  SELECT * FROM compchem_audit_log
+  WHERE ($1 = '' OR event = $1)
+    AND ($2 = '' OR subject = $2)
+    AND ($3 = '' OR record_id = $3)
+    AND ($4 = '' OR workflow_name = $4)
+    AND ($5 = '' OR action = $5)
+    AND ($6 = '' OR outcome = $6)
+    AND ($7::timestamptz IS NULL OR created_at >= $7)
+    AND ($8::timestamptz IS NULL OR created_at < $8)
+  ORDER BY created_at DESC, id DESC
+  `
+
+func filterArgs(filter AuditFilter) []any {
+	return []any{
+		filter.Event,
+		filter.Subject,
+		filter.RecordId,
+		filter.WorkflowName,
+		filter.Action,
+		filter.Outcome,
+		filter.From,
+		filter.To,
+	}
+}
+
+// Returns a page of entries matching the filter, newest first.
+func FindAuditEntries(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	filter AuditFilter,
+	limit int,
+	skip int,
+) ([]ExistingAuditEntryEntity, error) {
+	logger.Debug("Query audit entries", zap.Any("filter", filter))
+	SQL := filterAuditEntriesSQL + "LIMIT $9 OFFSET $10;"
+
+	entries, err := repository_common.QueryManyTx[ExistingAuditEntryEntity](
+		ctx,
+		tx,
+		SQL,
+		append(filterArgs(filter), limit, skip)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Error when retrieving audit entries: %v", err)
+	}
+
+	return entries, nil
+}
+
+// Hands every entry matching the filter to handle, newest first, without loading them all at once.
+// Iteration stops at the first error of handle.
+func ForEachAuditEntry(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	filter AuditFilter,
+	handle func(entry ExistingAuditEntryEntity) error,
+) error {
+	logger.Debug("Export audit entries", zap.Any("filter", filter))
+
+	rows, err := tx.Query(ctx, filterAuditEntriesSQL+";", filterArgs(filter)...)
+	if err != nil {
+		return fmt.Errorf("Error when exporting audit entries: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := pgx.RowToStructByName[ExistingAuditEntryEntity](rows)
+		if err != nil {
+			return fmt.Errorf("Error when reading audit entry: %v", err)
+		}
+		if err := handle(entry); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("Error when exporting audit entries: %v", err)
+	}
+
+	return nil
+}
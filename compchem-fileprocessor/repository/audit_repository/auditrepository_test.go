@@ -0,0 +1,119 @@
+package audit_repository
+
+import (
+	"testing"
+	"time"
+
+	repositorytest "fi.muni.cz/invenio-file-processor/v2/repository/test"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type auditRepositoryTestSuite struct {
+	repositorytest.PostgresTestSuite
+}
+
+func (s *auditRepositoryTestSuite) SetupSuite() {
+	s.PostgresTestSuite.MigratonsPath = "file://../../migrations"
+	s.PostgresTestSuite.SetupSuite()
+}
+
+func (s *auditRepositoryTestSuite) TearDownSuite() {
+	s.PostgresTestSuite.TearDownSuite()
+}
+
+func ptr[T any](value T) *T {
+	return &value
+}
+
+func denial() AuditEntryEntity {
+	return AuditEntryEntity{
+		Event:         EventAuthorization,
+		Subject:       "oidc:user-1",
+		RecordId:      ptr("abcd-1234"),
+		Action:        "process",
+		Outcome:       "denied",
+		Allowed:       ptr(false),
+		Source:        ptr("compchem"),
+		Cached:        ptr(true),
+		Reason:        "compchem answered 403",
+		RequestId:     ptr("4f1c"),
+		SourceIp:      ptr("10.0.0.1"),
+		RequestMethod: "POST",
+		RequestPath:   "/api/v1/workflows/abcd-1234",
+	}
+}
+
+func start() AuditEntryEntity {
+	return AuditEntryEntity{
+		Event:         EventAction,
+		Subject:       "api-key:compchem",
+		RecordId:      ptr("abcd-1234"),
+		WorkflowName:  ptr("count-words-abcd-1234-1"),
+		Action:        "start",
+		Outcome:       "succeeded",
+		Status:        ptr(201),
+		Reason:        "Created",
+		RequestMethod: "POST",
+		RequestPath:   "/api/v1/workflows/abcd-1234",
+	}
+}
+
+func (s *auditRepositoryTestSuite) TestFindAuditEntries_Filtered_MatchingEntriesNewestFirst() {
+	ctx := s.Ctx
+	t := s.T()
+
+	s.RunInTestTransaction(func(tx pgx.Tx) {
+		assert.NoError(t, CreateAuditEntry(ctx, s.Logger, tx, denial()))
+		assert.NoError(t, CreateAuditEntry(ctx, s.Logger, tx, start()))
+
+		entries, err := FindAuditEntries(ctx, s.Logger, tx, AuditFilter{RecordId: "abcd-1234"}, 10, 0)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 2)
+		assert.Equal(t, start(), entries[0].AuditEntryEntity)
+		assert.Equal(t, denial(), entries[1].AuditEntryEntity)
+
+		entries, err = FindAuditEntries(ctx, s.Logger, tx, AuditFilter{
+			WorkflowName: "count-words-abcd-1234-1",
+			Event:        EventAction,
+		}, 10, 0)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+
+		future := time.Now().Add(time.Hour)
+		entries, err = FindAuditEntries(ctx, s.Logger, tx, AuditFilter{From: &future}, 10, 0)
+		assert.NoError(t, err)
+		assert.Empty(t, entries)
+
+		var exported []string
+		err = ForEachAuditEntry(
+			ctx,
+			s.Logger,
+			tx,
+			AuditFilter{Subject: "oidc:user-1"},
+			func(entry ExistingAuditEntryEntity) error {
+				exported = append(exported, entry.Action)
+				return nil
+			},
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"process"}, exported)
+	})
+}
+
+func (s *auditRepositoryTestSuite) TestUpdateAuditEntry_AppendOnly_Rejected() {
+	ctx := s.Ctx
+	t := s.T()
+
+	s.RunInTestTransaction(func(tx pgx.Tx) {
+		assert.NoError(t, CreateAuditEntry(ctx, s.Logger, tx, start()))
+
+		_, err := tx.Exec(ctx, "UPDATE compchem_audit_log SET outcome = 'failed'")
+		assert.ErrorContains(t, err, "append-only")
+	})
+}
+
+func TestAuditRepositorySuite(t *testing.T) {
+	suite.Run(t, new(auditRepositoryTestSuite))
+}
@@ -0,0 +1,315 @@
+package definition_repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	repository_common "fi.muni.cz/invenio-file-processor/v2/repository/common"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+type DefinitionSource string
+
+const (
+	// seeded from the config file, read-only for the admin api
+	SourceConfig DefinitionSource = "config"
+	SourceApi    DefinitionSource = "api"
+)
+
+type DefinitionEntity struct {
+	Name      string           `db:"name"`
+	Source    DefinitionSource `db:"source"`
+	Enabled   bool             `db:"enabled"`
+	Version   int              `db:"version"`
+	CreatedAt time.Time        `db:"created_at"`
+	UpdatedAt time.Time        `db:"updated_at"`
+}
+
+type ExistingDefinitionEntity struct {
+	DefinitionEntity
+	Id uint64 `db:"id"`
+}
+
+type ProcessingTemplateEntity struct {
+	Position   int
+	Name       string
+	Template   string
+	Definition []byte
+}
+
+// Templates are a json list of the template definitions ordered by their position.
+type DefinitionVersionEntity struct {
+	Version    int       `db:"version"`
+	Definition []byte    `db:"definition"`
+	Templates  []byte    `db:"templates"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// Definition with the workflow config and templates of its current version.
+type CurrentDefinitionEntity struct {
+	ExistingDefinitionEntity
+	Definition []byte `db:"definition"`
+	Templates  []byte `db:"templates"`
+}
+
+const templatesAggregate = `
+  COALESCE(
+    jsonb_agg(t.definition ORDER BY t.position) FILTER (WHERE t.id IS NOT NULL),
+    '[]'::jsonb
+  ) AS templates
+`
+
+// Locks the definition so that concurrent updates create consecutive versions, returns nil when
+// there is no definition of the name.
+func FindDefinitionByName(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	name string,
+) (*ExistingDefinitionEntity, error) {
+	logger.Debug("Query workflow definition by name", zap.String("name", name))
+	SQL := `
+  SELECT * FROM compchem_workflow_definition WHERE name = $1 FOR UPDATE;
+  `
+
+	definition, err := repository_common.QueryOneTx[ExistingDefinitionEntity](ctx, tx, SQL, name)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Error when retrieving workflow definition by name: %v", err)
+	}
+
+	return definition, nil
+}
+
+// Creates the definition at version one, the version itself is added by CreateDefinitionVersion.
+func CreateDefinition(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	name string,
+	source DefinitionSource,
+) (*ExistingDefinitionEntity, error) {
+	logger.Debug("Creating workflow definition", zap.String("name", name))
+	SQL := `
+  INSERT INTO compchem_workflow_definition(name, source)
+  VALUES ($1, $2)
+  RETURNING *;
+  `
+
+	definition, err := repository_common.QueryOneTx[ExistingDefinitionEntity](ctx, tx, SQL, name, source)
+	if err != nil {
+		return nil, fmt.Errorf("Error during creation of workflow definition: %v", err)
+	}
+
+	return definition, nil
+}
+
+// Moves the definition to its next version and enables it, the version itself is added by
+// CreateDefinitionVersion.
+func BumpDefinitionVersion(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	id uint64,
+	source DefinitionSource,
+) (*ExistingDefinitionEntity, error) {
+	logger.Debug("Bumping workflow definition version", zap.Uint64("id", id))
+	SQL := `
+  UPDATE compchem_workflow_definition
+  SET version = version + 1, source = $2, enabled = TRUE, updated_at = now()
+  WHERE id = $1
+  RETURNING *;
+  `
+
+	definition, err := repository_common.QueryOneTx[ExistingDefinitionEntity](ctx, tx, SQL, id, source)
+	if err != nil {
+		return nil, fmt.Errorf("Error when bumping workflow definition version: %v", err)
+	}
+
+	return definition, nil
+}
+
+func CreateDefinitionVersion(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	definitionId uint64,
+	version int,
+	definition []byte,
+	templates []ProcessingTemplateEntity,
+) error {
+	logger.Debug(
+		"Creating workflow definition version",
+		zap.Uint64("definitionId", definitionId),
+		zap.Int("version", version),
+	)
+	SQL := `
+  INSERT INTO compchem_workflow_definition_version(compchem_workflow_definition_id, version, definition)
+  VALUES ($1, $2, $3)
+  RETURNING id;
+  `
+
+	var versionId uint64
+	err := tx.QueryRow(ctx, SQL, definitionId, version, definition).Scan(&versionId)
+	if err != nil {
+		return fmt.Errorf("Error during creation of workflow definition version: %v", err)
+	}
+
+	templateSQL := `
+  INSERT INTO compchem_processing_template(
+    compchem_workflow_definition_version_id, position, name, template, definition
+  )
+  VALUES ($1, $2, $3, $4, $5);
+  `
+	for _, template := range templates {
+		_, err := tx.Exec(
+			ctx,
+			templateSQL,
+			versionId,
+			template.Position,
+			template.Name,
+			template.Template,
+			template.Definition,
+		)
+		if err != nil {
+			return fmt.Errorf("Error during creation of processing template: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func SetDefinitionEnabled(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	id uint64,
+	enabled bool,
+) (*ExistingDefinitionEntity, error) {
+	logger.Debug("Setting workflow definition enabled", zap.Uint64("id", id), zap.Bool("enabled", enabled))
+	SQL := `
+  UPDATE compchem_workflow_definition
+  SET enabled = $2, updated_at = now()
+  WHERE id = $1
+  RETURNING *;
+  `
+
+	definition, err := repository_common.QueryOneTx[ExistingDefinitionEntity](ctx, tx, SQL, id, enabled)
+	if err != nil {
+		return nil, fmt.Errorf("Error when setting workflow definition enabled: %v", err)
+	}
+
+	return definition, nil
+}
+
+// Disables definitions seeded from the config file which are not in it anymore, returns how many
+// were disabled.
+func DisableRemovedConfigDefinitions(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	names []string,
+) (int64, error) {
+	logger.Debug("Disabling workflow definitions removed from the config file")
+	SQL := `
+  UPDATE compchem_workflow_definition
+  SET enabled = FALSE, updated_at = now()
+  WHERE source = 'config' AND enabled AND NOT (name = ANY($1));
+  `
+
+	tag, err := tx.Exec(ctx, SQL, names)
+	if err != nil {
+		return 0, fmt.Errorf("Error when disabling removed config definitions: %v", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func FindCurrentDefinitions(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+) ([]CurrentDefinitionEntity, error) {
+	logger.Debug("Query current workflow definitions")
+	SQL := `
+  SELECT d.*, v.definition,` + templatesAggregate + `
+  FROM compchem_workflow_definition d
+  JOIN compchem_workflow_definition_version v
+    ON v.compchem_workflow_definition_id = d.id AND v.version = d.version
+  LEFT JOIN compchem_processing_template t ON t.compchem_workflow_definition_version_id = v.id
+  GROUP BY d.id, v.id
+  ORDER BY d.name;
+  `
+
+	definitions, err := repository_common.QueryMany[CurrentDefinitionEntity](ctx, pool, SQL)
+	if err != nil {
+		return nil, fmt.Errorf("Error when retrieving current workflow definitions: %v", err)
+	}
+
+	return definitions, nil
+}
+
+func FindDefinitionVersion(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	definitionId uint64,
+	version int,
+) (*DefinitionVersionEntity, error) {
+	logger.Debug(
+		"Query workflow definition version",
+		zap.Uint64("definitionId", definitionId),
+		zap.Int("version", version),
+	)
+	SQL := `
+  SELECT v.version, v.definition, v.created_at,` + templatesAggregate + `
+  FROM compchem_workflow_definition_version v
+  LEFT JOIN compchem_processing_template t ON t.compchem_workflow_definition_version_id = v.id
+  WHERE v.compchem_workflow_definition_id = $1 AND v.version = $2
+  GROUP BY v.id;
+  `
+
+	definitionVersion, err := repository_common.QueryOneTx[DefinitionVersionEntity](
+		ctx,
+		tx,
+		SQL,
+		definitionId,
+		version,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Error when retrieving workflow definition version: %v", err)
+	}
+
+	return definitionVersion, nil
+}
+
+// Returns the version history of the definition, newest first.
+func FindDefinitionVersions(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	name string,
+) ([]DefinitionVersionEntity, error) {
+	logger.Debug("Query workflow definition versions", zap.String("name", name))
+	SQL := `
+  SELECT v.version, v.definition, v.created_at,` + templatesAggregate + `
+  FROM compchem_workflow_definition d
+  JOIN compchem_workflow_definition_version v ON v.compchem_workflow_definition_id = d.id
+  LEFT JOIN compchem_processing_template t ON t.compchem_workflow_definition_version_id = v.id
+  WHERE d.name = $1
+  GROUP BY v.id
+  ORDER BY v.version DESC;
+  `
+
+	versions, err := repository_common.QueryMany[DefinitionVersionEntity](ctx, pool, SQL, name)
+	if err != nil {
+		return nil, fmt.Errorf("Error when retrieving workflow definition versions: %v", err)
+	}
+
+	return versions, nil
+}
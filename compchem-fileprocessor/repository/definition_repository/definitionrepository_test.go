@@ -0,0 +1,145 @@
+package definition_repository
+
+import (
+	"testing"
+
+	repositorytest "fi.muni.cz/invenio-file-processor/v2/repository/test"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type definitionRepositoryTestSuite struct {
+	repositorytest.PostgresTestSuite
+}
+
+func (s *definitionRepositoryTestSuite) SetupSuite() {
+	s.PostgresTestSuite.MigratonsPath = "file://../../migrations"
+	s.PostgresTestSuite.SetupSuite()
+}
+
+func (s *definitionRepositoryTestSuite) TearDownSuite() {
+	s.PostgresTestSuite.TearDownSuite()
+}
+
+var countWordsTemplates = []ProcessingTemplateEntity{
+	{
+		Position:   0,
+		Name:       "count-words-template",
+		Template:   "count-words",
+		Definition: []byte(`{"name":"count-words-template","template":"count-words"}`),
+	},
+	{
+		Position:   1,
+		Name:       "sum-words-template",
+		Template:   "sum-words",
+		Definition: []byte(`{"name":"sum-words-template","template":"sum-words"}`),
+	},
+}
+
+func (s *definitionRepositoryTestSuite) TestCreateDefinition_WithVersion_FoundByName() {
+	ctx := s.Ctx
+	logger := s.Logger
+	t := s.T()
+
+	s.RunInTestTransaction(func(tx pgx.Tx) {
+		created, err := CreateDefinition(ctx, logger, tx, "count-words", SourceApi)
+		assert.NoError(t, err)
+		err = CreateDefinitionVersion(
+			ctx,
+			logger,
+			tx,
+			created.Id,
+			created.Version,
+			[]byte(`{"name":"count-words"}`),
+			countWordsTemplates,
+		)
+		assert.NoError(t, err)
+
+		found, err := FindDefinitionByName(ctx, logger, tx, "count-words")
+		assert.NoError(t, err)
+		assert.Equal(t, created.Id, found.Id)
+		assert.Equal(t, SourceApi, found.Source)
+		assert.True(t, found.Enabled)
+		assert.Equal(t, 1, found.Version)
+
+		version, err := FindDefinitionVersion(ctx, logger, tx, created.Id, 1)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"name":"count-words"}`, string(version.Definition))
+		assert.JSONEq(
+			t,
+			`[{"name":"count-words-template","template":"count-words"},`+
+				`{"name":"sum-words-template","template":"sum-words"}]`,
+			string(version.Templates),
+		)
+	})
+}
+
+func (s *definitionRepositoryTestSuite) TestFindDefinitionByName_NoDefinition_ReturnsNilAndNoError() {
+	s.RunInTestTransaction(func(tx pgx.Tx) {
+		definition, err := FindDefinitionByName(s.Ctx, s.Logger, tx, "count-words")
+		assert.NoError(s.T(), err)
+		assert.Nil(s.T(), definition)
+	})
+}
+
+func (s *definitionRepositoryTestSuite) TestCreateDefinition_SameNameTwice_ErrReturned() {
+	s.RunInTestTransaction(func(tx pgx.Tx) {
+		_, err := CreateDefinition(s.Ctx, s.Logger, tx, "count-words", SourceConfig)
+		assert.NoError(s.T(), err)
+
+		definition, err := CreateDefinition(s.Ctx, s.Logger, tx, "count-words", SourceApi)
+		assert.Error(s.T(), err)
+		assert.Nil(s.T(), definition)
+	})
+}
+
+func (s *definitionRepositoryTestSuite) TestBumpDefinitionVersion_DisabledDefinition_NextVersionEnabled() {
+	ctx := s.Ctx
+	logger := s.Logger
+	t := s.T()
+
+	s.RunInTestTransaction(func(tx pgx.Tx) {
+		created, err := CreateDefinition(ctx, logger, tx, "count-words", SourceApi)
+		assert.NoError(t, err)
+		_, err = SetDefinitionEnabled(ctx, logger, tx, created.Id, false)
+		assert.NoError(t, err)
+
+		bumped, err := BumpDefinitionVersion(ctx, logger, tx, created.Id, SourceConfig)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, bumped.Version)
+		assert.Equal(t, SourceConfig, bumped.Source)
+		assert.True(t, bumped.Enabled)
+	})
+}
+
+func (s *definitionRepositoryTestSuite) TestDisableRemovedConfigDefinitions_OnlyMissingConfigDefinitionsDisabled() {
+	ctx := s.Ctx
+	logger := s.Logger
+	t := s.T()
+
+	s.RunInTestTransaction(func(tx pgx.Tx) {
+		_, err := CreateDefinition(ctx, logger, tx, "count-words", SourceConfig)
+		assert.NoError(t, err)
+		_, err = CreateDefinition(ctx, logger, tx, "count-lines", SourceConfig)
+		assert.NoError(t, err)
+		_, err = CreateDefinition(ctx, logger, tx, "count-chars", SourceApi)
+		assert.NoError(t, err)
+
+		disabled, err := DisableRemovedConfigDefinitions(ctx, logger, tx, []string{"count-words"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), disabled)
+		removed, err := FindDefinitionByName(ctx, logger, tx, "count-lines")
+		assert.NoError(t, err)
+		assert.False(t, removed.Enabled)
+		managed, err := FindDefinitionByName(ctx, logger, tx, "count-chars")
+		assert.NoError(t, err)
+		assert.True(t, managed.Enabled)
+	})
+}
+
+func TestDefinitionRepositorySuite(t *testing.T) {
+	suite.Run(t, new(definitionRepositoryTestSuite))
+}
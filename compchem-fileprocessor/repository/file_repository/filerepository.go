@@ -63,6 +63,28 @@ func FindFilesForWorkflow(
 	return util.Map(fileKeys, func(wrapper stringWrapper) string { return wrapper.Key }), nil
 }
 
+func FindFileEntitiesForWorkflow(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	workflowId uint64,
+) ([]ExistingCompchemFile, error) {
+	logger.Debug("Getting all file entities for workflow", zap.Uint64("workflowId", workflowId))
+	const SQL = `
+    SELECT f.* FROM compchem_file f
+    INNER JOIN compchem_workflow_file wff ON f.id = wff.compchem_file_id
+    WHERE wff.compchem_workflow_id = $1
+    ORDER BY f.id
+    `
+
+	files, err := repository_common.QueryManyTx[ExistingCompchemFile](ctx, tx, SQL, workflowId)
+	if err != nil {
+		return nil, fmt.Errorf("Error when retrieving file entities for workflow: %v", err)
+	}
+
+	return files, nil
+}
+
 func CreateFile(
 	ctx context.Context,
 	logger *zap.Logger,
@@ -0,0 +1,65 @@
+package snapshot_repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	repository_common "fi.muni.cz/invenio-file-processor/v2/repository/common"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+type ConfigSnapshotEntity struct {
+	ConfigHash   string    `db:"config_hash"`
+	WorkflowName string    `db:"workflow_name"`
+	Config       []byte    `db:"config"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// Stores the snapshot unless a snapshot with the same hash exists already.
+func CreateConfigSnapshot(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	configHash string,
+	workflowName string,
+	config []byte,
+) error {
+	logger.Debug("Creating workflow config snapshot", zap.String("configHash", configHash))
+	SQL := `
+  INSERT INTO compchem_workflow_config_snapshot(config_hash, workflow_name, config)
+  VALUES ($1, $2, $3)
+  ON CONFLICT (config_hash) DO NOTHING;
+  `
+
+	_, err := tx.Exec(ctx, SQL, configHash, workflowName, string(config))
+	if err != nil {
+		return fmt.Errorf("Error during creation of workflow config snapshot: %v", err)
+	}
+
+	return nil
+}
+
+// Returns nil when there is no snapshot of the hash.
+func FindConfigSnapshot(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	configHash string,
+) (*ConfigSnapshotEntity, error) {
+	logger.Debug("Query workflow config snapshot", zap.String("configHash", configHash))
+	SQL := `
+  SELECT * FROM compchem_workflow_config_snapshot WHERE config_hash = $1;
+  `
+
+	snapshot, err := repository_common.QueryOneTx[ConfigSnapshotEntity](ctx, tx, SQL, configHash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Error when retrieving workflow config snapshot: %v", err)
+	}
+
+	return snapshot, nil
+}
@@ -0,0 +1,54 @@
+package snapshot_repository
+
+import (
+	"testing"
+
+	repositorytest "fi.muni.cz/invenio-file-processor/v2/repository/test"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type snapshotRepositoryTestSuite struct {
+	repositorytest.PostgresTestSuite
+}
+
+func (s *snapshotRepositoryTestSuite) SetupSuite() {
+	s.PostgresTestSuite.MigratonsPath = "file://../../migrations"
+	s.PostgresTestSuite.SetupSuite()
+}
+
+func (s *snapshotRepositoryTestSuite) TearDownSuite() {
+	s.PostgresTestSuite.TearDownSuite()
+}
+
+func (s *snapshotRepositoryTestSuite) TestCreateConfigSnapshot_SameHashTwice_FirstSnapshotKept() {
+	ctx := s.Ctx
+	logger := s.Logger
+	t := s.T()
+
+	s.RunInTestTransaction(func(tx pgx.Tx) {
+		err := CreateConfigSnapshot(ctx, logger, tx, "a1b2", "count-words", []byte(`{"name":"count-words"}`))
+		assert.NoError(t, err)
+		err = CreateConfigSnapshot(ctx, logger, tx, "a1b2", "count-lines", []byte(`{"name":"count-lines"}`))
+		assert.NoError(t, err)
+
+		snapshot, err := FindConfigSnapshot(ctx, logger, tx, "a1b2")
+		assert.NoError(t, err)
+		assert.Equal(t, "count-words", snapshot.WorkflowName)
+		assert.Equal(t, `{"name":"count-words"}`, string(snapshot.Config))
+		assert.NotEmpty(t, snapshot.CreatedAt)
+	})
+}
+
+func (s *snapshotRepositoryTestSuite) TestFindConfigSnapshot_NoSnapshot_ReturnsNilAndNoError() {
+	s.RunInTestTransaction(func(tx pgx.Tx) {
+		snapshot, err := FindConfigSnapshot(s.Ctx, s.Logger, tx, "a1b2")
+		assert.NoError(s.T(), err)
+		assert.Nil(s.T(), snapshot)
+	})
+}
+
+func TestSnapshotRepositorySuite(t *testing.T) {
+	suite.Run(t, new(snapshotRepositoryTestSuite))
+}
@@ -0,0 +1,282 @@
+package submission_repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	repository_common "fi.muni.cz/invenio-file-processor/v2/repository/common"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+type SubmissionStatus string
+
+const (
+	SubmissionPending    SubmissionStatus = "pending"
+	SubmissionSubmitting SubmissionStatus = "submitting"
+	SubmissionSubmitted  SubmissionStatus = "submitted"
+	SubmissionFailed     SubmissionStatus = "failed"
+	SubmissionCancelled  SubmissionStatus = "cancelled"
+)
+
+type SubmissionEntity struct {
+	WorkflowId    uint64           `db:"compchem_workflow_id"`
+	Workflow      []byte           `db:"workflow"`
+	Status        SubmissionStatus `db:"status"`
+	Attempts      int              `db:"attempts"`
+	LastError     *string          `db:"last_error"`
+	NextAttemptAt time.Time        `db:"next_attempt_at"`
+	CreatedAt     time.Time        `db:"created_at"`
+	SubmittedAt   *time.Time       `db:"submitted_at"`
+	SecretKey     *string          `db:"secret_key"`
+}
+
+type ExistingSubmissionEntity struct {
+	SubmissionEntity
+	Id uint64 `db:"id"`
+}
+
+type UnsubmittedWorkflow struct {
+	RecordId      string           `db:"record_id"`
+	WorkflowName  string           `db:"workflow_name"`
+	WorkflowSeqId uint64           `db:"workflow_record_seq_id"`
+	Status        SubmissionStatus `db:"status"`
+	Attempts      int              `db:"attempts"`
+	LastError     *string          `db:"last_error"`
+	CreatedAt     time.Time        `db:"created_at"`
+	NextAttemptAt time.Time        `db:"next_attempt_at"`
+}
+
+func CreateSubmission(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	workflowId uint64,
+	workflow []byte,
+	secretKey string,
+) (*ExistingSubmissionEntity, error) {
+	logger.Debug("Creating workflow submission", zap.Uint64("workflowId", workflowId))
+	SQL := `
+  INSERT INTO compchem_workflow_submission(compchem_workflow_id, workflow, secret_key)
+  VALUES ($1, $2, $3)
+  RETURNING *;
+  `
+
+	submission, err := repository_common.QueryOneTx[ExistingSubmissionEntity](
+		ctx,
+		tx,
+		SQL,
+		workflowId,
+		workflow,
+		secretKey,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Error during creation of workflow submission: %v", err)
+	}
+
+	return submission, nil
+}
+
+// Claims the oldest due submission as submitting until leaseUntil so that multiple dispatchers
+// never submit the same workflow, returns nil when there is nothing to submit. A claim which was
+// not resolved before its lease ran out, e.g. because the dispatcher stopped, is due again.
+func ClaimNextDueSubmission(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	leaseUntil time.Time,
+) (*ExistingSubmissionEntity, error) {
+	logger.Debug("Claiming next due workflow submission")
+	SQL := `
+  UPDATE compchem_workflow_submission
+  SET status = 'submitting', next_attempt_at = $1
+  WHERE id = (
+    SELECT id FROM compchem_workflow_submission
+    WHERE status IN ('pending', 'submitting') AND next_attempt_at <= now()
+    ORDER BY next_attempt_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+  )
+  RETURNING *;
+  `
+
+	submission, err := repository_common.QueryOneTx[ExistingSubmissionEntity](
+		ctx,
+		tx,
+		SQL,
+		leaseUntil,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Error when claiming due submission: %v", err)
+	}
+
+	return submission, nil
+}
+
+// Returns false when the submission is no longer claimed, e.g. it was cancelled meanwhile.
+func MarkSubmitted(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	id uint64,
+) (bool, error) {
+	logger.Debug("Marking workflow submission as submitted", zap.Uint64("id", id))
+	SQL := `
+  UPDATE compchem_workflow_submission
+  SET status = 'submitted', attempts = attempts + 1, submitted_at = now(), last_error = NULL
+  WHERE id = $1 AND status = 'submitting';
+  `
+
+	tag, err := tx.Exec(ctx, SQL, id)
+	if err != nil {
+		return false, fmt.Errorf("Error when marking submission as submitted: %v", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// The secret key is only kept until the kubernetes secret of the workflow holds it.
+func ClearSecretKey(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	id uint64,
+) error {
+	logger.Debug("Clearing secret key of workflow submission", zap.Uint64("id", id))
+	SQL := `
+  UPDATE compchem_workflow_submission
+  SET secret_key = NULL
+  WHERE id = $1;
+  `
+
+	_, err := tx.Exec(ctx, SQL, id)
+	if err != nil {
+		return fmt.Errorf("Error when clearing secret key of submission: %v", err)
+	}
+
+	return nil
+}
+
+// Cancels a submission which was not handed over to argo yet together with its secret key, returns
+// false when there was nothing to cancel. A claimed submission is cancelled as well, the dispatcher
+// withdraws it when it finds the claim gone.
+func CancelSubmission(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	workflowId uint64,
+) (bool, error) {
+	logger.Debug("Cancelling workflow submission", zap.Uint64("workflowId", workflowId))
+	SQL := `
+  UPDATE compchem_workflow_submission
+  SET status = 'cancelled', secret_key = NULL
+  WHERE compchem_workflow_id = $1 AND status IN ('pending', 'submitting');
+  `
+
+	tag, err := tx.Exec(ctx, SQL, workflowId)
+	if err != nil {
+		return false, fmt.Errorf("Error when cancelling workflow submission: %v", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// Records a failed attempt of a claimed submission, it is pending again until nextAttemptAt unless
+// failed is set. A failed submission drops its secret key. Returns false when the submission is no
+// longer claimed.
+func RecordFailedAttempt(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	id uint64,
+	message string,
+	nextAttemptAt time.Time,
+	failed bool,
+) (bool, error) {
+	logger.Debug(
+		"Recording failed submission attempt",
+		zap.Uint64("id", id),
+		zap.Bool("failed", failed),
+	)
+	SQL := `
+  UPDATE compchem_workflow_submission
+  SET attempts = attempts + 1,
+      last_error = $2,
+      next_attempt_at = $3,
+      status = CASE WHEN $4 THEN 'failed' ELSE 'pending' END,
+      secret_key = CASE WHEN $4 THEN NULL ELSE secret_key END
+  WHERE id = $1 AND status = 'submitting';
+  `
+
+	tag, err := tx.Exec(ctx, SQL, id, message, nextAttemptAt, failed)
+	if err != nil {
+		return false, fmt.Errorf("Error when recording failed submission attempt: %v", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+func FindSubmissionForWorkflow(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	recordId string,
+	workflowName string,
+	workflowSeq uint64,
+) (*ExistingSubmissionEntity, error) {
+	logger.Debug(
+		"Query submission for workflow",
+		zap.String("recordId", recordId),
+		zap.String("workflowName", workflowName),
+		zap.Uint64("workflowSeq", workflowSeq),
+	)
+	SQL := `
+  SELECT s.* FROM compchem_workflow_submission s
+  INNER JOIN compchem_workflow wf ON wf.id = s.compchem_workflow_id
+  WHERE wf.record_id = $1 AND wf.workflow_name = $2 AND wf.workflow_record_seq_id = $3;
+  `
+
+	submission, err := repository_common.QueryOneTx[ExistingSubmissionEntity](
+		ctx,
+		tx,
+		SQL,
+		recordId,
+		workflowName,
+		workflowSeq,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Error when retrieving submission for workflow: %v", err)
+	}
+
+	return submission, nil
+}
+
+func FindUnsubmittedForRecord(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	recordId string,
+) ([]UnsubmittedWorkflow, error) {
+	logger.Debug("Query unsubmitted workflows for record", zap.String("recordId", recordId))
+	SQL := `
+  SELECT wf.record_id, wf.workflow_name, wf.workflow_record_seq_id,
+         s.status, s.attempts, s.last_error, s.created_at, s.next_attempt_at
+  FROM compchem_workflow_submission s
+  INNER JOIN compchem_workflow wf ON wf.id = s.compchem_workflow_id
+  WHERE wf.record_id = $1 AND s.status <> 'submitted'
+  ORDER BY wf.workflow_record_seq_id DESC;
+  `
+
+	workflows, err := repository_common.QueryManyTx[UnsubmittedWorkflow](ctx, tx, SQL, recordId)
+	if err != nil {
+		return nil, fmt.Errorf("Error when retrieving unsubmitted workflows: %v", err)
+	}
+
+	return workflows, nil
+}
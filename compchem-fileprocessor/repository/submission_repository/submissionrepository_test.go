@@ -0,0 +1,304 @@
+package submission_repository
+
+import (
+	"testing"
+	"time"
+
+	repositorytest "fi.muni.cz/invenio-file-processor/v2/repository/test"
+	"fi.muni.cz/invenio-file-processor/v2/repository/workflow_repository"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type submissionRepositoryTestSuite struct {
+	repositorytest.PostgresTestSuite
+}
+
+func (s *submissionRepositoryTestSuite) SetupSuite() {
+	s.PostgresTestSuite.MigratonsPath = "file://../../migrations"
+	s.PostgresTestSuite.SetupSuite()
+}
+
+func (s *submissionRepositoryTestSuite) TearDownSuite() {
+	s.PostgresTestSuite.TearDownSuite()
+}
+
+func (s *submissionRepositoryTestSuite) createWorkflow(tx pgx.Tx, seq uint64) uint64 {
+	wf, err := workflow_repository.CreateWorkflowForRecord(
+		s.Ctx,
+		s.Logger,
+		tx,
+		workflow_repository.WorkflowEntity{
+			RecordId:      "ej281-k87lh",
+			WorkflowName:  "count-words",
+			WorkflowSeqId: seq,
+		},
+	)
+	assert.NoError(s.T(), err)
+
+	return wf.Id
+}
+
+// Claims the submission like the dispatcher does without picking among due submissions.
+func (s *submissionRepositoryTestSuite) claim(tx pgx.Tx, id uint64) {
+	_, err := tx.Exec(
+		s.Ctx,
+		"UPDATE compchem_workflow_submission SET status = 'submitting' WHERE id = $1",
+		id,
+	)
+	assert.NoError(s.T(), err)
+}
+
+func (s *submissionRepositoryTestSuite) TestCreateSubmission_NothingViolated_PendingSubmissionCreated() {
+	ctx := s.Ctx
+	logger := s.Logger
+	t := s.T()
+
+	s.RunInTestTransaction(func(tx pgx.Tx) {
+		workflowId := s.createWorkflow(tx, 1)
+
+		submission, err := CreateSubmission(
+			ctx,
+			logger,
+			tx,
+			workflowId,
+			[]byte(`{"kind":"Workflow"}`),
+			"mysecretkey",
+		)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, submission.Id)
+		assert.Equal(t, workflowId, submission.WorkflowId)
+		assert.Equal(t, SubmissionPending, submission.Status)
+		assert.Equal(t, 0, submission.Attempts)
+		assert.Nil(t, submission.SubmittedAt)
+		assert.JSONEq(t, `{"kind":"Workflow"}`, string(submission.Workflow))
+		assert.Equal(t, "mysecretkey", *submission.SecretKey)
+	})
+}
+
+func (s *submissionRepositoryTestSuite) TestCreateSubmission_SameWorkflowTwice_ErrReturned() {
+	ctx := s.Ctx
+	logger := s.Logger
+	t := s.T()
+
+	s.RunInTestTransaction(func(tx pgx.Tx) {
+		workflowId := s.createWorkflow(tx, 1)
+
+		_, err := CreateSubmission(ctx, logger, tx, workflowId, []byte(`{}`), "mysecretkey")
+		assert.NoError(t, err)
+
+		submission, err := CreateSubmission(ctx, logger, tx, workflowId, []byte(`{}`), "mysecretkey")
+		assert.Error(t, err)
+		assert.Nil(t, submission)
+	})
+}
+
+func (s *submissionRepositoryTestSuite) TestClaimNextDueSubmission_OnlyFutureAttempts_NothingReturned() {
+	ctx := s.Ctx
+	logger := s.Logger
+	t := s.T()
+
+	s.RunInTestTransaction(func(tx pgx.Tx) {
+		workflowId := s.createWorkflow(tx, 1)
+		created, err := CreateSubmission(ctx, logger, tx, workflowId, []byte(`{}`), "mysecretkey")
+		assert.NoError(t, err)
+		s.claim(tx, created.Id)
+
+		recorded, err := RecordFailedAttempt(
+			ctx,
+			logger,
+			tx,
+			created.Id,
+			"argo unavailable",
+			time.Now().Add(time.Hour),
+			false,
+		)
+		assert.NoError(t, err)
+		assert.True(t, recorded)
+
+		submission, err := ClaimNextDueSubmission(ctx, logger, tx, time.Now().Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Nil(t, submission)
+	})
+}
+
+func (s *submissionRepositoryTestSuite) TestClaimNextDueSubmission_LeaseRunsOut_ClaimedAgain() {
+	ctx := s.Ctx
+	logger := s.Logger
+	t := s.T()
+
+	s.RunInTestTransaction(func(tx pgx.Tx) {
+		workflowId := s.createWorkflow(tx, 1)
+		_, err := CreateSubmission(ctx, logger, tx, workflowId, []byte(`{}`), "mysecretkey")
+		assert.NoError(t, err)
+
+		claimed, err := ClaimNextDueSubmission(ctx, logger, tx, time.Now().Add(-time.Minute))
+		assert.NoError(t, err)
+		assert.Equal(t, SubmissionSubmitting, claimed.Status)
+
+		expired, err := ClaimNextDueSubmission(ctx, logger, tx, time.Now().Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Equal(t, claimed.Id, expired.Id)
+
+		leased, err := ClaimNextDueSubmission(ctx, logger, tx, time.Now().Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Nil(t, leased)
+	})
+}
+
+func (s *submissionRepositoryTestSuite) TestMarkSubmitted_ClaimedSubmission_NoLongerDue() {
+	ctx := s.Ctx
+	logger := s.Logger
+	t := s.T()
+
+	s.RunInTestTransaction(func(tx pgx.Tx) {
+		workflowId := s.createWorkflow(tx, 1)
+		_, err := CreateSubmission(ctx, logger, tx, workflowId, []byte(`{}`), "mysecretkey")
+		assert.NoError(t, err)
+
+		due, err := ClaimNextDueSubmission(ctx, logger, tx, time.Now().Add(-time.Minute))
+		assert.NoError(t, err)
+		assert.NotNil(t, due)
+
+		marked, err := MarkSubmitted(ctx, logger, tx, due.Id)
+		assert.NoError(t, err)
+		assert.True(t, marked)
+
+		due, err = ClaimNextDueSubmission(ctx, logger, tx, time.Now().Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Nil(t, due)
+
+		submission, err := FindSubmissionForWorkflow(ctx, logger, tx, "ej281-k87lh", "count-words", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, SubmissionSubmitted, submission.Status)
+		assert.Equal(t, 1, submission.Attempts)
+		assert.NotNil(t, submission.SubmittedAt)
+	})
+}
+
+func (s *submissionRepositoryTestSuite) TestMarkSubmitted_CancelledWhileClaimed_NotMarked() {
+	ctx := s.Ctx
+	logger := s.Logger
+	t := s.T()
+
+	s.RunInTestTransaction(func(tx pgx.Tx) {
+		workflowId := s.createWorkflow(tx, 1)
+		created, err := CreateSubmission(ctx, logger, tx, workflowId, []byte(`{}`), "mysecretkey")
+		assert.NoError(t, err)
+		s.claim(tx, created.Id)
+
+		cancelled, err := CancelSubmission(ctx, logger, tx, workflowId)
+		assert.NoError(t, err)
+		assert.True(t, cancelled)
+
+		marked, err := MarkSubmitted(ctx, logger, tx, created.Id)
+		assert.NoError(t, err)
+		assert.False(t, marked)
+		recorded, err := RecordFailedAttempt(ctx, logger, tx, created.Id, "", time.Now(), false)
+		assert.NoError(t, err)
+		assert.False(t, recorded)
+
+		submission, err := FindSubmissionForWorkflow(ctx, logger, tx, "ej281-k87lh", "count-words", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, SubmissionCancelled, submission.Status)
+	})
+}
+
+func (s *submissionRepositoryTestSuite) TestClearSecretKey_PendingSubmission_OnlyKeyCleared() {
+	ctx := s.Ctx
+	logger := s.Logger
+	t := s.T()
+
+	s.RunInTestTransaction(func(tx pgx.Tx) {
+		workflowId := s.createWorkflow(tx, 1)
+		created, err := CreateSubmission(ctx, logger, tx, workflowId, []byte(`{}`), "mysecretkey")
+		assert.NoError(t, err)
+
+		assert.NoError(t, ClearSecretKey(ctx, logger, tx, created.Id))
+
+		submission, err := FindSubmissionForWorkflow(ctx, logger, tx, "ej281-k87lh", "count-words", 1)
+		assert.NoError(t, err)
+		assert.Nil(t, submission.SecretKey)
+		assert.Equal(t, SubmissionPending, submission.Status)
+	})
+}
+
+func (s *submissionRepositoryTestSuite) TestCancelSubmission_PendingAndSubmitted_OnlyPendingCancelled() {
+	ctx := s.Ctx
+	logger := s.Logger
+	t := s.T()
+
+	s.RunInTestTransaction(func(tx pgx.Tx) {
+		pendingId := s.createWorkflow(tx, 1)
+		_, err := CreateSubmission(ctx, logger, tx, pendingId, []byte(`{}`), "mysecretkey")
+		assert.NoError(t, err)
+		submittedId := s.createWorkflow(tx, 2)
+		submitted, err := CreateSubmission(ctx, logger, tx, submittedId, []byte(`{}`), "mysecretkey")
+		assert.NoError(t, err)
+		s.claim(tx, submitted.Id)
+		_, err = MarkSubmitted(ctx, logger, tx, submitted.Id)
+		assert.NoError(t, err)
+
+		cancelled, err := CancelSubmission(ctx, logger, tx, pendingId)
+		assert.NoError(t, err)
+		assert.True(t, cancelled)
+
+		cancelled, err = CancelSubmission(ctx, logger, tx, submittedId)
+		assert.NoError(t, err)
+		assert.False(t, cancelled)
+
+		due, err := ClaimNextDueSubmission(ctx, logger, tx, time.Now().Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Nil(t, due)
+
+		submission, err := FindSubmissionForWorkflow(ctx, logger, tx, "ej281-k87lh", "count-words", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, SubmissionCancelled, submission.Status)
+		assert.Nil(t, submission.SecretKey)
+	})
+}
+
+func (s *submissionRepositoryTestSuite) TestFindUnsubmittedForRecord_FailedAndSubmitted_OnlyFailedReturned() {
+	ctx := s.Ctx
+	logger := s.Logger
+	t := s.T()
+
+	s.RunInTestTransaction(func(tx pgx.Tx) {
+		body := []byte(`{}`)
+		submitted, err := CreateSubmission(ctx, logger, tx, s.createWorkflow(tx, 1), body, "mysecretkey")
+		assert.NoError(t, err)
+		failed, err := CreateSubmission(ctx, logger, tx, s.createWorkflow(tx, 2), body, "mysecretkey")
+		assert.NoError(t, err)
+
+		s.claim(tx, submitted.Id)
+		s.claim(tx, failed.Id)
+		_, err = MarkSubmitted(ctx, logger, tx, submitted.Id)
+		assert.NoError(t, err)
+		_, err = RecordFailedAttempt(ctx, logger, tx, failed.Id, "bad request", time.Now(), true)
+		assert.NoError(t, err)
+
+		unsubmitted, err := FindUnsubmittedForRecord(ctx, logger, tx, "ej281-k87lh")
+		assert.NoError(t, err)
+		assert.Len(t, unsubmitted, 1)
+		assert.Equal(t, uint64(2), unsubmitted[0].WorkflowSeqId)
+		assert.Equal(t, SubmissionFailed, unsubmitted[0].Status)
+		assert.Equal(t, "bad request", *unsubmitted[0].LastError)
+
+		stored, err := FindSubmissionForWorkflow(ctx, logger, tx, "ej281-k87lh", "count-words", 2)
+		assert.NoError(t, err)
+		assert.Nil(t, stored.SecretKey)
+	})
+}
+
+func (s *submissionRepositoryTestSuite) TestFindSubmissionForWorkflow_NoSubmission_ReturnsNilAndNoError() {
+	s.RunInTestTransaction(func(tx pgx.Tx) {
+		submission, err := FindSubmissionForWorkflow(s.Ctx, s.Logger, tx, "ej281-k87lh", "count-words", 1)
+		assert.NoError(s.T(), err)
+		assert.Nil(s.T(), submission)
+	})
+}
+
+func TestSubmissionRepositorySuite(t *testing.T) {
+	suite.Run(t, new(submissionRepositoryTestSuite))
+}
@@ -2,7 +2,9 @@ package workflow_repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	repository_common "fi.muni.cz/invenio-file-processor/v2/repository/common"
 	"github.com/jackc/pgx/v5"
@@ -10,16 +12,74 @@ import (
 )
 
 type WorkflowEntity struct {
-	RecordId      string `db:"record_id"`
-	WorkflowName  string `db:"workflow_name"`
-	WorkflowSeqId uint64 `db:"workflow_record_seq_id"`
+	RecordId      string  `db:"record_id"`
+	WorkflowName  string  `db:"workflow_name"`
+	WorkflowSeqId uint64  `db:"workflow_record_seq_id"`
+	SecretKeyHash *string `db:"secret_key_hash"`
+	Namespace     *string `db:"namespace"`
+	Parameters    []byte  `db:"parameters"`
+	Inputs        []byte  `db:"inputs"`
+	ConfigHash    *string `db:"config_hash"`
+}
+
+// Workflows stored without a namespace run in the default one.
+func (w *WorkflowEntity) ArgoNamespace(defaultNamespace string) string {
+	if w.Namespace == nil || *w.Namespace == "" {
+		return defaultNamespace
+	}
+	return *w.Namespace
+}
+
+// Lifecycle of the workflow as last seen in argo, full name is generated by the database.
+type WorkflowState struct {
+	FullName          string     `db:"workflow_full_name"`
+	Phase             string     `db:"phase"`
+	ArgoUid           *string    `db:"argo_uid"`
+	Message           *string    `db:"message"`
+	Progress          *string    `db:"progress"`
+	SubmittedAt       *time.Time `db:"submitted_at"`
+	StartedAt         *time.Time `db:"started_at"`
+	FinishedAt        *time.Time `db:"finished_at"`
+	CancelAction      *string    `db:"cancel_action"`
+	CancelledAt       *time.Time `db:"cancelled_at"`
+	RetriedFrom       *uint64    `db:"retried_from"`
+	RetryCount        int        `db:"retry_count"`
+	RetriedAt         *time.Time `db:"retried_at"`
+	FailedNodes       []byte     `db:"failed_nodes"`
+	OutcomeReportedAt *time.Time `db:"outcome_reported_at"`
+	OutputFiles       []byte     `db:"output_files"`
 }
 
 type ExistingWorfklowEntity struct {
 	WorkflowEntity
+	WorkflowState
 	Id uint64 `db:"id"`
 }
 
+type WorkflowStateUpdate struct {
+	Phase      string
+	ArgoUid    *string
+	Message    *string
+	Progress   *string
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+}
+
+// Final state of the workflow reported by its exit handler.
+type WorkflowOutcome struct {
+	Phase       string
+	Message     *string
+	FailedNodes []byte
+	OutputFiles []byte
+}
+
+const (
+	PhaseUnsubmitted      = "Unsubmitted"
+	PhaseSubmissionFailed = "SubmissionFailed"
+	PhasePending          = "Pending"
+	PhaseCancelled        = "Cancelled"
+)
+
 func GetSequentialNumberForRecord(
 	ctx context.Context,
 	logger *zap.Logger,
@@ -49,26 +109,32 @@ func CreateWorkflowForRecord(
 ) (*ExistingWorfklowEntity, error) {
 	logger.Debug("Creating workflow", zap.String("workflow-name", workflow.WorkflowName))
 	SQL := `
-  INSERT INTO compchem_workflow(record_id, workflow_name, workflow_record_seq_id)
-  VALUES ($1, $2, $3)
-  RETURNING id;
+  INSERT INTO compchem_workflow(
+    record_id, workflow_name, workflow_record_seq_id, secret_key_hash, namespace, parameters,
+    inputs, config_hash
+  )
+  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+  RETURNING *;
   `
 
-	var id uint64
-	err := tx.QueryRow(ctx, SQL, workflow.RecordId, workflow.WorkflowName, workflow.WorkflowSeqId).
-		Scan(&id)
+	created, err := repository_common.QueryOneTx[ExistingWorfklowEntity](
+		ctx,
+		tx,
+		SQL,
+		workflow.RecordId,
+		workflow.WorkflowName,
+		workflow.WorkflowSeqId,
+		workflow.SecretKeyHash,
+		workflow.Namespace,
+		workflow.Parameters,
+		workflow.Inputs,
+		workflow.ConfigHash,
+	)
 	if err != nil {
 		return nil, fmt.Errorf("Error during creation of workflow: %v", err)
 	}
 
-	return &ExistingWorfklowEntity{
-		Id: id,
-		WorkflowEntity: WorkflowEntity{
-			RecordId:      workflow.RecordId,
-			WorkflowName:  workflow.WorkflowName,
-			WorkflowSeqId: workflow.WorkflowSeqId,
-		},
-	}, nil
+	return created, nil
 }
 
 func GetWorkflowsForRecord(
@@ -89,3 +155,322 @@ func GetWorkflowsForRecord(
 
 	return workflows, nil
 }
+
+func FindWorkflowByFullName(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	fullName string,
+) (*ExistingWorfklowEntity, error) {
+	logger.Debug("Query workflow by full name", zap.String("workflowName", fullName))
+	SQL := `
+  SELECT * FROM compchem_workflow WHERE workflow_full_name = $1;
+  `
+
+	workflow, err := repository_common.QueryOneTx[ExistingWorfklowEntity](ctx, tx, SQL, fullName)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Error when retrieving workflow by name: %v", err)
+	}
+
+	return workflow, nil
+}
+
+// Returns a page of workflows of the record which were handed over to argo, newest first.
+func FindSubmittedWorkflowsForRecord(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	recordId string,
+	phases []string,
+	limit int,
+	skip int,
+) ([]ExistingWorfklowEntity, error) {
+	logger.Debug(
+		"Query submitted workflows for record",
+		zap.String("recordId", recordId),
+		zap.Strings("phases", phases),
+		zap.Int("limit", limit),
+		zap.Int("skip", skip),
+	)
+	SQL := `
+  SELECT * FROM compchem_workflow
+  WHERE record_id = $1
+    AND submitted_at IS NOT NULL
+    AND (cardinality($2::text[]) = 0 OR phase = ANY($2))
+  ORDER BY workflow_record_seq_id DESC
+  LIMIT $3 OFFSET $4;
+  `
+
+	if phases == nil {
+		phases = []string{}
+	}
+
+	workflows, err := repository_common.QueryManyTx[ExistingWorfklowEntity](
+		ctx,
+		tx,
+		SQL,
+		recordId,
+		phases,
+		limit,
+		skip,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Error when retrieving submitted workflows for record: %v", err)
+	}
+
+	return workflows, nil
+}
+
+func MarkWorkflowSubmitted(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	id uint64,
+	argoUid *string,
+) error {
+	logger.Debug("Marking workflow as submitted", zap.Uint64("id", id))
+	SQL := `
+  UPDATE compchem_workflow
+  SET phase = CASE WHEN phase = 'Unsubmitted' THEN 'Pending' ELSE phase END,
+      argo_uid = COALESCE($2, argo_uid),
+      message = NULL,
+      submitted_at = now()
+  WHERE id = $1;
+  `
+
+	_, err := tx.Exec(ctx, SQL, id, argoUid)
+	if err != nil {
+		return fmt.Errorf("Error when marking workflow as submitted: %v", err)
+	}
+
+	return nil
+}
+
+func MarkWorkflowSubmissionFailed(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	id uint64,
+	message string,
+) error {
+	logger.Debug("Marking workflow submission as failed", zap.Uint64("id", id))
+	SQL := `
+  UPDATE compchem_workflow
+  SET phase = 'SubmissionFailed', message = $2
+  WHERE id = $1;
+  `
+
+	_, err := tx.Exec(ctx, SQL, id, message)
+	if err != nil {
+		return fmt.Errorf("Error when marking workflow submission as failed: %v", err)
+	}
+
+	return nil
+}
+
+// Stores the state reported by argo, returns false when the workflow is not ours.
+func UpdateWorkflowState(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	fullName string,
+	state WorkflowStateUpdate,
+) (bool, error) {
+	logger.Debug(
+		"Updating workflow state",
+		zap.String("workflowName", fullName),
+		zap.String("phase", state.Phase),
+	)
+	SQL := `
+  UPDATE compchem_workflow
+  SET phase = $2,
+      argo_uid = COALESCE($3, argo_uid),
+      message = $4,
+      progress = $5,
+      started_at = $6,
+      finished_at = $7,
+      submitted_at = COALESCE(submitted_at, $6, now())
+  WHERE workflow_full_name = $1;
+  `
+
+	tag, err := tx.Exec(
+		ctx,
+		SQL,
+		fullName,
+		state.Phase,
+		state.ArgoUid,
+		state.Message,
+		state.Progress,
+		state.StartedAt,
+		state.FinishedAt,
+	)
+	if err != nil {
+		return false, fmt.Errorf("Error when updating workflow state: %v", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// Stores the outcome reported by the exit handler, returns false when the workflow is not ours.
+func RecordWorkflowOutcome(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	fullName string,
+	outcome WorkflowOutcome,
+) (bool, error) {
+	logger.Debug(
+		"Recording workflow outcome",
+		zap.String("workflowName", fullName),
+		zap.String("phase", outcome.Phase),
+	)
+	SQL := `
+  UPDATE compchem_workflow
+  SET phase = $2,
+      message = COALESCE($3, message),
+      failed_nodes = $4,
+      output_files = $5,
+      finished_at = COALESCE(finished_at, now()),
+      outcome_reported_at = now()
+  WHERE workflow_full_name = $1;
+  `
+
+	tag, err := tx.Exec(
+		ctx,
+		SQL,
+		fullName,
+		outcome.Phase,
+		outcome.Message,
+		outcome.FailedNodes,
+		outcome.OutputFiles,
+	)
+	if err != nil {
+		return false, fmt.Errorf("Error when recording workflow outcome: %v", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// Records that the workflow was stopped or terminated, phase is kept when not provided.
+func RecordCancellation(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	id uint64,
+	action string,
+	phase *string,
+) error {
+	logger.Debug(
+		"Recording workflow cancellation",
+		zap.Uint64("id", id),
+		zap.String("action", action),
+	)
+	SQL := `
+  UPDATE compchem_workflow
+  SET cancel_action = $2, cancelled_at = now(), phase = COALESCE($3, phase)
+  WHERE id = $1;
+  `
+
+	_, err := tx.Exec(ctx, SQL, id, action, phase)
+	if err != nil {
+		return fmt.Errorf("Error when recording workflow cancellation: %v", err)
+	}
+
+	return nil
+}
+
+// Links a workflow resubmitted as a new run to the workflow it retries.
+func LinkRetriedWorkflow(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	id uint64,
+	retriedFrom uint64,
+) error {
+	logger.Debug(
+		"Linking retried workflow",
+		zap.Uint64("id", id),
+		zap.Uint64("retriedFrom", retriedFrom),
+	)
+	SQL := `
+  UPDATE compchem_workflow
+  SET retried_from = $2
+  WHERE id = $1;
+  `
+
+	_, err := tx.Exec(ctx, SQL, id, retriedFrom)
+	if err != nil {
+		return fmt.Errorf("Error when linking retried workflow: %v", err)
+	}
+
+	return nil
+}
+
+// Returns the run created by resubmitting the workflow, nil when it was not resubmitted yet.
+func FindRetryOfWorkflow(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	id uint64,
+) (*ExistingWorfklowEntity, error) {
+	logger.Debug("Query retry of workflow", zap.Uint64("id", id))
+	SQL := `
+  SELECT * FROM compchem_workflow WHERE retried_from = $1;
+  `
+
+	workflow, err := repository_common.QueryOneTx[ExistingWorfklowEntity](ctx, tx, SQL, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Error when retrieving retry of workflow: %v", err)
+	}
+
+	return workflow, nil
+}
+
+// Records a retry of failed nodes done by argo, the workflow keeps running under the same name.
+func RecordRetry(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	id uint64,
+) error {
+	logger.Debug("Recording workflow retry", zap.Uint64("id", id))
+	SQL := `
+  UPDATE compchem_workflow
+  SET retry_count = retry_count + 1, retried_at = now()
+  WHERE id = $1;
+  `
+
+	_, err := tx.Exec(ctx, SQL, id)
+	if err != nil {
+		return fmt.Errorf("Error when recording workflow retry: %v", err)
+	}
+
+	return nil
+}
+
+// Replaces the hash of the secret key, a workflow retried by argo gets a fresh token.
+func UpdateSecretKeyHash(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	id uint64,
+	secretKeyHash string,
+) error {
+	logger.Debug("Updating secret key of workflow", zap.Uint64("id", id))
+	SQL := `
+  UPDATE compchem_workflow
+  SET secret_key_hash = $2
+  WHERE id = $1;
+  `
+
+	_, err := tx.Exec(ctx, SQL, id, secretKeyHash)
+	if err != nil {
+		return fmt.Errorf("Error when updating secret key of workflow: %v", err)
+	}
+
+	return nil
+}
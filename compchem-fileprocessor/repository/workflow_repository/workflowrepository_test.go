@@ -2,6 +2,7 @@ package workflow_repository
 
 import (
 	"testing"
+	"time"
 
 	repository_common "fi.muni.cz/invenio-file-processor/v2/repository/common"
 	repositorytest "fi.muni.cz/invenio-file-processor/v2/repository/test"
@@ -89,6 +90,8 @@ func (s *workflowRepositoryTestSuite) TestCreateWorkflow_NothingViolated_Creates
 		assert.Equal(t, recordId, wf.RecordId)
 		assert.Equal(t, workflowName, wf.WorkflowName)
 		assert.Equal(t, workflowSeq, wf.WorkflowSeqId)
+		assert.Equal(t, "summarize-document-ej281-k87lh-1", wf.FullName)
+		assert.Equal(t, PhaseUnsubmitted, wf.Phase)
 	})
 }
 
@@ -117,6 +120,188 @@ func (s *workflowRepositoryTestSuite) TestCreateWorkflow_SameSeqId_ReturnsErrNot
 	})
 }
 
+func (s *workflowRepositoryTestSuite) TestUpdateWorkflowState_SubmittedWorkflow_StateStored() {
+	ctx := s.Ctx
+	logger := s.Logger
+	t := s.T()
+
+	s.PostgresTestSuite.RunInTestTransaction(func(tx pgx.Tx) {
+		wf, err := CreateWorkflowForRecord(ctx, logger, tx, WorkflowEntity{
+			WorkflowName:  "count-words",
+			WorkflowSeqId: 2,
+			RecordId:      "ej281-k87lh",
+		})
+		assert.NoError(t, err)
+
+		uid := "9c4b5c1e"
+		assert.NoError(t, MarkWorkflowSubmitted(ctx, logger, tx, wf.Id, &uid))
+
+		startedAt := time.Date(2025, 5, 24, 15, 15, 41, 0, time.UTC)
+		progress := "1/3"
+		updated, err := UpdateWorkflowState(ctx, logger, tx, wf.FullName, WorkflowStateUpdate{
+			Phase:     "Running",
+			Progress:  &progress,
+			StartedAt: &startedAt,
+		})
+		assert.NoError(t, err)
+		assert.True(t, updated)
+
+		stored, err := FindWorkflowByFullName(ctx, logger, tx, "count-words-ej281-k87lh-2")
+		assert.NoError(t, err)
+		assert.Equal(t, "Running", stored.Phase)
+		assert.Equal(t, uid, *stored.ArgoUid, "uid should be kept when argo does not send it")
+		assert.Equal(t, progress, *stored.Progress)
+		assert.True(t, startedAt.Equal(*stored.StartedAt))
+		assert.NotNil(t, stored.SubmittedAt)
+		assert.Nil(t, stored.FinishedAt)
+	})
+}
+
+func (s *workflowRepositoryTestSuite) TestUpdateWorkflowState_UnknownWorkflow_NothingUpdated() {
+	s.PostgresTestSuite.RunInTestTransaction(func(tx pgx.Tx) {
+		updated, err := UpdateWorkflowState(
+			s.Ctx,
+			s.Logger,
+			tx,
+			"hello-world-x7k2p",
+			WorkflowStateUpdate{Phase: "Succeeded"},
+		)
+		assert.NoError(s.T(), err)
+		assert.False(s.T(), updated)
+	})
+}
+
+func (s *workflowRepositoryTestSuite) TestFindWorkflowByFullName_NoWorkflow_ReturnsNilAndNoError() {
+	s.PostgresTestSuite.RunInTestTransaction(func(tx pgx.Tx) {
+		wf, err := FindWorkflowByFullName(s.Ctx, s.Logger, tx, "count-words-ej281-k87lh-1")
+		assert.NoError(s.T(), err)
+		assert.Nil(s.T(), wf)
+	})
+}
+
+func (s *workflowRepositoryTestSuite) TestLinkRetriedWorkflow_Resubmitted_RetryFound() {
+	ctx := s.Ctx
+	logger := s.Logger
+	t := s.T()
+
+	s.PostgresTestSuite.RunInTestTransaction(func(tx pgx.Tx) {
+		original, err := CreateWorkflowForRecord(ctx, logger, tx, WorkflowEntity{
+			WorkflowName:  "count-words",
+			WorkflowSeqId: 1,
+			RecordId:      "ej281-k87lh",
+		})
+		assert.NoError(t, err)
+		retry, err := CreateWorkflowForRecord(ctx, logger, tx, WorkflowEntity{
+			WorkflowName:  "count-words",
+			WorkflowSeqId: 2,
+			RecordId:      "ej281-k87lh",
+		})
+		assert.NoError(t, err)
+
+		found, err := FindRetryOfWorkflow(ctx, logger, tx, original.Id)
+		assert.NoError(t, err)
+		assert.Nil(t, found)
+
+		assert.NoError(t, LinkRetriedWorkflow(ctx, logger, tx, retry.Id, original.Id))
+
+		found, err = FindRetryOfWorkflow(ctx, logger, tx, original.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, "count-words-ej281-k87lh-2", found.FullName)
+		assert.Equal(t, original.Id, *found.RetriedFrom)
+	})
+}
+
+func (s *workflowRepositoryTestSuite) TestRecordWorkflowOutcome_OutputsReported_OutcomeStored() {
+	ctx := s.Ctx
+	logger := s.Logger
+	t := s.T()
+
+	s.PostgresTestSuite.RunInTestTransaction(func(tx pgx.Tx) {
+		hash := "4f2a"
+		_, err := CreateWorkflowForRecord(ctx, logger, tx, WorkflowEntity{
+			WorkflowName:  "count-words",
+			WorkflowSeqId: 1,
+			RecordId:      "ej281-k87lh",
+			SecretKeyHash: &hash,
+		})
+		assert.NoError(t, err)
+
+		recorded, err := RecordWorkflowOutcome(
+			ctx,
+			logger,
+			tx,
+			"count-words-ej281-k87lh-1",
+			WorkflowOutcome{
+				Phase:       "Succeeded",
+				OutputFiles: []byte(`["count-words-ej281-k87lh-1-count-words-result.txt"]`),
+			},
+		)
+		assert.NoError(t, err)
+		assert.True(t, recorded)
+
+		found, err := FindWorkflowByFullName(ctx, logger, tx, "count-words-ej281-k87lh-1")
+		assert.NoError(t, err)
+		assert.Equal(t, "Succeeded", found.Phase)
+		assert.Equal(t, hash, *found.SecretKeyHash)
+		assert.JSONEq(
+			t,
+			`["count-words-ej281-k87lh-1-count-words-result.txt"]`,
+			string(found.OutputFiles),
+		)
+		assert.NotNil(t, found.FinishedAt)
+		assert.NotNil(t, found.OutcomeReportedAt)
+	})
+}
+
+func (s *workflowRepositoryTestSuite) TestCreateWorkflowForRecord_NamespaceSet_NamespaceStored() {
+	ctx := s.Ctx
+	logger := s.Logger
+	t := s.T()
+
+	s.PostgresTestSuite.RunInTestTransaction(func(tx pgx.Tx) {
+		namespace := "compchem-gpu"
+		created, err := CreateWorkflowForRecord(ctx, logger, tx, WorkflowEntity{
+			WorkflowName:  "count-words",
+			WorkflowSeqId: 1,
+			RecordId:      "ej281-k87lh",
+			Namespace:     &namespace,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "compchem-gpu", created.ArgoNamespace("argo"))
+	})
+}
+
+func (s *workflowRepositoryTestSuite) TestUpdateSecretKeyHash_KeyReplaced_NewHashStored() {
+	ctx := s.Ctx
+	logger := s.Logger
+	t := s.T()
+
+	s.PostgresTestSuite.RunInTestTransaction(func(tx pgx.Tx) {
+		oldHash := "old-hash"
+		created, err := CreateWorkflowForRecord(ctx, logger, tx, WorkflowEntity{
+			WorkflowName:  "count-words",
+			WorkflowSeqId: 1,
+			RecordId:      "ej281-k87lh",
+			SecretKeyHash: &oldHash,
+		})
+		assert.NoError(t, err)
+
+		err = UpdateSecretKeyHash(ctx, logger, tx, created.Id, "new-hash")
+		assert.NoError(t, err)
+
+		found, err := FindWorkflowByFullName(ctx, logger, tx, created.FullName)
+		assert.NoError(t, err)
+		assert.Equal(t, "new-hash", *found.SecretKeyHash)
+	})
+}
+
+func TestArgoNamespace_NamespaceNotStored_DefaultReturned(t *testing.T) {
+	empty := ""
+
+	assert.Equal(t, "argo", (&WorkflowEntity{}).ArgoNamespace("argo"))
+	assert.Equal(t, "argo", (&WorkflowEntity{Namespace: &empty}).ArgoNamespace("argo"))
+}
+
 func TestWorkflowRepositorySuite(t *testing.T) {
 	suite.Run(t, new(workflowRepositoryTestSuite))
 }
@@ -0,0 +1,294 @@
+package audit_route
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"fi.muni.cz/invenio-file-processor/v2/jsonapi"
+	"fi.muni.cz/invenio-file-processor/v2/routes/common"
+	auditlog_service "fi.muni.cz/invenio-file-processor/v2/services/audit_log"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+const (
+	formatJson   = "json"
+	formatCsv    = "csv"
+	formatNdjson = "ndjson"
+
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+var csvHeader = []string{
+	"id",
+	"createdAt",
+	"event",
+	"subject",
+	"action",
+	"outcome",
+	"reason",
+	"recordId",
+	"workflowName",
+	"status",
+	"allowed",
+	"source",
+	"cached",
+	"requestId",
+	"sourceIp",
+	"method",
+	"path",
+}
+
+type auditParams struct {
+	filter auditlog_service.AuditFilter
+	format string
+	limit  int
+	skip   int
+}
+
+// Lists audit entries matching the query filters as a json page, or exports all of them as csv or
+// ndjson when asked for by the format parameter or the Accept header.
+func AuditLogHandler(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params, err := getAuditParams(r)
+		if err != nil {
+			jsonapi.Encode(w, r, http.StatusBadRequest, common.ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		switch params.format {
+		case formatCsv:
+			err = exportCsv(ctx, logger, pool, w, params.filter)
+		case formatNdjson:
+			err = exportNdjson(ctx, logger, pool, w, params.filter)
+		default:
+			var entries []auditlog_service.AuditEntry
+			entries, err = auditlog_service.ListAuditEntries(
+				ctx,
+				logger,
+				pool,
+				params.filter,
+				params.limit,
+				params.skip,
+			)
+			if err != nil {
+				jsonapi.Encode(w, r, http.StatusInternalServerError, common.ErrorResponse{
+					Message: fmt.Errorf("Failed to list audit entries: %v", err).Error(),
+				})
+				return
+			}
+			common.EncodeResponse(w, r, http.StatusOK, entries)
+		}
+
+		// exports are streamed, the status was sent with the first entry already
+		if err != nil {
+			logger.Error(
+				"Failed to export audit entries",
+				zap.String("format", params.format),
+				zap.Error(err),
+			)
+		}
+	})
+}
+
+func exportCsv(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	w http.ResponseWriter,
+	filter auditlog_service.AuditFilter,
+) error {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit-log.csv"`)
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+
+	err := auditlog_service.ExportAuditEntries(
+		ctx,
+		logger,
+		pool,
+		filter,
+		func(entry auditlog_service.AuditEntry) error {
+			return writer.Write(csvRow(entry))
+		},
+	)
+	writer.Flush()
+	if err != nil {
+		return err
+	}
+
+	return writer.Error()
+}
+
+func exportNdjson(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	w http.ResponseWriter,
+	filter auditlog_service.AuditFilter,
+) error {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit-log.ndjson"`)
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+	return auditlog_service.ExportAuditEntries(
+		ctx,
+		logger,
+		pool,
+		filter,
+		func(entry auditlog_service.AuditEntry) error {
+			return encoder.Encode(entry)
+		},
+	)
+}
+
+func csvRow(entry auditlog_service.AuditEntry) []string {
+	row := []string{
+		strconv.FormatInt(entry.Id, 10),
+		entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+		entry.Event,
+		entry.Subject,
+		entry.Action,
+		entry.Outcome,
+		entry.Reason,
+		stringOrEmpty(entry.RecordId),
+		stringOrEmpty(entry.WorkflowName),
+		"",
+		"",
+		stringOrEmpty(entry.Source),
+		"",
+		stringOrEmpty(entry.RequestId),
+		stringOrEmpty(entry.SourceIp),
+		entry.Method,
+		entry.Path,
+	}
+	if entry.Status != nil {
+		row[9] = strconv.Itoa(*entry.Status)
+	}
+	if entry.Allowed != nil {
+		row[10] = strconv.FormatBool(*entry.Allowed)
+	}
+	if entry.Cached != nil {
+		row[12] = strconv.FormatBool(*entry.Cached)
+	}
+
+	for i, value := range row {
+		row[i] = escapeFormula(value)
+	}
+	return row
+}
+
+// Spreadsheets evaluate cells starting with these characters, request paths and subjects come from
+// clients.
+func escapeFormula(value string) string {
+	if value != "" && strings.ContainsAny(value[:1], "=+-@\t\r") {
+		return "'" + value
+	}
+	return value
+}
+
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func getAuditParams(r *http.Request) (*auditParams, error) {
+	query := r.URL.Query()
+	params := &auditParams{
+		filter: auditlog_service.AuditFilter{
+			Event:        query.Get("event"),
+			Subject:      query.Get("subject"),
+			RecordId:     query.Get("recordId"),
+			WorkflowName: query.Get("workflowName"),
+			Action:       query.Get("action"),
+			Outcome:      query.Get("outcome"),
+		},
+		format: exportFormat(r),
+	}
+
+	var err error
+	if params.filter.From, err = getTime(query.Get("from")); err != nil {
+		return nil, fmt.Errorf("Invalid from, expected RFC 3339 time: %v", err)
+	}
+	if params.filter.To, err = getTime(query.Get("to")); err != nil {
+		return nil, fmt.Errorf("Invalid to, expected RFC 3339 time: %v", err)
+	}
+
+	if params.limit, err = getNum(query.Get("limit"), defaultLimit); err != nil ||
+		params.limit < 1 || params.limit > maxLimit {
+		return nil, fmt.Errorf("Invalid limit, expected a number from 1 to %d", maxLimit)
+	}
+	if params.skip, err = getNum(query.Get("skip"), 0); err != nil || params.skip < 0 {
+		return nil, errors.New("Invalid skip, expected zero or a positive number")
+	}
+
+	switch params.format {
+	case formatJson, formatCsv, formatNdjson:
+	default:
+		return nil, fmt.Errorf(
+			"Unknown format %s, expected %s, %s or %s",
+			params.format,
+			formatJson,
+			formatCsv,
+			formatNdjson,
+		)
+	}
+
+	return params, nil
+}
+
+// The format parameter wins over the Accept header.
+func exportFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format
+	}
+
+	accept := r.Header.Get("Accept")
+	if strings.Contains(accept, "text/csv") {
+		return formatCsv
+	} else if strings.Contains(accept, "application/x-ndjson") {
+		return formatNdjson
+	}
+	return formatJson
+}
+
+func getTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+func getNum(num string, defaultVal int) (int, error) {
+	if num == "" {
+		return defaultVal, nil
+	}
+
+	return strconv.Atoi(num)
+}
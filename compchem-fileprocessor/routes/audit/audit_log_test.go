@@ -0,0 +1,82 @@
+package audit_route
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	auditlog_service "fi.muni.cz/invenio-file-processor/v2/services/audit_log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAuditParams_Query_FilterAndFormatParsed(t *testing.T) {
+	// Arrange
+	r := httptest.NewRequest(
+		http.MethodGet,
+		"/admin/audit?subject=oidc:user-1&workflowName=count-words-1&from=2026-01-01T00:00:00Z&limit=5",
+		nil,
+	)
+	r.Header.Set("Accept", "text/csv")
+
+	// Act
+	params, err := getAuditParams(r)
+
+	// Assert
+	assert.NoError(t, err)
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, auditlog_service.AuditFilter{
+		Subject:      "oidc:user-1",
+		WorkflowName: "count-words-1",
+		From:         &from,
+	}, params.filter)
+	assert.Equal(t, formatCsv, params.format)
+	assert.Equal(t, 5, params.limit)
+}
+
+func TestGetAuditParams_InvalidQuery_Rejected(t *testing.T) {
+	queries := []string{"from=yesterday", "limit=0", "limit=5000", "skip=-1", "format=xml"}
+	for _, query := range queries {
+		_, err := getAuditParams(httptest.NewRequest(http.MethodGet, "/admin/audit?"+query, nil))
+		assert.Error(t, err, query)
+	}
+}
+
+func TestCsvRow_ClientValues_FormulasEscaped(t *testing.T) {
+	status := 201
+	workflow := "count-words-1"
+	row := csvRow(auditlog_service.AuditEntry{
+		Id:           7,
+		CreatedAt:    time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC),
+		Event:        "action",
+		Subject:      "api-key:compchem",
+		Action:       "start",
+		Outcome:      "succeeded",
+		Reason:       "Created",
+		WorkflowName: &workflow,
+		Status:       &status,
+		Method:       "POST",
+		Path:         "=HYPERLINK(\"http://example.org\")",
+	})
+
+	assert.Equal(t, []string{
+		"7",
+		"2026-01-01T12:00:00Z",
+		"action",
+		"api-key:compchem",
+		"start",
+		"succeeded",
+		"Created",
+		"",
+		"count-words-1",
+		"201",
+		"",
+		"",
+		"",
+		"",
+		"",
+		"POST",
+		"'=HYPERLINK(\"http://example.org\")",
+	}, row)
+	assert.Len(t, row, len(csvHeader))
+}
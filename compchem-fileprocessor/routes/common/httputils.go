@@ -11,6 +11,12 @@ type ErrorResponse struct {
 	Message string `json:"message"`
 }
 
+// Field level errors of a request, keyed by the offending field.
+type ValidationErrorResponse struct {
+	Message string            `json:"message"`
+	Errors  map[string]string `json:"errors"`
+}
+
 func GetValidRequestBody[T any](
 	w http.ResponseWriter,
 	r *http.Request,
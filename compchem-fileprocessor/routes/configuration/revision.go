@@ -0,0 +1,38 @@
+package configuration
+
+import (
+	"net/http"
+	"time"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/routes/common"
+	"fi.muni.cz/invenio-file-processor/v2/util"
+)
+
+// Revision is a hash of the config file the active workflows were loaded from, managed workflows
+// come from the admin api.
+type revisionResponse struct {
+	Revision  string    `json:"revision"`
+	LoadedAt  time.Time `json:"loadedAt"`
+	Workflows []string  `json:"workflows"`
+	Managed   []string  `json:"managed"`
+}
+
+func workflowNames(workflows []config.WorkflowConfig) []string {
+	return util.Map(workflows, func(workflow config.WorkflowConfig) string {
+		return workflow.Name
+	})
+}
+
+func RevisionHandler(workflows *config.Workflows) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		active := workflows.Active()
+
+		common.EncodeResponse(w, r, http.StatusOK, revisionResponse{
+			Revision:  active.Revision,
+			LoadedAt:  active.LoadedAt,
+			Workflows: workflowNames(active.Workflows),
+			Managed:   workflowNames(active.Managed),
+		})
+	})
+}
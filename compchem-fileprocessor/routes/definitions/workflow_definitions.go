@@ -0,0 +1,198 @@
+package definitions_route
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"fi.muni.cz/invenio-file-processor/v2/audit"
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/jsonapi"
+	"fi.muni.cz/invenio-file-processor/v2/routes/common"
+	workflowdefinitions_service "fi.muni.cz/invenio-file-processor/v2/services/workflow_definitions"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+func ListDefinitionsHandler(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		definitions, err := workflowdefinitions_service.ListDefinitions(ctx, logger, pool)
+		if err != nil {
+			logger.Error("Failed to list workflow definitions", zap.Error(err))
+			handleError(w, r, err)
+			return
+		}
+
+		common.EncodeResponse(w, r, http.StatusOK, definitions)
+	})
+}
+
+func DefinitionVersionsHandler(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		versions, err := workflowdefinitions_service.ListDefinitionVersions(
+			ctx,
+			logger,
+			pool,
+			r.PathValue("name"),
+		)
+		if err != nil {
+			logger.Error("Failed to list workflow definition versions", zap.Error(err))
+			handleError(w, r, err)
+			return
+		}
+
+		common.EncodeResponse(w, r, http.StatusOK, versions)
+	})
+}
+
+func CreateDefinitionHandler(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	argo config.ArgoApi,
+	workflows *config.Workflows,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		workflow, err := decodeWorkflow(w, r)
+		if err != nil {
+			logger.Error("Failed to decode workflow definition", zap.Error(err))
+			return
+		}
+		audit.NoteWorkflows(r.Context(), workflow.Name)
+
+		definition, err := workflowdefinitions_service.CreateDefinition(
+			ctx,
+			logger,
+			pool,
+			argo,
+			workflows,
+			*workflow,
+		)
+		if err != nil {
+			logger.Error("Failed to create workflow definition", zap.Error(err))
+			handleError(w, r, err)
+			return
+		}
+
+		common.EncodeResponse(w, r, http.StatusCreated, definition)
+	})
+}
+
+func UpdateDefinitionHandler(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	argo config.ArgoApi,
+	workflows *config.Workflows,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		workflow, err := decodeWorkflow(w, r)
+		if err != nil {
+			logger.Error("Failed to decode workflow definition", zap.Error(err))
+			return
+		}
+
+		definition, err := workflowdefinitions_service.UpdateDefinition(
+			ctx,
+			logger,
+			pool,
+			argo,
+			workflows,
+			r.PathValue("name"),
+			*workflow,
+		)
+		if err != nil {
+			logger.Error("Failed to update workflow definition", zap.Error(err))
+			handleError(w, r, err)
+			return
+		}
+
+		common.EncodeResponse(w, r, http.StatusOK, definition)
+	})
+}
+
+func SetDefinitionEnabledHandler(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	argo config.ArgoApi,
+	workflows *config.Workflows,
+	enabled bool,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		definition, err := workflowdefinitions_service.SetDefinitionEnabled(
+			ctx,
+			logger,
+			pool,
+			argo,
+			workflows,
+			r.PathValue("name"),
+			enabled,
+		)
+		if err != nil {
+			logger.Error(
+				"Failed to change workflow definition",
+				zap.Bool("enabled", enabled),
+				zap.Error(err),
+			)
+			handleError(w, r, err)
+			return
+		}
+
+		common.EncodeResponse(w, r, http.StatusOK, definition)
+	})
+}
+
+// Bodies use the keys of the config file, so they are decoded with the yaml tags of the config.
+func decodeWorkflow(w http.ResponseWriter, r *http.Request) (*config.WorkflowConfig, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		jsonapi.Encode(w, r, http.StatusBadRequest, common.ErrorResponse{
+			Message: "Failed to read request for processing",
+		})
+		return nil, err
+	}
+
+	workflow, err := workflowdefinitions_service.DecodeWorkflow(body)
+	if err != nil {
+		jsonapi.Encode(w, r, http.StatusBadRequest, common.ErrorResponse{
+			Message: "Invalid request body, " + err.Error(),
+		})
+		return nil, err
+	}
+
+	return &workflow, nil
+}
+
+func handleError(w http.ResponseWriter, r *http.Request, err error) {
+	var definitionErr *workflowdefinitions_service.InvalidDefinitionError
+	if errors.As(err, &definitionErr) {
+		jsonapi.Encode(w, r, http.StatusBadRequest, common.ValidationErrorResponse{
+			Message: "Invalid workflow definition",
+			Errors:  definitionErr.Errors,
+		})
+	} else if errors.Is(err, workflowdefinitions_service.ErrDefinitionNotFound) {
+		jsonapi.Encode(w, r, http.StatusNotFound, common.ErrorResponse{
+			Message: err.Error(),
+		})
+	} else if errors.Is(err, workflowdefinitions_service.ErrDefinitionExists) ||
+		errors.Is(err, workflowdefinitions_service.ErrDefinitionReadOnly) {
+		jsonapi.Encode(w, r, http.StatusConflict, common.ErrorResponse{
+			Message: err.Error(),
+		})
+	} else {
+		jsonapi.Encode(w, r, http.StatusInternalServerError, common.ErrorResponse{
+			Message: fmt.Errorf("Something went wrong when processing request: %v", err).Error(),
+		})
+	}
+}
@@ -1,9 +1,23 @@
 package routes
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
 	"net/http"
+	"regexp"
+	"strings"
 	"time"
 
+	"fi.muni.cz/invenio-file-processor/v2/audit"
+	"fi.muni.cz/invenio-file-processor/v2/auth"
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/jsonapi"
+	"fi.muni.cz/invenio-file-processor/v2/routes/common"
+	auditlog_service "fi.muni.cz/invenio-file-processor/v2/services/audit_log"
+	authorizerecord_service "fi.muni.cz/invenio-file-processor/v2/services/authorize_record"
 	"go.uber.org/zap"
 )
 
@@ -30,6 +44,7 @@ func loggingMiddleware(logger *zap.Logger, h http.Handler) http.Handler {
 		h.ServeHTTP(ww, r)
 
 		logger.Info("HTTP request",
+			zap.String("requestId", audit.RequestId(r.Context())),
 			zap.String("method", r.Method),
 			zap.String("path", r.URL.Path),
 			zap.Int("status", ww.status),
@@ -37,3 +52,230 @@ func loggingMiddleware(logger *zap.Logger, h http.Handler) http.Handler {
 		)
 	})
 }
+
+const RequestIdHeader = "X-Request-Id"
+
+// Request ids of clients are kept when they are short and safe to log.
+var requestIdPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Tags the request with its request id, which is echoed in the response, and the ip of the
+// client. The X-Forwarded-For header is only trusted when the service runs behind a proxy setting
+// it, otherwise clients could forge their address.
+func requestContextMiddleware(trustForwardedFor bool, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId := r.Header.Get(RequestIdHeader)
+		if !requestIdPattern.MatchString(requestId) {
+			requestId = newRequestId()
+		}
+		w.Header().Set(RequestIdHeader, requestId)
+
+		ctx := audit.WithRequestId(r.Context(), requestId)
+		ctx = audit.WithSourceIp(ctx, sourceIp(r, trustForwardedFor))
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func newRequestId() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// The proxy appends the address it received the request from, so the last entry is the one it
+// vouches for.
+func sourceIp(r *http.Request, trustForwardedFor bool) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); trustForwardedFor && forwarded != "" {
+		addresses := strings.Split(forwarded, ",")
+		return strings.TrimSpace(addresses[len(addresses)-1])
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Requests without valid credentials are answered with 401 and requests lacking the scope with 403,
+// without authenticators every request passes.
+func authMiddleware(
+	logger *zap.Logger,
+	authenticators []auth.Authenticator,
+	scope config.Scope,
+	h http.Handler,
+) http.Handler {
+	if len(authenticators) == 0 {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := auth.Authenticate(r, authenticators)
+		if err != nil {
+			if !errors.Is(err, auth.ErrNoCredentials) {
+				logger.Warn(
+					"Request with invalid credentials",
+					zap.String("path", r.URL.Path),
+					zap.Error(err),
+				)
+			}
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			jsonapi.Encode(w, r, http.StatusUnauthorized, common.ErrorResponse{
+				Message: "Missing or invalid credentials",
+			})
+			return
+		}
+
+		if !principal.HasScope(scope) {
+			jsonapi.Encode(w, r, http.StatusForbidden, common.ErrorResponse{
+				Message: "Missing scope " + string(scope),
+			})
+			return
+		}
+
+		audit.NoteSubject(r.Context(), principal.Subject)
+		h.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	})
+}
+
+// Resolves the record a request acts on, empty when the request names no existing record.
+type recordResolver func(r *http.Request) (string, error)
+
+type recordAuthorizer func(
+	r *http.Request,
+	principal *auth.Principal,
+	request authorizerecord_service.RecordRequest,
+) (auth.Decision, error)
+
+func recordFromPath(r *http.Request) (string, error) {
+	return r.PathValue("recordId"), nil
+}
+
+// Requests the authorizer denies are answered with 403 and requests it could not decide with 503,
+// requests naming unknown workflows pass so that the handler answers them.
+func authorizeMiddleware(
+	logger *zap.Logger,
+	authorize recordAuthorizer,
+	action config.RecordAction,
+	recordOf recordResolver,
+	h http.Handler,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal := auth.PrincipalFrom(r.Context())
+		if principal == nil {
+			jsonapi.Encode(w, r, http.StatusForbidden, common.ErrorResponse{
+				Message: "Request is not authenticated",
+			})
+			return
+		}
+
+		recordId, err := recordOf(r)
+		if err != nil {
+			logger.Error("Failed to resolve record of request", zap.Error(err))
+			jsonapi.Encode(w, r, http.StatusInternalServerError, common.ErrorResponse{
+				Message: "Internal server error",
+			})
+			return
+		}
+		if recordId == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		decision, err := authorize(r, principal, authorizerecord_service.RecordRequest{
+			RecordId:  recordId,
+			Action:    action,
+			RequestId: audit.RequestId(r.Context()),
+			SourceIp:  audit.SourceIp(r.Context()),
+			Method:    r.Method,
+			Path:      r.URL.Path,
+		})
+		if err != nil {
+			jsonapi.Encode(w, r, http.StatusServiceUnavailable, common.ErrorResponse{
+				Message: "Record authorization unavailable",
+			})
+			return
+		}
+
+		if !decision.Allowed {
+			logger.Info(
+				"Record access denied",
+				zap.String("subject", principal.Subject),
+				zap.String("recordId", recordId),
+				zap.String("action", string(action)),
+				zap.String("reason", decision.Reason),
+			)
+			jsonapi.Encode(w, r, http.StatusForbidden, common.ErrorResponse{
+				Message: "Not allowed to " + string(action) + " record " + recordId,
+			})
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+type actionRecorder func(ctx context.Context, action auditlog_service.ApiAction) error
+
+// Writes mutating requests to the audit log once they were answered, including the ones rejected
+// for missing credentials. Workflows noted by the handler are recorded, otherwise the workflow of
+// the path. Reads pass without an entry.
+func auditMiddleware(
+	logger *zap.Logger,
+	record actionRecorder,
+	action string,
+	recordOf recordResolver,
+	h http.Handler,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead ||
+			r.Method == http.MethodOptions {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		trail := audit.NewTrail()
+		ww := newResponseWriter(w)
+		h.ServeHTTP(ww, r.WithContext(audit.WithTrail(r.Context(), trail)))
+
+		// the entry is written even when the client went away meanwhile
+		ctx := context.WithoutCancel(r.Context())
+		workflows := trail.Workflows()
+		if len(workflows) == 0 {
+			for _, name := range []string{r.PathValue("workflowName"), r.PathValue("name")} {
+				if name != "" {
+					workflows = []string{name}
+					break
+				}
+			}
+		}
+
+		recordId := ""
+		if recordOf != nil {
+			var err error
+			recordId, err = recordOf(r.WithContext(ctx))
+			if err != nil {
+				logger.Error("Failed to resolve record of audited request", zap.Error(err))
+			}
+		}
+
+		err := record(ctx, auditlog_service.ApiAction{
+			Subject:   trail.Subject(),
+			Action:    action,
+			RecordId:  recordId,
+			Workflows: workflows,
+			Status:    ww.status,
+			RequestId: audit.RequestId(r.Context()),
+			SourceIp:  audit.SourceIp(r.Context()),
+			Method:    r.Method,
+			Path:      r.URL.Path,
+		})
+		if err != nil {
+			logger.Error(
+				"Failed to write audit entry",
+				zap.String("action", action),
+				zap.String("requestId", audit.RequestId(r.Context())),
+				zap.Error(err),
+			)
+		}
+	})
+}
@@ -0,0 +1,216 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fi.muni.cz/invenio-file-processor/v2/audit"
+	"fi.muni.cz/invenio-file-processor/v2/auth"
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	auditlog_service "fi.muni.cz/invenio-file-processor/v2/services/audit_log"
+	authorizerecord_service "fi.muni.cz/invenio-file-processor/v2/services/authorize_record"
+	"fi.muni.cz/invenio-file-processor/v2/util"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestAuthMiddleware_Credentials_ScopeEnforced(t *testing.T) {
+	authenticators := []auth.Authenticator{auth.NewApiKeyAuthenticator([]config.ApiKeyConfig{
+		{
+			Name:    "compchem",
+			KeyHash: util.HashSecretKey("compchem-key"),
+			Scopes:  []config.Scope{config.ScopeList},
+		},
+	})}
+	var principal *auth.Principal
+	handler := authMiddleware(
+		zap.NewNop(),
+		authenticators,
+		config.ScopeList,
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal = auth.PrincipalFrom(r.Context())
+		}),
+	)
+	admin := authMiddleware(zap.NewNop(), authenticators, config.ScopeAdmin, handler)
+	serve := func(h http.Handler, key string) int {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/workflows/available", nil)
+		if key != "" {
+			r.Header.Set(auth.ApiKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, serve(handler, ""))
+	assert.Equal(t, http.StatusUnauthorized, serve(handler, "other-key"))
+	assert.Equal(t, http.StatusForbidden, serve(admin, "compchem-key"))
+	assert.Nil(t, principal)
+
+	assert.Equal(t, http.StatusOK, serve(handler, "compchem-key"))
+	assert.Equal(t, "api-key:compchem", principal.Subject)
+}
+
+func TestAuthMiddleware_NoAuthenticators_RequestPassed(t *testing.T) {
+	handler := authMiddleware(
+		zap.NewNop(),
+		nil,
+		config.ScopeAdmin,
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}),
+	)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestAuthorizeMiddleware_Decision_RequestAllowedOrDenied(t *testing.T) {
+	decisions := map[string]auth.Decision{
+		"abcd-1234": {Allowed: true, Source: "policy"},
+		"efgh-5678": {Allowed: false, Reason: "no policy rule allows read", Source: "policy"},
+	}
+	var requests []authorizerecord_service.RecordRequest
+	authorize := func(
+		r *http.Request,
+		principal *auth.Principal,
+		request authorizerecord_service.RecordRequest,
+	) (auth.Decision, error) {
+		requests = append(requests, request)
+		decision, present := decisions[request.RecordId]
+		if !present {
+			return auth.Decision{}, errors.New("compchem unreachable")
+		}
+		return decision, nil
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/workflows/{recordId}/list", authorizeMiddleware(
+		zap.NewNop(),
+		authorize,
+		config.ActionRead,
+		recordFromPath,
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}),
+	))
+	serve := func(recordId string) int {
+		r := httptest.NewRequest(http.MethodGet, "/workflows/"+recordId+"/list", nil)
+		r = r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{Subject: "oidc:user-1"}))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusNoContent, serve("abcd-1234"))
+	assert.Equal(t, http.StatusForbidden, serve("efgh-5678"))
+	assert.Equal(t, http.StatusServiceUnavailable, serve("ijkl-9012"))
+	assert.Equal(t, authorizerecord_service.RecordRequest{
+		RecordId: "abcd-1234",
+		Action:   config.ActionRead,
+		Method:   http.MethodGet,
+		Path:     "/workflows/abcd-1234/list",
+	}, requests[0])
+}
+
+func TestAuditMiddleware_MutatingRequest_ActionRecorded(t *testing.T) {
+	authenticators := []auth.Authenticator{auth.NewApiKeyAuthenticator([]config.ApiKeyConfig{
+		{
+			Name:    "compchem",
+			KeyHash: util.HashSecretKey("compchem-key"),
+			Scopes:  []config.Scope{config.ScopeStart},
+		},
+	})}
+	var actions []auditlog_service.ApiAction
+	record := func(ctx context.Context, action auditlog_service.ApiAction) error {
+		actions = append(actions, action)
+		return nil
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/workflows/{recordId}", requestContextMiddleware(false, auditMiddleware(
+		zap.NewNop(),
+		record,
+		"start",
+		recordFromPath,
+		authMiddleware(
+			zap.NewNop(),
+			authenticators,
+			config.ScopeStart,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				audit.NoteWorkflows(r.Context(), "count-words-1", "count-lines-2")
+				w.WriteHeader(http.StatusCreated)
+			}),
+		),
+	)))
+	serve := func(method string, key string) {
+		r := httptest.NewRequest(method, "/workflows/abcd-1234", nil)
+		r.RemoteAddr = "10.0.0.1:41234"
+		r.Header.Set(RequestIdHeader, "req-1")
+		if key != "" {
+			r.Header.Set(auth.ApiKeyHeader, key)
+		}
+		mux.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	serve(http.MethodPost, "compchem-key")
+	serve(http.MethodPost, "")
+	serve(http.MethodGet, "compchem-key")
+
+	assert.Equal(t, []auditlog_service.ApiAction{
+		{
+			Subject:   "api-key:compchem",
+			Action:    "start",
+			RecordId:  "abcd-1234",
+			Workflows: []string{"count-words-1", "count-lines-2"},
+			Status:    http.StatusCreated,
+			RequestId: "req-1",
+			SourceIp:  "10.0.0.1",
+			Method:    http.MethodPost,
+			Path:      "/workflows/abcd-1234",
+		},
+		{
+			Subject:   audit.AnonymousSubject,
+			Action:    "start",
+			RecordId:  "abcd-1234",
+			Status:    http.StatusUnauthorized,
+			RequestId: "req-1",
+			SourceIp:  "10.0.0.1",
+			Method:    http.MethodPost,
+			Path:      "/workflows/abcd-1234",
+		},
+	}, actions)
+}
+
+func TestRequestContextMiddleware_Headers_RequestIdAndSourceIpSet(t *testing.T) {
+	serve := func(trustForwardedFor bool, requestId string) (string, string, string) {
+		var sourceIp, seenId string
+		handler := requestContextMiddleware(
+			trustForwardedFor,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				sourceIp = audit.SourceIp(r.Context())
+				seenId = audit.RequestId(r.Context())
+			}),
+		)
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "10.0.0.1:41234"
+		r.Header.Set("X-Forwarded-For", "192.0.2.1, 198.51.100.7")
+		r.Header.Set(RequestIdHeader, requestId)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return seenId, w.Header().Get(RequestIdHeader), sourceIp
+	}
+
+	requestId, echoed, sourceIp := serve(false, "req-1")
+	assert.Equal(t, "req-1", requestId)
+	assert.Equal(t, "req-1", echoed)
+	assert.Equal(t, "10.0.0.1", sourceIp)
+
+	requestId, echoed, sourceIp = serve(true, "req 1\n")
+	assert.Len(t, requestId, 32)
+	assert.Equal(t, requestId, echoed)
+	assert.Equal(t, "198.51.100.7", sourceIp)
+}
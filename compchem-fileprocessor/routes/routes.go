@@ -4,21 +4,53 @@ import (
 	"context"
 	"net/http"
 
+	"fi.muni.cz/invenio-file-processor/v2/auth"
 	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/kubeclient"
+	audit_route "fi.muni.cz/invenio-file-processor/v2/routes/audit"
+	"fi.muni.cz/invenio-file-processor/v2/routes/configuration"
+	definitions_route "fi.muni.cz/invenio-file-processor/v2/routes/definitions"
 	"fi.muni.cz/invenio-file-processor/v2/routes/health"
+	tokens_route "fi.muni.cz/invenio-file-processor/v2/routes/tokens"
 	active_workflows "fi.muni.cz/invenio-file-processor/v2/routes/workflow/active"
 	"fi.muni.cz/invenio-file-processor/v2/routes/workflow/available"
+	outcome_route "fi.muni.cz/invenio-file-processor/v2/routes/workflow/outcome"
+	retry_workflow_route "fi.muni.cz/invenio-file-processor/v2/routes/workflow/retry"
 	start_workflow_route "fi.muni.cz/invenio-file-processor/v2/routes/workflow/start"
+	stop_workflow_route "fi.muni.cz/invenio-file-processor/v2/routes/workflow/stop"
+	auditlog_service "fi.muni.cz/invenio-file-processor/v2/services/audit_log"
+	authorizerecord_service "fi.muni.cz/invenio-file-processor/v2/services/authorize_record"
+	stopworkflow_service "fi.muni.cz/invenio-file-processor/v2/services/stop_workflow"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/cors"
 	"go.uber.org/zap"
 )
 
+// Scopes and record actions required by the routes, the config parameter of AddRoutes shadows the
+// package.
+type (
+	routeScope  = config.Scope
+	routeAction = config.RecordAction
+)
+
+const (
+	scopeStart    = config.ScopeStart
+	scopeList     = config.ScopeList
+	scopeAdmin    = config.ScopeAdmin
+	actionRead    = config.ActionRead
+	actionProcess = config.ActionProcess
+)
+
 func AddRoutes(
 	ctx context.Context,
 	logger *zap.Logger,
 	mux *http.ServeMux,
 	config *config.Config,
+	workflows *config.Workflows,
+	authenticators []auth.Authenticator,
+	authorizer auth.Authorizer,
+	tokens *auth.WorkflowTokenSigner,
+	kube *kubeclient.Client,
 	pool *pgxpool.Pool,
 ) {
 	logger.Info("Adding server routes")
@@ -26,12 +58,67 @@ func AddRoutes(
 	middleware := func(h http.Handler) http.Handler {
 		h = cors.Default().Handler(h)
 		h = loggingMiddleware(logger, h)
-
-		// TBD auth?
+		h = requestContextMiddleware(config.Server.TrustForwardedFor, h)
 
 		return h
 	}
 
+	// cors answers preflight requests before credentials are checked
+	secured := func(scope routeScope, h http.Handler) http.Handler {
+		return middleware(authMiddleware(logger, authenticators, scope, h))
+	}
+
+	recordAction := func(ctx context.Context, action auditlog_service.ApiAction) error {
+		return auditlog_service.RecordAction(ctx, logger, pool, action)
+	}
+
+	// mutating requests are written to the audit log, rejected credentials included
+	audited := func(
+		scope routeScope,
+		action string,
+		recordOf recordResolver,
+		h http.Handler,
+	) http.Handler {
+		return middleware(auditMiddleware(
+			logger,
+			recordAction,
+			action,
+			recordOf,
+			authMiddleware(logger, authenticators, scope, h),
+		))
+	}
+
+	authorize := func(
+		r *http.Request,
+		principal *auth.Principal,
+		request authorizerecord_service.RecordRequest,
+	) (auth.Decision, error) {
+		return authorizerecord_service.AuthorizeRecord(
+			r.Context(),
+			logger,
+			pool,
+			authorizer,
+			principal,
+			request,
+		)
+	}
+	recordOfWorkflow := func(r *http.Request) (string, error) {
+		return authorizerecord_service.RecordOfWorkflow(
+			r.Context(),
+			logger,
+			pool,
+			r.PathValue("workflowName"),
+		)
+	}
+
+	// runs after authentication, without an authorizer records are not checked
+	authorized := func(action routeAction, recordOf recordResolver, h http.Handler) http.Handler {
+		if authorizer == nil {
+			return h
+		}
+		return authorizeMiddleware(logger, authorize, action, recordOf, h)
+	}
+
 	mux.Handle(
 		buildPathV1(config.ApiContext, "/health/liveness"),
 		middleware(methodHandler(http.MethodGet, health.HandleLive())),
@@ -42,62 +129,249 @@ func AddRoutes(
 		middleware(methodHandler(http.MethodGet, health.HandleReady(ctx, pool))),
 	)
 
+	// workflows authenticate to compchem, which fetches the keys without credentials of this api
+	if tokens != nil {
+		mux.Handle(
+			buildPathV1(config.ApiContext, "/workflow-tokens/jwks"),
+			middleware(methodHandler(http.MethodGet, tokens_route.JwksHandler(logger, tokens))),
+		)
+	}
+
+	mux.Handle(
+		buildPathV1(config.ApiContext, "/config/revision"),
+		secured(scopeList, methodHandler(http.MethodGet, configuration.RevisionHandler(workflows))),
+	)
+
+	mux.Handle(
+		buildPathV1(config.ApiContext, "/admin/audit"),
+		secured(scopeAdmin, methodHandler(http.MethodGet,
+			audit_route.AuditLogHandler(ctx, logger, pool),
+		)),
+	)
+
+	mux.Handle(
+		buildPathV1(config.ApiContext, "/admin/workflows"),
+		audited(scopeAdmin, "create-definition", nil, methodsHandler(map[string]http.Handler{
+			http.MethodGet: definitions_route.ListDefinitionsHandler(ctx, logger, pool),
+			http.MethodPost: definitions_route.CreateDefinitionHandler(
+				ctx,
+				logger,
+				pool,
+				config.ArgoApi,
+				workflows,
+			),
+		})),
+	)
+
+	mux.Handle(
+		buildPathV1(config.ApiContext, "/admin/workflows/{name}"),
+		audited(scopeAdmin, "update-definition", nil, methodHandler(http.MethodPut,
+			definitions_route.UpdateDefinitionHandler(
+				ctx,
+				logger,
+				pool,
+				config.ArgoApi,
+				workflows,
+			),
+		)),
+	)
+
+	mux.Handle(
+		buildPathV1(config.ApiContext, "/admin/workflows/{name}/versions"),
+		secured(scopeAdmin, methodHandler(http.MethodGet,
+			definitions_route.DefinitionVersionsHandler(ctx, logger, pool),
+		)),
+	)
+
+	mux.Handle(
+		buildPathV1(config.ApiContext, "/admin/workflows/{name}/enable"),
+		audited(scopeAdmin, "enable-definition", nil, methodHandler(http.MethodPost,
+			definitions_route.SetDefinitionEnabledHandler(
+				ctx,
+				logger,
+				pool,
+				config.ArgoApi,
+				workflows,
+				true,
+			),
+		)),
+	)
+
+	mux.Handle(
+		buildPathV1(config.ApiContext, "/admin/workflows/{name}/disable"),
+		audited(scopeAdmin, "disable-definition", nil, methodHandler(http.MethodPost,
+			definitions_route.SetDefinitionEnabledHandler(
+				ctx,
+				logger,
+				pool,
+				config.ArgoApi,
+				workflows,
+				false,
+			),
+		)),
+	)
+
 	mux.Handle(
 		buildPathV1(config.ApiContext, "/workflows/{recordId}"),
-		middleware(methodHandler(http.MethodPost, start_workflow_route.PostWorkflowHandler(
-			ctx,
-			logger,
-			pool,
-			config.ArgoApi.Url,
-			config.CompchemApi.Url,
-			config.Workflows,
-		))),
+		audited(scopeStart, "start", recordFromPath, methodHandler(http.MethodPost,
+			authorized(actionProcess, recordFromPath, start_workflow_route.PostWorkflowHandler(
+				ctx,
+				logger,
+				pool,
+				tokens,
+				config.CompchemApi.Url,
+				config.ArgoApi.Instance,
+				config.ArgoApi.CallbackUrl,
+				workflows,
+			)),
+		)),
+	)
+
+	mux.Handle(
+		buildPathV1(config.ApiContext, "/workflows/{recordId}/render"),
+		secured(scopeStart, methodHandler(http.MethodPost,
+			authorized(actionProcess, recordFromPath, start_workflow_route.RenderWorkflowHandler(
+				logger,
+				config.CompchemApi.Url,
+				config.ArgoApi.Instance,
+				config.ArgoApi.CallbackUrl,
+				workflows,
+			)),
+		)),
 	)
 
 	mux.Handle(
 		buildPathV1(config.ApiContext, "/workflows/{recordId}/all"),
-		middleware(methodHandler(http.MethodPost, start_workflow_route.PostAllWorkflowsHandler(
-			ctx,
-			logger,
-			pool,
-			config.ArgoApi.Url,
-			config.CompchemApi.Url,
-			config.Workflows,
-		))),
+		audited(scopeStart, "start-all", recordFromPath, methodHandler(http.MethodPost,
+			authorized(actionProcess, recordFromPath, start_workflow_route.PostAllWorkflowsHandler(
+				ctx,
+				logger,
+				pool,
+				tokens,
+				config.CompchemApi.Url,
+				config.ArgoApi.Instance,
+				config.ArgoApi.CallbackUrl,
+				workflows,
+			)),
+		)),
 	)
 
 	mux.Handle(
 		buildPathV1(config.ApiContext, "/workflows/{recordId}/list"),
-		middleware(methodHandler(http.MethodGet,
-			active_workflows.ActiveWorkflowsListHandler(
+		secured(scopeList, methodHandler(http.MethodGet,
+			authorized(actionRead, recordFromPath, active_workflows.ActiveWorkflowsListHandler(
+				ctx,
+				logger,
+				pool,
+			)),
+		)),
+	)
+
+	mux.Handle(
+		buildPathV1(config.ApiContext, "/workflows/{workflowName}/detail"),
+		secured(scopeList, methodHandler(http.MethodGet,
+			authorized(actionRead, recordOfWorkflow, active_workflows.WorkflowDetailHandler(
 				ctx,
 				logger,
 				pool,
 				config.ArgoApi.Url,
 				config.ArgoApi.Namespace,
+				config.ArgoApi.Instance,
+			)),
+		)),
+	)
+
+	mux.Handle(
+		buildPathV1(config.ApiContext, "/workflows/{workflowName}/config"),
+		secured(scopeList, methodHandler(http.MethodGet,
+			authorized(
+				actionRead,
+				recordOfWorkflow,
+				active_workflows.WorkflowConfigHandler(ctx, logger, pool),
 			),
 		)),
 	)
 
 	mux.Handle(
-		buildPathV1(config.ApiContext, "/workflows/{workflowName}/detail"),
-		middleware(methodHandler(http.MethodGet,
-			active_workflows.WorkflowDetailHandler(
+		buildPathV1(config.ApiContext, "/config/snapshots/{configHash}"),
+		secured(scopeList, methodHandler(http.MethodGet,
+			active_workflows.ConfigSnapshotHandler(ctx, logger, pool),
+		)),
+	)
+
+	mux.Handle(
+		buildPathV1(config.ApiContext, "/workflows/{workflowName}/stop"),
+		audited(scopeStart, "stop", recordOfWorkflow, methodHandler(http.MethodPost,
+			authorized(actionProcess, recordOfWorkflow, stop_workflow_route.StopWorkflowHandler(
 				ctx,
 				logger,
 				pool,
+				kube,
+				tokens,
 				config.ArgoApi.Url,
 				config.ArgoApi.Namespace,
-			),
+				config.CompchemApi.Url,
+				stopworkflow_service.ActionStop,
+			)),
+		)),
+	)
+
+	mux.Handle(
+		buildPathV1(config.ApiContext, "/workflows/{workflowName}/terminate"),
+		audited(scopeStart, "terminate", recordOfWorkflow, methodHandler(http.MethodPost,
+			authorized(actionProcess, recordOfWorkflow, stop_workflow_route.StopWorkflowHandler(
+				ctx,
+				logger,
+				pool,
+				kube,
+				tokens,
+				config.ArgoApi.Url,
+				config.ArgoApi.Namespace,
+				config.CompchemApi.Url,
+				stopworkflow_service.ActionTerminate,
+			)),
+		)),
+	)
+
+	mux.Handle(
+		buildPathV1(config.ApiContext, "/workflows/{workflowName}/retry"),
+		audited(scopeStart, "retry", recordOfWorkflow, methodHandler(http.MethodPost,
+			authorized(actionProcess, recordOfWorkflow, retry_workflow_route.RetryWorkflowHandler(
+				ctx,
+				logger,
+				pool,
+				kube,
+				tokens,
+				config.ArgoApi.Url,
+				config.ArgoApi.Namespace,
+				config.CompchemApi.Url,
+				config.ArgoApi.Instance,
+				config.ArgoApi.CallbackUrl,
+				workflows,
+			)),
+		)),
+	)
+
+	mux.Handle(
+		buildPathV1(config.ApiContext, "/workflows/{workflowName}/outcome"),
+		middleware(auditMiddleware(logger, recordAction, "report-outcome", recordOfWorkflow,
+			methodHandler(http.MethodPost, outcome_route.ReportOutcomeHandler(
+				ctx,
+				logger,
+				pool,
+				config.CompchemApi.Url,
+				config.CompchemApi.NotifyOutcome,
+			)),
 		)),
 	)
 
 	mux.Handle(
 		buildPathV1(config.ApiContext, "/workflows/available"),
-		middleware(
+		secured(
+			scopeList,
 			methodHandler(
 				http.MethodPost,
-				available.AvailableWorkflowsHandler(ctx, logger, config.Workflows),
+				available.AvailableWorkflowsHandler(ctx, logger, workflows),
 			),
 		),
 	)
@@ -113,6 +387,17 @@ func methodHandler(allowedMethod string, handler http.Handler) http.Handler {
 	})
 }
 
+// Serves paths with a handler per method.
+func methodsHandler(handlers map[string]http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler, present := handlers[r.Method]
+		if !present {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
 
 func buildPathV1(apiContext string, path string) string {
 	return apiContext + "/v1" + path
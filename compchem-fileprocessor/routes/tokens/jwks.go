@@ -0,0 +1,23 @@
+package tokens_route
+
+import (
+	"net/http"
+
+	"fi.muni.cz/invenio-file-processor/v2/auth"
+	"go.uber.org/zap"
+)
+
+// Verifiers may cache the keys briefly, rotated keys are picked up within this time.
+const jwksMaxAge = "max-age=300"
+
+// Public keys compchem verifies workflow tokens with.
+func JwksHandler(logger *zap.Logger, tokens *auth.WorkflowTokenSigner) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/jwk-set+json")
+		w.Header().Set("Cache-Control", jwksMaxAge)
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(tokens.Jwks()); err != nil {
+			logger.Error("Failed to write jwks", zap.Error(err))
+		}
+	})
+}
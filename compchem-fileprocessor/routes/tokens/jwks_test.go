@@ -0,0 +1,51 @@
+package tokens_route
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fi.muni.cz/invenio-file-processor/v2/auth"
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestJwksHandler_SigningKeyConfigured_PublicKeyServed(t *testing.T) {
+	// Arrange
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	data, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "signing.pem")
+	err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: data}), 0600)
+	assert.NoError(t, err)
+	tokens, err := auth.NewWorkflowTokenSigner(&config.WorkflowTokensConfig{SigningKeyFile: path})
+	assert.NoError(t, err)
+	recorder := httptest.NewRecorder()
+
+	// Act
+	JwksHandler(zap.NewNop(), tokens).ServeHTTP(
+		recorder,
+		httptest.NewRequest(http.MethodGet, "/api/v1/workflow-tokens/jwks", nil),
+	)
+
+	// Assert
+	var body struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/jwk-set+json", recorder.Header().Get("Content-Type"))
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+	assert.Len(t, body.Keys, 1)
+	assert.Equal(t, "ES256", body.Keys[0]["alg"])
+	assert.Empty(t, body.Keys[0]["d"], "private key must not be published")
+}
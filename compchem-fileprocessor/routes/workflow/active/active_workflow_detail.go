@@ -16,6 +16,7 @@ func WorkflowDetailHandler(
 	pool *pgxpool.Pool,
 	argoUrl string,
 	namespace string,
+	instance string,
 ) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		workflows, err := list_workflows.GetWorkflowDetailed(
@@ -24,6 +25,7 @@ func WorkflowDetailHandler(
 			pool,
 			argoUrl,
 			namespace,
+			instance,
 			r.PathValue("workflowName"),
 		)
 		if err != nil {
@@ -27,8 +27,6 @@ func ActiveWorkflowsListHandler(
 	ctx context.Context,
 	logger *zap.Logger,
 	pool *pgxpool.Pool,
-	argoUrl string,
-	namespace string,
 ) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		params, err := getRequestParams(w, r)
@@ -39,8 +37,7 @@ func ActiveWorkflowsListHandler(
 		workflows, err := list_workflows.GetWorkflowsForRecord(
 			ctx,
 			logger,
-			argoUrl,
-			namespace,
+			pool,
 			params.recordId,
 			params.limit,
 			params.skip,
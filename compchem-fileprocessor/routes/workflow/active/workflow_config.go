@@ -0,0 +1,66 @@
+package active_workflows
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"fi.muni.cz/invenio-file-processor/v2/jsonapi"
+	"fi.muni.cz/invenio-file-processor/v2/routes/common"
+	"fi.muni.cz/invenio-file-processor/v2/services/list_workflows"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+func WorkflowConfigHandler(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		snapshot, err := list_workflows.GetWorkflowConfig(
+			ctx,
+			logger,
+			pool,
+			r.PathValue("workflowName"),
+		)
+		if err != nil {
+			handleConfigError(w, r, err)
+			return
+		}
+
+		jsonapi.Encode(w, r, http.StatusOK, snapshot)
+	})
+}
+
+func ConfigSnapshotHandler(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		snapshot, err := list_workflows.GetConfigSnapshot(
+			ctx,
+			logger,
+			pool,
+			r.PathValue("configHash"),
+		)
+		if err != nil {
+			handleConfigError(w, r, err)
+			return
+		}
+
+		jsonapi.Encode(w, r, http.StatusOK, snapshot)
+	})
+}
+
+func handleConfigError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, list_workflows.ErrConfigNotRecorded) {
+		jsonapi.Encode(w, r, http.StatusNotFound, common.ErrorResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	handleError(w, r, err)
+}
@@ -64,7 +64,7 @@ func TestAvailableWorkflowsHandler_ValidBody_CorrectResponseReturned(t *testing.
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
-	handler := AvailableWorkflowsHandler(ctx, logger, configs)
+	handler := AvailableWorkflowsHandler(ctx, logger, config.NewWorkflows(&config.Config{Workflows: configs}))
 	handler.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
@@ -112,7 +112,7 @@ func TestAvailableWorkflowsHandler_ValidBodyNoConfigs_EmtpyOKResponse(t *testing
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
-	handler := AvailableWorkflowsHandler(ctx, logger, configs)
+	handler := AvailableWorkflowsHandler(ctx, logger, config.NewWorkflows(&config.Config{Workflows: configs}))
 	handler.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
@@ -189,7 +189,7 @@ func TestAvailbleWorkflowsHandler_InvalidBody_StatusBadRequest(t *testing.T) {
 			req.Header.Set("Content-Type", "application/json")
 			w := httptest.NewRecorder()
 
-			handler := AvailableWorkflowsHandler(ctx, logger, configs)
+			handler := AvailableWorkflowsHandler(ctx, logger, config.NewWorkflows(&config.Config{Workflows: configs}))
 			handler.ServeHTTP(w, req)
 
 			assert.Equal(t, http.StatusBadRequest, w.Code)
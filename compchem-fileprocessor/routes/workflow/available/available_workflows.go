@@ -16,7 +16,7 @@ import (
 func AvailableWorkflowsHandler(
 	ctx context.Context,
 	logger *zap.Logger,
-	configs []config.WorkflowConfig,
+	workflows *config.Workflows,
 ) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		_, cancel := context.WithTimeout(ctx, 10*time.Second)
@@ -40,7 +40,7 @@ func AvailableWorkflowsHandler(
 			return
 		}
 
-		response := list_workflows.AvailableWorkflows(logger, reqBody, configs)
+		response := list_workflows.AvailableWorkflows(logger, reqBody, workflows.List())
 
 		common.EncodeResponse(w, r, http.StatusOK, response)
 	})
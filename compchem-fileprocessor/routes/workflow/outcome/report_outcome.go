@@ -0,0 +1,96 @@
+package outcome_route
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"fi.muni.cz/invenio-file-processor/v2/jsonapi"
+	"fi.muni.cz/invenio-file-processor/v2/routes/common"
+	reportoutcome_service "fi.muni.cz/invenio-file-processor/v2/services/report_outcome"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+var finalPhases = map[string]bool{
+	"Succeeded": true,
+	"Failed":    true,
+	"Error":     true,
+}
+
+// Called by the exit handler of generated workflows with their final phase, produced files and
+// failed nodes. The workflow authenticates with its secret key as a bearer token.
+func ReportOutcomeHandler(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	compchemUrl string,
+	notifyCompchem bool,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		workflowName := r.PathValue("workflowName")
+		secretKey := bearerToken(r)
+		if secretKey == "" {
+			jsonapi.Encode(w, r, http.StatusUnauthorized, common.ErrorResponse{
+				Message: "Missing bearer token with workflow secret key",
+			})
+			return
+		}
+
+		reqBody, err := common.GetValidRequestBody(w, r, validateOutcomeBody)
+		if err != nil {
+			logger.Error(
+				"Workflow outcome invalid",
+				zap.String("workflowName", workflowName),
+				zap.Error(err),
+			)
+			return
+		}
+
+		err = reportoutcome_service.RecordOutcome(
+			ctx,
+			logger,
+			pool,
+			compchemUrl,
+			notifyCompchem,
+			workflowName,
+			secretKey,
+			*reqBody,
+		)
+		if errors.Is(err, reportoutcome_service.ErrUnauthorized) {
+			logger.Warn("Rejected workflow outcome", zap.String("workflowName", workflowName))
+			jsonapi.Encode(w, r, http.StatusUnauthorized, common.ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		} else if err != nil {
+			logger.Error("Failed to record workflow outcome", zap.Error(err))
+			jsonapi.Encode(w, r, http.StatusInternalServerError, common.ErrorResponse{
+				Message: "Failed to record workflow outcome",
+			})
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// Returns empty string when the request does not carry a bearer token.
+func bearerToken(r *http.Request) string {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return ""
+	}
+
+	return strings.TrimSpace(token)
+}
+
+func validateOutcomeBody(body *reportoutcome_service.WorkflowOutcome) error {
+	if !finalPhases[body.Phase] {
+		return fmt.Errorf("phase must be one of Succeeded, Failed, Error, got: %q", body.Phase)
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package outcome_route
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	reportoutcome_service "fi.muni.cz/invenio-file-processor/v2/services/report_outcome"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateOutcomeBody_FinalPhase_NoErr(t *testing.T) {
+	for _, phase := range []string{"Succeeded", "Failed", "Error"} {
+		err := validateOutcomeBody(&reportoutcome_service.WorkflowOutcome{Phase: phase})
+		assert.NoError(t, err, phase)
+	}
+}
+
+func TestValidateOutcomeBody_RunningOrMissingPhase_Err(t *testing.T) {
+	for _, phase := range []string{"", "Running", "{{workflow.status}}"} {
+		err := validateOutcomeBody(&reportoutcome_service.WorkflowOutcome{Phase: phase})
+		assert.Error(t, err, phase)
+	}
+}
+
+func TestBearerToken_HeaderPresent_TokenReturned(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/v1/workflows/count-words-ej26y-ad28j-1/outcome", nil)
+	r.Header.Set("Authorization", "Bearer mysecretkey")
+
+	assert.Equal(t, "mysecretkey", bearerToken(r))
+}
+
+func TestBearerToken_MissingOrOtherScheme_Empty(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/v1/workflows/count-words-ej26y-ad28j-1/outcome", nil)
+	assert.Equal(t, "", bearerToken(r))
+
+	r.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+	assert.Equal(t, "", bearerToken(r))
+}
@@ -0,0 +1,98 @@
+package retry_workflow_route
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"fi.muni.cz/invenio-file-processor/v2/audit"
+	"fi.muni.cz/invenio-file-processor/v2/auth"
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/httpclient"
+	"fi.muni.cz/invenio-file-processor/v2/jsonapi"
+	"fi.muni.cz/invenio-file-processor/v2/kubeclient"
+	"fi.muni.cz/invenio-file-processor/v2/routes/common"
+	retryworkflow_service "fi.muni.cz/invenio-file-processor/v2/services/retry_workflow"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+func RetryWorkflowHandler(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	kube *kubeclient.Client,
+	tokens *auth.WorkflowTokenSigner,
+	argoUrl string,
+	namespace string,
+	baseUrl string,
+	instance string,
+	callbackUrl string,
+	workflows *config.Workflows,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, err := retryworkflow_service.RetryWorkflow(
+			ctx,
+			logger,
+			pool,
+			kube,
+			tokens,
+			argoUrl,
+			namespace,
+			baseUrl,
+			instance,
+			callbackUrl,
+			workflows.List(),
+			r.PathValue("workflowName"),
+		)
+		if err != nil {
+			logger.Error("Failed to retry workflow", zap.Error(err))
+			handleError(w, r, err)
+			return
+		}
+		// a resubmitted workflow is recorded next to the one it retried
+		audit.NoteWorkflows(r.Context(), response.RetriedFrom, response.WorkflowName)
+
+		status := http.StatusOK
+		if response.Mode == retryworkflow_service.ModeResubmitted {
+			status = http.StatusCreated
+		}
+
+		err = jsonapi.Encode(w, r, status, response)
+		if err != nil {
+			logger.Error(
+				"Failed to Encode response for retry workflow handler",
+				zap.String("workflowName", response.WorkflowName),
+				zap.Error(err),
+			)
+		}
+	})
+}
+
+func handleError(w http.ResponseWriter, r *http.Request, err error) {
+	var clientErr *httpclient.ClientError
+	var serverErr *httpclient.ServerError
+	if errors.Is(err, retryworkflow_service.ErrWorkflowNotFound) {
+		jsonapi.Encode(w, r, http.StatusNotFound, common.ErrorResponse{
+			Message: err.Error(),
+		})
+	} else if errors.Is(err, retryworkflow_service.ErrWorkflowNotRetryable) ||
+		errors.Is(err, retryworkflow_service.ErrWorkflowAlreadyRetried) {
+		jsonapi.Encode(w, r, http.StatusConflict, common.ErrorResponse{
+			Message: err.Error(),
+		})
+	} else if errors.As(err, &clientErr) {
+		jsonapi.Encode(w, r, http.StatusInternalServerError, common.ErrorResponse{
+			Message: fmt.Errorf("Argo could not process request: %v", err).Error(),
+		})
+	} else if errors.As(err, &serverErr) {
+		jsonapi.Encode(w, r, http.StatusServiceUnavailable, common.ErrorResponse{
+			Message: fmt.Errorf("Argo might currently be unavailable: %v", err).Error(),
+		})
+	} else {
+		jsonapi.Encode(w, r, http.StatusInternalServerError, common.ErrorResponse{
+			Message: fmt.Errorf("Something went wrong when processing request: %v", err).Error(),
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package retry_workflow_route
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fi.muni.cz/invenio-file-processor/v2/httpclient"
+	"fi.muni.cz/invenio-file-processor/v2/routes/common"
+	retryworkflow_service "fi.muni.cz/invenio-file-processor/v2/services/retry_workflow"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleError(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedMsg    string
+	}{
+		{
+			name:           "WorkflowNotFound",
+			err:            retryworkflow_service.ErrWorkflowNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedMsg:    "workflow not found",
+		},
+		{
+			name:           "WorkflowNotRetryable",
+			err:            fmt.Errorf("%w: phase Running", retryworkflow_service.ErrWorkflowNotRetryable),
+			expectedStatus: http.StatusConflict,
+			expectedMsg:    "workflow can not be retried: phase Running",
+		},
+		{
+			name: "WorkflowAlreadyRetried",
+			err: fmt.Errorf(
+				"%w as count-words-ej26y-ad28j-2",
+				retryworkflow_service.ErrWorkflowAlreadyRetried,
+			),
+			expectedStatus: http.StatusConflict,
+			expectedMsg:    "workflow was already resubmitted as count-words-ej26y-ad28j-2",
+		},
+		{
+			name: "ClientError",
+			err: &httpclient.ClientError{
+				Status:  400,
+				Message: "workflow must be Failed/Error to retry",
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedMsg:    "Argo could not process request: Error on client side, status: 400, message: workflow must be Failed/Error to retry",
+		},
+		{
+			name: "ServerError",
+			err: &httpclient.ServerError{
+				Status:  503,
+				Message: "unavailable",
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedMsg:    "Argo might currently be unavailable: Error on server side, status: 503, message: unavailable",
+		},
+		{
+			name:           "GenericError",
+			err:            errors.New("unexpected error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedMsg:    "Something went wrong when processing request: unexpected error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/test", nil)
+
+			handleError(w, r, tt.err)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+
+			var response common.ErrorResponse
+			err := json.NewDecoder(w.Body).Decode(&response)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedMsg, response.Message)
+		})
+	}
+}
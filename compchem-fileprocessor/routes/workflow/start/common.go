@@ -1,9 +1,14 @@
 package start_workflow_route
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
 
+	"fi.muni.cz/invenio-file-processor/v2/jsonapi"
+	"fi.muni.cz/invenio-file-processor/v2/routes/common"
 	"fi.muni.cz/invenio-file-processor/v2/services"
+	startworkflow_service "fi.muni.cz/invenio-file-processor/v2/services/start_workflow"
 )
 
 func validateFiles(files []services.File, errors []string) {
@@ -19,3 +24,23 @@ func validateFiles(files []services.File, errors []string) {
 		}
 	}
 }
+
+func handleError(w http.ResponseWriter, r *http.Request, err error) {
+	var parametersErr *startworkflow_service.InvalidParametersError
+	var inputsErr *startworkflow_service.InvalidInputsError
+	if errors.As(err, &parametersErr) {
+		jsonapi.Encode(w, r, http.StatusBadRequest, common.ValidationErrorResponse{
+			Message: "Invalid workflow parameters",
+			Errors:  parametersErr.Errors,
+		})
+	} else if errors.As(err, &inputsErr) {
+		jsonapi.Encode(w, r, http.StatusBadRequest, common.ValidationErrorResponse{
+			Message: "Invalid workflow inputs",
+			Errors:  inputsErr.Errors,
+		})
+	} else {
+		jsonapi.Encode(w, r, http.StatusInternalServerError, common.ErrorResponse{
+			Message: "Failed to submit workflow to argo",
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package start_workflow_route
+
+import (
+	"errors"
+	"net/http"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/jsonapi"
+	"fi.muni.cz/invenio-file-processor/v2/routes/common"
+	startworkflow_service "fi.muni.cz/invenio-file-processor/v2/services/start_workflow"
+	"go.uber.org/zap"
+)
+
+// Takes the body of the start endpoint and answers with the argo workflow it would submit, as yaml
+// when the client accepts it.
+func RenderWorkflowHandler(
+	logger *zap.Logger,
+	baseUrl string,
+	instance string,
+	callbackUrl string,
+	workflows *config.Workflows,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recordId := r.PathValue("recordId")
+		reqBody, err := common.GetValidRequestBody(w, r, validateStartBody)
+		if err != nil {
+			logger.Error("Requst body invalid", zap.Error(err))
+			return
+		}
+
+		workflow, err := startworkflow_service.RenderWorkflow(
+			baseUrl,
+			instance,
+			callbackUrl,
+			reqBody.Name,
+			recordId,
+			reqBody.Files,
+			reqBody.Parameters,
+			workflows.List(),
+		)
+		if err != nil {
+			logger.Info("Failed to render workflow", zap.Error(err))
+			handleRenderError(w, r, err)
+			return
+		}
+
+		if jsonapi.AcceptsYaml(r) {
+			err = jsonapi.EncodeYaml(w, r, http.StatusOK, workflow)
+		} else {
+			err = jsonapi.Encode(w, r, http.StatusOK, workflow)
+		}
+		if err != nil {
+			logger.Error(
+				"Failed to Encode response for render workflow handler",
+				zap.String("workflowName", workflow.Metadata.Name),
+				zap.Error(err),
+			)
+		}
+	})
+}
+
+// Nothing is submitted while rendering, every failure is caused by the request.
+func handleRenderError(w http.ResponseWriter, r *http.Request, err error) {
+	var parametersErr *startworkflow_service.InvalidParametersError
+	var inputsErr *startworkflow_service.InvalidInputsError
+	if errors.As(err, &parametersErr) || errors.As(err, &inputsErr) {
+		handleError(w, r, err)
+		return
+	}
+
+	jsonapi.Encode(w, r, http.StatusBadRequest, common.ErrorResponse{
+		Message: err.Error(),
+	})
+}
@@ -0,0 +1,86 @@
+package start_workflow_route
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"fi.muni.cz/invenio-file-processor/v2/api/argodtos"
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+)
+
+var renderConfigs = []config.WorkflowConfig{
+	{
+		Name:      "count-words",
+		Mimetype:  "text/plain",
+		Extension: "txt",
+		ProcessingTemplates: []config.ProcessingTemplate{
+			{Name: "count-words-template", Template: "count-words"},
+		},
+	},
+}
+
+const renderBody = `
+  {
+    "name": "count-words",
+    "files": [
+      {
+        "key": "test.txt",
+        "mimetype": "text/plain"
+      }
+    ]
+  }
+  `
+
+func serveRender(t *testing.T, body string, accept string) *httptest.ResponseRecorder {
+	handler := RenderWorkflowHandler(
+		zaptest.NewLogger(t),
+		"https://localhost:5000",
+		"compchem-test",
+		"http://fileprocessor:8062/api",
+		config.NewWorkflows(&config.Config{Workflows: renderConfigs}),
+	)
+
+	mux := http.NewServeMux()
+	mux.Handle("/workflows/{recordId}/render", handler)
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("POST", "/workflows/ej26y-ad28j/render", strings.NewReader(body))
+	if accept != "" {
+		request.Header.Set("Accept", accept)
+	}
+	mux.ServeHTTP(recorder, request)
+
+	return recorder
+}
+
+func TestRenderWorkflowHandler_ValidBody_WorkflowReturnedAsJson(t *testing.T) {
+	recorder := serveRender(t, renderBody, "")
+
+	var workflow argodtos.Workflow
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&workflow))
+	assert.Equal(t, "count-words-ej26y-ad28j-0", workflow.Metadata.Name)
+	assert.Equal(t, "count-words-ej26y-ad28j-0-key", workflow.GetParameter("secret-name"))
+}
+
+func TestRenderWorkflowHandler_YamlAccepted_WorkflowReturnedAsYaml(t *testing.T) {
+	recorder := serveRender(t, renderBody, "application/yaml")
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/yaml", recorder.Header().Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(recorder.Body.String(), "apiVersion: argoproj.io/v1alpha1\n"))
+	assert.Contains(t, recorder.Body.String(), "  name: count-words-ej26y-ad28j-0\n")
+}
+
+func TestRenderWorkflowHandler_UnknownWorkflow_BadRequest(t *testing.T) {
+	recorder := serveRender(t, strings.Replace(renderBody, "count-words", "count-lines", 1), "")
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "No workflow with name: count-lines")
+}
@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"strings"
 
+	"fi.muni.cz/invenio-file-processor/v2/audit"
+	"fi.muni.cz/invenio-file-processor/v2/auth"
 	"fi.muni.cz/invenio-file-processor/v2/config"
 	"fi.muni.cz/invenio-file-processor/v2/jsonapi"
 	"fi.muni.cz/invenio-file-processor/v2/routes/common"
@@ -23,9 +25,11 @@ func PostAllWorkflowsHandler(
 	ctx context.Context,
 	logger *zap.Logger,
 	pool *pgxpool.Pool,
-	argoUrl string,
+	tokens *auth.WorkflowTokenSigner,
 	baseUrl string,
-	configs []config.WorkflowConfig,
+	instance string,
+	callbackUrl string,
+	workflows *config.Workflows,
 ) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		recordId := r.PathValue("recordId")
@@ -39,19 +43,22 @@ func PostAllWorkflowsHandler(
 			ctx,
 			logger,
 			pool,
-			argoUrl,
+			tokens,
 			baseUrl,
+			instance,
+			callbackUrl,
 			recordId,
 			reqBody.Files,
-			configs,
+			workflows.List(),
 		)
 		if err != nil {
 			logger.Error("Failed to submit file for processing", zap.Error(err))
-			jsonapi.Encode(w, r, http.StatusInternalServerError, common.ErrorResponse{
-				Message: "Failed to submit workflow to argo",
-			})
+			handleError(w, r, err)
 			return
 		}
+		for _, workflow := range response.WorkflowContexts {
+			audit.NoteWorkflows(r.Context(), workflow.WorkflowName)
+		}
 
 		err = jsonapi.Encode(w, r, http.StatusCreated, response)
 		if err != nil {
@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"strings"
 
+	"fi.muni.cz/invenio-file-processor/v2/audit"
+	"fi.muni.cz/invenio-file-processor/v2/auth"
 	"fi.muni.cz/invenio-file-processor/v2/config"
 	"fi.muni.cz/invenio-file-processor/v2/jsonapi"
 	"fi.muni.cz/invenio-file-processor/v2/routes/common"
@@ -15,18 +17,22 @@ import (
 	"go.uber.org/zap"
 )
 
+// Parameters are keyed by processing template name, then by parameter name.
 type startRequestBody struct {
-	Name  string          `json:"name"`
-	Files []services.File `json:"files"`
+	Name       string                    `json:"name"`
+	Files      []services.File           `json:"files"`
+	Parameters map[string]map[string]any `json:"parameters"`
 }
 
 func PostWorkflowHandler(
 	ctx context.Context,
 	logger *zap.Logger,
 	pool *pgxpool.Pool,
-	argoUrl string,
+	tokens *auth.WorkflowTokenSigner,
 	baseUrl string,
-	configs []config.WorkflowConfig,
+	instance string,
+	callbackUrl string,
+	workflows *config.Workflows,
 ) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		recordId := r.PathValue("recordId")
@@ -40,20 +46,22 @@ func PostWorkflowHandler(
 			ctx,
 			logger,
 			pool,
-			argoUrl,
+			tokens,
 			baseUrl,
+			instance,
+			callbackUrl,
 			reqBody.Name,
 			recordId,
 			reqBody.Files,
-			configs,
+			reqBody.Parameters,
+			workflows.List(),
 		)
 		if err != nil {
 			logger.Error("Failed to submit file for processing", zap.Error(err))
-			jsonapi.Encode(w, r, http.StatusInternalServerError, common.ErrorResponse{
-				Message: "Failed to submit workflow to argo",
-			})
+			handleError(w, r, err)
 			return
 		}
+		audit.NoteWorkflows(r.Context(), response.WorkflowName)
 
 		err = jsonapi.Encode(w, r, http.StatusCreated, response)
 		if err != nil {
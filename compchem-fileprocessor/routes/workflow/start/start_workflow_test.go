@@ -1,12 +1,16 @@
 package start_workflow_route
 
 import (
+	"encoding/json"
+	"errors"
+	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
 	"fi.muni.cz/invenio-file-processor/v2/routes/common"
 	"fi.muni.cz/invenio-file-processor/v2/services"
+	startworkflow_service "fi.muni.cz/invenio-file-processor/v2/services/start_workflow"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -58,3 +62,53 @@ func TestValidateBody_OkBody_ReturnsCorrectBody(t *testing.T) {
 	assert.Nil(t, err, "expected error returned")
 	assert.Equal(t, expected, *reqBody, "expected same body as in test")
 }
+
+func TestValidateBody_ParametersSupplied_ParametersMapped(t *testing.T) {
+	reader := strings.NewReader(`
+  {
+    "name": "simulation-annotation",
+    "files": [
+      {
+        "key": "run.tpr",
+        "mimetype": "application/octet-stream"
+      }
+    ],
+    "parameters": {
+      "simulation-annotation": {
+        "keep": false
+      }
+    }
+  }
+  `)
+
+	recorder := httptest.NewRecorder()
+
+	request := httptest.NewRequest("POST", "https://localhost:8080", reader)
+
+	reqBody, err := common.GetValidRequestBody(recorder, request, validateStartBody)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]map[string]any{"simulation-annotation": {"keep": false}}, reqBody.Parameters)
+}
+
+func TestHandleError_InvalidParameters_FieldErrorsReturned(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("POST", "https://localhost:8080", nil)
+
+	handleError(recorder, request, &startworkflow_service.InvalidParametersError{
+		Errors: map[string]string{"simulation-annotation.keep": "must be a boolean"},
+	})
+
+	var response common.ValidationErrorResponse
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+	assert.Equal(t, "must be a boolean", response.Errors["simulation-annotation.keep"])
+}
+
+func TestHandleError_OtherError_InternalServerError(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("POST", "https://localhost:8080", nil)
+
+	handleError(recorder, request, errors.New("argo unavailable"))
+
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+}
@@ -0,0 +1,81 @@
+package stop_workflow_route
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"fi.muni.cz/invenio-file-processor/v2/auth"
+	"fi.muni.cz/invenio-file-processor/v2/httpclient"
+	"fi.muni.cz/invenio-file-processor/v2/jsonapi"
+	"fi.muni.cz/invenio-file-processor/v2/kubeclient"
+	"fi.muni.cz/invenio-file-processor/v2/routes/common"
+	stopworkflow_service "fi.muni.cz/invenio-file-processor/v2/services/stop_workflow"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+func StopWorkflowHandler(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	kube *kubeclient.Client,
+	tokens *auth.WorkflowTokenSigner,
+	argoUrl string,
+	namespace string,
+	baseUrl string,
+	action stopworkflow_service.Action,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, err := stopworkflow_service.StopWorkflow(
+			ctx,
+			logger,
+			pool,
+			kube,
+			tokens,
+			argoUrl,
+			namespace,
+			baseUrl,
+			r.PathValue("workflowName"),
+			action,
+		)
+		if err != nil {
+			logger.Error(
+				"Failed to cancel workflow",
+				zap.String("action", string(action)),
+				zap.Error(err),
+			)
+			handleError(w, r, err)
+			return
+		}
+
+		jsonapi.Encode(w, r, http.StatusOK, response)
+	})
+}
+
+func handleError(w http.ResponseWriter, r *http.Request, err error) {
+	var clientErr *httpclient.ClientError
+	var serverErr *httpclient.ServerError
+	if errors.Is(err, stopworkflow_service.ErrWorkflowNotFound) {
+		jsonapi.Encode(w, r, http.StatusNotFound, common.ErrorResponse{
+			Message: err.Error(),
+		})
+	} else if errors.Is(err, stopworkflow_service.ErrWorkflowFinished) {
+		jsonapi.Encode(w, r, http.StatusConflict, common.ErrorResponse{
+			Message: err.Error(),
+		})
+	} else if errors.As(err, &clientErr) {
+		jsonapi.Encode(w, r, http.StatusInternalServerError, common.ErrorResponse{
+			Message: fmt.Errorf("Argo could not process request: %v", err).Error(),
+		})
+	} else if errors.As(err, &serverErr) {
+		jsonapi.Encode(w, r, http.StatusServiceUnavailable, common.ErrorResponse{
+			Message: fmt.Errorf("Argo might currently be unavailable: %v", err).Error(),
+		})
+	} else {
+		jsonapi.Encode(w, r, http.StatusInternalServerError, common.ErrorResponse{
+			Message: fmt.Errorf("Something went wrong when processing request: %v", err).Error(),
+		})
+	}
+}
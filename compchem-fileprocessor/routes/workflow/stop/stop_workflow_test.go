@@ -0,0 +1,77 @@
+package stop_workflow_route
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fi.muni.cz/invenio-file-processor/v2/httpclient"
+	"fi.muni.cz/invenio-file-processor/v2/routes/common"
+	stopworkflow_service "fi.muni.cz/invenio-file-processor/v2/services/stop_workflow"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleError(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedMsg    string
+	}{
+		{
+			name:           "WorkflowNotFound",
+			err:            stopworkflow_service.ErrWorkflowNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedMsg:    "workflow not found",
+		},
+		{
+			name:           "WorkflowFinished",
+			err:            stopworkflow_service.ErrWorkflowFinished,
+			expectedStatus: http.StatusConflict,
+			expectedMsg:    "workflow already finished",
+		},
+		{
+			name: "ClientError",
+			err: fmt.Errorf("wrapped: %w", &httpclient.ClientError{
+				Status:  404,
+				Message: "not found",
+			}),
+			expectedStatus: http.StatusInternalServerError,
+			expectedMsg:    "Argo could not process request: wrapped: Error on client side, status: 404, message: not found",
+		},
+		{
+			name: "ServerError",
+			err: &httpclient.ServerError{
+				Status:  503,
+				Message: "unavailable",
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedMsg:    "Argo might currently be unavailable: Error on server side, status: 503, message: unavailable",
+		},
+		{
+			name:           "GenericError",
+			err:            errors.New("unexpected error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedMsg:    "Something went wrong when processing request: unexpected error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/test", nil)
+
+			handleError(w, r, tt.err)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+
+			var response common.ErrorResponse
+			err := json.NewDecoder(w.Body).Decode(&response)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedMsg, response.Message)
+		})
+	}
+}
@@ -81,6 +81,7 @@ func TestRunHttpServer_ServerFullyConfigured_ReadyCheckReturnsOk(t *testing.T) {
 			},
 		},
 		Migrations: fmt.Sprintf("file://%s/migrations", wd),
+		Kubernetes: config.KubernetesApi{Url: "http://localhost:8001"},
 	}
 
 	ready := make(chan struct{})
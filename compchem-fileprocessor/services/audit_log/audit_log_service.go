@@ -0,0 +1,202 @@
+package auditlog_service
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"fi.muni.cz/invenio-file-processor/v2/repository/audit_repository"
+	repository_common "fi.muni.cz/invenio-file-processor/v2/repository/common"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+const (
+	OutcomeSucceeded = "succeeded"
+	OutcomeRejected  = "rejected"
+	OutcomeFailed    = "failed"
+)
+
+type AuditFilter = audit_repository.AuditFilter
+
+// Mutating request as answered by the api, one entry is written per workflow it acted on.
+type ApiAction struct {
+	Subject   string
+	Action    string
+	RecordId  string
+	Workflows []string
+	Status    int
+	RequestId string
+	SourceIp  string
+	Method    string
+	Path      string
+}
+
+type AuditEntry struct {
+	Id           int64     `json:"id"`
+	CreatedAt    time.Time `json:"createdAt"`
+	Event        string    `json:"event"`
+	Subject      string    `json:"subject"`
+	Action       string    `json:"action"`
+	Outcome      string    `json:"outcome"`
+	Reason       string    `json:"reason"`
+	RecordId     *string   `json:"recordId"`
+	WorkflowName *string   `json:"workflowName"`
+	Status       *int      `json:"status"`
+	Allowed      *bool     `json:"allowed"`
+	Source       *string   `json:"source"`
+	Cached       *bool     `json:"cached"`
+	RequestId    *string   `json:"requestId"`
+	SourceIp     *string   `json:"sourceIp"`
+	Method       string    `json:"method"`
+	Path         string    `json:"path"`
+}
+
+// Client errors reject the request, anything else above 400 failed while handling it.
+func OutcomeOf(status int) string {
+	if status < http.StatusBadRequest {
+		return OutcomeSucceeded
+	} else if status < http.StatusInternalServerError {
+		return OutcomeRejected
+	}
+	return OutcomeFailed
+}
+
+func RecordAction(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	action ApiAction,
+) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		logger.Error("error when starting tx for writing audit entry", zap.Error(err))
+		return err
+	}
+
+	workflows := []*string{nil}
+	if len(action.Workflows) > 0 {
+		workflows = make([]*string, len(action.Workflows))
+		for i := range action.Workflows {
+			workflows[i] = &action.Workflows[i]
+		}
+	}
+
+	for _, workflow := range workflows {
+		err = audit_repository.CreateAuditEntry(ctx, logger, tx, audit_repository.AuditEntryEntity{
+			Event:         audit_repository.EventAction,
+			Subject:       action.Subject,
+			RecordId:      emptyToNil(action.RecordId),
+			WorkflowName:  workflow,
+			Action:        action.Action,
+			Outcome:       OutcomeOf(action.Status),
+			Status:        &action.Status,
+			Reason:        http.StatusText(action.Status),
+			RequestId:     emptyToNil(action.RequestId),
+			SourceIp:      emptyToNil(action.SourceIp),
+			RequestMethod: action.Method,
+			RequestPath:   action.Path,
+		})
+		if err != nil {
+			tx.Rollback(ctx)
+			return err
+		}
+	}
+
+	return repository_common.CommitTx(ctx, tx, logger)
+}
+
+// Returns a page of entries matching the filter, newest first.
+func ListAuditEntries(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	filter AuditFilter,
+	limit int,
+	skip int,
+) ([]AuditEntry, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		logger.Error("error when starting tx for listing audit entries", zap.Error(err))
+		return nil, err
+	}
+
+	entities, err := audit_repository.FindAuditEntries(ctx, logger, tx, filter, limit, skip)
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+
+	err = repository_common.CommitTx(ctx, tx, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]AuditEntry, 0, len(entities))
+	for _, entity := range entities {
+		entries = append(entries, toAuditEntry(entity))
+	}
+
+	return entries, nil
+}
+
+// Hands every entry matching the filter to handle, newest first, the entries are read in a single
+// transaction so the export is consistent.
+func ExportAuditEntries(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	filter AuditFilter,
+	handle func(entry AuditEntry) error,
+) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		logger.Error("error when starting tx for exporting audit entries", zap.Error(err))
+		return err
+	}
+
+	err = audit_repository.ForEachAuditEntry(
+		ctx,
+		logger,
+		tx,
+		filter,
+		func(entity audit_repository.ExistingAuditEntryEntity) error {
+			return handle(toAuditEntry(entity))
+		},
+	)
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	return repository_common.CommitTx(ctx, tx, logger)
+}
+
+func toAuditEntry(entity audit_repository.ExistingAuditEntryEntity) AuditEntry {
+	return AuditEntry{
+		Id:           entity.Id,
+		CreatedAt:    entity.CreatedAt,
+		Event:        entity.Event,
+		Subject:      entity.Subject,
+		Action:       entity.Action,
+		Outcome:      entity.Outcome,
+		Reason:       entity.Reason,
+		RecordId:     entity.RecordId,
+		WorkflowName: entity.WorkflowName,
+		Status:       entity.Status,
+		Allowed:      entity.Allowed,
+		Source:       entity.Source,
+		Cached:       entity.Cached,
+		RequestId:    entity.RequestId,
+		SourceIp:     entity.SourceIp,
+		Method:       entity.RequestMethod,
+		Path:         entity.RequestPath,
+	}
+}
+
+func emptyToNil(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,14 @@
+package auditlog_service
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutcomeOf_Status_OutcomeOfStatusClass(t *testing.T) {
+	assert.Equal(t, OutcomeSucceeded, OutcomeOf(http.StatusCreated))
+	assert.Equal(t, OutcomeRejected, OutcomeOf(http.StatusForbidden))
+	assert.Equal(t, OutcomeFailed, OutcomeOf(http.StatusBadGateway))
+}
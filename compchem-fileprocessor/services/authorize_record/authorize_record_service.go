@@ -0,0 +1,126 @@
+package authorizerecord_service
+
+import (
+	"context"
+
+	"fi.muni.cz/invenio-file-processor/v2/auth"
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/repository/audit_repository"
+	repository_common "fi.muni.cz/invenio-file-processor/v2/repository/common"
+	"fi.muni.cz/invenio-file-processor/v2/repository/workflow_repository"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// Source of the audit entry of a check which failed before a decision was made.
+const sourceFailed = "failed"
+
+// Request checked against the record, the request details are kept in the audit log.
+type RecordRequest struct {
+	RecordId  string
+	Action    config.RecordAction
+	RequestId string
+	SourceIp  string
+	Method    string
+	Path      string
+}
+
+// Asks the authorizer and writes its decision to the audit log. A failed check is written as a
+// denial and returned as an error, as is a decision which could not be written, so callers deny
+// the request in both cases.
+func AuthorizeRecord(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	authorizer auth.Authorizer,
+	principal *auth.Principal,
+	request RecordRequest,
+) (auth.Decision, error) {
+	decision, checkErr := authorizer.Authorize(ctx, principal, request.RecordId, request.Action)
+	if checkErr != nil {
+		logger.Error(
+			"Failed to authorize record",
+			zap.String("subject", principal.Subject),
+			zap.String("recordId", request.RecordId),
+			zap.Error(checkErr),
+		)
+		decision = auth.Decision{Allowed: false, Reason: checkErr.Error(), Source: sourceFailed}
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		logger.Error("error when starting tx for writing audit entry", zap.Error(err))
+		return decision, err
+	}
+
+	outcome := "denied"
+	if decision.Allowed {
+		outcome = "allowed"
+	} else if checkErr != nil {
+		outcome = "failed"
+	}
+	err = audit_repository.CreateAuditEntry(ctx, logger, tx, audit_repository.AuditEntryEntity{
+		Event:         audit_repository.EventAuthorization,
+		Subject:       principal.Subject,
+		RecordId:      &request.RecordId,
+		Action:        string(request.Action),
+		Outcome:       outcome,
+		Allowed:       &decision.Allowed,
+		Source:        &decision.Source,
+		Cached:        &decision.Cached,
+		Reason:        decision.Reason,
+		RequestId:     emptyToNil(request.RequestId),
+		SourceIp:      emptyToNil(request.SourceIp),
+		RequestMethod: request.Method,
+		RequestPath:   request.Path,
+	})
+	if err != nil {
+		tx.Rollback(ctx)
+		return decision, err
+	}
+
+	err = repository_common.CommitTx(ctx, tx, logger)
+	if err != nil {
+		return decision, err
+	}
+
+	return decision, checkErr
+}
+
+// Record the workflow was started for, empty when there is no such workflow.
+func RecordOfWorkflow(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	workflowFullName string,
+) (string, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		logger.Error(
+			"error when starting tx for finding record of workflow",
+			zap.String("workflowName", workflowFullName),
+			zap.Error(err),
+		)
+		return "", err
+	}
+
+	workflow, err := workflow_repository.FindWorkflowByFullName(ctx, logger, tx, workflowFullName)
+	if err != nil {
+		tx.Rollback(ctx)
+		return "", err
+	}
+
+	err = repository_common.CommitTx(ctx, tx, logger)
+	if err != nil || workflow == nil {
+		return "", err
+	}
+
+	return workflow.RecordId, nil
+}
+
+func emptyToNil(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -1,6 +1,20 @@
 package services
 
+import "fi.muni.cz/invenio-file-processor/v2/config"
+
+// Size in bytes is optional, workflows with size bounds do not accept files without it. Slot
+// names the input of a workflow with input slots the file is meant for.
 type File struct {
 	FileName string `json:"key"`
 	Mimetype string `json:"mimetype"`
+	Size     *int64 `json:"size,omitempty"`
+	Slot     string `json:"slot,omitempty"`
+}
+
+func (f File) Info() config.FileInfo {
+	return config.FileInfo{
+		Name:     f.FileName,
+		Mimetype: f.Mimetype,
+		Size:     f.Size,
+	}
 }
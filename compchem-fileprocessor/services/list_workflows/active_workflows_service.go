@@ -3,11 +3,13 @@ package list_workflows
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
 
+	"fi.muni.cz/invenio-file-processor/v2/api/argodtos"
 	"fi.muni.cz/invenio-file-processor/v2/httpclient"
 	repository_common "fi.muni.cz/invenio-file-processor/v2/repository/common"
 	"fi.muni.cz/invenio-file-processor/v2/repository/file_repository"
@@ -16,15 +18,31 @@ import (
 )
 
 type WorkflowWithFiles struct {
-	Workflow WorkflowWithStatus `json:"workflow"`
-	Files    []string           `json:"files"`
+	Workflow   WorkflowWithStatus `json:"workflow"`
+	Files      []string           `json:"files"`
+	Submission *SubmissionState   `json:"submission,omitempty"`
 }
 
 type WorkflowStatus struct {
-	Phase      string `json:"phase"`
-	StartedAt  string `json:"startedAt"`
-	FinishedAt string `json:"finishedAt"`
-	Progress   string `json:"progress"`
+	Phase       string       `json:"phase"`
+	StartedAt   string       `json:"startedAt"`
+	FinishedAt  string       `json:"finishedAt"`
+	Progress    string       `json:"progress"`
+	Message     string       `json:"message,omitempty"`
+	FailedNodes []FailedNode `json:"failedNodes,omitempty"`
+	OutputFiles []string     `json:"outputFiles,omitempty"`
+}
+
+// Node of the workflow which failed, as reported by the exit handler from workflow.failures. File
+// is set for branches of fanned out workflows processing a single input file.
+type FailedNode struct {
+	DisplayName  string `json:"displayName"`
+	TemplateName string `json:"templateName,omitempty"`
+	Phase        string `json:"phase"`
+	Message      string `json:"message,omitempty"`
+	PodName      string `json:"podName,omitempty"`
+	FinishedAt   string `json:"finishedAt,omitempty"`
+	File         string `json:"file,omitempty"`
 }
 
 type WorkflowWithStatus struct {
@@ -34,11 +52,13 @@ type WorkflowWithStatus struct {
 
 type WorkflowMetadata struct {
 	Name string `json:"name"`
+	Uid  string `json:"uid,omitempty"`
 }
 
 type ArgoWorkflowsResponse struct {
-	Items    []WorkflowWithStatus `json:"items"`
-	Metadata ListMetadata         `json:"metadata"`
+	Items       []WorkflowWithStatus  `json:"items"`
+	Metadata    ListMetadata          `json:"metadata"`
+	Unsubmitted []UnsubmittedWorkflow `json:"unsubmitted"`
 }
 
 type ListMetadata struct {
@@ -61,13 +81,9 @@ func GetWorkflowDetailed(
 	pool *pgxpool.Pool,
 	argoUrl string,
 	namespace string,
+	instance string,
 	workflowFullName string,
 ) (*WorkflowWithFiles, error) {
-	workflow, err := getSingleWorkflow(ctx, logger, argoUrl, namespace, workflowFullName, true)
-	if err != nil {
-		return nil, err
-	}
-
 	tx, err := pool.Begin(ctx)
 	if err != nil {
 		logger.Error(
@@ -93,6 +109,32 @@ func GetWorkflowDetailed(
 		return nil, err
 	}
 
+	var submission *SubmissionState
+	selector := argodtos.BuildLabelSelector(
+		instance,
+		argodtos.LabelRecordId, recordId,
+		argodtos.LabelWorkflowConfig, workflowName,
+		argodtos.LabelSequenceId, strconv.FormatUint(workflowSeq, 10),
+	)
+	namespace, err = storedNamespace(ctx, logger, tx, workflowFullName, namespace)
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+	workflow, err := getSingleWorkflow(ctx, logger, argoUrl, namespace, selector, true)
+	if err == nil {
+		err = RecordWorkflowStates(ctx, logger, tx, []WorkflowWithStatus{*workflow})
+		if err == nil {
+			err = addReportedOutcome(ctx, logger, tx, workflow)
+		}
+	} else if isNotFound(err) {
+		workflow, submission, err = getStoredWorkflow(ctx, logger, tx, workflowFullName, err)
+	}
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+
 	files, err := file_repository.FindFilesForWorkflow(
 		ctx,
 		logger,
@@ -112,8 +154,9 @@ func GetWorkflowDetailed(
 	}
 
 	return &WorkflowWithFiles{
-		Workflow: *workflow,
-		Files:    files,
+		Workflow:   *workflow,
+		Files:      files,
+		Submission: submission,
 	}, nil
 }
 
@@ -128,92 +171,98 @@ func getWorkflowIdentifiers(parts []string) (string, string, uint64, error) {
 	return wfName, recordId, seqId, nil
 }
 
+// Workflows are served from the database, their state is kept in sync with argo by the workflow
+// watcher so listing does not need to reach argo.
 func GetWorkflowsForRecord(
 	ctx context.Context,
 	logger *zap.Logger,
-	argoUrl string,
-	namespace string,
+	pool *pgxpool.Pool,
 	recordId string,
 	limit int,
 	skip int,
 	statusFilter []Status,
 ) (*ArgoWorkflowsResponse, error) {
-	url := createUrlWithQuery(argoUrl, namespace, recordId, limit, skip, statusFilter)
-	workflows, err := httpclient.GetRequest[ArgoWorkflowsResponse](ctx, logger, url, true)
+	tx, err := pool.Begin(ctx)
 	if err != nil {
 		logger.Error(
-			"error when fetching workflows from argo",
-			zap.String("url", url),
+			"error when starting tx for listing workflows",
 			zap.String("recordId", recordId),
 			zap.Error(err),
 		)
 		return nil, err
 	}
 
-	if workflows.Items == nil {
-		workflows.Items = []WorkflowWithStatus{}
+	items, continueToken, err := getStoredWorkflows(
+		ctx,
+		logger,
+		tx,
+		recordId,
+		limit,
+		skip,
+		statusFilter,
+	)
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+
+	workflows := ArgoWorkflowsResponse{
+		Items:       items,
+		Metadata:    ListMetadata{Continue: continueToken},
+		Unsubmitted: []UnsubmittedWorkflow{},
+	}
+
+	if skip == 0 {
+		workflows.Unsubmitted, err = getUnsubmittedWorkflows(ctx, logger, tx, recordId)
+		if err != nil {
+			tx.Rollback(ctx)
+			return nil, err
+		}
+	}
+
+	err = repository_common.CommitTx(ctx, tx, logger)
+	if err != nil {
+		return nil, err
 	}
 
 	return &workflows, nil
 }
 
+// Workflows are selected by their labels so that workflows of other services sharing the
+// namespace are never returned, not found is reported the same way argo does.
 func getSingleWorkflow(
 	ctx context.Context,
 	logger *zap.Logger,
 	argoUrl string,
 	namespace string,
-	workflowName string,
+	labelSelector string,
 	ignoreTls bool,
 ) (*WorkflowWithStatus, error) {
-	url := createSingleWorkflowUrl(argoUrl, namespace, workflowName)
-	workflow, err := httpclient.GetRequest[WorkflowWithStatus](ctx, logger, url, ignoreTls)
+	url := createSelectorUrl(argoUrl, namespace, labelSelector)
+	workflows, err := httpclient.GetRequest[ArgoWorkflowsResponse](ctx, logger, url, ignoreTls)
 	if err != nil {
 		logger.Error("error when retrieving argo workflow", zap.String("url", url), zap.Error(err))
 		return nil, err
 	}
 
-	return &workflow, nil
-}
+	if len(workflows.Items) == 0 {
+		return nil, &httpclient.ClientError{
+			Status:  http.StatusNotFound,
+			Message: fmt.Sprintf("no workflow matches selector %s", labelSelector),
+		}
+	}
 
-func createSingleWorkflowUrl(
-	argoUrl string,
-	namespace string,
-	workflowName string,
-) string {
-	return fmt.Sprintf("%s/api/v1/workflows/%s/%s", argoUrl, namespace, workflowName)
+	return &workflows.Items[0], nil
 }
 
-// TODO: add tests!!!
-func createUrlWithQuery(
+func createSelectorUrl(
 	argoUrl string,
 	namespace string,
-	recordId string,
-	limit int,
-	skip int,
-	statusFilter []Status,
+	labelSelector string,
 ) string {
 	params := url.Values{}
-
-	fields := "metadata,items.metadata.uid,items.metadata.name,items.metadata.namespace,items.metadata.creationTimestamp,items.metadata.labels,items.metadata.annotations,items.status.phase,items.status.message,items.status.finishedAt,items.status.startedAt,items.status.estimatedDuration,items.status.progress,items.spec.suspend"
-
-	params.Set("listOptions.limit", strconv.Itoa(limit))
-	params.Set("fields", fields)
-	params.Set("nameFilter", "Contains")
-	params.Set("listOptions.fieldSelector", fmt.Sprintf("metadata.name=%s", recordId))
-	if skip > 0 {
-		params.Set("listOptions.continue", strconv.Itoa(skip))
-	}
-
-	if len(statusFilter) > 0 {
-		statusValues := make([]string, len(statusFilter))
-		for i, s := range statusFilter {
-			statusValues[i] = string(s)
-		}
-		params.Set(
-			"listOptions.labelSelector",
-			fmt.Sprintf("workflows.argoproj.io/phase in (%s)", strings.Join(statusValues, ",")),
-		)
-	}
+	params.Set("listOptions.labelSelector", labelSelector)
+	params.Set("listOptions.limit", "1")
 
 	return fmt.Sprintf("%s/api/v1/workflows/%s?%s", argoUrl, namespace, params.Encode())
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 
 	repositorytest "fi.muni.cz/invenio-file-processor/v2/repository/test"
@@ -26,48 +27,28 @@ func (s *activeWorkflowServiceTestSuite) TearDownSuite() {
 	s.PostgresTestSuite.TearDownSuite()
 }
 
-func (s *activeWorkflowServiceTestSuite) TestListWorkflows_ReturnsFiveWorkflows_FiveWorkflowsInResult() {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		assert.Equal(s.T(), "GET", r.Method)
-
-		queryParams := r.URL.Query()
-
-		assert.Equal(s.T(), "5", queryParams.Get("listOptions.limit"))
-		assert.Equal(s.T(), "Contains", queryParams.Get("nameFilter"))
-		assert.Contains(s.T(), queryParams.Get("listOptions.fieldSelector"), "metadata.name=")
-
-		expectedFields := "fields=metadata,items.metadata.uid,items.metadata.name,items.metadata.namespace,items.metadata.creationTimestamp,items.metadata.labels,items.metadata.annotations,items.status.phase,items.status.message,items.status.finishedAt,items.status.startedAt,items.status.estimatedDuration,items.status.progress,items.spec.suspend"
-		assert.Equal(s.T(), expectedFields, "fields="+queryParams.Get("fields"))
-
-		labelSelector := queryParams.Get("listOptions.labelSelector")
-		if labelSelector != "" {
-			assert.Contains(s.T(), labelSelector, "workflows.argoproj.io/phase in")
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(service_test_resources.FIVE_WORKFLOWS_RESPONSE))
-	}))
-	defer server.Close()
+func (s *activeWorkflowServiceTestSuite) insertSubmittedWorkflows(recordId string, phases ...string) {
+	for i, phase := range phases {
+		_, err := s.Pool.Exec(s.Ctx, `
+			INSERT INTO compchem_workflow (
+			  record_id, workflow_name, workflow_record_seq_id, phase, progress, submitted_at, started_at
+			)
+			VALUES ($1, 'count-words', $2, $3, '1/3', now(), '2025-05-24T20:16:08Z')
+		`, recordId, i+1, phase)
+		assert.NoError(s.T(), err)
+	}
+}
 
+func (s *activeWorkflowServiceTestSuite) TestListWorkflows_SixWorkflowsStored_FirstPageWithContinue() {
 	ctx := context.Background()
 	logger := zap.NewNop()
-	namespace := "argo"
-	recordId := "p8175"
+	recordId := "ew6jd-p8175"
 	limit := 5
 	skip := 0
-	statusFilter := []Status{StateError, StateFailed, StatePending, StateRunning, StateSucceeded}
 
-	result, err := GetWorkflowsForRecord(
-		ctx,
-		logger,
-		server.URL,
-		namespace,
-		recordId,
-		limit,
-		skip,
-		statusFilter,
-	)
+	s.insertSubmittedWorkflows(recordId, "Succeeded", "Failed", "Error", "Running", "Pending", "Succeeded")
+
+	result, err := GetWorkflowsForRecord(ctx, logger, s.Pool, recordId, limit, skip, []Status{})
 
 	assert.NoError(s.T(), err)
 	assert.NotNil(s.T(), result)
@@ -75,136 +56,80 @@ func (s *activeWorkflowServiceTestSuite) TestListWorkflows_ReturnsFiveWorkflows_
 	assert.Equal(s.T(), "5", result.Metadata.Continue)
 
 	firstWorkflow := result.Items[0]
-	assert.Equal(s.T(), "count-words", firstWorkflow.Metadata.Name)
+	assert.Equal(s.T(), "count-words-ew6jd-p8175-6", firstWorkflow.Metadata.Name)
 	assert.Equal(s.T(), "Succeeded", firstWorkflow.Status.Phase)
 	assert.Equal(s.T(), "2025-05-24T20:16:08Z", firstWorkflow.Status.StartedAt)
-	assert.Equal(s.T(), "2025-05-24T20:16:38Z", firstWorkflow.Status.FinishedAt)
-	assert.Equal(s.T(), "3/3", firstWorkflow.Status.Progress)
+	assert.Empty(s.T(), firstWorkflow.Status.FinishedAt)
+	assert.Equal(s.T(), "1/3", firstWorkflow.Status.Progress)
 
 	lastWorkflow := result.Items[4]
-	assert.Equal(s.T(), "count-words-ew6jd-p8175-4", lastWorkflow.Metadata.Name)
+	assert.Equal(s.T(), "count-words-ew6jd-p8175-2", lastWorkflow.Metadata.Name)
 	assert.Equal(s.T(), "Failed", lastWorkflow.Status.Phase)
-	assert.Equal(s.T(), "2025-05-24T14:50:20Z", lastWorkflow.Status.StartedAt)
-	assert.Equal(s.T(), "2025-05-24T14:50:30Z", lastWorkflow.Status.FinishedAt)
-	assert.Equal(s.T(), "0/1", lastWorkflow.Status.Progress)
+
+	err = repositorytest.ClearTable(ctx, s.Pool, "compchem_workflow")
+	assert.NoError(s.T(), err)
 }
 
 func (s *activeWorkflowServiceTestSuite) TestListWorkflows_WithContinue_SuccessfullyTraversesPages() {
-	callCount := 0
-
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		assert.Equal(s.T(), "GET", r.Method)
-
-		queryParams := r.URL.Query()
-
-		assert.Equal(s.T(), "5", queryParams.Get("listOptions.limit"))
-		assert.Equal(s.T(), "Contains", queryParams.Get("nameFilter"))
-
-		fieldSelector := queryParams.Get("listOptions.fieldSelector")
-		assert.Contains(s.T(), fieldSelector, "metadata.name=ew6jd-p8175")
-		assert.Contains(s.T(), fieldSelector, "metadata.name=")
-
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-
-		if callCount == 0 {
-			continueParam := queryParams.Get("listOptions.continue")
-			assert.Equal(s.T(), "5", continueParam)
-			w.Write([]byte(service_test_resources.FIRST_PAGE_RESPONSE))
-			callCount++
-		} else {
-			s.T().Fatalf("Unexpected call to server, callCount: %d", callCount)
-		}
-	}))
-	defer server.Close()
-
 	ctx := context.Background()
 	logger := zap.NewNop()
-	namespace := "argo"
 	recordId := "ew6jd-p8175"
 	limit := 5
-	skip := 5
-	statusFilter := []Status{StateError, StateFailed, StateSucceeded, StateRunning, StatePending}
 
-	result, err := GetWorkflowsForRecord(
-		ctx,
-		logger,
-		server.URL,
-		namespace,
-		recordId,
-		limit,
-		skip,
-		statusFilter,
-	)
+	s.insertSubmittedWorkflows(recordId, "Succeeded", "Failed", "Error", "Running", "Pending", "Succeeded")
 
+	result, err := GetWorkflowsForRecord(ctx, logger, s.Pool, recordId, limit, 0, []Status{})
 	assert.NoError(s.T(), err)
-	assert.NotNil(s.T(), result)
-	assert.Len(s.T(), result.Items, 5)
-	assert.Equal(s.T(), "5", result.Metadata.Continue)
-
-	firstWorkflow := result.Items[0]
-	assert.Equal(s.T(), "count-words", firstWorkflow.Metadata.Name)
-	assert.Equal(s.T(), "Succeeded", firstWorkflow.Status.Phase)
-
-	secondWorkflow := result.Items[1]
-	assert.Equal(s.T(), "count-words-ew6jd-p8175-9", secondWorkflow.Metadata.Name)
-	assert.Contains(s.T(), secondWorkflow.Metadata.Name, recordId)
-
-	callCount = 0
-	server2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		queryParams := r.URL.Query()
-
-		continueParam := queryParams.Get("listOptions.continue")
-		assert.Empty(s.T(), continueParam)
-
-		fieldSelector := queryParams.Get("listOptions.fieldSelector")
-		assert.Contains(s.T(), fieldSelector, "metadata.name=ew6jd-p8175")
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(service_test_resources.SECOND_PAGE_RESPONSE))
-	}))
-	defer server2.Close()
+	next, err := strconv.Atoi(result.Metadata.Continue)
+	assert.NoError(s.T(), err)
 
-	result2, err2 := GetWorkflowsForRecord(
-		ctx,
-		logger,
-		server2.URL,
-		namespace,
-		recordId,
-		limit,
-		0, statusFilter,
-	)
+	result2, err2 := GetWorkflowsForRecord(ctx, logger, s.Pool, recordId, limit, next, []Status{})
 
 	assert.NoError(s.T(), err2)
 	assert.NotNil(s.T(), result2)
 	assert.Len(s.T(), result2.Items, 1)
 	assert.Empty(s.T(), result2.Metadata.Continue)
-	lastPageWorkflow := result2.Items[0]
-	assert.Contains(s.T(), lastPageWorkflow.Metadata.Name, recordId)
+	assert.Equal(s.T(), "count-words-ew6jd-p8175-1", result2.Items[0].Metadata.Name)
+	assert.Empty(s.T(), result2.Unsubmitted, "unsubmitted workflows are only on the first page")
+
+	err = repositorytest.ClearTable(ctx, s.Pool, "compchem_workflow")
+	assert.NoError(s.T(), err)
 }
 
-func (s *activeWorkflowServiceTestSuite) TestListWorkflows_NoneReturned_EmptyResultNoErr() {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		assert.Equal(s.T(), "GET", r.Method)
+func (s *activeWorkflowServiceTestSuite) TestListWorkflows_StatusFilter_OnlyMatchingReturned() {
+	ctx := context.Background()
+	recordId := "ew6jd-p8175"
 
-		queryParams := r.URL.Query()
+	s.insertSubmittedWorkflows(recordId, "Succeeded", "Failed", "Running")
+	_, err := s.Pool.Exec(s.Ctx, `
+			INSERT INTO compchem_workflow (record_id, workflow_name, workflow_record_seq_id)
+			VALUES ($1, 'count-words', 4)
+		`, recordId)
+	assert.NoError(s.T(), err)
 
-		assert.Equal(s.T(), "10", queryParams.Get("listOptions.limit"))
-		assert.Equal(s.T(), "Contains", queryParams.Get("nameFilter"))
+	result, err := GetWorkflowsForRecord(
+		ctx,
+		zap.NewNop(),
+		s.Pool,
+		recordId,
+		10,
+		0,
+		[]Status{StateSucceeded, StateFailed},
+	)
 
-		fieldSelector := queryParams.Get("listOptions.fieldSelector")
-		assert.Contains(s.T(), fieldSelector, "metadata.name=nonexistent-record")
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), result.Items, 2)
+	assert.Equal(s.T(), "count-words-ew6jd-p8175-2", result.Items[0].Metadata.Name)
+	assert.Equal(s.T(), "count-words-ew6jd-p8175-1", result.Items[1].Metadata.Name)
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(service_test_resources.EMPTY_RESPONSE))
-	}))
-	defer server.Close()
+	err = repositorytest.ClearTable(ctx, s.Pool, "compchem_workflow")
+	assert.NoError(s.T(), err)
+}
 
+func (s *activeWorkflowServiceTestSuite) TestListWorkflows_NoneReturned_EmptyResultNoErr() {
 	ctx := context.Background()
 	logger := zap.NewNop()
-	namespace := "argo"
 	recordId := "nonexistent-record"
 	limit := 10
 	skip := 0
@@ -213,8 +138,7 @@ func (s *activeWorkflowServiceTestSuite) TestListWorkflows_NoneReturned_EmptyRes
 	result, err := GetWorkflowsForRecord(
 		ctx,
 		logger,
-		server.URL,
-		namespace,
+		s.Pool,
 		recordId,
 		limit,
 		skip,
@@ -226,6 +150,8 @@ func (s *activeWorkflowServiceTestSuite) TestListWorkflows_NoneReturned_EmptyRes
 	assert.NotNil(s.T(), result.Items)
 	assert.Len(s.T(), result.Items, 0)
 	assert.Empty(s.T(), result.Metadata.Continue)
+	assert.NotNil(s.T(), result.Unsubmitted)
+	assert.Len(s.T(), result.Unsubmitted, 0)
 }
 
 func (s *activeWorkflowServiceTestSuite) TestGetWorkflowDetail_WorkflowExists_ReturnsWorkflow() {
@@ -233,11 +159,16 @@ func (s *activeWorkflowServiceTestSuite) TestGetWorkflowDetail_WorkflowExists_Re
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		assert.Equal(s.T(), "GET", r.Method)
-		assert.Equal(s.T(), "/api/v1/workflows/argo/count-words-ew6jd-p8175-9", r.URL.Path)
+		assert.Equal(s.T(), "/api/v1/workflows/argo", r.URL.Path)
+		assert.Equal(
+			s.T(),
+			"compchem.cerit.io/instance=compchem-test,compchem.cerit.io/record-id=ew6jd-p8175,compchem.cerit.io/workflow-config=count-words,compchem.cerit.io/sequence-id=9",
+			r.URL.Query().Get("listOptions.labelSelector"),
+		)
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(service_test_resources.SINGLE_WORKFLOW_RESPONSE))
+		w.Write([]byte(`{"items":[` + service_test_resources.SINGLE_WORKFLOW_RESPONSE + `]}`))
 	}))
 	defer server.Close()
 
@@ -282,6 +213,7 @@ func (s *activeWorkflowServiceTestSuite) TestGetWorkflowDetail_WorkflowExists_Re
 		s.Pool,
 		server.URL,
 		namespace,
+		"compchem-test",
 		workflowFullName,
 	)
 
@@ -297,6 +229,16 @@ func (s *activeWorkflowServiceTestSuite) TestGetWorkflowDetail_WorkflowExists_Re
 	assert.Len(s.T(), result.Files, 1)
 	assert.Contains(s.T(), result.Files, "test-cats.txt")
 
+	var phase, progress string
+	err = pool.QueryRow(
+		s.Ctx,
+		"SELECT phase, progress FROM compchem_workflow WHERE workflow_full_name = $1",
+		workflowFullName,
+	).Scan(&phase, &progress)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), "Succeeded", phase, "argo state should be stored")
+	assert.Equal(s.T(), "3/3", progress)
+
 	err = repositorytest.ClearTable(ctx, pool, "compchem_workflow_file")
 	assert.NoError(s.T(), err)
 	err = repositorytest.ClearTable(ctx, pool, "compchem_workflow")
@@ -308,11 +250,15 @@ func (s *activeWorkflowServiceTestSuite) TestGetWorkflowDetail_WorkflowExists_Re
 func (s *activeWorkflowServiceTestSuite) TestGetWorkflowDetail_WorkflowNotFound_ReturnsNothing() {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		assert.Equal(s.T(), "GET", r.Method)
-		assert.Equal(s.T(), "/api/v1/workflows/argo/does-not-exist-ew6jd-p8175-1", r.URL.Path)
+		assert.Contains(
+			s.T(),
+			r.URL.Query().Get("listOptions.labelSelector"),
+			"compchem.cerit.io/workflow-config=does-not-exist",
+		)
 
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte(`{}`))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(service_test_resources.EMPTY_RESPONSE))
 	}))
 	defer server.Close()
 
@@ -327,6 +273,7 @@ func (s *activeWorkflowServiceTestSuite) TestGetWorkflowDetail_WorkflowNotFound_
 		s.Pool,
 		server.URL,
 		namespace,
+		"compchem-test",
 		workflowFullName,
 	)
 
@@ -334,6 +281,109 @@ func (s *activeWorkflowServiceTestSuite) TestGetWorkflowDetail_WorkflowNotFound_
 	assert.Nil(s.T(), result)
 }
 
+func (s *activeWorkflowServiceTestSuite) TestGetWorkflowDetail_WorkflowNeverSubmitted_ReturnsSubmissionState() {
+	pool := s.Pool
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Contains(
+			s.T(),
+			r.URL.Query().Get("listOptions.labelSelector"),
+			"compchem.cerit.io/sequence-id=3",
+		)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(service_test_resources.EMPTY_RESPONSE))
+	}))
+	defer server.Close()
+
+	_, err := pool.Exec(s.Ctx, `
+			INSERT INTO compchem_workflow (record_id, workflow_name, workflow_record_seq_id, phase)
+			VALUES ('ew6jd-p8175', 'count-words', 3, 'SubmissionFailed')
+		`)
+	assert.NoError(s.T(), err)
+
+	_, err = pool.Exec(s.Ctx, `
+			INSERT INTO compchem_workflow_submission (compchem_workflow_id, workflow, status, attempts, last_error)
+			SELECT w.id, '{}', 'failed', 10, 'argo unavailable'
+			FROM compchem_workflow w
+			WHERE w.record_id = 'ew6jd-p8175' AND w.workflow_record_seq_id = 3
+		`)
+	assert.NoError(s.T(), err)
+
+	ctx := context.Background()
+
+	result, err := GetWorkflowDetailed(
+		ctx,
+		zap.NewNop(),
+		pool,
+		server.URL,
+		"argo",
+		"compchem-test",
+		"count-words-ew6jd-p8175-3",
+	)
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), "count-words-ew6jd-p8175-3", result.Workflow.Metadata.Name)
+	assert.Equal(s.T(), string(StateSubmissionFailed), result.Workflow.Status.Phase)
+	assert.NotNil(s.T(), result.Submission)
+	assert.Equal(s.T(), 10, result.Submission.Attempts)
+	assert.Equal(s.T(), "argo unavailable", result.Submission.LastError)
+	assert.Empty(s.T(), result.Files)
+
+	err = repositorytest.ClearTable(ctx, pool, "compchem_workflow_submission")
+	assert.NoError(s.T(), err)
+	err = repositorytest.ClearTable(ctx, pool, "compchem_workflow")
+	assert.NoError(s.T(), err)
+}
+
+func (s *activeWorkflowServiceTestSuite) TestGetWorkflowDetail_WorkflowGarbageCollected_ReturnsStoredState() {
+	pool := s.Pool
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(service_test_resources.EMPTY_RESPONSE))
+	}))
+	defer server.Close()
+
+	_, err := pool.Exec(s.Ctx, `
+			INSERT INTO compchem_workflow (
+			  record_id, workflow_name, workflow_record_seq_id, phase, argo_uid, progress,
+			  submitted_at, started_at, finished_at
+			)
+			VALUES (
+			  'ew6jd-p8175', 'count-words', 5, 'Succeeded', 'a1b2c3', '3/3',
+			  '2025-05-24T15:15:40Z', '2025-05-24T15:15:41Z', '2025-05-24T15:16:11Z'
+			)
+		`)
+	assert.NoError(s.T(), err)
+
+	ctx := context.Background()
+
+	result, err := GetWorkflowDetailed(
+		ctx,
+		zap.NewNop(),
+		pool,
+		server.URL,
+		"argo",
+		"compchem-test",
+		"count-words-ew6jd-p8175-5",
+	)
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), "count-words-ew6jd-p8175-5", result.Workflow.Metadata.Name)
+	assert.Equal(s.T(), "a1b2c3", result.Workflow.Metadata.Uid)
+	assert.Equal(s.T(), "Succeeded", result.Workflow.Status.Phase)
+	assert.Equal(s.T(), "2025-05-24T15:15:41Z", result.Workflow.Status.StartedAt)
+	assert.Equal(s.T(), "2025-05-24T15:16:11Z", result.Workflow.Status.FinishedAt)
+	assert.Equal(s.T(), "3/3", result.Workflow.Status.Progress)
+	assert.Nil(s.T(), result.Submission)
+
+	err = repositorytest.ClearTable(ctx, pool, "compchem_workflow")
+	assert.NoError(s.T(), err)
+}
+
 func TestActiveWorkflowsService(t *testing.T) {
 	suite.Run(t, new(activeWorkflowServiceTestSuite))
 }
@@ -1,6 +1,8 @@
 package list_workflows
 
 import (
+	"slices"
+
 	"fi.muni.cz/invenio-file-processor/v2/config"
 	"fi.muni.cz/invenio-file-processor/v2/services"
 	"go.uber.org/zap"
@@ -10,14 +12,44 @@ type AvailableWorkflowsRequest struct {
 	Files []services.File `json:"files"`
 }
 
+// Workflows without any eligible file or with input slots the files can not fill are listed as
+// unavailable with the reason for every file and slot.
 type AvailableWorkflowsResponse struct {
-	Workflows []AvailableWorkflow `json:"workflows"`
+	Workflows   []AvailableWorkflow   `json:"workflows"`
+	Unavailable []UnavailableWorkflow `json:"unavailable,omitempty"`
 }
 
+// Parameters the workflow accepts, keyed by processing template name. Rejected are the files of the request
+// the workflow does not accept.
 type AvailableWorkflow struct {
-	Name     string   `json:"name"`
-	Mimetype string   `json:"mimetype"`
-	Files    []string `json:"files"`
+	Name       string                                `json:"name"`
+	Mimetype   string                                `json:"mimetype"`
+	Files      []string                              `json:"files"`
+	Inputs     []AvailableInput                      `json:"inputs,omitempty"`
+	Rejected   []RejectedFile                        `json:"rejected,omitempty"`
+	Parameters map[string][]config.TemplateParameter `json:"parameters,omitempty"`
+}
+
+type UnavailableWorkflow struct {
+	Name     string           `json:"name"`
+	Inputs   []AvailableInput `json:"inputs,omitempty"`
+	Rejected []RejectedFile   `json:"rejected"`
+}
+
+// Files the input slot would get when the workflow is started with all eligible files, reason
+// explains why the slot can not be satisfied.
+type AvailableInput struct {
+	Name      string   `json:"name"`
+	MinFiles  int      `json:"minFiles"`
+	MaxFiles  int      `json:"maxFiles,omitempty"`
+	Files     []string `json:"files"`
+	Satisfied bool     `json:"satisfied"`
+	Reason    string   `json:"reason,omitempty"`
+}
+
+type RejectedFile struct {
+	File   string `json:"file"`
+	Reason string `json:"reason"`
 }
 
 func AvailableWorkflows(
@@ -25,39 +57,110 @@ func AvailableWorkflows(
 	request *AvailableWorkflowsRequest,
 	configs []config.WorkflowConfig,
 ) *AvailableWorkflowsResponse {
-	fileMap := convertRequestToMap(request)
-
-	return convertMapToAvailableWorkflows(fileMap, configs)
-}
+	response := &AvailableWorkflowsResponse{
+		Workflows: []AvailableWorkflow{},
+	}
 
-func convertMapToAvailableWorkflows(
-	mimeTypeMap map[string][]string,
-	configs []config.WorkflowConfig,
-) *AvailableWorkflowsResponse {
-	workflows := []AvailableWorkflow{}
 	for _, workflow := range configs {
-		if eligibleFiles, isPresent := mimeTypeMap[workflow.Mimetype]; isPresent {
-			workflows = append(workflows, AvailableWorkflow{
+		eligibleFiles, rejected := matchFiles(workflow, request.Files)
+		inputs, unassigned, satisfied := matchInputs(workflow, request.Files, eligibleFiles)
+		rejected = append(rejected, unassigned...)
+		eligibleFiles = slices.DeleteFunc(eligibleFiles, func(file string) bool {
+			return slices.ContainsFunc(unassigned, func(r RejectedFile) bool { return r.File == file })
+		})
+		if len(eligibleFiles) == 0 || !satisfied {
+			response.Unavailable = append(response.Unavailable, UnavailableWorkflow{
 				Name:     workflow.Name,
-				Mimetype: workflow.Mimetype,
-				Files:    eligibleFiles,
+				Inputs:   inputs,
+				Rejected: rejected,
 			})
+			continue
+		}
+
+		response.Workflows = append(response.Workflows, AvailableWorkflow{
+			Name:       workflow.Name,
+			Mimetype:   workflow.Mimetype,
+			Files:      eligibleFiles,
+			Inputs:     inputs,
+			Rejected:   rejected,
+			Parameters: templateParameters(workflow),
+		})
+	}
+
+	return response
+}
+
+// Assigns eligible files to the input slots the same way starting the workflow does. Returns the
+// files no slot takes and whether all slots are satisfied.
+func matchInputs(
+	workflow config.WorkflowConfig,
+	files []services.File,
+	eligibleFiles []string,
+) ([]AvailableInput, []RejectedFile, bool) {
+	if len(workflow.Inputs) == 0 {
+		return nil, nil, true
+	}
+
+	candidates := []config.FileInfo{}
+	for _, file := range files {
+		if slices.Contains(eligibleFiles, file.FileName) {
+			candidates = append(candidates, file.Info())
 		}
 	}
+	assigned, errs := workflow.AssignInputs(candidates, nil)
 
-	return &AvailableWorkflowsResponse{
-		Workflows: workflows,
+	unassigned := []RejectedFile{}
+	for _, file := range candidates {
+		if reason, present := errs[file.Name]; present {
+			unassigned = append(unassigned, RejectedFile{File: file.Name, Reason: reason})
+		}
 	}
+
+	result := []AvailableInput{}
+	satisfied := true
+	for _, slot := range workflow.Inputs {
+		reason := errs[slot.Name]
+		satisfied = satisfied && reason == ""
+		slotFiles := assigned[slot.Name]
+		if slotFiles == nil {
+			slotFiles = []string{}
+		}
+		result = append(result, AvailableInput{
+			Name:      slot.Name,
+			MinFiles:  slot.Min(),
+			MaxFiles:  slot.MaxFiles,
+			Files:     slotFiles,
+			Satisfied: reason == "",
+			Reason:    reason,
+		})
+	}
+
+	return result, unassigned, satisfied
 }
 
-func convertRequestToMap(request *AvailableWorkflowsRequest) map[string][]string {
-	result := make(map[string][]string)
+func matchFiles(
+	workflow config.WorkflowConfig,
+	files []services.File,
+) ([]string, []RejectedFile) {
+	eligibleFiles := []string{}
+	rejected := []RejectedFile{}
 
-	for _, file := range request.Files {
-		if filesForType, isPresent := result[file.Mimetype]; isPresent {
-			result[file.Mimetype] = append(filesForType, file.FileName)
+	for _, file := range files {
+		if err := workflow.MatchFile(file.Info()); err != nil {
+			rejected = append(rejected, RejectedFile{File: file.FileName, Reason: err.Error()})
 		} else {
-			result[file.Mimetype] = []string{file.FileName}
+			eligibleFiles = append(eligibleFiles, file.FileName)
+		}
+	}
+
+	return eligibleFiles, rejected
+}
+
+func templateParameters(workflow config.WorkflowConfig) map[string][]config.TemplateParameter {
+	result := make(map[string][]config.TemplateParameter)
+	for _, template := range workflow.ProcessingTemplates {
+		if len(template.Parameters) > 0 {
+			result[template.Name] = template.Parameters
 		}
 	}
 
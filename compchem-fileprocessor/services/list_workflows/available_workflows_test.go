@@ -9,86 +9,104 @@ import (
 	"go.uber.org/zap"
 )
 
-func TestConvertRequest_ThreeMimeTypes_ThreeMapKeys(t *testing.T) {
-	TEXT_PLAIN := "text/plain"
-	JSON := "application/json"
-	IMAGE := "image/jpeg"
+func TestMatchFiles_MimetypeOnly_FilesSplitWithReasons(t *testing.T) {
+	workflow := config.WorkflowConfig{
+		Name:     "count-words",
+		Mimetype: "text/plain",
+	}
 
+	eligibleFiles, rejected := matchFiles(workflow, []services.File{
+		{FileName: "mytextfile.txt", Mimetype: "text/plain"},
+		{FileName: "mysecondtext.txt", Mimetype: "text/plain"},
+		{FileName: "mypdffile.txt", Mimetype: "application/pdf"},
+	})
+
+	assert.Equal(t, []string{"mytextfile.txt", "mysecondtext.txt"}, eligibleFiles)
+	assert.Equal(t, []RejectedFile{
+		{File: "mypdffile.txt", Reason: "mimetype application/pdf is not one of [text/plain]"},
+	}, rejected)
+}
+
+func TestAvailableWorkflows_MatchRule_SameMatchingAsStart(t *testing.T) {
 	request := AvailableWorkflowsRequest{
 		Files: []services.File{
-			{
-				FileName: "test1.txt",
-				Mimetype: TEXT_PLAIN,
-			},
-			{
-				FileName: "test2.txt",
-				Mimetype: TEXT_PLAIN,
-			},
-			{
-				FileName: "test.jpeg",
-				Mimetype: IMAGE,
-			},
-			{
-				FileName: "request.json",
-				Mimetype: JSON,
+			{FileName: "trajectory.tar.gz", Mimetype: "application/gzip"},
+			{FileName: "notes.txt", Mimetype: "text/plain"},
+		},
+	}
+	configs := []config.WorkflowConfig{
+		{
+			Name: "unpack",
+			Match: &config.MatchRule{
+				Mimetypes:  []string{"application/gzip", "application/x-gzip"},
+				Extensions: []string{"tar.gz", "tgz"},
 			},
 		},
+		{
+			Name:      "count-words",
+			Mimetype:  "text/markdown",
+			Extension: "md",
+		},
 	}
 
-	mimetypeMap := convertRequestToMap(&request)
+	response := AvailableWorkflows(zap.NewNop(), &request, configs)
 
-	assert.Equal(t, len(mimetypeMap), len(mimetypeMap))
-	assert.Equal(t, len(mimetypeMap[TEXT_PLAIN]), len(mimetypeMap[TEXT_PLAIN]))
-	assert.Equal(t, len(mimetypeMap[IMAGE]), len(mimetypeMap[IMAGE]))
-	assert.Equal(t, len(mimetypeMap[JSON]), len(mimetypeMap[JSON]))
+	assert.Len(t, response.Workflows, 1)
+	assert.Equal(t, "unpack", response.Workflows[0].Name)
+	assert.Equal(t, []string{"trajectory.tar.gz"}, response.Workflows[0].Files)
+	assert.Len(t, response.Workflows[0].Rejected, 1)
+	assert.Equal(t, "notes.txt", response.Workflows[0].Rejected[0].File)
 
-	assert.Contains(t, mimetypeMap[TEXT_PLAIN], "test1.txt")
-	assert.Contains(t, mimetypeMap[TEXT_PLAIN], "test2.txt")
-	assert.Contains(t, mimetypeMap[IMAGE], "test.jpeg")
-	assert.Contains(t, mimetypeMap[JSON], "request.json")
+	assert.Len(t, response.Unavailable, 1)
+	assert.Equal(t, "count-words", response.Unavailable[0].Name)
+	assert.Len(t, response.Unavailable[0].Rejected, 2)
 }
 
-func TestCreateResponse_TwoConfigs_CorrectResponse(t *testing.T) {
-	configs := []config.WorkflowConfig{
-		{
-			Name:     "count-words",
-			Mimetype: "text/plain",
-			ProcessingTemplates: []config.ProcessingTemplate{
-				{
-					Name:     "count-words",
-					Template: "count-words-template",
-				},
-			},
+func TestAvailableWorkflows_InputSlots_SatisfiedSlotsReported(t *testing.T) {
+	request := AvailableWorkflowsRequest{
+		Files: []services.File{
+			{FileName: "run.tpr", Mimetype: "application/octet-stream"},
+			{FileName: "traj.xtc", Mimetype: "application/octet-stream"},
 		},
+	}
+	inputs := []config.InputSlot{
+		{Name: "structure", Match: config.MatchRule{Extensions: []string{"tpr"}}},
+		{Name: "trajectory", Match: config.MatchRule{Extensions: []string{"xtc"}}},
+	}
+	configs := []config.WorkflowConfig{
+		{Name: "trajectory-analysis", Inputs: inputs},
 		{
-			Name:     "downsizeto480p",
-			Mimetype: "image/jpeg",
-			ProcessingTemplates: []config.ProcessingTemplate{
-				{
-					Name:     "downsizepng",
-					Template: "downsizepng-template",
-				},
-				{
-					Name:     "downsizejpeg",
-					Template: "downsizejpeg-template",
-				},
+			Name: "energy-analysis",
+			Inputs: []config.InputSlot{
+				inputs[0],
+				{Name: "energy", Match: config.MatchRule{Extensions: []string{"edr"}}},
 			},
 		},
 	}
 
-	mimetypeMap := map[string][]string{
-		"text/plain":      {"mytextfile.txt", "mysecondtext.txt"},
-		"application/pdf": {"mypdffile.txt"},
-	}
+	response := AvailableWorkflows(zap.NewNop(), &request, configs)
 
-	result := convertMapToAvailableWorkflows(mimetypeMap, configs)
+	assert.Len(t, response.Workflows, 1)
+	assert.Equal(t, []AvailableInput{
+		{Name: "structure", MinFiles: 1, Files: []string{"run.tpr"}, Satisfied: true},
+		{Name: "trajectory", MinFiles: 1, Files: []string{"traj.xtc"}, Satisfied: true},
+	}, response.Workflows[0].Inputs)
 
-	// only for txt
-	assert.Len(t, result.Workflows, 1)
-	assert.Len(t, result.Workflows[0].Files, 2)
-	assert.Contains(t, result.Workflows[0].Files, "mytextfile.txt")
-	assert.Contains(t, result.Workflows[0].Files, "mysecondtext.txt")
-	assert.Equal(t, result.Workflows[0].Mimetype, "text/plain")
+	assert.Len(t, response.Unavailable, 1)
+	assert.Equal(t, "energy-analysis", response.Unavailable[0].Name)
+	assert.Equal(t, []AvailableInput{
+		{Name: "structure", MinFiles: 1, Files: []string{"run.tpr"}, Satisfied: true},
+		{
+			Name:      "energy",
+			MinFiles:  1,
+			Files:     []string{},
+			Satisfied: false,
+			Reason:    "requires at least 1 files, got 0",
+		},
+	}, response.Unavailable[0].Inputs)
+	assert.Equal(t, []RejectedFile{
+		{File: "traj.xtc", Reason: "not accepted by any input"},
+	}, response.Unavailable[0].Rejected)
 }
 
 func TestAvailableWorkflows_TwoConfigs_ReturnsCorrectResponse(t *testing.T) {
@@ -0,0 +1,109 @@
+package list_workflows
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"fi.muni.cz/invenio-file-processor/v2/api/argodtos"
+	"fi.muni.cz/invenio-file-processor/v2/httpclient"
+	"fi.muni.cz/invenio-file-processor/v2/repository/submission_repository"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	StateUnsubmitted      Status = "Unsubmitted"
+	StateSubmissionFailed Status = "SubmissionFailed"
+)
+
+type SubmissionState struct {
+	Status        string    `json:"status"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"lastError,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+	NextAttemptAt time.Time `json:"nextAttemptAt"`
+}
+
+type UnsubmittedWorkflow struct {
+	Name       string          `json:"name"`
+	Submission SubmissionState `json:"submission"`
+}
+
+func getUnsubmittedWorkflows(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	recordId string,
+) ([]UnsubmittedWorkflow, error) {
+	entities, err := submission_repository.FindUnsubmittedForRecord(ctx, logger, tx, recordId)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []UnsubmittedWorkflow{}
+	for _, entity := range entities {
+		result = append(result, UnsubmittedWorkflow{
+			Name: argodtos.ConstructFullWorkflowName(
+				entity.WorkflowName,
+				entity.RecordId,
+				entity.WorkflowSeqId,
+			),
+			Submission: SubmissionState{
+				Status:        string(entity.Status),
+				Attempts:      entity.Attempts,
+				LastError:     stringOrEmpty(entity.LastError),
+				CreatedAt:     entity.CreatedAt,
+				NextAttemptAt: entity.NextAttemptAt,
+			},
+		})
+	}
+
+	return result, nil
+}
+
+// Returns nil when the workflow was already handed over to argo.
+func getSubmissionState(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	workflowName string,
+	recordId string,
+	workflowSeq uint64,
+) (*SubmissionState, error) {
+	submission, err := submission_repository.FindSubmissionForWorkflow(
+		ctx,
+		logger,
+		tx,
+		recordId,
+		workflowName,
+		workflowSeq,
+	)
+	if err != nil || submission == nil {
+		return nil, err
+	}
+	if submission.Status == submission_repository.SubmissionSubmitted {
+		return nil, nil
+	}
+
+	return &SubmissionState{
+		Status:        string(submission.Status),
+		Attempts:      submission.Attempts,
+		LastError:     stringOrEmpty(submission.LastError),
+		CreatedAt:     submission.CreatedAt,
+		NextAttemptAt: submission.NextAttemptAt,
+	}, nil
+}
+
+func isNotFound(err error) bool {
+	var clientErr *httpclient.ClientError
+	return errors.As(err, &clientErr) && clientErr.Status == http.StatusNotFound
+}
+
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,99 @@
+package list_workflows
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	repository_common "fi.muni.cz/invenio-file-processor/v2/repository/common"
+	"fi.muni.cz/invenio-file-processor/v2/repository/snapshot_repository"
+	"fi.muni.cz/invenio-file-processor/v2/repository/workflow_repository"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+var ErrConfigNotRecorded = errors.New("workflow config snapshot not found")
+
+// Config is the effective workflow config written with the keys of the config file, config hash is
+// the sha256 of exactly this json.
+type WorkflowConfigSnapshot struct {
+	ConfigHash   string          `json:"configHash"`
+	WorkflowName string          `json:"workflowName"`
+	RecordedAt   time.Time       `json:"recordedAt"`
+	Config       json.RawMessage `json:"config"`
+}
+
+// Returns the config the workflow was started with, workflows started before configs were recorded
+// have none.
+func GetWorkflowConfig(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	workflowFullName string,
+) (*WorkflowConfigSnapshot, error) {
+	return readConfigSnapshot(ctx, logger, pool, func(tx pgx.Tx) (string, error) {
+		workflow, err := workflow_repository.FindWorkflowByFullName(ctx, logger, tx, workflowFullName)
+		if err != nil {
+			return "", err
+		}
+		if workflow == nil || workflow.ConfigHash == nil {
+			return "", ErrConfigNotRecorded
+		}
+
+		return *workflow.ConfigHash, nil
+	})
+}
+
+func GetConfigSnapshot(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	configHash string,
+) (*WorkflowConfigSnapshot, error) {
+	return readConfigSnapshot(ctx, logger, pool, func(tx pgx.Tx) (string, error) {
+		return configHash, nil
+	})
+}
+
+func readConfigSnapshot(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	findHash func(tx pgx.Tx) (string, error),
+) (*WorkflowConfigSnapshot, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		logger.Error("error when starting tx for reading workflow config", zap.Error(err))
+		return nil, err
+	}
+
+	configHash, err := findHash(tx)
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+
+	snapshot, err := snapshot_repository.FindConfigSnapshot(ctx, logger, tx, configHash)
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+	if snapshot == nil {
+		tx.Rollback(ctx)
+		return nil, ErrConfigNotRecorded
+	}
+
+	err = repository_common.CommitTx(ctx, tx, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WorkflowConfigSnapshot{
+		ConfigHash:   snapshot.ConfigHash,
+		WorkflowName: snapshot.WorkflowName,
+		RecordedAt:   snapshot.CreatedAt,
+		Config:       snapshot.Config,
+	}, nil
+}
@@ -0,0 +1,223 @@
+package list_workflows
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"fi.muni.cz/invenio-file-processor/v2/repository/workflow_repository"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// Stores the state of workflows answered by argo so it survives argo garbage collection,
+// workflows not created by this service are ignored.
+func RecordWorkflowStates(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	workflows []WorkflowWithStatus,
+) error {
+	for _, workflow := range workflows {
+		if workflow.Status.Phase == "" {
+			continue
+		}
+
+		_, err := workflow_repository.UpdateWorkflowState(
+			ctx,
+			logger,
+			tx,
+			workflow.Metadata.Name,
+			toStateUpdate(workflow),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns a page of workflows together with the skip of the next page, empty on the last page.
+func getStoredWorkflows(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	recordId string,
+	limit int,
+	skip int,
+	statusFilter []Status,
+) ([]WorkflowWithStatus, string, error) {
+	phases := make([]string, len(statusFilter))
+	for i, s := range statusFilter {
+		phases[i] = string(s)
+	}
+
+	entities, err := workflow_repository.FindSubmittedWorkflowsForRecord(
+		ctx,
+		logger,
+		tx,
+		recordId,
+		phases,
+		limit+1,
+		skip,
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	continueToken := ""
+	if len(entities) > limit {
+		entities = entities[:limit]
+		continueToken = strconv.Itoa(skip + limit)
+	}
+
+	result := []WorkflowWithStatus{}
+	for _, entity := range entities {
+		result = append(result, fromEntity(entity))
+	}
+
+	return result, continueToken, nil
+}
+
+// Answers detail of a workflow argo does not have, argoErr is returned when the workflow is not ours.
+func getStoredWorkflow(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	workflowFullName string,
+	argoErr error,
+) (*WorkflowWithStatus, *SubmissionState, error) {
+	entity, err := workflow_repository.FindWorkflowByFullName(ctx, logger, tx, workflowFullName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if entity == nil {
+		return nil, nil, argoErr
+	}
+
+	submission, err := getSubmissionState(
+		ctx,
+		logger,
+		tx,
+		entity.WorkflowName,
+		entity.RecordId,
+		entity.WorkflowSeqId,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	workflow := fromEntity(*entity)
+	return &workflow, submission, nil
+}
+
+func toStateUpdate(workflow WorkflowWithStatus) workflow_repository.WorkflowStateUpdate {
+	return workflow_repository.WorkflowStateUpdate{
+		Phase:      workflow.Status.Phase,
+		ArgoUid:    emptyToNil(workflow.Metadata.Uid),
+		Message:    emptyToNil(workflow.Status.Message),
+		Progress:   emptyToNil(workflow.Status.Progress),
+		StartedAt:  parseTime(workflow.Status.StartedAt),
+		FinishedAt: parseTime(workflow.Status.FinishedAt),
+	}
+}
+
+func fromEntity(entity workflow_repository.ExistingWorfklowEntity) WorkflowWithStatus {
+	return WorkflowWithStatus{
+		Metadata: WorkflowMetadata{
+			Name: entity.FullName,
+			Uid:  stringOrEmpty(entity.ArgoUid),
+		},
+		Status: WorkflowStatus{
+			Phase:       entity.Phase,
+			StartedAt:   formatTime(entity.StartedAt),
+			FinishedAt:  formatTime(entity.FinishedAt),
+			Progress:    stringOrEmpty(entity.Progress),
+			Message:     stringOrEmpty(entity.Message),
+			FailedNodes: parseFailedNodes(entity.FailedNodes),
+			OutputFiles: parseOutputFiles(entity.OutputFiles),
+		},
+	}
+}
+
+// Argo does not keep what the exit handler reported, failed nodes and outputs are taken from the
+// database.
+func addReportedOutcome(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	workflow *WorkflowWithStatus,
+) error {
+	entity, err := workflow_repository.FindWorkflowByFullName(ctx, logger, tx, workflow.Metadata.Name)
+	if err != nil || entity == nil {
+		return err
+	}
+
+	workflow.Status.FailedNodes = parseFailedNodes(entity.FailedNodes)
+	workflow.Status.OutputFiles = parseOutputFiles(entity.OutputFiles)
+	return nil
+}
+
+func parseFailedNodes(value []byte) []FailedNode {
+	if value == nil {
+		return nil
+	}
+
+	var nodes []FailedNode
+	if err := json.Unmarshal(value, &nodes); err != nil {
+		return nil
+	}
+	return nodes
+}
+
+func parseOutputFiles(value []byte) []string {
+	if value == nil {
+		return nil
+	}
+
+	var files []string
+	if err := json.Unmarshal(value, &files); err != nil {
+		return nil
+	}
+	return files
+}
+
+func parseTime(value string) *time.Time {
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil
+	}
+	return &parsed
+}
+
+func formatTime(value *time.Time) string {
+	if value == nil {
+		return ""
+	}
+	return value.UTC().Format(time.RFC3339)
+}
+
+func emptyToNil(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// Namespace the workflow was submitted to, the default one for workflows not stored yet.
+func storedNamespace(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	workflowFullName string,
+	defaultNamespace string,
+) (string, error) {
+	entity, err := workflow_repository.FindWorkflowByFullName(ctx, logger, tx, workflowFullName)
+	if err != nil || entity == nil {
+		return defaultNamespace, err
+	}
+
+	return entity.ArgoNamespace(defaultNamespace), nil
+}
@@ -0,0 +1,185 @@
+package reportoutcome_service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"fi.muni.cz/invenio-file-processor/v2/api/argodtos"
+	"fi.muni.cz/invenio-file-processor/v2/httpclient"
+	repository_common "fi.muni.cz/invenio-file-processor/v2/repository/common"
+	"fi.muni.cz/invenio-file-processor/v2/repository/workflow_repository"
+	"fi.muni.cz/invenio-file-processor/v2/services/list_workflows"
+	"fi.muni.cz/invenio-file-processor/v2/util"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// Unknown workflows are not distinguished from a wrong secret key, names are easy to guess.
+var ErrUnauthorized = errors.New("workflow not found or secret key invalid")
+
+// Sent by the exit handler of the workflow once it finished.
+type WorkflowOutcome struct {
+	Phase    string                      `json:"phase"`
+	Outputs  []string                    `json:"outputs"`
+	Failures []list_workflows.FailedNode `json:"failures"`
+}
+
+type outcomeNotification struct {
+	WorkflowName string                      `json:"workflowName"`
+	RecordId     string                      `json:"recordId"`
+	Phase        string                      `json:"phase"`
+	Outputs      []string                    `json:"outputs"`
+	Failures     []list_workflows.FailedNode `json:"failures"`
+}
+
+// Stores the outcome against the workflow authenticated by its secret key and, when enabled,
+// forwards it to compchem. Forwarding is best effort, the outcome is stored either way.
+func RecordOutcome(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	compchemUrl string,
+	notifyCompchem bool,
+	workflowFullName string,
+	secretKey string,
+	outcome WorkflowOutcome,
+) error {
+	if outcome.Outputs == nil {
+		outcome.Outputs = []string{}
+	}
+	outputFiles, err := json.Marshal(outcome.Outputs)
+	if err != nil {
+		return fmt.Errorf("Error when serializing output files: %v", err)
+	}
+
+	for i := range outcome.Failures {
+		outcome.Failures[i].File = argodtos.FanOutFile(outcome.Failures[i].DisplayName)
+	}
+
+	var failedNodes []byte
+	if len(outcome.Failures) > 0 {
+		failedNodes, err = json.Marshal(outcome.Failures)
+		if err != nil {
+			return fmt.Errorf("Error when serializing failed nodes: %v", err)
+		}
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		logger.Error(
+			"error when starting tx for recording workflow outcome",
+			zap.String("workflowName", workflowFullName),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	workflow, err := workflow_repository.FindWorkflowByFullName(ctx, logger, tx, workflowFullName)
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+	if workflow == nil || workflow.SecretKeyHash == nil ||
+		!util.SecretKeyMatches(secretKey, *workflow.SecretKeyHash) {
+		tx.Rollback(ctx)
+		return ErrUnauthorized
+	}
+
+	_, err = workflow_repository.RecordWorkflowOutcome(
+		ctx,
+		logger,
+		tx,
+		workflowFullName,
+		workflow_repository.WorkflowOutcome{
+			Phase:       outcome.Phase,
+			Message:     failureMessage(outcome.Failures),
+			FailedNodes: failedNodes,
+			OutputFiles: outputFiles,
+		},
+	)
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	err = repository_common.CommitTx(ctx, tx, logger)
+	if err != nil {
+		return err
+	}
+
+	logger.Info(
+		"Workflow reported its outcome",
+		zap.String("workflowName", workflowFullName),
+		zap.String("phase", outcome.Phase),
+		zap.Int("outputs", len(outcome.Outputs)),
+		zap.Int("failedNodes", len(outcome.Failures)),
+	)
+
+	if notifyCompchem {
+		forwardOutcome(ctx, logger, compchemUrl, workflow, secretKey, outcome)
+	}
+
+	return nil
+}
+
+// The exit handler revokes the workflow context only after reporting, so the secret key is still
+// accepted by compchem.
+func forwardOutcome(
+	ctx context.Context,
+	logger *zap.Logger,
+	compchemUrl string,
+	workflow *workflow_repository.ExistingWorfklowEntity,
+	secretKey string,
+	outcome WorkflowOutcome,
+) {
+	notificationUrl := fmt.Sprintf(
+		"%s/workflows/%s/outcome?secret_key=%s",
+		compchemUrl,
+		url.PathEscape(workflow.FullName),
+		url.QueryEscape(secretKey),
+	)
+
+	err := httpclient.PostRequestNoContent(ctx, logger, notificationUrl, outcomeNotification{
+		WorkflowName: workflow.FullName,
+		RecordId:     workflow.RecordId,
+		Phase:        outcome.Phase,
+		Outputs:      outcome.Outputs,
+		Failures:     outcome.Failures,
+	}, true)
+	if err != nil {
+		logger.Warn(
+			"Failed to forward workflow outcome to compchem",
+			zap.String("workflowName", workflow.FullName),
+			zap.Error(err),
+		)
+	}
+}
+
+// Summarizes failed nodes into the workflow message, fanned out branches are named by their
+// file. Nil keeps the message argo reported.
+func failureMessage(failures []list_workflows.FailedNode) *string {
+	if len(failures) == 0 {
+		return nil
+	}
+
+	messages := []string{}
+	for _, failure := range failures {
+		name := failure.DisplayName
+		if failure.File != "" {
+			name = failure.File
+		}
+
+		if failure.Message == "" {
+			messages = append(messages, name)
+		} else {
+			messages = append(messages, fmt.Sprintf("%s: %s", name, failure.Message))
+		}
+	}
+
+	message := strings.Join(messages, "; ")
+	return &message
+}
@@ -0,0 +1,236 @@
+package reportoutcome_service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	repository_common "fi.muni.cz/invenio-file-processor/v2/repository/common"
+	repositorytest "fi.muni.cz/invenio-file-processor/v2/repository/test"
+	"fi.muni.cz/invenio-file-processor/v2/repository/workflow_repository"
+	"fi.muni.cz/invenio-file-processor/v2/services/list_workflows"
+	"fi.muni.cz/invenio-file-processor/v2/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+func TestFailureMessage_NoFailures_Nil(t *testing.T) {
+	assert.Nil(t, failureMessage(nil))
+	assert.Nil(t, failureMessage([]list_workflows.FailedNode{}))
+}
+
+func TestFailureMessage_FannedOutFailure_NamedByFile(t *testing.T) {
+	message := failureMessage([]list_workflows.FailedNode{
+		{
+			DisplayName: "per-file-ej26y-ad28j-1(1:file:broken.tpr,index:1)",
+			Message:     "child 'simulation-annotation-ej26y-ad28j-1' failed",
+			File:        "broken.tpr",
+		},
+	})
+
+	assert.Equal(t, "broken.tpr: child 'simulation-annotation-ej26y-ad28j-1' failed", *message)
+}
+
+func TestFailureMessage_MultipleFailures_Joined(t *testing.T) {
+	message := failureMessage([]list_workflows.FailedNode{
+		{DisplayName: "simulation-annotation-ej26y-ad28j-1", Message: "Error (exit code 1)"},
+		{DisplayName: "write-files-ej26y-ad28j-1"},
+	})
+
+	assert.Equal(
+		t,
+		"simulation-annotation-ej26y-ad28j-1: Error (exit code 1); write-files-ej26y-ad28j-1",
+		*message,
+	)
+}
+
+func TestForwardOutcome_NameWithReservedCharacters_PathEscaped(t *testing.T) {
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.EscapedPath()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	workflow := &workflow_repository.ExistingWorfklowEntity{}
+	workflow.FullName = "count/words?1"
+
+	forwardOutcome(
+		context.Background(),
+		zap.NewNop(),
+		server.URL,
+		workflow,
+		"key",
+		WorkflowOutcome{Phase: "Succeeded"},
+	)
+
+	assert.Equal(t, "/workflows/count%2Fwords%3F1/outcome", path)
+}
+
+type reportOutcomeServiceTestSuite struct {
+	repositorytest.PostgresTestSuite
+}
+
+func (s *reportOutcomeServiceTestSuite) SetupSuite() {
+	s.PostgresTestSuite.MigratonsPath = "file://../../migrations"
+	s.PostgresTestSuite.SetupSuite()
+}
+
+func (s *reportOutcomeServiceTestSuite) TearDownSuite() {
+	s.PostgresTestSuite.TearDownSuite()
+}
+
+func (s *reportOutcomeServiceTestSuite) TearDownTest() {
+	assert.NoError(s.T(), repositorytest.ClearTable(s.Ctx, s.Pool, "compchem_workflow"))
+}
+
+func (s *reportOutcomeServiceTestSuite) createWorkflow() *workflow_repository.ExistingWorfklowEntity {
+	t := s.T()
+	tx, err := s.Pool.Begin(s.Ctx)
+	assert.NoError(t, err)
+
+	hash := util.HashSecretKey("mysecretkey")
+	wf, err := workflow_repository.CreateWorkflowForRecord(
+		s.Ctx,
+		s.Logger,
+		tx,
+		workflow_repository.WorkflowEntity{
+			RecordId:      "ej26y-ad28j",
+			WorkflowName:  "count-words",
+			WorkflowSeqId: 1,
+			SecretKeyHash: &hash,
+		},
+	)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit(s.Ctx))
+
+	return wf
+}
+
+func (s *reportOutcomeServiceTestSuite) getWorkflow(id uint64) *workflow_repository.ExistingWorfklowEntity {
+	workflow, err := repository_common.QueryOne[workflow_repository.ExistingWorfklowEntity](
+		s.Ctx,
+		s.Pool,
+		"SELECT * FROM compchem_workflow WHERE id = $1",
+		id,
+	)
+	assert.NoError(s.T(), err)
+
+	return workflow
+}
+
+func (s *reportOutcomeServiceTestSuite) TestRecordOutcome_ValidKey_StoredAndForwarded() {
+	t := s.T()
+	var forwarded outcomeNotification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/workflows/count-words-ej26y-ad28j-1/outcome", r.URL.Path)
+		assert.Equal(t, "mysecretkey", r.URL.Query().Get("secret_key"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&forwarded))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	wf := s.createWorkflow()
+
+	err := RecordOutcome(
+		s.Ctx,
+		s.Logger,
+		s.Pool,
+		server.URL,
+		true,
+		"count-words-ej26y-ad28j-1",
+		"mysecretkey",
+		WorkflowOutcome{
+			Phase:   "Succeeded",
+			Outputs: []string{"count-words-ej26y-ad28j-1-count-words-result.txt"},
+		},
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ej26y-ad28j", forwarded.RecordId)
+	assert.Equal(t, "Succeeded", forwarded.Phase)
+	assert.Equal(t, []string{"count-words-ej26y-ad28j-1-count-words-result.txt"}, forwarded.Outputs)
+
+	workflow := s.getWorkflow(wf.Id)
+	assert.Equal(t, "Succeeded", workflow.Phase)
+	assert.JSONEq(t, `["count-words-ej26y-ad28j-1-count-words-result.txt"]`, string(workflow.OutputFiles))
+	assert.NotNil(t, workflow.OutcomeReportedAt)
+}
+
+func (s *reportOutcomeServiceTestSuite) TestRecordOutcome_FannedOutFailure_FailureReportedPerFile() {
+	t := s.T()
+	wf := s.createWorkflow()
+
+	err := RecordOutcome(
+		s.Ctx,
+		s.Logger,
+		s.Pool,
+		"http://compchem.invalid",
+		false,
+		"count-words-ej26y-ad28j-1",
+		"mysecretkey",
+		WorkflowOutcome{
+			Phase:   "Failed",
+			Outputs: []string{"count-words-ej26y-ad28j-1-count-words-0-result.txt"},
+			Failures: []list_workflows.FailedNode{
+				{
+					DisplayName: "per-file-ej26y-ad28j-1(1:file:broken.txt,index:1)",
+					Phase:       "Failed",
+					Message:     "child 'count-words-ej26y-ad28j-1' failed",
+				},
+			},
+		},
+	)
+
+	assert.NoError(t, err)
+	workflow := s.getWorkflow(wf.Id)
+	assert.Equal(t, "Failed", workflow.Phase)
+	assert.Equal(t, "broken.txt: child 'count-words-ej26y-ad28j-1' failed", *workflow.Message)
+
+	var failures []list_workflows.FailedNode
+	assert.NoError(t, json.Unmarshal(workflow.FailedNodes, &failures))
+	assert.Equal(t, "broken.txt", failures[0].File)
+}
+
+func (s *reportOutcomeServiceTestSuite) TestRecordOutcome_WrongKey_ErrUnauthorizedAndNothingStored() {
+	t := s.T()
+	wf := s.createWorkflow()
+
+	err := RecordOutcome(
+		s.Ctx,
+		s.Logger,
+		s.Pool,
+		"http://compchem.invalid",
+		true,
+		"count-words-ej26y-ad28j-1",
+		"notmysecretkey",
+		WorkflowOutcome{Phase: "Failed"},
+	)
+
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	workflow := s.getWorkflow(wf.Id)
+	assert.Equal(t, workflow_repository.PhaseUnsubmitted, workflow.Phase)
+	assert.Nil(t, workflow.OutcomeReportedAt)
+}
+
+func (s *reportOutcomeServiceTestSuite) TestRecordOutcome_UnknownWorkflow_ErrUnauthorized() {
+	err := RecordOutcome(
+		s.Ctx,
+		s.Logger,
+		s.Pool,
+		"http://compchem.invalid",
+		false,
+		"count-words-ej26y-ad28j-7",
+		"mysecretkey",
+		WorkflowOutcome{Phase: "Succeeded"},
+	)
+
+	assert.ErrorIs(s.T(), err, ErrUnauthorized)
+}
+
+func TestReportOutcomeServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(reportOutcomeServiceTestSuite))
+}
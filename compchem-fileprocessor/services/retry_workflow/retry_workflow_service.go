@@ -0,0 +1,443 @@
+package retryworkflow_service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"fi.muni.cz/invenio-file-processor/v2/auth"
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/httpclient"
+	"fi.muni.cz/invenio-file-processor/v2/kubeclient"
+	repository_common "fi.muni.cz/invenio-file-processor/v2/repository/common"
+	"fi.muni.cz/invenio-file-processor/v2/repository/file_repository"
+	"fi.muni.cz/invenio-file-processor/v2/repository/workflow_repository"
+	"fi.muni.cz/invenio-file-processor/v2/services"
+	"fi.muni.cz/invenio-file-processor/v2/services/list_workflows"
+	startworkflow_service "fi.muni.cz/invenio-file-processor/v2/services/start_workflow"
+	submitworkflow_service "fi.muni.cz/invenio-file-processor/v2/services/submit_workflow"
+	"fi.muni.cz/invenio-file-processor/v2/util"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+type Mode string
+
+const (
+	// failed nodes are rerun by argo, the workflow keeps its name and secret key, signed tokens are
+	// replaced by a fresh token in the workflow secret since the original one may expire before
+	// the rerun finishes
+	ModeRetried Mode = "retried"
+	// stored inputs are cloned into a new workflow with a new sequence id and secret key
+	ModeResubmitted Mode = "resubmitted"
+)
+
+var (
+	ErrWorkflowNotFound       = errors.New("workflow not found")
+	ErrWorkflowNotRetryable   = errors.New("workflow can not be retried")
+	ErrWorkflowAlreadyRetried = errors.New("workflow was already resubmitted")
+)
+
+type RetryWorkflowResponse struct {
+	Mode         Mode   `json:"mode"`
+	WorkflowName string `json:"workflowName"`
+	RetriedFrom  string `json:"retriedFrom"`
+	// the exit handler revoked the context of the failed run, it has to be created again for this key
+	SecretKey string     `json:"secretKey,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+type retryRequest struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+var argoRetryPhases = map[string]bool{
+	"Failed": true,
+	"Error":  true,
+}
+
+var resubmitPhases = map[string]bool{
+	workflow_repository.PhaseSubmissionFailed: true,
+	workflow_repository.PhaseCancelled:        true,
+}
+
+// Retries a failed workflow through argo when argo still has it, otherwise the workflow is
+// resubmitted as a new run linked to the original one.
+func RetryWorkflow(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	kube *kubeclient.Client,
+	tokens *auth.WorkflowTokenSigner,
+	argoUrl string,
+	namespace string,
+	baseUrl string,
+	instance string,
+	callbackUrl string,
+	configs []config.WorkflowConfig,
+	workflowFullName string,
+) (*RetryWorkflowResponse, error) {
+	tx, err := pool.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel: pgx.RepeatableRead,
+	})
+	if err != nil {
+		logger.Error(
+			"error when starting tx for retrying workflow",
+			zap.String("workflowName", workflowFullName),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	workflow, err := workflow_repository.FindWorkflowByFullName(ctx, logger, tx, workflowFullName)
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+	if workflow == nil {
+		tx.Rollback(ctx)
+		return nil, ErrWorkflowNotFound
+	}
+	if !argoRetryPhases[workflow.Phase] && !resubmitPhases[workflow.Phase] {
+		tx.Rollback(ctx)
+		return nil, fmt.Errorf("%w: phase %s", ErrWorkflowNotRetryable, workflow.Phase)
+	}
+
+	var response *RetryWorkflowResponse
+	restoreSecret := func() {}
+	if canRetryInArgo(workflow) {
+		response, restoreSecret, err = retryInArgo(
+			ctx,
+			logger,
+			tx,
+			kube,
+			tokens,
+			configs,
+			argoUrl,
+			workflow.ArgoNamespace(namespace),
+			workflow,
+		)
+		if isNotFound(err) {
+			restoreSecret()
+			restoreSecret = func() {}
+			logger.Info(
+				"Workflow no longer in argo, resubmitting",
+				zap.String("workflowName", workflowFullName),
+			)
+			response, err = resubmit(
+				ctx,
+				logger,
+				tx,
+				tokens,
+				baseUrl,
+				instance,
+				callbackUrl,
+				configs,
+				workflow,
+			)
+		}
+	} else {
+		response, err = resubmit(
+			ctx,
+			logger,
+			tx,
+			tokens,
+			baseUrl,
+			instance,
+			callbackUrl,
+			configs,
+			workflow,
+		)
+	}
+	if err != nil {
+		tx.Rollback(ctx)
+		restoreSecret()
+		return nil, err
+	}
+
+	err = repository_common.CommitTx(ctx, tx, logger)
+	if err != nil {
+		restoreSecret()
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func canRetryInArgo(workflow *workflow_repository.ExistingWorfklowEntity) bool {
+	return argoRetryPhases[workflow.Phase]
+}
+
+// The secret is updated before argo is asked since rerun steps read it when they start, the
+// returned func puts the previous secret key back when the retry is not recorded so the secret
+// keeps matching the stored hash.
+func retryInArgo(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	kube *kubeclient.Client,
+	tokens *auth.WorkflowTokenSigner,
+	configs []config.WorkflowConfig,
+	argoUrl string,
+	namespace string,
+	workflow *workflow_repository.ExistingWorfklowEntity,
+) (*RetryWorkflowResponse, func(), error) {
+	restoreSecret := func() {}
+	request := &retryRequest{Name: workflow.FullName, Namespace: namespace}
+	var token string
+	var expiresAt time.Time
+	if tokens != nil {
+		previous, err := submitworkflow_service.ReadWorkflowSecret(
+			ctx,
+			logger,
+			kube,
+			namespace,
+			workflow.FullName,
+		)
+		if err != nil {
+			return nil, restoreSecret, err
+		}
+		token, expiresAt, err = mintRetryToken(ctx, logger, tx, tokens, configs, workflow)
+		if err != nil {
+			return nil, restoreSecret, err
+		}
+		err = submitworkflow_service.UpdateWorkflowSecret(
+			ctx,
+			logger,
+			kube,
+			namespace,
+			workflow.FullName,
+			token,
+		)
+		if err != nil {
+			return nil, restoreSecret, err
+		}
+		restoreSecret = func() {
+			restoreWorkflowSecret(ctx, logger, kube, namespace, workflow.FullName, previous)
+		}
+	}
+
+	url := fmt.Sprintf("%s/api/v1/workflows/%s/%s/retry", argoUrl, namespace, workflow.FullName)
+	logger.Info("Retrying argo workflow", zap.String("url", url))
+	retried, err := httpclient.PutRequest[list_workflows.WorkflowWithStatus](
+		ctx,
+		logger,
+		url,
+		request,
+		true,
+	)
+	if err != nil {
+		logger.Error("error when retrying argo workflow", zap.String("url", url), zap.Error(err))
+		return nil, restoreSecret, err
+	}
+
+	err = list_workflows.RecordWorkflowStates(
+		ctx,
+		logger,
+		tx,
+		[]list_workflows.WorkflowWithStatus{retried},
+	)
+	if err != nil {
+		return nil, restoreSecret, err
+	}
+
+	err = workflow_repository.RecordRetry(ctx, logger, tx, workflow.Id)
+	if err != nil {
+		return nil, restoreSecret, err
+	}
+
+	if tokens != nil {
+		err = workflow_repository.UpdateSecretKeyHash(
+			ctx,
+			logger,
+			tx,
+			workflow.Id,
+			util.HashSecretKey(token),
+		)
+		if err != nil {
+			return nil, restoreSecret, err
+		}
+
+		return &RetryWorkflowResponse{
+			Mode:         ModeRetried,
+			WorkflowName: workflow.FullName,
+			RetriedFrom:  workflow.FullName,
+			SecretKey:    token,
+			ExpiresAt:    &expiresAt,
+		}, restoreSecret, nil
+	}
+
+	secretKey, err := submitworkflow_service.FindSecretKey(
+		ctx,
+		logger,
+		tx,
+		kube,
+		namespace,
+		workflow,
+	)
+	if err != nil {
+		return nil, restoreSecret, err
+	}
+
+	return &RetryWorkflowResponse{
+		Mode:         ModeRetried,
+		WorkflowName: workflow.FullName,
+		RetriedFrom:  workflow.FullName,
+		SecretKey:    secretKey,
+	}, restoreSecret, nil
+}
+
+// Failures are only logged, the workflow then reports with a token whose hash is not stored.
+func restoreWorkflowSecret(
+	ctx context.Context,
+	logger *zap.Logger,
+	kube *kubeclient.Client,
+	namespace string,
+	workflowFullName string,
+	secretKey string,
+) {
+	err := submitworkflow_service.UpdateWorkflowSecret(
+		ctx,
+		logger,
+		kube,
+		namespace,
+		workflowFullName,
+		secretKey,
+	)
+	if err != nil {
+		logger.Error(
+			"Secret of workflow not restored after failed retry",
+			zap.String("workflowName", workflowFullName),
+			zap.Error(err),
+		)
+	}
+}
+
+// Token for the rerun scoped to the files of the original run, its deadline starts again.
+func mintRetryToken(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	tokens *auth.WorkflowTokenSigner,
+	configs []config.WorkflowConfig,
+	workflow *workflow_repository.ExistingWorfklowEntity,
+) (string, time.Time, error) {
+	// a workflow whose config was removed meanwhile falls back to the token lifetime
+	var deadline time.Duration
+	for _, conf := range configs {
+		if conf.Name == workflow.WorkflowName {
+			deadline = startworkflow_service.WorkflowDeadline(conf)
+		}
+	}
+
+	return submitworkflow_service.MintWorkflowToken(ctx, logger, tx, tokens, workflow, deadline)
+}
+
+func resubmit(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	tokens *auth.WorkflowTokenSigner,
+	baseUrl string,
+	instance string,
+	callbackUrl string,
+	configs []config.WorkflowConfig,
+	workflow *workflow_repository.ExistingWorfklowEntity,
+) (*RetryWorkflowResponse, error) {
+	existing, err := workflow_repository.FindRetryOfWorkflow(ctx, logger, tx, workflow.Id)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("%w as %s", ErrWorkflowAlreadyRetried, existing.FullName)
+	}
+
+	fileEntities, err := file_repository.FindFileEntitiesForWorkflow(ctx, logger, tx, workflow.Id)
+	if err != nil {
+		return nil, err
+	}
+	files := util.Map(fileEntities, func(file file_repository.ExistingCompchemFile) services.File {
+		return services.File{FileName: file.FileKey, Mimetype: file.Mimetype}
+	})
+
+	conf, err := startworkflow_service.FindWorkflowConfig(configs, workflow.WorkflowName, files)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWorkflowNotRetryable, err)
+	}
+
+	// the new run gets the parameters and inputs of the original one
+	var parameters map[string]map[string]string
+	if workflow.Parameters != nil {
+		err = json.Unmarshal(workflow.Parameters, &parameters)
+		if err != nil {
+			return nil, fmt.Errorf("Error when reading workflow parameters: %v", err)
+		}
+		parameters = parametersByProcessingTemplate(*conf, parameters)
+	}
+	var inputs map[string][]string
+	if workflow.Inputs != nil {
+		err = json.Unmarshal(workflow.Inputs, &inputs)
+		if err != nil {
+			return nil, fmt.Errorf("Error when reading workflow inputs: %v", err)
+		}
+	}
+
+	created, workflowContext, err := startworkflow_service.CreateWorkflow(
+		ctx,
+		logger,
+		tx,
+		tokens,
+		baseUrl,
+		instance,
+		callbackUrl,
+		*conf,
+		workflow.RecordId,
+		files,
+		inputs,
+		parameters,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = workflow_repository.LinkRetriedWorkflow(ctx, logger, tx, created.Id, workflow.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RetryWorkflowResponse{
+		Mode:         ModeResubmitted,
+		WorkflowName: workflowContext.WorkflowName,
+		RetriedFrom:  workflow.FullName,
+		SecretKey:    workflowContext.SecretKey,
+		ExpiresAt:    workflowContext.ExpiresAt,
+	}, nil
+}
+
+func isNotFound(err error) bool {
+	var clientErr *httpclient.ClientError
+	return errors.As(err, &clientErr) && clientErr.Status == http.StatusNotFound
+}
+
+// Parameters used to be stored by argo template name, such values apply to every processing
+// template of the argo template.
+func parametersByProcessingTemplate(
+	conf config.WorkflowConfig,
+	parameters map[string]map[string]string,
+) map[string]map[string]string {
+	result := make(map[string]map[string]string)
+	for _, template := range conf.ProcessingTemplates {
+		if values, present := parameters[template.Name]; present {
+			result[template.Name] = values
+		} else if values, present := parameters[template.Template]; present {
+			result[template.Name] = values
+		}
+	}
+
+	return result
+}
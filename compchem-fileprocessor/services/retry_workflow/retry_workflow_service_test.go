@@ -0,0 +1,313 @@
+package retryworkflow_service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fi.muni.cz/invenio-file-processor/v2/auth"
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/kubeclient"
+	kubetest "fi.muni.cz/invenio-file-processor/v2/kubeclient/test"
+	repository_common "fi.muni.cz/invenio-file-processor/v2/repository/common"
+	repositorytest "fi.muni.cz/invenio-file-processor/v2/repository/test"
+	"fi.muni.cz/invenio-file-processor/v2/repository/workflow_repository"
+	"fi.muni.cz/invenio-file-processor/v2/services"
+	startworkflow_service "fi.muni.cz/invenio-file-processor/v2/services/start_workflow"
+	"fi.muni.cz/invenio-file-processor/v2/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+var configs = []config.WorkflowConfig{
+	{
+		Name:      "count-words",
+		Mimetype:  "text/plain",
+		Extension: "txt",
+		ProcessingTemplates: []config.ProcessingTemplate{
+			{Name: "count-words", Template: "count-words-template"},
+		},
+	},
+}
+
+type retryWorkflowServiceTestSuite struct {
+	repositorytest.PostgresTestSuite
+}
+
+func (s *retryWorkflowServiceTestSuite) SetupSuite() {
+	s.PostgresTestSuite.MigratonsPath = "file://../../migrations"
+	s.PostgresTestSuite.SetupSuite()
+}
+
+func (s *retryWorkflowServiceTestSuite) TearDownSuite() {
+	s.PostgresTestSuite.TearDownSuite()
+}
+
+func (s *retryWorkflowServiceTestSuite) TearDownTest() {
+	assert.NoError(s.T(), repositorytest.ClearTable(s.Ctx, s.Pool, "compchem_workflow_submission"))
+	assert.NoError(s.T(), repositorytest.ClearTable(s.Ctx, s.Pool, "compchem_workflow_file"))
+	assert.NoError(s.T(), repositorytest.ClearTable(s.Ctx, s.Pool, "compchem_workflow"))
+	assert.NoError(s.T(), repositorytest.ClearTable(s.Ctx, s.Pool, "compchem_file"))
+}
+
+func (s *retryWorkflowServiceTestSuite) createWorkflow(phase string) *workflow_repository.ExistingWorfklowEntity {
+	t := s.T()
+	tx, err := s.Pool.Begin(s.Ctx)
+	assert.NoError(t, err)
+
+	wf, _, err := startworkflow_service.CreateWorkflow(
+		s.Ctx,
+		s.Logger,
+		tx,
+		nil,
+		"https://localhost:5000",
+		"compchem-test",
+		"http://fileprocessor:8062/api",
+		configs[0],
+		"ej26y-ad28j",
+		[]services.File{{FileName: "test.txt", Mimetype: "text/plain"}},
+		nil,
+		nil,
+	)
+	assert.NoError(t, err)
+
+	_, err = tx.Exec(s.Ctx, "UPDATE compchem_workflow SET phase = $2 WHERE id = $1", wf.Id, phase)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit(s.Ctx))
+
+	return wf
+}
+
+func (s *retryWorkflowServiceTestSuite) getWorkflow(fullName string) *workflow_repository.ExistingWorfklowEntity {
+	workflow, err := repository_common.QueryOne[workflow_repository.ExistingWorfklowEntity](
+		s.Ctx,
+		s.Pool,
+		"SELECT * FROM compchem_workflow WHERE workflow_full_name = $1",
+		fullName,
+	)
+	assert.NoError(s.T(), err)
+
+	return workflow
+}
+
+func (s *retryWorkflowServiceTestSuite) retry(argoUrl string) (*RetryWorkflowResponse, error) {
+	return s.retryWithTokens(argoUrl, nil, nil)
+}
+
+func (s *retryWorkflowServiceTestSuite) retryWithTokens(
+	argoUrl string,
+	kube *kubeclient.Client,
+	tokens *auth.WorkflowTokenSigner,
+) (*RetryWorkflowResponse, error) {
+	return RetryWorkflow(
+		s.Ctx,
+		s.Logger,
+		s.Pool,
+		kube,
+		tokens,
+		argoUrl,
+		"argo",
+		"https://localhost:5000",
+		"compchem-test",
+		"http://fileprocessor:8062/api",
+		configs,
+		"count-words-ej26y-ad28j-1",
+	)
+}
+
+func (s *retryWorkflowServiceTestSuite) TestRetryWorkflow_FailedInArgo_RetriedByArgo() {
+	t := s.T()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/api/v1/workflows/argo/count-words-ej26y-ad28j-1/retry", r.URL.Path)
+
+		var body retryRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, retryRequest{Name: "count-words-ej26y-ad28j-1", Namespace: "argo"}, body)
+
+		w.Write([]byte(`{"metadata":{"name":"count-words-ej26y-ad28j-1"},"status":{"phase":"Running","startedAt":"2025-06-01T10:00:00Z"}}`))
+	}))
+	defer server.Close()
+
+	wf := s.createWorkflow("Failed")
+
+	response, err := s.retry(server.URL)
+
+	assert.NoError(t, err)
+	assert.Equal(t, ModeRetried, response.Mode)
+	assert.Equal(t, "count-words-ej26y-ad28j-1", response.WorkflowName)
+	assert.Equal(t, "count-words-ej26y-ad28j-1", response.RetriedFrom)
+	assert.Len(t, response.SecretKey, 256, "original key is returned so the context can be recreated")
+
+	stored := s.getWorkflow(wf.FullName)
+	assert.Equal(t, "Running", stored.Phase)
+	assert.Equal(t, 1, stored.RetryCount)
+	assert.NotNil(t, stored.RetriedAt)
+	assert.Nil(t, stored.FinishedAt)
+}
+
+func newTokenSigner(t *testing.T) *auth.WorkflowTokenSigner {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	data, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "signing.pem")
+	err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: data}), 0600)
+	assert.NoError(t, err)
+
+	tokens, err := auth.NewWorkflowTokenSigner(&config.WorkflowTokensConfig{
+		Issuer:         "compchem-fileprocessor",
+		Audience:       "compchem",
+		SigningKeyFile: path,
+	})
+	assert.NoError(t, err)
+	return tokens
+}
+
+func (s *retryWorkflowServiceTestSuite) TestRetryWorkflow_TokensConfigured_FreshTokenInSecret() {
+	t := s.T()
+	tokens := newTokenSigner(t)
+	fake := kubetest.NewFakeApiServer()
+	defer fake.Close()
+	kube, err := kubeclient.NewClient(config.KubernetesApi{Url: fake.URL})
+	assert.NoError(t, err)
+	err = kube.CreateSecret(s.Ctx, s.Logger, kubeclient.Secret{
+		Metadata:   kubeclient.ObjectMeta{Name: "count-words-ej26y-ad28j-1-key", Namespace: "argo"},
+		StringData: map[string]string{"secret-key": "original"},
+	})
+	assert.NoError(t, err)
+	var secretAtRetry string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secretAtRetry = fake.Secret("argo", "count-words-ej26y-ad28j-1-key").StringData["secret-key"]
+
+		w.Write([]byte(`{"metadata":{"name":"count-words-ej26y-ad28j-1"},"status":{"phase":"Running","startedAt":"2025-06-01T10:00:00Z"}}`))
+	}))
+	defer server.Close()
+
+	wf := s.createWorkflow("Failed")
+
+	response, err := s.retryWithTokens(server.URL, kube, tokens)
+
+	assert.NoError(t, err)
+	assert.Equal(t, ModeRetried, response.Mode)
+	assert.Equal(t, response.SecretKey, secretAtRetry, "rerun steps read the fresh token")
+	assert.NotNil(t, response.ExpiresAt)
+	claims, err := tokens.Verify(response.SecretKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "count-words-ej26y-ad28j-1", claims.Workflow)
+	assert.Equal(t, []string{"test.txt"}, claims.Files)
+
+	stored := s.getWorkflow(wf.FullName)
+	assert.Equal(t, util.HashSecretKey(response.SecretKey), *stored.SecretKeyHash)
+}
+
+func (s *retryWorkflowServiceTestSuite) TestRetryWorkflow_TokensConfiguredArgoFails_SecretRestored() {
+	t := s.T()
+	tokens := newTokenSigner(t)
+	fake := kubetest.NewFakeApiServer()
+	defer fake.Close()
+	kube, err := kubeclient.NewClient(config.KubernetesApi{Url: fake.URL})
+	assert.NoError(t, err)
+	err = kube.CreateSecret(s.Ctx, s.Logger, kubeclient.Secret{
+		Metadata:   kubeclient.ObjectMeta{Name: "count-words-ej26y-ad28j-1-key", Namespace: "argo"},
+		StringData: map[string]string{"secret-key": "original"},
+	})
+	assert.NoError(t, err)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	wf := s.createWorkflow("Failed")
+
+	response, err := s.retryWithTokens(server.URL, kube, tokens)
+
+	assert.Error(t, err)
+	assert.Nil(t, response)
+	secret := fake.Secret("argo", "count-words-ej26y-ad28j-1-key")
+	assert.Equal(t, "original", secret.StringData["secret-key"], "secret matches the stored hash")
+
+	stored := s.getWorkflow(wf.FullName)
+	assert.Equal(t, 0, stored.RetryCount)
+}
+
+func (s *retryWorkflowServiceTestSuite) TestRetryWorkflow_GoneFromArgo_ResubmittedAndLinked() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"workflows.argoproj.io not found"}`))
+	}))
+	defer server.Close()
+
+	wf := s.createWorkflow("Error")
+
+	response, err := s.retry(server.URL)
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), ModeResubmitted, response.Mode)
+	assert.Equal(s.T(), "count-words-ej26y-ad28j-2", response.WorkflowName)
+	assert.Equal(s.T(), "count-words-ej26y-ad28j-1", response.RetriedFrom)
+	assert.NotEmpty(s.T(), response.SecretKey)
+
+	created := s.getWorkflow(response.WorkflowName)
+	assert.Equal(s.T(), wf.Id, *created.RetriedFrom)
+	assert.Equal(s.T(), workflow_repository.PhaseUnsubmitted, created.Phase)
+}
+
+func (s *retryWorkflowServiceTestSuite) TestRetryWorkflow_Cancelled_ResubmittedOnlyOnce() {
+	t := s.T()
+	s.createWorkflow(workflow_repository.PhaseCancelled)
+
+	response, err := s.retry("http://localhost:1")
+	assert.NoError(t, err)
+	assert.Equal(t, ModeResubmitted, response.Mode)
+
+	response, err = s.retry("http://localhost:1")
+	assert.ErrorIs(t, err, ErrWorkflowAlreadyRetried)
+	assert.Nil(t, response)
+
+	count, err := repositorytest.GetCountInTable(s.Ctx, s.Pool, "compchem_workflow")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func (s *retryWorkflowServiceTestSuite) TestRetryWorkflow_StillRunning_ErrWorkflowNotRetryable() {
+	s.createWorkflow("Running")
+
+	response, err := s.retry("http://localhost:1")
+
+	assert.ErrorIs(s.T(), err, ErrWorkflowNotRetryable)
+	assert.Nil(s.T(), response)
+}
+
+func TestRetryWorkflowServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(retryWorkflowServiceTestSuite))
+}
+
+func TestParametersByProcessingTemplate_StoredByArgoTemplate_AppliedToItsSteps(t *testing.T) {
+	conf := config.WorkflowConfig{
+		ProcessingTemplates: []config.ProcessingTemplate{
+			{Name: "convert-input", Template: "convert"},
+			{Name: "convert-output", Template: "convert"},
+			{Name: "annotate-template", Template: "annotate"},
+		},
+	}
+
+	parameters := parametersByProcessingTemplate(conf, map[string]map[string]string{
+		"convert":           {"format": "pdb"},
+		"annotate-template": {"keep": "false"},
+	})
+
+	assert.Equal(t, map[string]map[string]string{
+		"convert-input":     {"format": "pdb"},
+		"convert-output":    {"format": "pdb"},
+		"annotate-template": {"keep": "false"},
+	}, parameters)
+}
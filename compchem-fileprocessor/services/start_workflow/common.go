@@ -3,16 +3,18 @@ package startworkflow_service
 import (
 	"context"
 	"crypto/rand"
-	"fmt"
 	"math/big"
-	"strings"
+	"time"
 
 	"fi.muni.cz/invenio-file-processor/v2/api/argodtos"
-	"fi.muni.cz/invenio-file-processor/v2/httpclient"
+	"fi.muni.cz/invenio-file-processor/v2/auth"
+	"fi.muni.cz/invenio-file-processor/v2/config"
 	"fi.muni.cz/invenio-file-processor/v2/repository/file_repository"
+	"fi.muni.cz/invenio-file-processor/v2/repository/snapshot_repository"
 	"fi.muni.cz/invenio-file-processor/v2/repository/workflow_repository"
 	"fi.muni.cz/invenio-file-processor/v2/repository/workflowfile_repository"
 	"fi.muni.cz/invenio-file-processor/v2/services"
+	"fi.muni.cz/invenio-file-processor/v2/util"
 	"github.com/jackc/pgx/v5"
 	"go.uber.org/zap"
 )
@@ -21,9 +23,55 @@ type StartWorkflowsResponse struct {
 	WorkflowContexts []WorkflowContext `json:"workflowContexts"`
 }
 
+// Expires at is only set for signed workflow tokens, random secret keys do not expire.
 type WorkflowContext struct {
-	SecretKey    string `json:"secretKey"`
-	WorkflowName string `json:"workflowName"`
+	SecretKey    string     `json:"secretKey"`
+	WorkflowName string     `json:"workflowName"`
+	ExpiresAt    *time.Time `json:"expiresAt,omitempty"`
+}
+
+// Key the workflow authenticates to compchem with.
+type workflowKey struct {
+	secret    string
+	expiresAt *time.Time
+}
+
+// Mints a token scoped to the record, workflow and its files when workflow tokens are configured,
+// generates a random key otherwise. The token is only returned to the caller, the dispatcher mints
+// the one the workflow runs with when it submits the workflow.
+func issueWorkflowKey(
+	tokens *auth.WorkflowTokenSigner,
+	recordId string,
+	workflowName string,
+	files []services.File,
+	conf config.WorkflowConfig,
+) (workflowKey, error) {
+	if tokens == nil {
+		secretKey, err := generateKeyToWorkflow()
+		return workflowKey{secret: secretKey}, err
+	}
+
+	token, expiresAt, err := tokens.Mint(auth.WorkflowGrant{
+		RecordId:   recordId,
+		Workflow:   workflowName,
+		Files:      util.Map(files, func(file services.File) string { return file.FileName }),
+		Operations: auth.WorkflowOperations,
+		Deadline:   WorkflowDeadline(conf),
+	})
+	if err != nil {
+		return workflowKey{}, err
+	}
+
+	return workflowKey{secret: token, expiresAt: &expiresAt}, nil
+}
+
+// Active deadline of the workflow, zero when argo lets it run without one.
+func WorkflowDeadline(conf config.WorkflowConfig) time.Duration {
+	if conf.Spec.ActiveDeadlineSeconds == nil {
+		return 0
+	}
+
+	return time.Duration(*conf.Spec.ActiveDeadlineSeconds) * time.Second
 }
 
 func generateKeyToWorkflow() (string, error) {
@@ -50,34 +98,6 @@ func generateRandomString(n int) (string, error) {
 	return string(ret), nil
 }
 
-func submitWorkflow(
-	ctx context.Context,
-	logger *zap.Logger,
-	argoUrl string,
-	workflow *argodtos.Workflow,
-) {
-	url := buildWorkflowUrl("argo", argoUrl)
-	logger.Info(
-		"Submitting workflow to argo",
-		zap.String("workflow-name", workflow.Metadata.Name),
-		zap.String("url", url),
-	)
-
-	_, err := httpclient.PostRequest[any](ctx, logger, url, &argodtos.WorkflowWrapper{
-		Workflow: *workflow,
-	}, true)
-	if err != nil {
-		logger.Error("failed to submit workflow", zap.Error(err))
-	}
-}
-
-func buildWorkflowUrl(namespace string, argoUrl string, more ...string) string {
-	if len(more) == 0 {
-		return fmt.Sprintf("%s/api/v1/workflows/%s", argoUrl, namespace)
-	}
-	return fmt.Sprintf("%s/api/v1/workflows/%s/%s", argoUrl, namespace, strings.Join(more, "/"))
-}
-
 func createWorkflowFile(
 	ctx context.Context,
 	logger *zap.Logger,
@@ -126,34 +146,45 @@ func createWorkflowFile(
 	return nil
 }
 
-func addSingleWorkflowToDb(
+func addWorkflowInternal(
 	ctx context.Context,
 	logger *zap.Logger,
 	tx pgx.Tx,
 	recordId string,
 	files []services.File,
-	workflowName string,
-) (*workflow_repository.ExistingWorfklowEntity, error) {
-	workflow, err := addWorkflowInternal(ctx, logger, tx, recordId, files, workflowName)
+	conf config.WorkflowConfig,
+	tokens *auth.WorkflowTokenSigner,
+	inputs map[string][]string,
+	parameters map[string]map[string]string,
+) (*workflow_repository.ExistingWorfklowEntity, workflowKey, error) {
+	seqNumber, err := workflow_repository.GetSequentialNumberForRecord(ctx, logger, tx, recordId)
 	if err != nil {
-		tx.Rollback(ctx)
-		return nil, err
+		return nil, workflowKey{}, err
 	}
 
-	return workflow, nil
-}
-
-func addWorkflowInternal(
-	ctx context.Context,
-	logger *zap.Logger,
-	tx pgx.Tx,
-	recordId string,
-	files []services.File,
-	workflowName string,
-) (*workflow_repository.ExistingWorfklowEntity, error) {
-	seqNumber, err := workflow_repository.GetSequentialNumberForRecord(ctx, logger, tx, recordId)
+	key, err := issueWorkflowKey(
+		tokens,
+		recordId,
+		argodtos.ConstructFullWorkflowName(conf.Name, recordId, seqNumber),
+		files,
+		conf,
+	)
+	if err != nil {
+		logger.Error("Error when generating workflow context key", zap.Error(err))
+		return nil, workflowKey{}, err
+	}
+	secretKeyHash := util.HashSecretKey(key.secret)
+	storedParameters, err := marshalParameters(parameters)
+	if err != nil {
+		return nil, workflowKey{}, err
+	}
+	storedInputs, err := marshalInputs(inputs)
 	if err != nil {
-		return nil, err
+		return nil, workflowKey{}, err
+	}
+	configHash, err := createConfigSnapshot(ctx, logger, tx, conf)
+	if err != nil {
+		return nil, workflowKey{}, err
 	}
 
 	createdWorkflow, err := workflow_repository.CreateWorkflowForRecord(
@@ -162,13 +193,18 @@ func addWorkflowInternal(
 		tx,
 		workflow_repository.WorkflowEntity{
 			RecordId:      recordId,
-			WorkflowName:  workflowName,
+			WorkflowName:  conf.Name,
 			WorkflowSeqId: seqNumber,
+			SecretKeyHash: &secretKeyHash,
+			Namespace:     &conf.Spec.Namespace,
+			Parameters:    storedParameters,
+			Inputs:        storedInputs,
+			ConfigHash:    &configHash,
 		},
 	)
 	if err != nil {
 		tx.Rollback(ctx)
-		return nil, err
+		return nil, workflowKey{}, err
 	}
 
 	// TBD extract to improve function readability
@@ -176,9 +212,29 @@ func addWorkflowInternal(
 		err = createWorkflowFile(ctx, logger, tx, file, recordId, createdWorkflow.Id)
 		if err != nil {
 			tx.Rollback(ctx)
-			return nil, err
+			return nil, workflowKey{}, err
 		}
 	}
 
-	return createdWorkflow, nil
+	return createdWorkflow, key, nil
+}
+
+// Stores the effective config the workflow runs with, returns the hash it is stored under.
+func createConfigSnapshot(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	conf config.WorkflowConfig,
+) (string, error) {
+	snapshot, configHash, err := conf.Snapshot()
+	if err != nil {
+		return "", err
+	}
+
+	err = snapshot_repository.CreateConfigSnapshot(ctx, logger, tx, configHash, conf.Name, snapshot)
+	if err != nil {
+		return "", err
+	}
+
+	return configHash, nil
 }
@@ -1,20 +1,75 @@
 package startworkflow_service
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"fi.muni.cz/invenio-file-processor/v2/auth"
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/services"
 	"github.com/stretchr/testify/assert"
 )
 
-func TestGetArgoUrl_ArgsProvided_UrlCorrectlyFormed(t *testing.T) {
-	baseUrl := "https://argo-service.kubernetes.local"
-	namespace := "argo"
+func newTokenSigner(t *testing.T) *auth.WorkflowTokenSigner {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	data, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "signing.pem")
+	err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: data}), 0600)
+	assert.NoError(t, err)
 
-	result := buildWorkflowUrl(namespace, baseUrl, "submit")
-	assert.Equal(
-		t,
-		"https://argo-service.kubernetes.local/api/v1/workflows/argo/submit",
-		result,
-		"urls should match",
+	tokens, err := auth.NewWorkflowTokenSigner(&config.WorkflowTokensConfig{
+		Issuer:              "compchem-fileprocessor",
+		Audience:            "compchem",
+		SigningKeyFile:      path,
+		ExpiryMarginSeconds: 600,
+	})
+	assert.NoError(t, err)
+	return tokens
+}
+
+func TestIssueWorkflowKey_TokensConfigured_ScopedToWorkflow(t *testing.T) {
+	// Arrange
+	tokens := newTokenSigner(t)
+	deadline := int64(7200)
+	conf := config.WorkflowConfig{
+		Name: "count-words",
+		Spec: config.WorkflowSpec{ActiveDeadlineSeconds: &deadline},
+	}
+	files := []services.File{{FileName: "test.txt"}, {FileName: "test2.txt"}}
+
+	// Act
+	key, err := issueWorkflowKey(tokens, "ej26y-ad28j", "count-words-ej26y-ad28j-1", files, conf)
+
+	// Assert
+	assert.NoError(t, err)
+	claims, err := tokens.Verify(key.secret)
+	assert.NoError(t, err)
+	assert.Equal(t, "ej26y-ad28j", claims.RecordId)
+	assert.Equal(t, "count-words-ej26y-ad28j-1", claims.Workflow)
+	assert.Equal(t, []string{"test.txt", "test2.txt"}, claims.Files)
+	assert.Equal(t, auth.WorkflowOperations, claims.Operations)
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour+10*time.Minute), *key.expiresAt, time.Minute)
+}
+
+func TestIssueWorkflowKey_TokensNotConfigured_RandomKey(t *testing.T) {
+	key, err := issueWorkflowKey(
+		nil,
+		"ej26y-ad28j",
+		"count-words-ej26y-ad28j-1",
+		nil,
+		config.WorkflowConfig{},
 	)
+
+	assert.NoError(t, err)
+	assert.Len(t, key.secret, 256)
+	assert.Nil(t, key.expiresAt)
 }
@@ -0,0 +1,59 @@
+package startworkflow_service
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/services"
+	"fi.muni.cz/invenio-file-processor/v2/util"
+)
+
+// Files can not be assigned to the input slots of the workflow, errors are keyed by the file or
+// the slot name.
+type InvalidInputsError struct {
+	Errors map[string]string
+}
+
+func (e *InvalidInputsError) Error() string {
+	fields := slices.Sorted(maps.Keys(e.Errors))
+	return fmt.Sprintf("Invalid workflow inputs: %s", strings.Join(fields, ", "))
+}
+
+// Returns file keys of every input slot, nil for workflows without input slots.
+func AssignInputs(
+	conf config.WorkflowConfig,
+	files []services.File,
+) (map[string][]string, error) {
+	if len(conf.Inputs) == 0 {
+		return nil, nil
+	}
+
+	requested := make(map[string]string)
+	for _, file := range files {
+		if file.Slot != "" {
+			requested[file.FileName] = file.Slot
+		}
+	}
+
+	assigned, errs := conf.AssignInputs(
+		util.Map(files, func(file services.File) config.FileInfo { return file.Info() }),
+		requested,
+	)
+	if len(errs) > 0 {
+		return nil, &InvalidInputsError{Errors: errs}
+	}
+
+	return assigned, nil
+}
+
+func marshalInputs(inputs map[string][]string) ([]byte, error) {
+	if len(inputs) == 0 {
+		return nil, nil
+	}
+
+	return json.Marshal(inputs)
+}
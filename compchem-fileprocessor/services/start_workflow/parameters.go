@@ -0,0 +1,65 @@
+package startworkflow_service
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+)
+
+// Values supplied for the workflow do not match parameters declared by its templates, errors are
+// keyed by processing template and parameter name joined with a dot.
+type InvalidParametersError struct {
+	Errors map[string]string
+}
+
+func (e *InvalidParametersError) Error() string {
+	fields := slices.Sorted(maps.Keys(e.Errors))
+	return fmt.Sprintf("Invalid workflow parameters: %s", strings.Join(fields, ", "))
+}
+
+// Values are keyed by processing template name, then by parameter name, processing templates
+// sharing an argo template take their own values. Returns values passed to argo with defaults
+// filled in.
+func ResolveParameters(
+	conf config.WorkflowConfig,
+	values map[string]map[string]any,
+) (map[string]map[string]string, error) {
+	resolved := make(map[string]map[string]string)
+	errs := make(map[string]string)
+
+	for template := range values {
+		if !slices.ContainsFunc(conf.ProcessingTemplates, func(t config.ProcessingTemplate) bool {
+			return t.Name == template
+		}) {
+			errs[template] = "unknown template"
+		}
+	}
+
+	for _, template := range conf.ProcessingTemplates {
+		templateValues, templateErrs := template.ResolveParameters(values[template.Name])
+		for name, msg := range templateErrs {
+			errs[template.Name+"."+name] = msg
+		}
+		if len(templateValues) > 0 {
+			resolved[template.Name] = templateValues
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, &InvalidParametersError{Errors: errs}
+	}
+
+	return resolved, nil
+}
+
+func marshalParameters(parameters map[string]map[string]string) ([]byte, error) {
+	if len(parameters) == 0 {
+		return nil, nil
+	}
+
+	return json.Marshal(parameters)
+}
@@ -0,0 +1,78 @@
+package startworkflow_service
+
+import (
+	"errors"
+	"testing"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveParameters_ValuesValid_ResolvedPerTemplate(t *testing.T) {
+	conf := config.WorkflowConfig{
+		ProcessingTemplates: []config.ProcessingTemplate{
+			{
+				Name:       "simulation-annotation-template",
+				Template:   "simulation-annotation",
+				Parameters: []config.TemplateParameter{{Name: "keep", Type: config.ParameterBoolean}},
+			},
+			{Name: "count-words-template", Template: "count-words"},
+		},
+	}
+
+	resolved, err := ResolveParameters(conf, map[string]map[string]any{
+		"simulation-annotation-template": {"keep": false},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		map[string]map[string]string{"simulation-annotation-template": {"keep": "false"}},
+		resolved,
+	)
+}
+
+func TestResolveParameters_ValuesInvalid_FieldErrorsReturned(t *testing.T) {
+	conf := config.WorkflowConfig{
+		ProcessingTemplates: []config.ProcessingTemplate{
+			{
+				Name:       "simulation-annotation-template",
+				Template:   "simulation-annotation",
+				Parameters: []config.TemplateParameter{{Name: "keep", Type: config.ParameterBoolean}},
+			},
+		},
+	}
+
+	_, err := ResolveParameters(conf, map[string]map[string]any{
+		"simulation-annotation-template": {"keep": "sometimes"},
+		"simulation-annotation":          {"keep": false},
+	})
+
+	var parametersErr *InvalidParametersError
+	assert.True(t, errors.As(err, &parametersErr))
+	assert.Equal(t, map[string]string{
+		"simulation-annotation-template.keep": "must be a boolean",
+		"simulation-annotation":               "unknown template",
+	}, parametersErr.Errors)
+}
+
+func TestResolveParameters_TemplateSharedByTwoSteps_ValuesPerProcessingTemplate(t *testing.T) {
+	pdb := "pdb"
+	format := []config.TemplateParameter{{Name: "format", Default: &pdb}}
+	conf := config.WorkflowConfig{
+		ProcessingTemplates: []config.ProcessingTemplate{
+			{Name: "convert-input", Template: "convert", Parameters: format},
+			{Name: "convert-output", Template: "convert", Parameters: format},
+		},
+	}
+
+	resolved, err := ResolveParameters(conf, map[string]map[string]any{
+		"convert-output": {"format": "gro"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]map[string]string{
+		"convert-input":  {"format": "pdb"},
+		"convert-output": {"format": "gro"},
+	}, resolved)
+}
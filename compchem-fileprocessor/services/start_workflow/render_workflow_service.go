@@ -0,0 +1,53 @@
+package startworkflow_service
+
+import (
+	"fi.muni.cz/invenio-file-processor/v2/api/argodtos"
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/services"
+	"fi.muni.cz/invenio-file-processor/v2/util"
+)
+
+const (
+	// rendered workflows are not stored so no sequence id is allocated for them
+	RenderedSequenceId = uint64(0)
+)
+
+// Renders the workflow the start endpoint would submit without touching the database or argo.
+func RenderWorkflow(
+	baseUrl string,
+	instance string,
+	callbackUrl string,
+	name string,
+	recordId string,
+	files []services.File,
+	parameters map[string]map[string]any,
+	configs []config.WorkflowConfig,
+) (*argodtos.Workflow, error) {
+	conf, err := FindWorkflowConfig(configs, name, files)
+	if err != nil {
+		return nil, err
+	}
+
+	inputs, err := AssignInputs(*conf, files)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, err := ResolveParameters(*conf, parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	return argodtos.BuildWorkflow(
+		*conf,
+		baseUrl,
+		conf.Name,
+		RenderedSequenceId,
+		recordId,
+		util.Map(files, services.File.Info),
+		inputs,
+		resolved,
+		instance,
+		callbackUrl,
+	), nil
+}
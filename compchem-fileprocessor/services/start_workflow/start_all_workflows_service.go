@@ -3,13 +3,14 @@ package startworkflow_service
 import (
 	"context"
 	"fmt"
-	"regexp"
-	"strings"
+	"slices"
 
 	"fi.muni.cz/invenio-file-processor/v2/api/argodtos"
+	"fi.muni.cz/invenio-file-processor/v2/auth"
 	"fi.muni.cz/invenio-file-processor/v2/config"
 	repository_common "fi.muni.cz/invenio-file-processor/v2/repository/common"
 	"fi.muni.cz/invenio-file-processor/v2/services"
+	submitworkflow_service "fi.muni.cz/invenio-file-processor/v2/services/submit_workflow"
 	"fi.muni.cz/invenio-file-processor/v2/util"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -19,14 +20,17 @@ import (
 type ConfigWithFiles struct {
 	config config.WorkflowConfig
 	files  []services.File
+	inputs map[string][]string
 }
 
 func StartAllWorkflows(
 	ctx context.Context,
 	logger *zap.Logger,
 	pool *pgxpool.Pool,
-	argoUrl string,
+	tokens *auth.WorkflowTokenSigner,
 	baseUrl string,
+	instance string,
+	callbackUrl string,
 	recordId string,
 	files []services.File,
 	configs []config.WorkflowConfig,
@@ -35,34 +39,27 @@ func StartAllWorkflows(
 		ctx,
 		logger,
 		pool,
+		tokens,
 		configs,
 		recordId,
 		files,
 		baseUrl,
-		argoUrl,
+		instance,
+		callbackUrl,
 	)
 }
 
-func submitAllWorkflows(
-	ctx context.Context,
-	logger *zap.Logger,
-	argoUrl string,
-	workflows []*argodtos.Workflow,
-) {
-	for _, workflow := range workflows {
-		submitWorkflow(ctx, logger, argoUrl, workflow)
-	}
-}
-
 func createWorkflowsWithAllConfigs(
 	ctx context.Context,
 	logger *zap.Logger,
 	pool *pgxpool.Pool,
+	tokens *auth.WorkflowTokenSigner,
 	configs []config.WorkflowConfig,
 	recordId string,
 	files []services.File,
 	baseUrl string,
-	argoUrl string,
+	instance string,
+	callbackUrl string,
 ) (StartWorkflowsResponse, error) {
 	configsWithFiles, err := findAllMatchingConfigs(configs, files)
 	if err != nil {
@@ -70,7 +67,6 @@ func createWorkflowsWithAllConfigs(
 	}
 
 	contexts := []WorkflowContext{}
-	workflows := []*argodtos.Workflow{}
 
 	tx, err := pool.BeginTx(ctx, pgx.TxOptions{
 		IsoLevel: pgx.RepeatableRead,
@@ -81,40 +77,59 @@ func createWorkflowsWithAllConfigs(
 	}
 
 	for _, configAndFiles := range configsWithFiles {
-		createdWorkflow, err := addWorkflowInternal(
+		// no values are supplied when starting all workflows, only defaults apply
+		parameters, err := ResolveParameters(configAndFiles.config, nil)
+		if err != nil {
+			tx.Rollback(ctx)
+			return StartWorkflowsResponse{}, err
+		}
+
+		createdWorkflow, key, err := addWorkflowInternal(
 			ctx,
 			logger,
 			tx,
 			recordId,
 			configAndFiles.files,
-			configAndFiles.config.Name,
+			configAndFiles.config,
+			tokens,
+			configAndFiles.inputs,
+			parameters,
 		)
 		if err != nil {
 			tx.Rollback(ctx)
 			return StartWorkflowsResponse{}, err
 		}
 
-		secretKey, err := generateKeyToWorkflow()
-		if err != nil {
-			logger.Error("Error when generating workflow context", zap.Error(err))
-			tx.Rollback(ctx)
-			return StartWorkflowsResponse{}, err
-		}
-
 		workflow := argodtos.BuildWorkflow(
 			configAndFiles.config,
 			baseUrl,
 			createdWorkflow.WorkflowName,
 			createdWorkflow.WorkflowSeqId,
-			secretKey,
 			recordId,
-			util.Map(files, func(file services.File) string { return file.FileName }),
+			util.Map(configAndFiles.files, services.File.Info),
+			configAndFiles.inputs,
+			parameters,
+			instance,
+			callbackUrl,
 		)
 
-		workflows = append(workflows, workflow)
+		err = submitworkflow_service.EnqueueWorkflow(
+			ctx,
+			logger,
+			tx,
+			createdWorkflow.Id,
+			workflow,
+			key.secret,
+		)
+		if err != nil {
+			tx.Rollback(ctx)
+			return StartWorkflowsResponse{}, err
+		}
+
 		contexts = append(contexts, WorkflowContext{
-			SecretKey:    secretKey,
+			SecretKey:    key.secret,
 			WorkflowName: workflow.Metadata.Name,
+			ExpiresAt:    key.expiresAt,
 		})
 	}
 
@@ -123,10 +138,6 @@ func createWorkflowsWithAllConfigs(
 		return StartWorkflowsResponse{}, err
 	}
 
-	go func() {
-		submitAllWorkflows(ctx, logger, argoUrl, workflows)
-	}()
-
 	return StartWorkflowsResponse{WorkflowContexts: contexts}, nil
 }
 
@@ -138,9 +149,17 @@ func findAllMatchingConfigs(
 
 	for _, conf := range configs {
 		configResult := findFilesForConfig(conf, files)
-		if len(configResult.files) != 0 {
-			result = append(result, configResult)
+		if len(configResult.files) == 0 {
+			continue
 		}
+
+		// workflows whose input slots can not be filled are not started
+		inputs, err := AssignInputs(conf, configResult.files)
+		if err != nil {
+			continue
+		}
+		configResult.inputs = inputs
+		result = append(result, configResult)
 	}
 
 	if len(result) == 0 {
@@ -150,28 +169,23 @@ func findAllMatchingConfigs(
 	return result, nil
 }
 
+// Files of workflows with input slots have to be accepted by one of the slots as well.
 func findFilesForConfig(
 	conf config.WorkflowConfig,
 	files []services.File,
 ) ConfigWithFiles {
 	result := []services.File{}
-	fileExtensionRegex := regexp.MustCompile(`\.([^.]+)$`)
 
 	for _, file := range files {
-		if file.Mimetype != conf.Mimetype {
+		if conf.MatchFile(file.Info()) != nil {
 			continue
 		}
-
-		matches := fileExtensionRegex.FindStringSubmatch(file.FileName)
-		if len(matches) < 2 {
+		if len(conf.Inputs) > 0 && !slices.ContainsFunc(conf.Inputs, func(slot config.InputSlot) bool {
+			return slot.Match.Match(file.Info()) == nil
+		}) {
 			continue
 		}
-
-		fileExt := strings.ToLower(matches[1])
-
-		if fileExt == conf.Extension {
-			result = append(result, file)
-		}
+		result = append(result, file)
 	}
 
 	return ConfigWithFiles{
@@ -6,6 +6,7 @@ import (
 	"fi.muni.cz/invenio-file-processor/v2/config"
 	repository_common "fi.muni.cz/invenio-file-processor/v2/repository/common"
 	"fi.muni.cz/invenio-file-processor/v2/repository/file_repository"
+	"fi.muni.cz/invenio-file-processor/v2/repository/submission_repository"
 	repositorytest "fi.muni.cz/invenio-file-processor/v2/repository/test"
 	"fi.muni.cz/invenio-file-processor/v2/repository/workflow_repository"
 	"fi.muni.cz/invenio-file-processor/v2/repository/workflowfile_repository"
@@ -139,20 +140,6 @@ func (s *startAllWorkflowsTestSuite) TestFindAllMatchingCnofigs_FilesMatchMultip
 	)
 }
 
-func (s *startAllWorkflowsTestSuite) TestGetArgoUrl_ArgsProvided_UrlCorrectlyFormed() {
-	t := s.PostgresTestSuite.T()
-	baseUrl := "https://argo-service.kubernetes.local"
-	namespace := "argo"
-
-	result := buildWorkflowUrl(namespace, baseUrl, "submit")
-	assert.Equal(
-		t,
-		"https://argo-service.kubernetes.local/api/v1/workflows/argo/submit",
-		result,
-		"urls should match",
-	)
-}
-
 func (s *startAllWorkflowsTestSuite) TestCreateWorkflowsWithAllConfigs_TwoConfigsMatch_DbInCorrectState() {
 	t := s.PostgresTestSuite.T()
 	configs := []config.WorkflowConfig{
@@ -187,6 +174,7 @@ func (s *startAllWorkflowsTestSuite) TestCreateWorkflowsWithAllConfigs_TwoConfig
 		s.PostgresTestSuite.Ctx,
 		s.PostgresTestSuite.Logger,
 		pool,
+		nil,
 		configs,
 		"ej26y-ad28j",
 		[]services.File{
@@ -208,7 +196,8 @@ func (s *startAllWorkflowsTestSuite) TestCreateWorkflowsWithAllConfigs_TwoConfig
 			},
 		},
 		"http://localhost:7000",
-		"http://does.not.matter.com",
+		"compchem-test",
+		"http://fileprocessor:8062/api",
 	)
 
 	assert.NoError(t, err)
@@ -303,6 +292,21 @@ func (s *startAllWorkflowsTestSuite) TestCreateWorkflowsWithAllConfigs_TwoConfig
 	assert.Equal(t, workflowFile2.FileId, file2.Id)
 	assert.Equal(t, workflowFile2.WorkflowId, workflowEntities[1].Id)
 
+	submissions, err := repository_common.QueryMany[submission_repository.ExistingSubmissionEntity](
+		ctx,
+		pool,
+		"SELECT * FROM compchem_workflow_submission ORDER BY compchem_workflow_id",
+	)
+	assert.NoError(t, err)
+	assert.Len(t, submissions, 2, "every workflow should be waiting in the outbox")
+	assert.Equal(t, workflowEntities[0].Id, submissions[0].WorkflowId)
+	assert.Equal(t, submission_repository.SubmissionPending, submissions[0].Status)
+	assert.Contains(t, string(submissions[0].Workflow), "count-words-ej26y-ad28j-1")
+	assert.Equal(t, workflowEntities[1].Id, submissions[1].WorkflowId)
+	assert.Equal(t, submission_repository.SubmissionPending, submissions[1].Status)
+
+	err = repositorytest.ClearTable(ctx, pool, "compchem_workflow_submission")
+	assert.NoError(t, err)
 	err = repositorytest.ClearTable(ctx, pool, "compchem_workflow_file")
 	assert.NoError(t, err)
 	err = repositorytest.ClearTable(ctx, pool, "compchem_workflow")
@@ -4,13 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"regexp"
-	"strings"
 
 	"fi.muni.cz/invenio-file-processor/v2/api/argodtos"
+	"fi.muni.cz/invenio-file-processor/v2/auth"
 	"fi.muni.cz/invenio-file-processor/v2/config"
 	repository_common "fi.muni.cz/invenio-file-processor/v2/repository/common"
+	"fi.muni.cz/invenio-file-processor/v2/repository/workflow_repository"
 	"fi.muni.cz/invenio-file-processor/v2/services"
+	submitworkflow_service "fi.muni.cz/invenio-file-processor/v2/services/submit_workflow"
 	"fi.muni.cz/invenio-file-processor/v2/util"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -21,23 +22,29 @@ func StartWorkflow(
 	ctx context.Context,
 	logger *zap.Logger,
 	pool *pgxpool.Pool,
-	argoUrl string,
+	tokens *auth.WorkflowTokenSigner,
 	baseUrl string,
+	instance string,
+	callbackUrl string,
 	name string,
 	recordId string,
 	files []services.File,
+	parameters map[string]map[string]any,
 	configs []config.WorkflowConfig,
 ) (WorkflowContext, error) {
 	return createWorkflowSingleConfig(
 		ctx,
 		logger,
 		pool,
+		tokens,
 		configs,
 		name,
 		recordId,
 		files,
+		parameters,
 		baseUrl,
-		argoUrl,
+		instance,
+		callbackUrl,
 	)
 }
 
@@ -45,80 +52,141 @@ func createWorkflowSingleConfig(
 	ctx context.Context,
 	logger *zap.Logger,
 	pool *pgxpool.Pool,
+	tokens *auth.WorkflowTokenSigner,
 	configs []config.WorkflowConfig,
 	name string,
 	recordId string,
 	files []services.File,
+	parameters map[string]map[string]any,
 	baseUrl string,
-	argoUrl string,
+	instance string,
+	callbackUrl string,
 ) (WorkflowContext, error) {
 	tx, err := pool.BeginTx(ctx, pgx.TxOptions{
 		IsoLevel: pgx.RepeatableRead,
 	})
+	if err != nil {
+		logger.Error("Error when starting transaction")
+		return WorkflowContext{}, err
+	}
+
+	conf, err := FindWorkflowConfig(configs, name, files)
+	if err != nil {
+		tx.Rollback(ctx)
+		return WorkflowContext{}, err
+	}
 
-	conf, err := findWorkflowConfig(configs, name, files)
+	inputs, err := AssignInputs(*conf, files)
 	if err != nil {
+		tx.Rollback(ctx)
+		return WorkflowContext{}, err
+	}
+
+	resolved, err := ResolveParameters(*conf, parameters)
+	if err != nil {
+		tx.Rollback(ctx)
 		return WorkflowContext{}, err
 	}
 
-	workflowEntity, err := addSingleWorkflowToDb(
+	_, workflowContext, err := CreateWorkflow(
 		ctx,
 		logger,
 		tx,
+		tokens,
+		baseUrl,
+		instance,
+		callbackUrl,
+		*conf,
 		recordId,
 		files,
-		conf.Name,
+		inputs,
+		resolved,
 	)
 	if err != nil {
 		tx.Rollback(ctx)
 		return WorkflowContext{}, err
 	}
 
-	secretKey, err := generateKeyToWorkflow()
+	err = repository_common.CommitTx(ctx, tx, logger)
 	if err != nil {
-		logger.Error("Error when generating workflow context key", zap.Error(err))
-		tx.Rollback(ctx)
+		return WorkflowContext{}, err
+	}
+
+	return workflowContext, nil
+}
+
+// Creates the workflow with a fresh secret key, or a signed token when tokens are configured, and
+// enqueues it for submission, the caller owns tx.
+// Inputs are already assigned and parameters resolved against the config.
+func CreateWorkflow(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	tokens *auth.WorkflowTokenSigner,
+	baseUrl string,
+	instance string,
+	callbackUrl string,
+	conf config.WorkflowConfig,
+	recordId string,
+	files []services.File,
+	inputs map[string][]string,
+	parameters map[string]map[string]string,
+) (*workflow_repository.ExistingWorfklowEntity, WorkflowContext, error) {
+	workflowEntity, key, err := addWorkflowInternal(
+		ctx,
+		logger,
+		tx,
+		recordId,
+		files,
+		conf,
+		tokens,
+		inputs,
+		parameters,
+	)
+	if err != nil {
+		return nil, WorkflowContext{}, err
 	}
 
 	workflow := argodtos.BuildWorkflow(
-		*conf,
+		conf,
 		baseUrl,
 		workflowEntity.WorkflowName,
 		workflowEntity.WorkflowSeqId,
-		secretKey,
 		recordId,
-		util.Map(files, func(file services.File) string { return file.FileName }),
+		util.Map(files, services.File.Info),
+		inputs,
+		parameters,
+		instance,
+		callbackUrl,
 	)
 
+	err = submitworkflow_service.EnqueueWorkflow(
+		ctx,
+		logger,
+		tx,
+		workflowEntity.Id,
+		workflow,
+		key.secret,
+	)
 	if err != nil {
-		logger.Error("Error when generating workflow keys", zap.Error(err))
-		tx.Rollback(ctx)
-		return WorkflowContext{}, err
-	}
-
-	err = repository_common.CommitTx(ctx, tx, logger)
-	if err != nil {
-		return WorkflowContext{}, err
+		return nil, WorkflowContext{}, err
 	}
 
-	go func() {
-		submitWorkflow(ctx, logger, argoUrl, workflow)
-	}()
-
-	return WorkflowContext{
-		SecretKey:    secretKey,
+	return workflowEntity, WorkflowContext{
+		SecretKey:    key.secret,
 		WorkflowName: workflow.Metadata.Name,
+		ExpiresAt:    key.expiresAt,
 	}, nil
 }
 
-func findWorkflowConfig(
+func FindWorkflowConfig(
 	configs []config.WorkflowConfig,
 	name string,
 	files []services.File,
 ) (*config.WorkflowConfig, error) {
 	for _, conf := range configs {
 		if conf.Name == name {
-			if err := validateFiles(conf.Mimetype, conf.Extension, files); err != nil {
+			if err := validateFiles(conf, files); err != nil {
 				return nil, err
 			}
 			return &conf, nil
@@ -129,31 +197,12 @@ func findWorkflowConfig(
 }
 
 func validateFiles(
-	mimetype string,
-	extension string,
+	conf config.WorkflowConfig,
 	files []services.File,
 ) error {
 	for _, file := range files {
-		if file.Mimetype != mimetype {
-			return fmt.Errorf(
-				"workflow requires mimetype: %s, found file %s with type: %s",
-				mimetype, file.FileName, file.Mimetype,
-			)
-		}
-
-		fileExtensionRegex := regexp.MustCompile(`\.([^.]+)$`)
-		matches := fileExtensionRegex.FindStringSubmatch(file.FileName)
-		if len(matches) < 2 {
-			return fmt.Errorf("file %s has no extension", file.FileName)
-		}
-
-		fileExt := strings.ToLower(matches[1])
-
-		if fileExt != extension {
-			return fmt.Errorf(
-				"file %s has extension .%s, expected .%s",
-				file.FileName, fileExt, extension,
-			)
+		if err := conf.MatchFile(file.Info()); err != nil {
+			return fmt.Errorf("workflow %s does not accept file %s: %v", conf.Name, file.FileName, err)
 		}
 	}
 
@@ -6,6 +6,8 @@ import (
 	"fi.muni.cz/invenio-file-processor/v2/config"
 	repository_common "fi.muni.cz/invenio-file-processor/v2/repository/common"
 	"fi.muni.cz/invenio-file-processor/v2/repository/file_repository"
+	"fi.muni.cz/invenio-file-processor/v2/repository/snapshot_repository"
+	"fi.muni.cz/invenio-file-processor/v2/repository/submission_repository"
 	repositorytest "fi.muni.cz/invenio-file-processor/v2/repository/test"
 	"fi.muni.cz/invenio-file-processor/v2/repository/workflow_repository"
 	"fi.muni.cz/invenio-file-processor/v2/repository/workflowfile_repository"
@@ -37,7 +39,7 @@ func (s *startWorkflowServiceTestSuite) TestFindWorkflowConfig_MatchingConfigExi
 		},
 	}
 
-	conf, err := findWorkflowConfig(configs, "text-processing", []services.File{})
+	conf, err := FindWorkflowConfig(configs, "text-processing", []services.File{})
 	assert.NoError(t, err, "error should be nil because config exists")
 	assert.Equal(t, conf, &configs[0], "returned should be the same object as in setup")
 }
@@ -52,7 +54,7 @@ func (s *startWorkflowServiceTestSuite) TestFindWorkflowConfig_NoConfig_ErorrRet
 		},
 	}
 
-	conf, err := findWorkflowConfig(configs, "text-processing", []services.File{})
+	conf, err := FindWorkflowConfig(configs, "text-processing", []services.File{})
 	assert.Nil(t, conf, "config should be null")
 	assert.Error(t, err, "error should have been returned")
 }
@@ -80,6 +82,7 @@ func (s *startWorkflowServiceTestSuite) TestCreateWorkflow_WorkflowCreated_DbInC
 		s.PostgresTestSuite.Ctx,
 		s.PostgresTestSuite.Logger,
 		pool,
+		nil,
 		configs,
 		"count-words",
 		"ej26y-ad28j",
@@ -93,8 +96,10 @@ func (s *startWorkflowServiceTestSuite) TestCreateWorkflow_WorkflowCreated_DbInC
 				Mimetype: "text/plain",
 			},
 		},
+		nil,
 		"http://localhost:7000",
-		"https://example.argo.url.com",
+		"compchem-test",
+		"http://fileprocessor:8062/api",
 	)
 
 	assert.NoError(t, err)
@@ -137,6 +142,18 @@ func (s *startWorkflowServiceTestSuite) TestCreateWorkflow_WorkflowCreated_DbInC
 	assert.Equal(t, workflow.WorkflowName, configs[0].Name)
 	assert.Equal(t, workflow.RecordId, "ej26y-ad28j")
 
+	_, configHash, err := configs[0].Snapshot()
+	assert.NoError(t, err)
+	assert.Equal(t, configHash, *workflow.ConfigHash)
+	snapshot, err := repository_common.QueryOne[snapshot_repository.ConfigSnapshotEntity](
+		ctx,
+		pool,
+		"SELECT * FROM compchem_workflow_config_snapshot WHERE config_hash = $1",
+		configHash,
+	)
+	assert.NoError(t, err)
+	assert.Contains(t, string(snapshot.Config), `"processing-templates"`)
+
 	workflowFile, err := repository_common.QueryOne[workflowfile_repository.ExistingWorkflowFileEntity](
 		ctx,
 		pool,
@@ -157,6 +174,20 @@ func (s *startWorkflowServiceTestSuite) TestCreateWorkflow_WorkflowCreated_DbInC
 	assert.Equal(t, workflowFile1.FileId, file1.Id)
 	assert.Equal(t, workflowFile1.WorkflowId, workflow.Id)
 
+	submission, err := repository_common.QueryOne[submission_repository.ExistingSubmissionEntity](
+		ctx,
+		pool,
+		"SELECT * FROM compchem_workflow_submission WHERE compchem_workflow_id = $1",
+		workflow.Id,
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, submission_repository.SubmissionPending, submission.Status)
+	assert.Equal(t, 0, submission.Attempts)
+	assert.Contains(t, string(submission.Workflow), "count-words-ej26y-ad28j-1")
+	assert.Contains(t, string(submission.Workflow), `"compchem.cerit.io/instance":"compchem-test"`)
+
+	err = repositorytest.ClearTable(ctx, pool, "compchem_workflow_submission")
+	assert.NoError(t, err)
 	err = repositorytest.ClearTable(ctx, pool, "compchem_workflow_file")
 	assert.NoError(t, err)
 	err = repositorytest.ClearTable(ctx, pool, "compchem_workflow")
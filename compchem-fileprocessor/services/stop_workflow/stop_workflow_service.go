@@ -0,0 +1,276 @@
+package stopworkflow_service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"fi.muni.cz/invenio-file-processor/v2/auth"
+	"fi.muni.cz/invenio-file-processor/v2/httpclient"
+	"fi.muni.cz/invenio-file-processor/v2/kubeclient"
+	repository_common "fi.muni.cz/invenio-file-processor/v2/repository/common"
+	"fi.muni.cz/invenio-file-processor/v2/repository/submission_repository"
+	"fi.muni.cz/invenio-file-processor/v2/repository/workflow_repository"
+	"fi.muni.cz/invenio-file-processor/v2/services/list_workflows"
+	submitworkflow_service "fi.muni.cz/invenio-file-processor/v2/services/submit_workflow"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+type Action string
+
+const (
+	// runs exit handlers of the workflow
+	ActionStop Action = "stop"
+	// kills the workflow immediately without running exit handlers
+	ActionTerminate Action = "terminate"
+)
+
+var (
+	ErrWorkflowNotFound = errors.New("workflow not found")
+	ErrWorkflowFinished = errors.New("workflow already finished")
+)
+
+type StopWorkflowResponse struct {
+	WorkflowName   string `json:"workflowName"`
+	Action         Action `json:"action"`
+	Phase          string `json:"phase"`
+	ContextRevoked bool   `json:"contextRevoked"`
+}
+
+type stopRequest struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+const revocationTokenLifetime = time.Minute
+
+var finishedPhases = map[string]bool{
+	"Succeeded": true,
+	"Failed":    true,
+	"Error":     true,
+	workflow_repository.PhaseSubmissionFailed: true,
+	workflow_repository.PhaseCancelled:        true,
+}
+
+// Stops or terminates the workflow in argo, a workflow still waiting in the outbox is cancelled
+// without reaching argo. The secret key of a terminated or never submitted workflow is revoked on
+// the compchem side afterwards, a stopped workflow revokes it in its exit handler.
+func StopWorkflow(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	kube *kubeclient.Client,
+	tokens *auth.WorkflowTokenSigner,
+	argoUrl string,
+	namespace string,
+	baseUrl string,
+	workflowFullName string,
+	action Action,
+) (*StopWorkflowResponse, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		logger.Error(
+			"error when starting tx for stopping workflow",
+			zap.String("workflowName", workflowFullName),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	workflow, err := workflow_repository.FindWorkflowByFullName(ctx, logger, tx, workflowFullName)
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+	if workflow == nil {
+		tx.Rollback(ctx)
+		return nil, ErrWorkflowNotFound
+	}
+
+	// cancelling drops the key from the outbox, so it is read first when the context gets revoked
+	secretKey := ""
+	revoked := action == ActionTerminate || workflow.Phase == workflow_repository.PhaseUnsubmitted
+	if tokens == nil && revoked {
+		secretKey, err = submitworkflow_service.FindSecretKey(
+			ctx,
+			logger,
+			tx,
+			kube,
+			workflow.ArgoNamespace(namespace),
+			workflow,
+		)
+		if err != nil {
+			logger.Warn(
+				"Secret key of workflow could not be read",
+				zap.String("workflowName", workflowFullName),
+				zap.Error(err),
+			)
+		}
+	}
+
+	phase, inOutbox, err := cancelWorkflow(
+		ctx,
+		logger,
+		tx,
+		argoUrl,
+		workflow.ArgoNamespace(namespace),
+		workflow,
+		action,
+	)
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+
+	err = repository_common.CommitTx(ctx, tx, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &StopWorkflowResponse{
+		WorkflowName: workflowFullName,
+		Action:       action,
+		Phase:        phase,
+	}
+	if !inOutbox && action == ActionStop {
+		return response, nil
+	}
+
+	if tokens != nil {
+		secretKey = revocationToken(logger, tokens, workflow)
+	}
+	response.ContextRevoked = revokeContext(ctx, logger, baseUrl, workflowFullName, secretKey)
+
+	return response, nil
+}
+
+// Returns phase of the workflow after cancellation and whether it was cancelled in the outbox.
+func cancelWorkflow(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	argoUrl string,
+	namespace string,
+	workflow *workflow_repository.ExistingWorfklowEntity,
+	action Action,
+) (string, bool, error) {
+	cancelled, err := submission_repository.CancelSubmission(ctx, logger, tx, workflow.Id)
+	if err != nil {
+		return "", false, err
+	}
+	if cancelled {
+		phase := workflow_repository.PhaseCancelled
+		err = workflow_repository.RecordCancellation(
+			ctx,
+			logger,
+			tx,
+			workflow.Id,
+			string(action),
+			&phase,
+		)
+		return phase, true, err
+	}
+
+	if finishedPhases[workflow.Phase] {
+		return "", false, ErrWorkflowFinished
+	}
+
+	url := fmt.Sprintf("%s/api/v1/workflows/%s/%s/%s", argoUrl, namespace, workflow.FullName, action)
+	logger.Info("Cancelling argo workflow", zap.String("url", url))
+	stopped, err := httpclient.PutRequest[list_workflows.WorkflowWithStatus](
+		ctx,
+		logger,
+		url,
+		&stopRequest{Name: workflow.FullName, Namespace: namespace},
+		true,
+	)
+	if err != nil {
+		logger.Error("error when cancelling argo workflow", zap.String("url", url), zap.Error(err))
+		return "", false, err
+	}
+
+	err = list_workflows.RecordWorkflowStates(
+		ctx,
+		logger,
+		tx,
+		[]list_workflows.WorkflowWithStatus{stopped},
+	)
+	if err != nil {
+		return "", false, err
+	}
+
+	err = workflow_repository.RecordCancellation(ctx, logger, tx, workflow.Id, string(action), nil)
+	if err != nil {
+		return "", false, err
+	}
+
+	if stopped.Status.Phase == "" {
+		return workflow.Phase, false, nil
+	}
+	return stopped.Status.Phase, false, nil
+}
+
+// Short lived token which only allows deleting the context, the token the workflow runs with is
+// not kept by the service. Compchem identifies the context by the workflow claim of signed tokens.
+func revocationToken(
+	logger *zap.Logger,
+	tokens *auth.WorkflowTokenSigner,
+	workflow *workflow_repository.ExistingWorfklowEntity,
+) string {
+	revocation, _, err := tokens.Mint(auth.WorkflowGrant{
+		RecordId:   workflow.RecordId,
+		Workflow:   workflow.FullName,
+		Operations: []auth.WorkflowOperation{auth.OpDeleteContext},
+		Deadline:   revocationTokenLifetime,
+	})
+	if err != nil {
+		logger.Error(
+			"Failed to mint revocation token",
+			zap.String("workflowName", workflow.FullName),
+			zap.Error(err),
+		)
+		return ""
+	}
+
+	return revocation
+}
+
+// Same call as the delete-context step of the exit handler, which a terminated workflow skips.
+// Failures are only logged since the workflow is already cancelled at this point.
+func revokeContext(
+	ctx context.Context,
+	logger *zap.Logger,
+	baseUrl string,
+	workflowFullName string,
+	secretKey string,
+) bool {
+	if secretKey == "" {
+		logger.Warn(
+			"Secret key of workflow not found, context not revoked",
+			zap.String("workflowName", workflowFullName),
+		)
+		return false
+	}
+
+	contextUrl := fmt.Sprintf(
+		"%s/workflows/%s/context?secret_key=%s",
+		baseUrl,
+		url.PathEscape(workflowFullName),
+		url.QueryEscape(secretKey),
+	)
+	err := httpclient.DeleteRequest(ctx, logger, contextUrl, true)
+	if err != nil {
+		logger.Error(
+			"Failed to revoke context of cancelled workflow",
+			zap.String("workflowName", workflowFullName),
+			zap.Error(err),
+		)
+		return false
+	}
+
+	return true
+}
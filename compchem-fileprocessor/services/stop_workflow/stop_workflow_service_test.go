@@ -0,0 +1,302 @@
+package stopworkflow_service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fi.muni.cz/invenio-file-processor/v2/api/argodtos"
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/kubeclient"
+	kubetest "fi.muni.cz/invenio-file-processor/v2/kubeclient/test"
+	repository_common "fi.muni.cz/invenio-file-processor/v2/repository/common"
+	"fi.muni.cz/invenio-file-processor/v2/repository/submission_repository"
+	repositorytest "fi.muni.cz/invenio-file-processor/v2/repository/test"
+	"fi.muni.cz/invenio-file-processor/v2/repository/workflow_repository"
+	submitworkflow_service "fi.muni.cz/invenio-file-processor/v2/services/submit_workflow"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type stopWorkflowServiceTestSuite struct {
+	repositorytest.PostgresTestSuite
+	fake *kubetest.FakeApiServer
+	kube *kubeclient.Client
+}
+
+func (s *stopWorkflowServiceTestSuite) SetupSuite() {
+	s.PostgresTestSuite.MigratonsPath = "file://../../migrations"
+	s.PostgresTestSuite.SetupSuite()
+}
+
+func (s *stopWorkflowServiceTestSuite) TearDownSuite() {
+	s.PostgresTestSuite.TearDownSuite()
+}
+
+func (s *stopWorkflowServiceTestSuite) SetupTest() {
+	s.fake = kubetest.NewFakeApiServer()
+	kube, err := kubeclient.NewClient(config.KubernetesApi{Url: s.fake.URL})
+	assert.NoError(s.T(), err)
+	s.kube = kube
+}
+
+func (s *stopWorkflowServiceTestSuite) TearDownTest() {
+	s.fake.Close()
+	assert.NoError(s.T(), repositorytest.ClearTable(s.Ctx, s.Pool, "compchem_workflow_submission"))
+	assert.NoError(s.T(), repositorytest.ClearTable(s.Ctx, s.Pool, "compchem_workflow"))
+}
+
+// Creates a workflow with its outbox entry, submitted marks both as handed over to argo and moves
+// the secret key into the kubernetes secret of the workflow.
+func (s *stopWorkflowServiceTestSuite) createWorkflow(submitted bool) *workflow_repository.ExistingWorfklowEntity {
+	t := s.T()
+	tx, err := s.Pool.Begin(s.Ctx)
+	assert.NoError(t, err)
+
+	wf, err := workflow_repository.CreateWorkflowForRecord(
+		s.Ctx,
+		s.Logger,
+		tx,
+		workflow_repository.WorkflowEntity{
+			RecordId:      "ej26y-ad28j",
+			WorkflowName:  "count-words",
+			WorkflowSeqId: 1,
+		},
+	)
+	assert.NoError(t, err)
+
+	workflow := argodtos.BuildWorkflow(
+		config.WorkflowConfig{},
+		"https://localhost:5000",
+		"count-words",
+		1,
+		"ej26y-ad28j",
+		[]config.FileInfo{{Name: "test.txt"}},
+		nil,
+		nil,
+		"compchem-test",
+		"http://fileprocessor:8062/api",
+	)
+	err = submitworkflow_service.EnqueueWorkflow(s.Ctx, s.Logger, tx, wf.Id, workflow, "mysecretkey")
+	assert.NoError(t, err)
+
+	if submitted {
+		_, err = tx.Exec(
+			s.Ctx,
+			"UPDATE compchem_workflow_submission SET status = 'submitted', secret_key = NULL",
+		)
+		assert.NoError(t, err)
+		_, err = tx.Exec(s.Ctx, "UPDATE compchem_workflow SET phase = 'Running', submitted_at = now()")
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, tx.Commit(s.Ctx))
+
+	if submitted {
+		err = s.kube.CreateSecret(s.Ctx, s.Logger, kubeclient.Secret{
+			Metadata: kubeclient.ObjectMeta{
+				Name:      argodtos.SecretName("count-words-ej26y-ad28j-1"),
+				Namespace: "argo",
+			},
+			StringData: map[string]string{argodtos.SecretKeyField: "mysecretkey"},
+		})
+		assert.NoError(t, err)
+	}
+
+	return wf
+}
+
+func (s *stopWorkflowServiceTestSuite) getWorkflow(id uint64) *workflow_repository.ExistingWorfklowEntity {
+	workflow, err := repository_common.QueryOne[workflow_repository.ExistingWorfklowEntity](
+		s.Ctx,
+		s.Pool,
+		"SELECT * FROM compchem_workflow WHERE id = $1",
+		id,
+	)
+	assert.NoError(s.T(), err)
+
+	return workflow
+}
+
+func (s *stopWorkflowServiceTestSuite) TestStopWorkflow_RunningWorkflow_TerminatedAndContextRevoked() {
+	t := s.T()
+	revoked := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			assert.Equal(t, "/api/v1/workflows/argo/count-words-ej26y-ad28j-1/terminate", r.URL.Path)
+
+			var body stopRequest
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, stopRequest{Name: "count-words-ej26y-ad28j-1", Namespace: "argo"}, body)
+
+			w.Write([]byte(`{"metadata":{"name":"count-words-ej26y-ad28j-1","uid":"a1b2"},"status":{"phase":"Failed","message":"Stopped with strategy 'Terminate'","progress":"1/3"}}`))
+		case http.MethodDelete:
+			assert.Equal(t, "/workflows/count-words-ej26y-ad28j-1/context", r.URL.Path)
+			assert.Equal(t, "mysecretkey", r.URL.Query().Get("secret_key"))
+			revoked = true
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	wf := s.createWorkflow(true)
+
+	response, err := StopWorkflow(
+		s.Ctx,
+		s.Logger,
+		s.Pool,
+		s.kube,
+		nil,
+		server.URL,
+		"argo",
+		server.URL,
+		"count-words-ej26y-ad28j-1",
+		ActionTerminate,
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Failed", response.Phase)
+	assert.Equal(t, ActionTerminate, response.Action)
+	assert.True(t, response.ContextRevoked)
+	assert.True(t, revoked)
+
+	stored := s.getWorkflow(wf.Id)
+	assert.Equal(t, "Failed", stored.Phase)
+	assert.Equal(t, "terminate", *stored.CancelAction)
+	assert.NotNil(t, stored.CancelledAt)
+	assert.Equal(t, "Stopped with strategy 'Terminate'", *stored.Message)
+}
+
+func (s *stopWorkflowServiceTestSuite) TestStopWorkflow_RunningWorkflow_ContextLeftToExitHandler() {
+	t := s.T()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method, "context should not be revoked")
+		assert.Equal(t, "/api/v1/workflows/argo/count-words-ej26y-ad28j-1/stop", r.URL.Path)
+		w.Write([]byte(`{"metadata":{"name":"count-words-ej26y-ad28j-1","uid":"a1b2"},"status":{"phase":"Running","progress":"1/3"}}`))
+	}))
+	defer server.Close()
+
+	wf := s.createWorkflow(true)
+
+	response, err := StopWorkflow(
+		s.Ctx,
+		s.Logger,
+		s.Pool,
+		s.kube,
+		nil,
+		server.URL,
+		"argo",
+		server.URL,
+		"count-words-ej26y-ad28j-1",
+		ActionStop,
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Running", response.Phase)
+	assert.False(t, response.ContextRevoked)
+	assert.Equal(t, "stop", *s.getWorkflow(wf.Id).CancelAction)
+}
+
+func (s *stopWorkflowServiceTestSuite) TestStopWorkflow_PendingSubmission_CancelledWithoutArgo() {
+	t := s.T()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodDelete, r.Method, "argo should not be called")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	wf := s.createWorkflow(false)
+
+	response, err := StopWorkflow(
+		s.Ctx,
+		s.Logger,
+		s.Pool,
+		s.kube,
+		nil,
+		server.URL,
+		"argo",
+		server.URL,
+		"count-words-ej26y-ad28j-1",
+		ActionStop,
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, workflow_repository.PhaseCancelled, response.Phase)
+	assert.True(t, response.ContextRevoked)
+
+	stored := s.getWorkflow(wf.Id)
+	assert.Equal(t, workflow_repository.PhaseCancelled, stored.Phase)
+	assert.Equal(t, "stop", *stored.CancelAction)
+
+	submission, err := repository_common.QueryOne[submission_repository.ExistingSubmissionEntity](
+		s.Ctx,
+		s.Pool,
+		"SELECT * FROM compchem_workflow_submission WHERE compchem_workflow_id = $1",
+		wf.Id,
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, submission_repository.SubmissionCancelled, submission.Status)
+}
+
+func (s *stopWorkflowServiceTestSuite) TestStopWorkflow_FinishedWorkflow_ErrWorkflowFinished() {
+	wf := s.createWorkflow(true)
+	_, err := s.Pool.Exec(s.Ctx, "UPDATE compchem_workflow SET phase = 'Succeeded' WHERE id = $1", wf.Id)
+	assert.NoError(s.T(), err)
+
+	response, err := StopWorkflow(
+		s.Ctx,
+		s.Logger,
+		s.Pool,
+		s.kube,
+		nil,
+		"http://localhost:1",
+		"argo",
+		"http://localhost:1",
+		"count-words-ej26y-ad28j-1",
+		ActionStop,
+	)
+
+	assert.ErrorIs(s.T(), err, ErrWorkflowFinished)
+	assert.Nil(s.T(), response)
+	assert.Nil(s.T(), s.getWorkflow(wf.Id).CancelAction)
+}
+
+func (s *stopWorkflowServiceTestSuite) TestStopWorkflow_UnknownWorkflow_ErrWorkflowNotFound() {
+	response, err := StopWorkflow(
+		s.Ctx,
+		s.Logger,
+		s.Pool,
+		s.kube,
+		nil,
+		"http://localhost:1",
+		"argo",
+		"http://localhost:1",
+		"count-words-ej26y-ad28j-7",
+		ActionTerminate,
+	)
+
+	assert.ErrorIs(s.T(), err, ErrWorkflowNotFound)
+	assert.Nil(s.T(), response)
+}
+
+func TestStopWorkflowServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(stopWorkflowServiceTestSuite))
+}
+
+func TestRevokeContext_NameWithReservedCharacters_PathEscaped(t *testing.T) {
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.EscapedPath()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	revoked := revokeContext(context.Background(), zap.NewNop(), server.URL, "count/words?1", "key")
+
+	assert.True(t, revoked)
+	assert.Equal(t, "/workflows/count%2Fwords%3F1/context", path)
+}
@@ -0,0 +1,557 @@
+package submitworkflow_service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"fi.muni.cz/invenio-file-processor/v2/auth"
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/httpclient"
+	"fi.muni.cz/invenio-file-processor/v2/kubeclient"
+	repository_common "fi.muni.cz/invenio-file-processor/v2/repository/common"
+	"fi.muni.cz/invenio-file-processor/v2/repository/submission_repository"
+	"fi.muni.cz/invenio-file-processor/v2/repository/workflow_repository"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// Lease is how long a claimed submission is left to the dispatcher which claimed it, it has to
+// outlast the kubernetes and argo calls of one attempt including their retries.
+type DispatcherOpts struct {
+	Interval    time.Duration
+	BatchSize   int
+	MaxAttempts int
+	RetryDelay  time.Duration
+	MaxDelay    time.Duration
+	Lease       time.Duration
+}
+
+const (
+	DefaultInterval    = 5 * time.Second
+	DefaultBatchSize   = 20
+	DefaultMaxAttempts = 10
+	DefaultMaxDelay    = 10 * time.Minute
+	DefaultLease       = 10 * time.Minute
+)
+
+func NewDispatcherOpts(conf config.SubmissionConfig) DispatcherOpts {
+	opts := DispatcherOpts{
+		Interval:    DefaultInterval,
+		BatchSize:   DefaultBatchSize,
+		MaxAttempts: DefaultMaxAttempts,
+		RetryDelay:  DefaultInterval,
+		MaxDelay:    DefaultMaxDelay,
+		Lease:       DefaultLease,
+	}
+
+	if conf.IntervalSeconds > 0 {
+		opts.Interval = time.Duration(conf.IntervalSeconds) * time.Second
+		opts.RetryDelay = opts.Interval
+	}
+	if conf.BatchSize > 0 {
+		opts.BatchSize = conf.BatchSize
+	}
+	if conf.MaxAttempts > 0 {
+		opts.MaxAttempts = conf.MaxAttempts
+	}
+
+	return opts
+}
+
+type submitRequest struct {
+	Workflow json.RawMessage `json:"workflow"`
+}
+
+type submittedWorkflow struct {
+	Metadata struct {
+		Name      string            `json:"name"`
+		Namespace string            `json:"namespace"`
+		Labels    map[string]string `json:"labels"`
+	} `json:"metadata"`
+	Spec struct {
+		ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds"`
+	} `json:"spec"`
+}
+
+type claimedSubmission struct {
+	submission *submission_repository.ExistingSubmissionEntity
+	workflow   submittedWorkflow
+	namespace  string
+	secretKey  *string
+}
+
+type submitResponse struct {
+	Metadata struct {
+		Uid string `json:"uid"`
+	} `json:"metadata"`
+}
+
+// Submits workflows persisted in the submission outbox to argo until ctx is cancelled, the secret
+// of a workflow is created in kubernetes right before it is submitted.
+func RunDispatcher(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	kube *kubeclient.Client,
+	tokens *auth.WorkflowTokenSigner,
+	argoUrl string,
+	namespace string,
+	opts DispatcherOpts,
+) {
+	logger.Info(
+		"Starting workflow submission dispatcher",
+		zap.Duration("interval", opts.Interval),
+		zap.Int("max-attempts", opts.MaxAttempts),
+	)
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		dispatchDue(ctx, logger, pool, kube, tokens, argoUrl, namespace, opts)
+
+		select {
+		case <-ctx.Done():
+			logger.Info("Stopping workflow submission dispatcher")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func dispatchDue(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	kube *kubeclient.Client,
+	tokens *auth.WorkflowTokenSigner,
+	argoUrl string,
+	namespace string,
+	opts DispatcherOpts,
+) int {
+	dispatched := 0
+
+	for range opts.BatchSize {
+		found, err := dispatchNext(ctx, logger, pool, kube, tokens, argoUrl, namespace, opts)
+		if err != nil {
+			logger.Error("Error when dispatching workflow submission", zap.Error(err))
+			return dispatched
+		}
+		if !found {
+			return dispatched
+		}
+		dispatched++
+	}
+
+	return dispatched
+}
+
+// Each submission is claimed in its own transaction and its outcome recorded in another one, argo
+// and the kubernetes api are called in between so no connection is held while they answer. A
+// workflow which could not be made the owner of its secret still counts as submitted, its secret
+// is left behind when the workflow is deleted.
+func dispatchNext(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	kube *kubeclient.Client,
+	tokens *auth.WorkflowTokenSigner,
+	argoUrl string,
+	namespace string,
+	opts DispatcherOpts,
+) (bool, error) {
+	claimed, err := claimNextDue(ctx, logger, pool, tokens, namespace, opts)
+	if err != nil || claimed == nil {
+		return false, err
+	}
+
+	secretCreated, argoUid, submitErr := submitWithSecret(
+		ctx,
+		logger,
+		kube,
+		argoUrl,
+		claimed.namespace,
+		claimed.submission,
+		claimed.workflow,
+		claimed.secretKey,
+	)
+	submitted := submitErr == nil || isAlreadyExists(submitErr)
+	if submitted {
+		ownErr := ownWorkflowSecret(
+			ctx,
+			logger,
+			kube,
+			argoUrl,
+			claimed.namespace,
+			claimed.workflow,
+			argoUid,
+		)
+		if ownErr != nil {
+			logger.Error(
+				"Workflow does not own its secret",
+				zap.Uint64("workflowId", claimed.submission.WorkflowId),
+				zap.Error(ownErr),
+			)
+		}
+	}
+
+	recorded, failed, err := recordOutcome(
+		ctx,
+		logger,
+		pool,
+		claimed,
+		secretCreated,
+		argoUid,
+		submitErr,
+		opts,
+	)
+	if err != nil {
+		return false, err
+	}
+	if !recorded {
+		withdrawSubmission(ctx, logger, kube, argoUrl, claimed, submitted)
+	} else if failed {
+		err = deleteWorkflowSecret(ctx, logger, kube, claimed.namespace, claimed.workflow)
+		if err != nil {
+			logger.Error(
+				"Secret of failed workflow submission left behind",
+				zap.Uint64("workflowId", claimed.submission.WorkflowId),
+				zap.Error(err),
+			)
+		}
+	}
+
+	return true, nil
+}
+
+// Returns nil when no submission is due. The secret key is resolved while the submission is
+// claimed since signed tokens are minted from the files of the workflow.
+func claimNextDue(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	tokens *auth.WorkflowTokenSigner,
+	namespace string,
+	opts DispatcherOpts,
+) (*claimedSubmission, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	submission, err := submission_repository.ClaimNextDueSubmission(
+		ctx,
+		logger,
+		tx,
+		time.Now().Add(opts.Lease),
+	)
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+	if submission == nil {
+		tx.Rollback(ctx)
+		return nil, nil
+	}
+
+	workflow := readSubmittedWorkflow(submission.Workflow)
+	secretKey, err := submissionSecretKey(ctx, logger, tx, tokens, submission, workflow)
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+
+	err = repository_common.CommitTx(ctx, tx, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &claimedSubmission{
+		submission: submission,
+		workflow:   workflow,
+		namespace:  submissionNamespace(submission.Workflow, namespace),
+		secretKey:  secretKey,
+	}, nil
+}
+
+// Returns false for recorded when the claim is gone, i.e. the submission was cancelled meanwhile,
+// and whether the submission failed for good.
+func recordOutcome(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	claimed *claimedSubmission,
+	secretCreated bool,
+	argoUid *string,
+	submitErr error,
+	opts DispatcherOpts,
+) (bool, bool, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return false, false, err
+	}
+
+	submission := claimed.submission
+	if secretCreated {
+		err = submission_repository.ClearSecretKey(ctx, logger, tx, submission.Id)
+		if err != nil {
+			tx.Rollback(ctx)
+			return false, false, err
+		}
+	}
+
+	recorded, failed := false, false
+	if submitErr == nil || isAlreadyExists(submitErr) {
+		recorded, err = markSubmitted(ctx, logger, tx, submission, argoUid)
+	} else {
+		failed = !isRetryable(submitErr) || submission.Attempts+1 >= opts.MaxAttempts
+		message := submitErr.Error()
+		logger.Warn(
+			"Workflow submission attempt failed",
+			zap.Uint64("workflowId", submission.WorkflowId),
+			zap.Int("attempt", submission.Attempts+1),
+			zap.Bool("failed", failed),
+			zap.Error(submitErr),
+		)
+		recorded, err = submission_repository.RecordFailedAttempt(
+			ctx,
+			logger,
+			tx,
+			submission.Id,
+			message,
+			time.Now().Add(retryDelay(submission.Attempts, opts)),
+			failed,
+		)
+		if err == nil && recorded && failed {
+			err = workflow_repository.MarkWorkflowSubmissionFailed(
+				ctx,
+				logger,
+				tx,
+				submission.WorkflowId,
+				message,
+			)
+		}
+	}
+	if err != nil {
+		tx.Rollback(ctx)
+		return false, false, err
+	}
+
+	return recorded, failed, repository_common.CommitTx(ctx, tx, logger)
+}
+
+func markSubmitted(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	submission *submission_repository.ExistingSubmissionEntity,
+	argoUid *string,
+) (bool, error) {
+	marked, err := submission_repository.MarkSubmitted(ctx, logger, tx, submission.Id)
+	if err != nil || !marked {
+		return false, err
+	}
+
+	return true, workflow_repository.MarkWorkflowSubmitted(
+		ctx,
+		logger,
+		tx,
+		submission.WorkflowId,
+		argoUid,
+	)
+}
+
+// A submission cancelled while it was being submitted is removed from argo again together with
+// its secret, failures are only logged since the workflow is cancelled already.
+func withdrawSubmission(
+	ctx context.Context,
+	logger *zap.Logger,
+	kube *kubeclient.Client,
+	argoUrl string,
+	claimed *claimedSubmission,
+	submitted bool,
+) {
+	workflowId := claimed.submission.WorkflowId
+	logger.Info("Withdrawing cancelled workflow submission", zap.Uint64("workflowId", workflowId))
+
+	if submitted {
+		url := buildWorkflowUrl(claimed.namespace, argoUrl, claimed.workflow.Metadata.Name)
+		err := httpclient.DeleteRequest(ctx, logger, url, true)
+		if err != nil {
+			logger.Error(
+				"Cancelled workflow left in argo",
+				zap.Uint64("workflowId", workflowId),
+				zap.Error(err),
+			)
+		}
+	}
+
+	err := deleteWorkflowSecret(ctx, logger, kube, claimed.namespace, claimed.workflow)
+	if err != nil {
+		logger.Error(
+			"Secret of cancelled workflow submission left behind",
+			zap.Uint64("workflowId", workflowId),
+			zap.Error(err),
+		)
+	}
+}
+
+// Errors of the kubernetes api are classified like errors of argo, a secret the api rejects fails
+// the submission. Returns whether the secret was created, the secret key is dropped from the
+// outbox once the secret holds it and a nil key means an earlier attempt created the secret.
+func submitWithSecret(
+	ctx context.Context,
+	logger *zap.Logger,
+	kube *kubeclient.Client,
+	argoUrl string,
+	namespace string,
+	submission *submission_repository.ExistingSubmissionEntity,
+	workflow submittedWorkflow,
+	secretKey *string,
+) (bool, *string, error) {
+	secretCreated := false
+	if secretKey != nil {
+		err := createWorkflowSecret(ctx, logger, kube, namespace, workflow, *secretKey)
+		if err != nil {
+			return false, nil, err
+		}
+		secretCreated = true
+	}
+
+	argoUid, err := submitWorkflow(ctx, logger, argoUrl, namespace, submission.Workflow)
+	return secretCreated, argoUid, err
+}
+
+// Returns uid assigned by argo, nil when argo did not provide one.
+func submitWorkflow(
+	ctx context.Context,
+	logger *zap.Logger,
+	argoUrl string,
+	namespace string,
+	workflow []byte,
+) (*string, error) {
+	url := buildWorkflowUrl(namespace, argoUrl)
+	logger.Info("Submitting workflow to argo", zap.String("url", url))
+
+	response, err := httpclient.PostRequest[submitResponse](ctx, logger, url, &submitRequest{
+		Workflow: workflow,
+	}, true)
+	if err != nil || response.Metadata.Uid == "" {
+		return nil, err
+	}
+
+	return &response.Metadata.Uid, nil
+}
+
+// Workflows are stored as jsonb so they always decode, missing metadata is left empty.
+func readSubmittedWorkflow(workflow []byte) submittedWorkflow {
+	var submitted submittedWorkflow
+	_ = json.Unmarshal(workflow, &submitted)
+
+	return submitted
+}
+
+// Workflows configured with their own namespace carry it in metadata, the rest is submitted to
+// the default namespace.
+func submissionNamespace(workflow []byte, defaultNamespace string) string {
+	submitted := readSubmittedWorkflow(workflow)
+	if submitted.Metadata.Namespace == "" {
+		return defaultNamespace
+	}
+
+	return submitted.Metadata.Namespace
+}
+
+// argo answers with conflict when a workflow with the same name exists, that happens when a
+// previous attempt got through but its outcome could not be recorded
+func isAlreadyExists(err error) bool {
+	var clientErr *httpclient.ClientError
+	return errors.As(err, &clientErr) && clientErr.Status == http.StatusConflict
+}
+
+// Client errors of argo and the kubernetes api are permanent apart from timeouts and rate limiting,
+// server and connection errors are retried. Conflicts are handled by isAlreadyExists before.
+func isRetryable(err error) bool {
+	var clientErr *httpclient.ClientError
+	if !errors.As(err, &clientErr) {
+		return true
+	}
+
+	switch clientErr.Status {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	default:
+		return false
+	}
+}
+
+func retryDelay(attempts int, opts DispatcherOpts) time.Duration {
+	delay := opts.RetryDelay
+	for range attempts {
+		delay *= 2
+		if delay >= opts.MaxDelay {
+			return opts.MaxDelay
+		}
+	}
+
+	return delay
+}
+
+func buildWorkflowUrl(namespace string, argoUrl string, more ...string) string {
+	if len(more) == 0 {
+		return fmt.Sprintf("%s/api/v1/workflows/%s", argoUrl, namespace)
+	}
+	return fmt.Sprintf("%s/api/v1/workflows/%s/%s", argoUrl, namespace, strings.Join(more, "/"))
+}
+
+func EnqueueWorkflow(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	workflowId uint64,
+	workflow any,
+	secretKey string,
+) error {
+	body, err := json.Marshal(workflow)
+	if err != nil {
+		logger.Error("Failed to serialize workflow for submission", zap.Error(err))
+		return err
+	}
+
+	_, err = submission_repository.CreateSubmission(ctx, logger, tx, workflowId, body, secretKey)
+	return err
+}
+
+// The secret key is kept in the outbox until the kubernetes secret of the workflow is created and
+// read from the secret afterwards, empty when neither has it.
+func FindSecretKey(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	kube *kubeclient.Client,
+	namespace string,
+	workflow *workflow_repository.ExistingWorfklowEntity,
+) (string, error) {
+	submission, err := submission_repository.FindSubmissionForWorkflow(
+		ctx,
+		logger,
+		tx,
+		workflow.RecordId,
+		workflow.WorkflowName,
+		workflow.WorkflowSeqId,
+	)
+	if err != nil {
+		return "", err
+	}
+	if submission != nil && submission.SecretKey != nil {
+		return *submission.SecretKey, nil
+	}
+
+	return ReadWorkflowSecret(ctx, logger, kube, namespace, workflow.FullName)
+}
@@ -0,0 +1,456 @@
+package submitworkflow_service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fi.muni.cz/invenio-file-processor/v2/auth"
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/httpclient"
+	"fi.muni.cz/invenio-file-processor/v2/kubeclient"
+	kubetest "fi.muni.cz/invenio-file-processor/v2/kubeclient/test"
+	repository_common "fi.muni.cz/invenio-file-processor/v2/repository/common"
+	"fi.muni.cz/invenio-file-processor/v2/repository/submission_repository"
+	repositorytest "fi.muni.cz/invenio-file-processor/v2/repository/test"
+	"fi.muni.cz/invenio-file-processor/v2/repository/workflow_repository"
+	"fi.muni.cz/invenio-file-processor/v2/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestGetArgoUrl_ArgsProvided_UrlCorrectlyFormed(t *testing.T) {
+	baseUrl := "https://argo-service.kubernetes.local"
+	namespace := "argo"
+
+	result := buildWorkflowUrl(namespace, baseUrl, "submit")
+	assert.Equal(
+		t,
+		"https://argo-service.kubernetes.local/api/v1/workflows/argo/submit",
+		result,
+		"urls should match",
+	)
+}
+
+func TestSubmissionNamespace_NamespaceInMetadata_NamespaceUsed(t *testing.T) {
+	assert.Equal(
+		t,
+		"compchem-gpu",
+		submissionNamespace([]byte(`{"metadata":{"name":"a","namespace":"compchem-gpu"}}`), "argo"),
+	)
+	assert.Equal(t, "argo", submissionNamespace([]byte(`{"metadata":{"name":"a"}}`), "argo"))
+	assert.Equal(t, "argo", submissionNamespace([]byte(`{}`), "argo"))
+}
+
+func TestNewDispatcherOpts_EmptyConfig_DefaultsUsed(t *testing.T) {
+	opts := NewDispatcherOpts(config.SubmissionConfig{})
+
+	assert.Equal(t, DefaultInterval, opts.Interval)
+	assert.Equal(t, DefaultBatchSize, opts.BatchSize)
+	assert.Equal(t, DefaultMaxAttempts, opts.MaxAttempts)
+	assert.Equal(t, DefaultMaxDelay, opts.MaxDelay)
+	assert.Equal(t, DefaultLease, opts.Lease)
+}
+
+func TestNewDispatcherOpts_ConfigSet_ConfigUsed(t *testing.T) {
+	opts := NewDispatcherOpts(config.SubmissionConfig{
+		IntervalSeconds: 30,
+		MaxAttempts:     3,
+		BatchSize:       1,
+	})
+
+	assert.Equal(t, 30*time.Second, opts.Interval)
+	assert.Equal(t, 30*time.Second, opts.RetryDelay)
+	assert.Equal(t, 1, opts.BatchSize)
+	assert.Equal(t, 3, opts.MaxAttempts)
+}
+
+func TestRetryDelay_AttemptsGrow_DelayDoublesUntilCap(t *testing.T) {
+	opts := DispatcherOpts{RetryDelay: time.Second, MaxDelay: 5 * time.Second}
+
+	assert.Equal(t, time.Second, retryDelay(0, opts))
+	assert.Equal(t, 2*time.Second, retryDelay(1, opts))
+	assert.Equal(t, 4*time.Second, retryDelay(2, opts))
+	assert.Equal(t, 5*time.Second, retryDelay(3, opts))
+	assert.Equal(t, 5*time.Second, retryDelay(50, opts))
+}
+
+func TestErrorClassification(t *testing.T) {
+	conflict := fmt.Errorf("wrapped: %w", &httpclient.ClientError{Status: http.StatusConflict})
+	badRequest := &httpclient.ClientError{Status: http.StatusBadRequest}
+	unavailable := fmt.Errorf("request failed: %w", &httpclient.ServerError{Status: 503})
+
+	assert.True(t, isAlreadyExists(conflict))
+	assert.False(t, isAlreadyExists(badRequest))
+	assert.False(t, isRetryable(conflict))
+
+	forbidden := fmt.Errorf(
+		"Error when creating workflow secret: %w",
+		&httpclient.ClientError{Status: http.StatusForbidden},
+	)
+	throttled := fmt.Errorf(
+		"Error when creating workflow secret: %w",
+		&httpclient.ClientError{Status: http.StatusTooManyRequests},
+	)
+
+	assert.False(t, isRetryable(badRequest))
+	assert.False(t, isRetryable(forbidden))
+	assert.True(t, isRetryable(throttled))
+	assert.True(t, isRetryable(unavailable))
+	assert.True(t, isRetryable(errors.New("connection refused")))
+}
+
+type dispatcherTestSuite struct {
+	repositorytest.PostgresTestSuite
+}
+
+func (s *dispatcherTestSuite) SetupSuite() {
+	s.PostgresTestSuite.MigratonsPath = "file://../../migrations"
+	s.PostgresTestSuite.SetupSuite()
+}
+
+func (s *dispatcherTestSuite) TearDownSuite() {
+	s.PostgresTestSuite.TearDownSuite()
+}
+
+func (s *dispatcherTestSuite) TearDownTest() {
+	assert.NoError(s.T(), repositorytest.ClearTable(s.Ctx, s.Pool, "compchem_workflow_submission"))
+	assert.NoError(s.T(), repositorytest.ClearTable(s.Ctx, s.Pool, "compchem_workflow"))
+}
+
+func (s *dispatcherTestSuite) enqueue(seq uint64) uint64 {
+	tx, err := s.Pool.Begin(s.Ctx)
+	assert.NoError(s.T(), err)
+
+	wf, err := workflow_repository.CreateWorkflowForRecord(
+		s.Ctx,
+		s.Logger,
+		tx,
+		workflow_repository.WorkflowEntity{
+			RecordId:      "ej26y-ad28j",
+			WorkflowName:  "count-words",
+			WorkflowSeqId: seq,
+		},
+	)
+	assert.NoError(s.T(), err)
+
+	err = EnqueueWorkflow(s.Ctx, s.Logger, tx, wf.Id, map[string]any{
+		"metadata": map[string]string{"name": fmt.Sprintf("count-words-ej26y-ad28j-%d", seq)},
+	}, fmt.Sprintf("secret-%d", seq))
+	assert.NoError(s.T(), err)
+	assert.NoError(s.T(), tx.Commit(s.Ctx))
+
+	return wf.Id
+}
+
+func (s *dispatcherTestSuite) newKube() (*kubetest.FakeApiServer, *kubeclient.Client) {
+	fake := kubetest.NewFakeApiServer()
+	kube, err := kubeclient.NewClient(config.KubernetesApi{Url: fake.URL})
+	assert.NoError(s.T(), err)
+
+	return fake, kube
+}
+
+func (s *dispatcherTestSuite) getSubmission(workflowId uint64) *submission_repository.ExistingSubmissionEntity {
+	submission, err := repository_common.QueryOne[submission_repository.ExistingSubmissionEntity](
+		s.Ctx,
+		s.Pool,
+		"SELECT * FROM compchem_workflow_submission WHERE compchem_workflow_id = $1",
+		workflowId,
+	)
+	assert.NoError(s.T(), err)
+
+	return submission
+}
+
+func (s *dispatcherTestSuite) getWorkflow(workflowId uint64) *workflow_repository.ExistingWorfklowEntity {
+	workflow, err := repository_common.QueryOne[workflow_repository.ExistingWorfklowEntity](
+		s.Ctx,
+		s.Pool,
+		"SELECT * FROM compchem_workflow WHERE id = $1",
+		workflowId,
+	)
+	assert.NoError(s.T(), err)
+
+	return workflow
+}
+
+func (s *dispatcherTestSuite) TestDispatchDue_ArgoAccepts_SubmissionMarkedSubmitted() {
+	t := s.T()
+	received := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/api/v1/workflows/compchem", r.URL.Path)
+
+		var body struct {
+			Workflow struct {
+				Metadata struct {
+					Name string `json:"name"`
+				} `json:"metadata"`
+			} `json:"workflow"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		received = append(received, body.Workflow.Metadata.Name)
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(fmt.Sprintf(`{"metadata":{"uid":"uid-%s"}}`, body.Workflow.Metadata.Name)))
+	}))
+	defer server.Close()
+
+	fake, kube := s.newKube()
+	defer fake.Close()
+
+	first := s.enqueue(1)
+	second := s.enqueue(2)
+
+	opts := NewDispatcherOpts(config.SubmissionConfig{})
+	dispatched := dispatchDue(s.Ctx, s.Logger, s.Pool, kube, nil, server.URL, "compchem", opts)
+
+	assert.Equal(t, 2, dispatched)
+	assert.ElementsMatch(t, []string{"count-words-ej26y-ad28j-1", "count-words-ej26y-ad28j-2"}, received)
+	assert.Equal(t, submission_repository.SubmissionSubmitted, s.getSubmission(first).Status)
+	assert.Equal(t, submission_repository.SubmissionSubmitted, s.getSubmission(second).Status)
+	assert.Nil(t, s.getSubmission(first).SecretKey, "secret key should only be kept in the secret")
+
+	workflow := s.getWorkflow(first)
+	assert.Equal(t, workflow_repository.PhasePending, workflow.Phase)
+	assert.Equal(t, "uid-count-words-ej26y-ad28j-1", *workflow.ArgoUid)
+	assert.NotNil(t, workflow.SubmittedAt)
+
+	secret := fake.Secret("compchem", "count-words-ej26y-ad28j-1-key")
+	assert.Equal(t, map[string]string{"secret-key": "secret-1"}, secret.StringData)
+	assert.Equal(t, []kubetest.OwnerReference{{
+		ApiVersion: "argoproj.io/v1alpha1",
+		Kind:       "Workflow",
+		Name:       "count-words-ej26y-ad28j-1",
+		Uid:        "uid-count-words-ej26y-ad28j-1",
+	}}, secret.OwnerReferences)
+}
+
+func (s *dispatcherTestSuite) TestDispatchDue_ArgoAlreadyHasWorkflow_SubmissionMarkedSubmitted() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"metadata":{"uid":"existing-uid"}}`))
+			return
+		}
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"message":"already exists"}`))
+	}))
+	defer server.Close()
+	fake, kube := s.newKube()
+	defer fake.Close()
+
+	workflowId := s.enqueue(1)
+
+	opts := NewDispatcherOpts(config.SubmissionConfig{})
+	dispatchDue(s.Ctx, s.Logger, s.Pool, kube, nil, server.URL, "argo", opts)
+
+	assert.Equal(s.T(), submission_repository.SubmissionSubmitted, s.getSubmission(workflowId).Status)
+	secret := fake.Secret("argo", "count-words-ej26y-ad28j-1-key")
+	assert.Equal(s.T(), "existing-uid", secret.OwnerReferences[0].Uid)
+}
+
+func (s *dispatcherTestSuite) TestDispatchDue_ArgoUnavailable_AttemptRecordedAndRetriedLater() {
+	t := s.T()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	fake, kube := s.newKube()
+	defer fake.Close()
+
+	workflowId := s.enqueue(1)
+
+	opts := NewDispatcherOpts(config.SubmissionConfig{MaxAttempts: 2})
+	dispatched := dispatchDue(s.Ctx, s.Logger, s.Pool, kube, nil, server.URL, "argo", opts)
+	assert.Equal(t, 1, dispatched)
+
+	submission := s.getSubmission(workflowId)
+	assert.Equal(t, submission_repository.SubmissionPending, submission.Status)
+	assert.Equal(t, 1, submission.Attempts)
+	assert.NotNil(t, submission.LastError)
+	assert.True(t, submission.NextAttemptAt.After(time.Now()))
+	assert.Nil(t, submission.SecretKey)
+	assert.Equal(t, workflow_repository.PhaseUnsubmitted, s.getWorkflow(workflowId).Phase)
+
+	dispatched = dispatchDue(s.Ctx, s.Logger, s.Pool, kube, nil, server.URL, "argo", opts)
+	assert.Equal(t, 0, dispatched, "submission should wait for its next attempt")
+	secret := fake.Secret("argo", "count-words-ej26y-ad28j-1-key")
+	assert.Equal(t, "secret-1", secret.StringData["secret-key"], "secret is kept for the next attempt")
+}
+
+func (s *dispatcherTestSuite) TestDispatchDue_KubernetesUnavailable_NotSubmittedToArgo() {
+	t := s.T()
+	submitted := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		submitted = true
+	}))
+	defer server.Close()
+	fake, kube := s.newKube()
+	defer fake.Close()
+	fake.Failing = http.StatusServiceUnavailable
+
+	workflowId := s.enqueue(1)
+
+	opts := NewDispatcherOpts(config.SubmissionConfig{})
+	dispatchDue(s.Ctx, s.Logger, s.Pool, kube, nil, server.URL, "argo", opts)
+
+	submission := s.getSubmission(workflowId)
+	assert.False(t, submitted)
+	assert.Equal(t, submission_repository.SubmissionPending, submission.Status)
+	assert.Contains(t, *submission.LastError, "Error when creating workflow secret")
+	assert.Equal(t, "secret-1", *submission.SecretKey, "secret key is kept until the secret exists")
+}
+
+func (s *dispatcherTestSuite) TestDispatchDue_KubernetesForbidsSecret_SubmissionFailed() {
+	t := s.T()
+	submitted := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		submitted = true
+	}))
+	defer server.Close()
+	fake, kube := s.newKube()
+	defer fake.Close()
+	fake.Failing = http.StatusForbidden
+
+	workflowId := s.enqueue(1)
+
+	opts := NewDispatcherOpts(config.SubmissionConfig{})
+	dispatchDue(s.Ctx, s.Logger, s.Pool, kube, nil, server.URL, "argo", opts)
+
+	submission := s.getSubmission(workflowId)
+	assert.False(t, submitted)
+	assert.Equal(t, submission_repository.SubmissionFailed, submission.Status)
+	assert.Equal(t, 1, submission.Attempts)
+	assert.Equal(t, workflow_repository.PhaseSubmissionFailed, s.getWorkflow(workflowId).Phase)
+}
+
+func (s *dispatcherTestSuite) TestDispatchDue_ArgoRejectsWorkflow_SubmissionFailed() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"message":"templateRef not found"}`))
+	}))
+	defer server.Close()
+	fake, kube := s.newKube()
+	defer fake.Close()
+
+	workflowId := s.enqueue(1)
+
+	opts := NewDispatcherOpts(config.SubmissionConfig{})
+	dispatchDue(s.Ctx, s.Logger, s.Pool, kube, nil, server.URL, "argo", opts)
+
+	submission := s.getSubmission(workflowId)
+	assert.Nil(s.T(), fake.Secret("argo", "count-words-ej26y-ad28j-1-key"))
+	assert.Equal(s.T(), submission_repository.SubmissionFailed, submission.Status)
+	assert.Contains(s.T(), *submission.LastError, "templateRef not found")
+
+	workflow := s.getWorkflow(workflowId)
+	assert.Equal(s.T(), workflow_repository.PhaseSubmissionFailed, workflow.Phase)
+	assert.Contains(s.T(), *workflow.Message, "templateRef not found")
+	assert.Nil(s.T(), workflow.SubmittedAt)
+}
+
+func (s *dispatcherTestSuite) TestDispatchDue_CancelledWhileSubmitting_WithdrawnFromArgo() {
+	t := s.T()
+	var workflowId uint64
+	deleted := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			deleted = r.URL.Path
+			w.Write([]byte(`{}`))
+			return
+		}
+		// the submission is not locked while argo is called, so cancelling does not wait
+		tx, err := s.Pool.Begin(s.Ctx)
+		assert.NoError(t, err)
+		cancelled, err := submission_repository.CancelSubmission(s.Ctx, s.Logger, tx, workflowId)
+		assert.NoError(t, err)
+		assert.True(t, cancelled)
+		assert.NoError(t, tx.Commit(s.Ctx))
+
+		w.Write([]byte(`{"metadata":{"uid":"a1b2"}}`))
+	}))
+	defer server.Close()
+	fake, kube := s.newKube()
+	defer fake.Close()
+
+	workflowId = s.enqueue(1)
+
+	opts := NewDispatcherOpts(config.SubmissionConfig{})
+	dispatchDue(s.Ctx, s.Logger, s.Pool, kube, nil, server.URL, "argo", opts)
+
+	assert.Equal(t, submission_repository.SubmissionCancelled, s.getSubmission(workflowId).Status)
+	assert.Equal(t, "/api/v1/workflows/argo/count-words-ej26y-ad28j-1", deleted)
+	assert.Nil(t, fake.Secret("argo", "count-words-ej26y-ad28j-1-key"))
+	assert.Nil(t, s.getWorkflow(workflowId).SubmittedAt)
+}
+
+func newTokenSigner(t *testing.T) *auth.WorkflowTokenSigner {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	data, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "signing.pem")
+	err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: data}), 0600)
+	assert.NoError(t, err)
+
+	tokens, err := auth.NewWorkflowTokenSigner(&config.WorkflowTokensConfig{
+		Issuer:              "compchem-fileprocessor",
+		Audience:            "compchem",
+		SigningKeyFile:      path,
+		ExpiryMarginSeconds: 600,
+	})
+	assert.NoError(t, err)
+	return tokens
+}
+
+func (s *dispatcherTestSuite) TestDispatchDue_TokensConfigured_TokenMintedWhenSubmitted() {
+	t := s.T()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"metadata":{"uid":"a1b2"}}`))
+	}))
+	defer server.Close()
+	fake, kube := s.newKube()
+	defer fake.Close()
+	tokens := newTokenSigner(t)
+
+	workflowId := s.enqueue(1)
+	_, err := s.Pool.Exec(
+		s.Ctx,
+		"UPDATE compchem_workflow_submission SET workflow = workflow || $1::jsonb",
+		`{"spec":{"activeDeadlineSeconds":7200}}`,
+	)
+	assert.NoError(t, err)
+
+	opts := NewDispatcherOpts(config.SubmissionConfig{})
+	dispatchDue(s.Ctx, s.Logger, s.Pool, kube, tokens, server.URL, "argo", opts)
+
+	token := fake.Secret("argo", "count-words-ej26y-ad28j-1-key").StringData["secret-key"]
+	claims, err := tokens.Verify(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "count-words-ej26y-ad28j-1", claims.Workflow)
+	assert.Equal(t, "ej26y-ad28j", claims.RecordId)
+	assert.WithinDuration(t, time.Now(), time.Unix(claims.IssuedAt, 0), time.Minute)
+	assert.WithinDuration(
+		t,
+		time.Now().Add(2*time.Hour+10*time.Minute),
+		time.Unix(claims.ExpiresAt, 0),
+		time.Minute,
+	)
+	assert.True(t, util.SecretKeyMatches(token, *s.getWorkflow(workflowId).SecretKeyHash))
+}
+
+func TestDispatcherTestSuite(t *testing.T) {
+	suite.Run(t, new(dispatcherTestSuite))
+}
@@ -0,0 +1,144 @@
+package submitworkflow_service
+
+import (
+	"context"
+	"fmt"
+
+	"fi.muni.cz/invenio-file-processor/v2/api/argodtos"
+	"fi.muni.cz/invenio-file-processor/v2/httpclient"
+	"fi.muni.cz/invenio-file-processor/v2/kubeclient"
+	"go.uber.org/zap"
+)
+
+// Creates the secret the steps of the workflow read its secret key from. The secret of a previous
+// attempt is reused with the key written again. Labels of the workflow are copied to the secret
+// so secrets of an instance can be listed.
+func createWorkflowSecret(
+	ctx context.Context,
+	logger *zap.Logger,
+	kube *kubeclient.Client,
+	namespace string,
+	workflow submittedWorkflow,
+	secretKey string,
+) error {
+	secret := kubeclient.Secret{
+		Metadata: kubeclient.ObjectMeta{
+			Name:      argodtos.SecretName(workflow.Metadata.Name),
+			Namespace: namespace,
+			Labels:    workflow.Metadata.Labels,
+		},
+		Type:       "Opaque",
+		StringData: map[string]string{argodtos.SecretKeyField: secretKey},
+	}
+	err := kube.CreateSecret(ctx, logger, secret)
+	if isAlreadyExists(err) {
+		err = kube.PatchSecret(ctx, logger, namespace, secret.Metadata.Name, kubeclient.Secret{
+			StringData: secret.StringData,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("Error when creating workflow secret: %w", err)
+	}
+
+	return nil
+}
+
+// Makes the workflow the owner of its secret so kubernetes deletes the secret with the workflow.
+// The uid is looked up in argo when the submission did not return one.
+func ownWorkflowSecret(
+	ctx context.Context,
+	logger *zap.Logger,
+	kube *kubeclient.Client,
+	argoUrl string,
+	namespace string,
+	workflow submittedWorkflow,
+	argoUid *string,
+) error {
+	name := workflow.Metadata.Name
+
+	if argoUid == nil {
+		url := buildWorkflowUrl(namespace, argoUrl, name)
+		response, err := httpclient.GetRequest[submitResponse](ctx, logger, url, true)
+		if err != nil {
+			return fmt.Errorf("Error when retrieving uid of workflow %s: %w", name, err)
+		}
+		if response.Metadata.Uid == "" {
+			return fmt.Errorf("argo did not return uid of workflow %s", name)
+		}
+		argoUid = &response.Metadata.Uid
+	}
+
+	err := kube.PatchSecret(ctx, logger, namespace, argodtos.SecretName(name), kubeclient.Secret{
+		Metadata: kubeclient.ObjectMeta{
+			OwnerReferences: []kubeclient.OwnerReference{{
+				ApiVersion: "argoproj.io/v1alpha1",
+				Kind:       "Workflow",
+				Name:       name,
+				Uid:        *argoUid,
+			}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("Error when setting owner of workflow secret: %w", err)
+	}
+
+	return nil
+}
+
+// Secret of a workflow which is not going to be submitted has no owner to be deleted with.
+func deleteWorkflowSecret(
+	ctx context.Context,
+	logger *zap.Logger,
+	kube *kubeclient.Client,
+	namespace string,
+	workflow submittedWorkflow,
+) error {
+	name := argodtos.SecretName(workflow.Metadata.Name)
+	if err := kube.DeleteSecret(ctx, logger, namespace, name); err != nil {
+		return fmt.Errorf("Error when deleting workflow secret: %w", err)
+	}
+
+	return nil
+}
+
+// Replaces the secret key steps of a submitted workflow read, steps started afterwards get it.
+func UpdateWorkflowSecret(
+	ctx context.Context,
+	logger *zap.Logger,
+	kube *kubeclient.Client,
+	namespace string,
+	workflowFullName string,
+	secretKey string,
+) error {
+	err := kube.PatchSecret(
+		ctx,
+		logger,
+		namespace,
+		argodtos.SecretName(workflowFullName),
+		kubeclient.Secret{StringData: map[string]string{argodtos.SecretKeyField: secretKey}},
+	)
+	if err != nil {
+		return fmt.Errorf("Error when updating workflow secret: %w", err)
+	}
+
+	return nil
+}
+
+// Secret key the steps of a workflow read, empty when the workflow has no secret.
+func ReadWorkflowSecret(
+	ctx context.Context,
+	logger *zap.Logger,
+	kube *kubeclient.Client,
+	namespace string,
+	workflowFullName string,
+) (string, error) {
+	secret, err := kube.GetSecret(ctx, logger, namespace, argodtos.SecretName(workflowFullName))
+	if err != nil {
+		return "", fmt.Errorf("Error when reading workflow secret: %w", err)
+	}
+	if secret == nil {
+		return "", nil
+	}
+
+	return string(secret.Data[argodtos.SecretKeyField]), nil
+}
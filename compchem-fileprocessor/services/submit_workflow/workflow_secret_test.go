@@ -0,0 +1,98 @@
+package submitworkflow_service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/kubeclient"
+	kubetest "fi.muni.cz/invenio-file-processor/v2/kubeclient/test"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+var labeledWorkflow = readSubmittedWorkflow([]byte(`{
+	"metadata": {
+		"name": "count-words-ej26y-ad28j-1",
+		"labels": {"compchem.cerit.io/instance": "compchem-test"}
+	}
+}`))
+
+func newKube(t *testing.T) (*kubetest.FakeApiServer, *kubeclient.Client) {
+	fake := kubetest.NewFakeApiServer()
+	kube, err := kubeclient.NewClient(config.KubernetesApi{Url: fake.URL})
+	assert.NoError(t, err)
+
+	return fake, kube
+}
+
+func TestCreateWorkflowSecret_NewWorkflow_SecretWithKeyAndLabels(t *testing.T) {
+	fake, kube := newKube(t)
+	defer fake.Close()
+	ctx, logger := context.Background(), zap.NewNop()
+
+	err := createWorkflowSecret(ctx, logger, kube, "argo", labeledWorkflow, "key")
+
+	assert.NoError(t, err)
+	secret := fake.Secret("argo", "count-words-ej26y-ad28j-1-key")
+	assert.Equal(t, map[string]string{"secret-key": "key"}, secret.StringData)
+	assert.Equal(t, "compchem-test", secret.Labels["compchem.cerit.io/instance"])
+}
+
+func TestCreateWorkflowSecret_SecretOfPreviousAttempt_KeyWrittenAgain(t *testing.T) {
+	fake, kube := newKube(t)
+	defer fake.Close()
+	ctx, logger := context.Background(), zap.NewNop()
+	assert.NoError(t, createWorkflowSecret(ctx, logger, kube, "argo", labeledWorkflow, "stale"))
+
+	err := createWorkflowSecret(ctx, logger, kube, "argo", labeledWorkflow, "key")
+
+	assert.NoError(t, err)
+	secret := fake.Secret("argo", "count-words-ej26y-ad28j-1-key")
+	assert.Equal(t, "key", secret.StringData["secret-key"])
+}
+
+func TestOwnWorkflowSecret_NoUidFromSubmission_UidLookedUpInArgo(t *testing.T) {
+	// Arrange
+	fake, kube := newKube(t)
+	defer fake.Close()
+	argo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/api/v1/workflows/argo/count-words-ej26y-ad28j-1", r.URL.Path)
+		w.Write([]byte(`{"metadata":{"uid":"50efc9c4-124e-41fa-80ea-61515b4bcc1b"}}`))
+	}))
+	defer argo.Close()
+	ctx, logger := context.Background(), zap.NewNop()
+	assert.NoError(t, createWorkflowSecret(ctx, logger, kube, "argo", labeledWorkflow, "key"))
+
+	// Act
+	err := ownWorkflowSecret(ctx, logger, kube, argo.URL, "argo", labeledWorkflow, nil)
+
+	// Assert
+	assert.NoError(t, err)
+	secret := fake.Secret("argo", "count-words-ej26y-ad28j-1-key")
+	assert.Equal(t, []kubetest.OwnerReference{{
+		ApiVersion: "argoproj.io/v1alpha1",
+		Kind:       "Workflow",
+		Name:       "count-words-ej26y-ad28j-1",
+		Uid:        "50efc9c4-124e-41fa-80ea-61515b4bcc1b",
+	}}, secret.OwnerReferences)
+}
+
+func TestUpdateWorkflowSecret_MissingSecret_ErrReturned(t *testing.T) {
+	fake, kube := newKube(t)
+	defer fake.Close()
+
+	err := UpdateWorkflowSecret(
+		context.Background(),
+		zap.NewNop(),
+		kube,
+		"argo",
+		"count-words-ej26y-ad28j-1",
+		"key",
+	)
+
+	assert.ErrorContains(t, err, "Error when updating workflow secret")
+}
@@ -0,0 +1,89 @@
+package submitworkflow_service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fi.muni.cz/invenio-file-processor/v2/auth"
+	"fi.muni.cz/invenio-file-processor/v2/repository/file_repository"
+	"fi.muni.cz/invenio-file-processor/v2/repository/submission_repository"
+	"fi.muni.cz/invenio-file-processor/v2/repository/workflow_repository"
+	"fi.muni.cz/invenio-file-processor/v2/util"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// Mints a token scoped to the record, workflow and its files, the deadline starts when the token
+// is minted.
+func MintWorkflowToken(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	tokens *auth.WorkflowTokenSigner,
+	workflow *workflow_repository.ExistingWorfklowEntity,
+	deadline time.Duration,
+) (string, time.Time, error) {
+	fileEntities, err := file_repository.FindFileEntitiesForWorkflow(ctx, logger, tx, workflow.Id)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return tokens.Mint(auth.WorkflowGrant{
+		RecordId: workflow.RecordId,
+		Workflow: workflow.FullName,
+		Files: util.Map(fileEntities, func(file file_repository.ExistingCompchemFile) string {
+			return file.FileKey
+		}),
+		Operations: auth.WorkflowOperations,
+		Deadline:   deadline,
+	})
+}
+
+// Secret key the workflow is submitted with, nil when the kubernetes secret of the workflow holds
+// it already. Signed tokens are minted again right before the submission so the wait in the outbox
+// does not count against their lifetime, the hash of the workflow is replaced by the hash of the
+// new token. Random keys are taken from the outbox.
+func submissionSecretKey(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	tokens *auth.WorkflowTokenSigner,
+	submission *submission_repository.ExistingSubmissionEntity,
+	workflow submittedWorkflow,
+) (*string, error) {
+	if tokens == nil {
+		return submission.SecretKey, nil
+	}
+
+	name := workflow.Metadata.Name
+	entity, err := workflow_repository.FindWorkflowByFullName(ctx, logger, tx, name)
+	if err != nil {
+		return nil, err
+	}
+	if entity == nil {
+		return nil, fmt.Errorf("workflow %s of submission does not exist", name)
+	}
+
+	var deadline time.Duration
+	if workflow.Spec.ActiveDeadlineSeconds != nil {
+		deadline = time.Duration(*workflow.Spec.ActiveDeadlineSeconds) * time.Second
+	}
+	token, _, err := MintWorkflowToken(ctx, logger, tx, tokens, entity, deadline)
+	if err != nil {
+		return nil, fmt.Errorf("Error when minting workflow token: %v", err)
+	}
+
+	err = workflow_repository.UpdateSecretKeyHash(
+		ctx,
+		logger,
+		tx,
+		entity.Id,
+		util.HashSecretKey(token),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
@@ -0,0 +1,234 @@
+package watchworkflows_service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"fi.muni.cz/invenio-file-processor/v2/api/argodtos"
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/httpclient"
+	repository_common "fi.muni.cz/invenio-file-processor/v2/repository/common"
+	"fi.muni.cz/invenio-file-processor/v2/services/list_workflows"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+type WatcherOpts struct {
+	ReconnectDelay    time.Duration
+	MaxReconnectDelay time.Duration
+	PageSize          int
+}
+
+const (
+	DefaultReconnectDelay    = 5 * time.Second
+	DefaultMaxReconnectDelay = 5 * time.Minute
+	DefaultPageSize          = 100
+)
+
+const (
+	listFields  = "metadata.continue,items.metadata.name,items.metadata.uid,items.status.phase,items.status.message,items.status.startedAt,items.status.finishedAt,items.status.progress"
+	eventFields = "result.type,result.object.metadata.name,result.object.metadata.uid,result.object.status.phase,result.object.status.message,result.object.status.startedAt,result.object.status.finishedAt,result.object.status.progress"
+)
+
+func NewWatcherOpts(conf config.WatcherConfig) WatcherOpts {
+	opts := WatcherOpts{
+		ReconnectDelay:    DefaultReconnectDelay,
+		MaxReconnectDelay: DefaultMaxReconnectDelay,
+		PageSize:          DefaultPageSize,
+	}
+
+	if conf.ReconnectSeconds > 0 {
+		opts.ReconnectDelay = time.Duration(conf.ReconnectSeconds) * time.Second
+	}
+	if conf.PageSize > 0 {
+		opts.PageSize = conf.PageSize
+	}
+
+	return opts
+}
+
+type workflowList struct {
+	Items    []list_workflows.WorkflowWithStatus `json:"items"`
+	Metadata list_workflows.ListMetadata         `json:"metadata"`
+}
+
+type workflowEvent struct {
+	Result *struct {
+		Type   string                            `json:"type"`
+		Object list_workflows.WorkflowWithStatus `json:"object"`
+	} `json:"result"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Keeps stored workflow state in sync with argo until ctx is cancelled. Every (re)connect starts
+// with a full resync through the list endpoint so events missed while disconnected are not lost.
+// Only workflows labelled with the instance are watched.
+func RunWatcher(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	argoUrl string,
+	namespace string,
+	instance string,
+	opts WatcherOpts,
+) {
+	logger.Info("Starting workflow watcher", zap.String("namespace", namespace))
+
+	delay := opts.ReconnectDelay
+	for {
+		err := resync(ctx, logger, pool, argoUrl, namespace, instance, opts.PageSize)
+		if err == nil {
+			delay = opts.ReconnectDelay
+			err = watch(ctx, logger, pool, argoUrl, namespace, instance)
+		}
+
+		if ctx.Err() != nil {
+			logger.Info("Stopping workflow watcher")
+			return
+		}
+
+		// argo closes watch streams after a while, that is not worth a warning
+		if err == nil {
+			logger.Info("Workflow watch stream closed, reconnecting", zap.Duration("delay", delay))
+		} else {
+			logger.Warn(
+				"Workflow watcher disconnected, reconnecting",
+				zap.Duration("delay", delay),
+				zap.Error(err),
+			)
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Info("Stopping workflow watcher")
+			return
+		case <-time.After(delay):
+		}
+
+		delay = min(delay*2, opts.MaxReconnectDelay)
+	}
+}
+
+func resync(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	argoUrl string,
+	namespace string,
+	instance string,
+	pageSize int,
+) error {
+	continueToken := ""
+	for {
+		url := buildListUrl(argoUrl, namespace, instance, pageSize, continueToken)
+		workflows, err := httpclient.GetRequest[workflowList](ctx, logger, url, true)
+		if err != nil {
+			return fmt.Errorf("Error during resync of workflows: %w", err)
+		}
+
+		err = recordStates(ctx, logger, pool, workflows.Items)
+		if err != nil {
+			return err
+		}
+
+		if workflows.Metadata.Continue == "" {
+			return nil
+		}
+		continueToken = workflows.Metadata.Continue
+	}
+}
+
+func watch(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	argoUrl string,
+	namespace string,
+	instance string,
+) error {
+	url := buildEventsUrl(argoUrl, namespace, instance)
+	logger.Info("Watching argo workflow events", zap.String("url", url))
+
+	return httpclient.StreamRequest(ctx, logger, url, true, func(line []byte) error {
+		workflow, err := parseEvent(line)
+		if err != nil || workflow == nil {
+			return err
+		}
+
+		return recordStates(ctx, logger, pool, []list_workflows.WorkflowWithStatus{*workflow})
+	})
+}
+
+// Returns nil for events which do not carry a new state, deleted workflows keep their last
+// stored state.
+func parseEvent(line []byte) (*list_workflows.WorkflowWithStatus, error) {
+	var event workflowEvent
+	if err := json.Unmarshal(line, &event); err != nil {
+		return nil, fmt.Errorf("Error when parsing workflow event: %w", err)
+	}
+
+	if event.Error != nil {
+		return nil, fmt.Errorf("Workflow event stream failed: %s", event.Error.Message)
+	}
+	if event.Result == nil || event.Result.Type == "DELETED" {
+		return nil, nil
+	}
+
+	return &event.Result.Object, nil
+}
+
+func recordStates(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	workflows []list_workflows.WorkflowWithStatus,
+) error {
+	if len(workflows) == 0 {
+		return nil
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = list_workflows.RecordWorkflowStates(ctx, logger, tx, workflows)
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	return repository_common.CommitTx(ctx, tx, logger)
+}
+
+func buildListUrl(
+	argoUrl string,
+	namespace string,
+	instance string,
+	pageSize int,
+	continueToken string,
+) string {
+	params := url.Values{}
+	params.Set("listOptions.labelSelector", argodtos.BuildLabelSelector(instance))
+	params.Set("listOptions.limit", strconv.Itoa(pageSize))
+	params.Set("fields", listFields)
+	if continueToken != "" {
+		params.Set("listOptions.continue", continueToken)
+	}
+
+	return fmt.Sprintf("%s/api/v1/workflows/%s?%s", argoUrl, namespace, params.Encode())
+}
+
+func buildEventsUrl(argoUrl string, namespace string, instance string) string {
+	params := url.Values{}
+	params.Set("listOptions.labelSelector", argodtos.BuildLabelSelector(instance))
+	params.Set("fields", eventFields)
+
+	return fmt.Sprintf("%s/api/v1/workflow-events/%s?%s", argoUrl, namespace, params.Encode())
+}
@@ -0,0 +1,210 @@
+package watchworkflows_service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	repositorytest "fi.muni.cz/invenio-file-processor/v2/repository/test"
+	"fi.muni.cz/invenio-file-processor/v2/repository/workflow_repository"
+	service_test_resources "fi.muni.cz/invenio-file-processor/v2/services/test_resources"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNewWatcherOpts_EmptyConfig_DefaultsUsed(t *testing.T) {
+	opts := NewWatcherOpts(config.WatcherConfig{})
+
+	assert.Equal(t, DefaultReconnectDelay, opts.ReconnectDelay)
+	assert.Equal(t, DefaultMaxReconnectDelay, opts.MaxReconnectDelay)
+	assert.Equal(t, DefaultPageSize, opts.PageSize)
+}
+
+func TestBuildListUrl_ContinueSet_ContinuePassed(t *testing.T) {
+	result, err := url.Parse(buildListUrl("https://argo.local", "argo", "compchem-test", 50, "abc"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "/api/v1/workflows/argo", result.Path)
+	assert.Equal(t, "50", result.Query().Get("listOptions.limit"))
+	assert.Equal(t, "abc", result.Query().Get("listOptions.continue"))
+	assert.Equal(t, listFields, result.Query().Get("fields"))
+	assert.Equal(
+		t,
+		"compchem.cerit.io/instance=compchem-test",
+		result.Query().Get("listOptions.labelSelector"),
+	)
+}
+
+func TestBuildEventsUrl_ArgsProvided_UrlCorrectlyFormed(t *testing.T) {
+	result, err := url.Parse(buildEventsUrl("https://argo.local", "argo", "compchem-test"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "/api/v1/workflow-events/argo", result.Path)
+	assert.Equal(t, eventFields, result.Query().Get("fields"))
+	assert.Equal(
+		t,
+		"compchem.cerit.io/instance=compchem-test",
+		result.Query().Get("listOptions.labelSelector"),
+	)
+}
+
+func TestParseEvent(t *testing.T) {
+	modified, err := parseEvent([]byte(
+		`{"result":{"type":"MODIFIED","object":{"metadata":{"name":"count-words-ew6jd-p8175-9","uid":"50efc9c4"},"status":{"phase":"Running","progress":"1/3"}}}}`,
+	))
+	assert.NoError(t, err)
+	assert.Equal(t, "count-words-ew6jd-p8175-9", modified.Metadata.Name)
+	assert.Equal(t, "50efc9c4", modified.Metadata.Uid)
+	assert.Equal(t, "Running", modified.Status.Phase)
+
+	deleted, err := parseEvent([]byte(
+		`{"result":{"type":"DELETED","object":{"metadata":{"name":"count-words-ew6jd-p8175-9"}}}}`,
+	))
+	assert.NoError(t, err)
+	assert.Nil(t, deleted)
+
+	_, err = parseEvent([]byte(`{"error":{"message":"too old resource version"}}`))
+	assert.ErrorContains(t, err, "too old resource version")
+
+	_, err = parseEvent([]byte(`not json`))
+	assert.Error(t, err)
+}
+
+func TestRunWatcher_StreamCloses_WatcherResyncsAndReconnects(t *testing.T) {
+	var lists, watches atomic.Int32
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/workflows/argo":
+			lists.Add(1)
+			w.Write([]byte(service_test_resources.EMPTY_RESPONSE))
+		case "/api/v1/workflow-events/argo":
+			if watches.Add(1) == 3 {
+				cancel()
+			}
+			w.WriteHeader(http.StatusOK)
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+
+	RunWatcher(ctx, zap.New(core), nil, server.URL, "argo", "compchem-test", WatcherOpts{
+		ReconnectDelay:    time.Millisecond,
+		MaxReconnectDelay: 5 * time.Millisecond,
+		PageSize:          10,
+	})
+
+	assert.GreaterOrEqual(t, watches.Load(), int32(3))
+	assert.Equal(t, watches.Load(), lists.Load(), "every reconnect should start with a resync")
+	assert.NotZero(t, logs.FilterMessage("Workflow watch stream closed, reconnecting").Len())
+	assert.Zero(t, logs.FilterLevelExact(zapcore.WarnLevel).Len(), "closed streams are no failure")
+}
+
+type watcherTestSuite struct {
+	repositorytest.PostgresTestSuite
+}
+
+func (s *watcherTestSuite) SetupSuite() {
+	s.PostgresTestSuite.MigratonsPath = "file://../../migrations"
+	s.PostgresTestSuite.SetupSuite()
+}
+
+func (s *watcherTestSuite) TearDownSuite() {
+	s.PostgresTestSuite.TearDownSuite()
+}
+
+func (s *watcherTestSuite) TearDownTest() {
+	assert.NoError(s.T(), repositorytest.ClearTable(s.Ctx, s.Pool, "compchem_workflow"))
+}
+
+func (s *watcherTestSuite) insertWorkflow(seq uint64) {
+	_, err := s.Pool.Exec(s.Ctx, `
+		INSERT INTO compchem_workflow (record_id, workflow_name, workflow_record_seq_id, phase, submitted_at)
+		VALUES ('ew6jd-p8175', 'count-words', $1, 'Pending', now())
+	`, seq)
+	assert.NoError(s.T(), err)
+}
+
+func (s *watcherTestSuite) getWorkflow(fullName string) *workflow_repository.ExistingWorfklowEntity {
+	var workflow *workflow_repository.ExistingWorfklowEntity
+	s.RunInTestTransaction(func(tx pgx.Tx) {
+		var err error
+		workflow, err = workflow_repository.FindWorkflowByFullName(s.Ctx, s.Logger, tx, fullName)
+		assert.NoError(s.T(), err)
+	})
+
+	return workflow
+}
+
+func (s *watcherTestSuite) TestResync_TwoPages_StoredWorkflowsUpdated() {
+	t := s.T()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("listOptions.continue") == "5" {
+			w.Write([]byte(service_test_resources.SECOND_PAGE_RESPONSE))
+			return
+		}
+		w.Write([]byte(service_test_resources.FIRST_PAGE_RESPONSE))
+	}))
+	defer server.Close()
+
+	s.insertWorkflow(9)
+	s.insertWorkflow(7)
+
+	err := resync(s.Ctx, s.Logger, s.Pool, server.URL, "argo", "compchem-test", 5)
+	assert.NoError(t, err)
+
+	succeeded := s.getWorkflow("count-words-ew6jd-p8175-9")
+	assert.Equal(t, "Succeeded", succeeded.Phase)
+	assert.Equal(t, "50efc9c4-124e-41fa-80ea-61515b4bcc1b", *succeeded.ArgoUid)
+	assert.Equal(t, "3/3", *succeeded.Progress)
+	assert.NotNil(t, succeeded.FinishedAt)
+
+	failed := s.getWorkflow("count-words-ew6jd-p8175-7")
+	assert.Equal(t, "Failed", failed.Phase)
+
+	untracked := s.getWorkflow("count-words")
+	assert.Nil(t, untracked, "workflows not created by the service should not be stored")
+}
+
+func (s *watcherTestSuite) TestWatch_EventsStreamed_StoredWorkflowsUpdated() {
+	t := s.T()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/workflow-events/argo", r.URL.Path)
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"result":{"type":"MODIFIED","object":{"metadata":{"name":"count-words-ew6jd-p8175-3","uid":"a1b2"},"status":{"phase":"Running","startedAt":"2025-05-24T15:15:41Z","progress":"1/3"}}}}` + "\n"))
+		w.Write([]byte(`{"result":{"type":"MODIFIED","object":{"metadata":{"name":"other-workflow"},"status":{"phase":"Running"}}}}` + "\n"))
+		w.Write([]byte(`{"result":{"type":"DELETED","object":{"metadata":{"name":"count-words-ew6jd-p8175-3"},"status":{"phase":"Running"}}}}` + "\n"))
+	}))
+	defer server.Close()
+
+	s.insertWorkflow(3)
+
+	err := watch(s.Ctx, s.Logger, s.Pool, server.URL, "argo", "compchem-test")
+	assert.NoError(t, err)
+
+	workflow := s.getWorkflow("count-words-ew6jd-p8175-3")
+	assert.Equal(t, "Running", workflow.Phase)
+	assert.Equal(t, "a1b2", *workflow.ArgoUid)
+	assert.Equal(t, "1/3", *workflow.Progress)
+	assert.NotNil(t, workflow.StartedAt)
+	assert.Nil(t, workflow.FinishedAt)
+}
+
+func TestWatcherTestSuite(t *testing.T) {
+	suite.Run(t, new(watcherTestSuite))
+}
@@ -0,0 +1,116 @@
+package workflowdefinitions_service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/repository/definition_repository"
+	"gopkg.in/yaml.v3"
+)
+
+const templatesKey = "processing-templates"
+
+// Decodes a workflow written with the keys of the config file, json is valid yaml so both request
+// bodies and stored definitions are decoded with the yaml tags. Unknown keys are rejected.
+func DecodeWorkflow(document []byte) (config.WorkflowConfig, error) {
+	var workflow config.WorkflowConfig
+
+	decoder := yaml.NewDecoder(bytes.NewReader(document))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&workflow); err != nil {
+		return config.WorkflowConfig{}, fmt.Errorf("Error when decoding workflow definition: %v", err)
+	}
+
+	return workflow, nil
+}
+
+// Splits the workflow into its definition without processing templates and the templates, as
+// they are stored.
+func encodeWorkflow(
+	workflow config.WorkflowConfig,
+) ([]byte, []definition_repository.ProcessingTemplateEntity, error) {
+	document, err := config.ToDocument(workflow)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error when encoding workflow definition: %v", err)
+	}
+	delete(document.(map[string]any), templatesKey)
+
+	definition, err := json.Marshal(document)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error when encoding workflow definition: %v", err)
+	}
+
+	templates := make([]definition_repository.ProcessingTemplateEntity, 0, len(workflow.ProcessingTemplates))
+	for position, template := range workflow.ProcessingTemplates {
+		document, err := config.ToDocument(template)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error when encoding processing template: %v", err)
+		}
+		templateDefinition, err := json.Marshal(document)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error when encoding processing template: %v", err)
+		}
+
+		templates = append(templates, definition_repository.ProcessingTemplateEntity{
+			Position:   position,
+			Name:       template.Name,
+			Template:   template.Template,
+			Definition: templateDefinition,
+		})
+	}
+
+	return definition, templates, nil
+}
+
+// Joins a stored definition with its templates back into a single workflow document.
+func workflowDocument(definition []byte, templates []byte) (json.RawMessage, error) {
+	var document map[string]json.RawMessage
+	if err := json.Unmarshal(definition, &document); err != nil {
+		return nil, fmt.Errorf("Error when decoding stored workflow definition: %v", err)
+	}
+	document[templatesKey] = templates
+
+	return json.Marshal(document)
+}
+
+func decodeStoredWorkflow(definition []byte, templates []byte) (config.WorkflowConfig, error) {
+	document, err := workflowDocument(definition, templates)
+	if err != nil {
+		return config.WorkflowConfig{}, err
+	}
+
+	return DecodeWorkflow(document)
+}
+
+// Whether the stored version holds the workflow, json key order and formatting are ignored.
+func sameWorkflow(
+	stored *definition_repository.DefinitionVersionEntity,
+	workflow config.WorkflowConfig,
+) bool {
+	storedDocument, err := workflowDocument(stored.Definition, stored.Templates)
+	if err != nil {
+		return false
+	}
+	document, err := config.ToDocument(workflow)
+	if err != nil {
+		return false
+	}
+
+	var storedValue any
+	if err := json.Unmarshal(storedDocument, &storedValue); err != nil {
+		return false
+	}
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		return false
+	}
+	var value any
+	if err := json.Unmarshal(encoded, &value); err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(storedValue, value)
+}
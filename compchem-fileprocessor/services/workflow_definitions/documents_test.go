@@ -0,0 +1,86 @@
+package workflowdefinitions_service
+
+import (
+	"testing"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	"fi.muni.cz/invenio-file-processor/v2/repository/definition_repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func countWords() config.WorkflowConfig {
+	return config.WorkflowConfig{
+		Name:      "count-words",
+		Mimetype:  "text/plain",
+		Extension: "txt",
+		ProcessingTemplates: []config.ProcessingTemplate{
+			{Name: "count-words-template", Template: "count-words"},
+			{
+				Name:      "sum-words-template",
+				Template:  "sum-words",
+				DependsOn: []string{"count-words-template"},
+			},
+		},
+	}
+}
+
+func TestEncodeWorkflow_TemplatesStoredSeparately_DecodedBack(t *testing.T) {
+	// Arrange
+	workflow := countWords()
+
+	// Act
+	definition, templates, err := encodeWorkflow(workflow)
+	assert.NoError(t, err)
+	decoded, decodeErr := decodeStoredWorkflow(
+		definition,
+		[]byte(`[`+string(templates[0].Definition)+`,`+string(templates[1].Definition)+`]`),
+	)
+
+	// Assert
+	assert.NoError(t, decodeErr)
+	assert.NotContains(t, string(definition), "processing-templates")
+	assert.Len(t, templates, 2)
+	assert.Equal(t, 1, templates[1].Position)
+	assert.Equal(t, "sum-words", templates[1].Template)
+	assert.Equal(t, workflow.Name, decoded.Name)
+	assert.Equal(t, workflow.ProcessingTemplates[1].DependsOn, decoded.ProcessingTemplates[1].DependsOn)
+}
+
+func TestDecodeWorkflow_ConfigFileKeys_Decoded(t *testing.T) {
+	// Act
+	workflow, err := DecodeWorkflow([]byte(`{
+		"name": "count-words",
+		"fan-out": true,
+		"processing-templates": [{"name": "count-words-template", "template": "count-words"}]
+	}`))
+	_, unknownErr := DecodeWorkflow([]byte(`{"name": "count-words", "fanout": true}`))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, workflow.FanOut)
+	assert.Equal(t, "count-words", workflow.ProcessingTemplates[0].Template)
+	assert.Error(t, unknownErr)
+}
+
+func TestSameWorkflow_StoredVersion_ComparedByContent(t *testing.T) {
+	// Arrange
+	workflow := countWords()
+	definition, templates, err := encodeWorkflow(workflow)
+	assert.NoError(t, err)
+	stored := &definition_repository.DefinitionVersionEntity{
+		Definition: definition,
+		Templates: []byte(
+			`[` + string(templates[0].Definition) + `,` + string(templates[1].Definition) + `]`,
+		),
+	}
+	changed := countWords()
+	changed.Extension = "md"
+
+	// Act
+	same := sameWorkflow(stored, workflow)
+	different := sameWorkflow(stored, changed)
+
+	// Assert
+	assert.True(t, same)
+	assert.False(t, different)
+}
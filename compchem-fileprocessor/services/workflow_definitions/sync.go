@@ -0,0 +1,216 @@
+package workflowdefinitions_service
+
+import (
+	"context"
+	"time"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	repository_common "fi.muni.cz/invenio-file-processor/v2/repository/common"
+	"fi.muni.cz/invenio-file-processor/v2/repository/definition_repository"
+	"fi.muni.cz/invenio-file-processor/v2/util"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// Stores workflows of the config file as read-only definitions. A changed workflow gets a new
+// version, a definition created through the admin api is taken over by the config file of the same
+// name and definitions removed from the config file are disabled.
+func SeedConfigWorkflows(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	workflows []config.WorkflowConfig,
+) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		logger.Error("error when starting tx for seeding workflow definitions", zap.Error(err))
+		return err
+	}
+
+	for _, workflow := range workflows {
+		definition, templates, err := encodeWorkflow(workflow)
+		if err != nil {
+			tx.Rollback(ctx)
+			return err
+		}
+
+		existing, err := definition_repository.FindDefinitionByName(ctx, logger, tx, workflow.Name)
+		if err != nil {
+			tx.Rollback(ctx)
+			return err
+		}
+
+		var entity *definition_repository.ExistingDefinitionEntity
+		if existing == nil {
+			entity, err = definition_repository.CreateDefinition(
+				ctx,
+				logger,
+				tx,
+				workflow.Name,
+				definition_repository.SourceConfig,
+			)
+		} else {
+			current, findErr := definition_repository.FindDefinitionVersion(
+				ctx,
+				logger,
+				tx,
+				existing.Id,
+				existing.Version,
+			)
+			if findErr != nil {
+				tx.Rollback(ctx)
+				return findErr
+			}
+			if existing.Source == definition_repository.SourceConfig && existing.Enabled &&
+				sameWorkflow(current, workflow) {
+				continue
+			}
+
+			entity, err = definition_repository.BumpDefinitionVersion(
+				ctx,
+				logger,
+				tx,
+				existing.Id,
+				definition_repository.SourceConfig,
+			)
+		}
+		if err != nil {
+			tx.Rollback(ctx)
+			return err
+		}
+
+		err = definition_repository.CreateDefinitionVersion(
+			ctx,
+			logger,
+			tx,
+			entity.Id,
+			entity.Version,
+			definition,
+			templates,
+		)
+		if err != nil {
+			tx.Rollback(ctx)
+			return err
+		}
+		logger.Info(
+			"Seeded workflow definition from the config file",
+			zap.String("name", workflow.Name),
+			zap.Int("version", entity.Version),
+		)
+	}
+
+	names := util.Map(workflows, func(workflow config.WorkflowConfig) string { return workflow.Name })
+	disabled, err := definition_repository.DisableRemovedConfigDefinitions(ctx, logger, tx, names)
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+	if disabled > 0 {
+		logger.Info(
+			"Disabled workflow definitions removed from the config file",
+			zap.Int64("count", disabled),
+		)
+	}
+
+	return repository_common.CommitTx(ctx, tx, logger)
+}
+
+// Activates the enabled definitions created through the admin api. Definitions which do not pass
+// the validation anymore or run in a namespace without a watcher are skipped.
+func Refresh(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	argo config.ArgoApi,
+	workflows *config.Workflows,
+) error {
+	entities, err := definition_repository.FindCurrentDefinitions(ctx, logger, pool)
+	if err != nil {
+		return err
+	}
+
+	managed := []config.WorkflowConfig{}
+	for _, entity := range entities {
+		if !entity.Enabled || entity.Source != definition_repository.SourceApi {
+			continue
+		}
+
+		workflow, err := decodeStoredWorkflow(entity.Definition, entity.Templates)
+		if err != nil {
+			logger.Error(
+				"Skipping undecodable workflow definition",
+				zap.String("name", entity.Name),
+				zap.Error(err),
+			)
+			continue
+		}
+		resolved, errs := validateDefinition(argo, workflows, workflow)
+		if len(errs) > 0 {
+			logger.Error(
+				"Skipping invalid workflow definition",
+				zap.String("name", entity.Name),
+				zap.Any("errors", errs),
+			)
+			continue
+		}
+		managed = append(managed, resolved)
+	}
+
+	workflows.SetManaged(managed)
+
+	return nil
+}
+
+// Seeds the config file workflows when their revision differs from the seeded one and refreshes the
+// managed workflows, returns the revision seeded by now.
+func Sync(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	argo config.ArgoApi,
+	workflows *config.Workflows,
+	seededRevision string,
+) string {
+	if active := workflows.Active(); active.Revision != seededRevision {
+		if err := SeedConfigWorkflows(ctx, logger, pool, active.Workflows); err != nil {
+			logger.Error("Failed to seed workflow definitions", zap.Error(err))
+		} else {
+			seededRevision = active.Revision
+		}
+	}
+
+	if err := Refresh(ctx, logger, pool, argo, workflows); err != nil {
+		logger.Error("Failed to refresh managed workflows", zap.Error(err))
+	}
+
+	return seededRevision
+}
+
+// Keeps definitions in sync until ctx is done so that changes made through other replicas and
+// reloads of the config file are picked up. Without an interval only changes made through this
+// replica are picked up.
+func RunRefresher(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	argo config.ArgoApi,
+	workflows *config.Workflows,
+	interval time.Duration,
+	seededRevision string,
+) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			seededRevision = Sync(ctx, logger, pool, argo, workflows, seededRevision)
+		}
+	}
+}
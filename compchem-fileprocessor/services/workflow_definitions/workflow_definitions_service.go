@@ -0,0 +1,388 @@
+package workflowdefinitions_service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"fi.muni.cz/invenio-file-processor/v2/config"
+	repository_common "fi.muni.cz/invenio-file-processor/v2/repository/common"
+	"fi.muni.cz/invenio-file-processor/v2/repository/definition_repository"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrDefinitionNotFound = errors.New("workflow definition not found")
+	ErrDefinitionExists   = errors.New("workflow definition already exists")
+	ErrDefinitionReadOnly = errors.New("workflow definition comes from the config file and is read-only")
+)
+
+// Definition was rejected by the workflow validation, errors are keyed as in the config file.
+type InvalidDefinitionError struct {
+	Errors map[string]string
+}
+
+func (e *InvalidDefinitionError) Error() string {
+	fields := slices.Sorted(maps.Keys(e.Errors))
+	return fmt.Sprintf("Invalid workflow definition: %s", strings.Join(fields, ", "))
+}
+
+// Workflow is the current version written with the keys of the config file.
+type WorkflowDefinition struct {
+	Name      string                                 `json:"name"`
+	Source    definition_repository.DefinitionSource `json:"source"`
+	Enabled   bool                                   `json:"enabled"`
+	Version   int                                    `json:"version"`
+	CreatedAt time.Time                              `json:"createdAt"`
+	UpdatedAt time.Time                              `json:"updatedAt"`
+	Workflow  json.RawMessage                        `json:"workflow"`
+}
+
+type DefinitionVersion struct {
+	Version   int             `json:"version"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Workflow  json.RawMessage `json:"workflow"`
+}
+
+func ListDefinitions(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+) ([]WorkflowDefinition, error) {
+	entities, err := definition_repository.FindCurrentDefinitions(ctx, logger, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	definitions := make([]WorkflowDefinition, 0, len(entities))
+	for _, entity := range entities {
+		document, err := workflowDocument(entity.Definition, entity.Templates)
+		if err != nil {
+			return nil, err
+		}
+		definitions = append(definitions, toDefinition(entity.ExistingDefinitionEntity, document))
+	}
+
+	return definitions, nil
+}
+
+// Returns versions of the definition, newest first.
+func ListDefinitionVersions(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	name string,
+) ([]DefinitionVersion, error) {
+	entities, err := definition_repository.FindDefinitionVersions(ctx, logger, pool, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(entities) == 0 {
+		return nil, ErrDefinitionNotFound
+	}
+
+	versions := make([]DefinitionVersion, 0, len(entities))
+	for _, entity := range entities {
+		document, err := workflowDocument(entity.Definition, entity.Templates)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, DefinitionVersion{
+			Version:   entity.Version,
+			CreatedAt: entity.CreatedAt,
+			Workflow:  document,
+		})
+	}
+
+	return versions, nil
+}
+
+// Registers a new workflow, it is available to the handlers as soon as it is stored.
+func CreateDefinition(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	argo config.ArgoApi,
+	workflows *config.Workflows,
+	workflow config.WorkflowConfig,
+) (*WorkflowDefinition, error) {
+	if _, errs := validateDefinition(argo, workflows, workflow); len(errs) > 0 {
+		return nil, &InvalidDefinitionError{Errors: errs}
+	}
+	if slices.ContainsFunc(workflows.Active().Workflows, func(w config.WorkflowConfig) bool {
+		return w.Name == workflow.Name
+	}) {
+		return nil, ErrDefinitionExists
+	}
+
+	definition, templates, err := encodeWorkflow(workflow)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		logger.Error("error when starting tx for creating workflow definition", zap.Error(err))
+		return nil, err
+	}
+
+	existing, err := definition_repository.FindDefinitionByName(ctx, logger, tx, workflow.Name)
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+	if existing != nil {
+		tx.Rollback(ctx)
+		return nil, ErrDefinitionExists
+	}
+
+	entity, err := definition_repository.CreateDefinition(
+		ctx,
+		logger,
+		tx,
+		workflow.Name,
+		definition_repository.SourceApi,
+	)
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+
+	err = definition_repository.CreateDefinitionVersion(
+		ctx,
+		logger,
+		tx,
+		entity.Id,
+		entity.Version,
+		definition,
+		templates,
+	)
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+
+	if err := repository_common.CommitTx(ctx, tx, logger); err != nil {
+		return nil, err
+	}
+
+	return activated(ctx, logger, pool, argo, workflows, *entity, workflow)
+}
+
+// Stores the workflow as the next version of the definition and enables it, the name of the
+// workflow can not be changed.
+func UpdateDefinition(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	argo config.ArgoApi,
+	workflows *config.Workflows,
+	name string,
+	workflow config.WorkflowConfig,
+) (*WorkflowDefinition, error) {
+	if workflow.Name == "" {
+		workflow.Name = name
+	}
+	if workflow.Name != name {
+		return nil, &InvalidDefinitionError{Errors: map[string]string{
+			"name-0": "name of a workflow definition can not be changed",
+		}}
+	}
+	if _, errs := validateDefinition(argo, workflows, workflow); len(errs) > 0 {
+		return nil, &InvalidDefinitionError{Errors: errs}
+	}
+
+	definition, templates, err := encodeWorkflow(workflow)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		logger.Error("error when starting tx for updating workflow definition", zap.Error(err))
+		return nil, err
+	}
+
+	existing, err := findEditableDefinition(ctx, logger, tx, name)
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+
+	entity, err := definition_repository.BumpDefinitionVersion(
+		ctx,
+		logger,
+		tx,
+		existing.Id,
+		definition_repository.SourceApi,
+	)
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+
+	err = definition_repository.CreateDefinitionVersion(
+		ctx,
+		logger,
+		tx,
+		entity.Id,
+		entity.Version,
+		definition,
+		templates,
+	)
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+
+	if err := repository_common.CommitTx(ctx, tx, logger); err != nil {
+		return nil, err
+	}
+
+	return activated(ctx, logger, pool, argo, workflows, *entity, workflow)
+}
+
+// Disabled definitions are kept with their history but can not be started.
+func SetDefinitionEnabled(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	argo config.ArgoApi,
+	workflows *config.Workflows,
+	name string,
+	enabled bool,
+) (*WorkflowDefinition, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		logger.Error("error when starting tx for enabling workflow definition", zap.Error(err))
+		return nil, err
+	}
+
+	existing, err := findEditableDefinition(ctx, logger, tx, name)
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+
+	entity, err := definition_repository.SetDefinitionEnabled(ctx, logger, tx, existing.Id, enabled)
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+
+	version, err := definition_repository.FindDefinitionVersion(
+		ctx,
+		logger,
+		tx,
+		entity.Id,
+		entity.Version,
+	)
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+
+	if err := repository_common.CommitTx(ctx, tx, logger); err != nil {
+		return nil, err
+	}
+
+	if err := Refresh(ctx, logger, pool, argo, workflows); err != nil {
+		logger.Error("Failed to refresh managed workflows", zap.Error(err))
+	}
+
+	document, err := workflowDocument(version.Definition, version.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	response := toDefinition(*entity, document)
+	return &response, nil
+}
+
+func findEditableDefinition(
+	ctx context.Context,
+	logger *zap.Logger,
+	tx pgx.Tx,
+	name string,
+) (*definition_repository.ExistingDefinitionEntity, error) {
+	existing, err := definition_repository.FindDefinitionByName(ctx, logger, tx, name)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, ErrDefinitionNotFound
+	}
+	if existing.Source == definition_repository.SourceConfig {
+		return nil, ErrDefinitionReadOnly
+	}
+
+	return existing, nil
+}
+
+// Validated like workflows of the config file, returns the workflow with its spec resolved. The
+// namespace of the workflow has to be watched already.
+func validateDefinition(
+	argo config.ArgoApi,
+	workflows *config.Workflows,
+	workflow config.WorkflowConfig,
+) (config.WorkflowConfig, map[string]string) {
+	resolved, errs := config.ValidateWorkflow(workflow, argo)
+	if !workflows.Watches(resolved.Spec.Namespace) {
+		errs["spec-0"] = fmt.Sprintf(
+			"namespace %s is not watched, restart is required",
+			resolved.Spec.Namespace,
+		)
+	}
+
+	return resolved, errs
+}
+
+// Refreshes the managed workflows after a stored change, a failed refresh is only logged since the
+// change is picked up by the next periodic refresh.
+func activated(
+	ctx context.Context,
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	argo config.ArgoApi,
+	workflows *config.Workflows,
+	entity definition_repository.ExistingDefinitionEntity,
+	workflow config.WorkflowConfig,
+) (*WorkflowDefinition, error) {
+	if err := Refresh(ctx, logger, pool, argo, workflows); err != nil {
+		logger.Error("Failed to refresh managed workflows", zap.Error(err))
+	}
+
+	document, err := config.ToDocument(workflow)
+	if err != nil {
+		return nil, fmt.Errorf("Error when encoding workflow definition: %v", err)
+	}
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("Error when encoding workflow definition: %v", err)
+	}
+
+	response := toDefinition(entity, encoded)
+	return &response, nil
+}
+
+func toDefinition(
+	entity definition_repository.ExistingDefinitionEntity,
+	document json.RawMessage,
+) WorkflowDefinition {
+	return WorkflowDefinition{
+		Name:      entity.Name,
+		Source:    entity.Source,
+		Enabled:   entity.Enabled,
+		Version:   entity.Version,
+		CreatedAt: entity.CreatedAt,
+		UpdatedAt: entity.UpdatedAt,
+		Workflow:  document,
+	}
+}
@@ -0,0 +1,17 @@
+package util
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+)
+
+// Only hashes of workflow secret keys are stored, the key itself is handed to compchem and argo.
+func HashSecretKey(secretKey string) string {
+	sum := sha256.Sum256([]byte(secretKey))
+	return hex.EncodeToString(sum[:])
+}
+
+func SecretKeyMatches(secretKey string, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashSecretKey(secretKey)), []byte(hash)) == 1
+}